@@ -0,0 +1,55 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"hash"
+	"sync"
+)
+
+// HashAdapter returns a double hash built from a 64-bit hash such as hash/fnv's New64a.
+// The first hash is the hash of an entry; the second is the hash of the entry with a prefix
+// byte, so the two differ as if the hash were seeded differently. It is safe for concurrent use.
+func HashAdapter(h func() hash.Hash64) func([]byte) (uint64, uint64) {
+	pool := sync.Pool{New: func() any { return h() }}
+	return func(b []byte) (uint64, uint64) {
+		hx := pool.Get().(hash.Hash64)
+		defer pool.Put(hx)
+		hx.Reset()
+		hx.Write(b)
+		x := hx.Sum64()
+		hx.Reset()
+		hx.Write([]byte{1})
+		hx.Write(b)
+		return x, hx.Sum64()
+	}
+}
+
+// DigestAdapter returns a double hash built from any hash such as crypto/sha256's New.
+// Digests of at least 16 bytes are split into two words in one pass; shorter ones are
+// computed twice like in HashAdapter. It is safe for concurrent use.
+func DigestAdapter(h func() hash.Hash) func([]byte) (uint64, uint64) {
+	pool := sync.Pool{New: func() any { return h() }}
+	return func(b []byte) (uint64, uint64) {
+		hx := pool.Get().(hash.Hash)
+		defer pool.Put(hx)
+		var buf [64]byte
+		hx.Reset()
+		hx.Write(b)
+		sum := hx.Sum(buf[:0])
+		if len(sum) >= 16 {
+			return binary.LittleEndian.Uint64(sum), binary.LittleEndian.Uint64(sum[8:])
+		}
+		x := digestWord(sum)
+		hx.Reset()
+		hx.Write([]byte{1})
+		hx.Write(b)
+		return x, digestWord(hx.Sum(buf[:0]))
+	}
+}
+
+// digestWord returns the first 8 bytes of a digest as a little-endian word, zero padded.
+func digestWord(sum []byte) uint64 {
+	var w [8]byte
+	copy(w[:], sum)
+	return binary.LittleEndian.Uint64(w[:])
+}
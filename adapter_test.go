@@ -0,0 +1,44 @@
+package bloom
+
+import (
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"strconv"
+	"testing"
+)
+
+func TestHashAdapter(t *testing.T) {
+	crc := func() hash.Hash { return crc32.NewIEEE() }
+	for name, h := range map[string]func([]byte) (uint64, uint64){
+		"fnv":    HashAdapter(fnv.New64a),
+		"sha256": DigestAdapter(sha256.New),
+		"crc32":  DigestAdapter(crc),
+	} {
+		x, y := h([]byte("hello"))
+		if x == y {
+			t.Fatalf("%s: both hashes are %#x", name, x)
+		}
+		if x2, y2 := h([]byte("hello")); x2 != x || y2 != y {
+			t.Fatalf("%s: hash is not deterministic", name)
+		}
+		bf := New(1e4, 1e-2, h)
+		for i := 0; i < 1e4; i++ {
+			bf.Add([]byte(strconv.Itoa(i)))
+		}
+		fp := 0
+		for i := 0; i < 1e4; i++ {
+			if bf.Test([]byte("absent" + strconv.Itoa(i))) {
+				fp++
+			}
+		}
+		if fp > 200 {
+			t.Fatalf("%s: false positive rate too high: %d/10000", name, fp)
+		}
+	}
+	sum := sha256.Sum256([]byte("hello"))
+	if x, _ := DigestAdapter(sha256.New)([]byte("hello")); x != digestWord(sum[:]) {
+		t.Fatal("Long digests should be split")
+	}
+}
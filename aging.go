@@ -0,0 +1,78 @@
+package bloom
+
+import "time"
+
+// agingTicks is the number of clock ticks in the lifetime of an entry.
+const agingTicks = 64
+
+// Aging Bloom Filter
+//
+// An aging filter stores a coarse timestamp in each cell instead of a bit.
+// Add stamps the K cells of an entry with the current time and Test only
+// counts cells stamped within the lifetime D, so entries expire on their own
+// without a Reset. Time is measured in ticks of D/64, so an entry expires
+// between D and D+D/64 after it was last added. Cells are 32 bits wide,
+// making the filter 32 times larger than a classic filter of the same
+// geometry.
+type AgingFilter struct {
+	C   []uint32 // tick each cell was last stamped at, 0 if never
+	D   time.Duration
+	K   int
+	H   func([]byte) (uint64, uint64)
+	Now func() time.Time // clock, time.Now by default
+
+	epoch time.Time
+}
+
+// NewAging creates an aging Bloom Filter that is optimal for n live entries and false positive
+// rate of p, where entries expire d after they were added.
+func NewAging(n int, p float64, d time.Duration, h func([]byte) (uint64, uint64)) *AgingFilter {
+	m, k := optimal(n, p)
+	f := &AgingFilter{C: make([]uint32, elements(m, 4)), D: d, K: int(k), H: h, Now: time.Now}
+	f.epoch = f.Now()
+	return f
+}
+
+// tick returns the current tick, starting at 1.
+func (f *AgingFilter) tick() uint32 {
+	res := f.D / agingTicks
+	if res <= 0 {
+		res = 1
+	}
+	return uint32(f.Now().Sub(f.epoch)/res) + 1
+}
+
+func (f *AgingFilter) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return (x + uint64(i)*y) % uint64(len(f.C))
+}
+
+func (f *AgingFilter) Add(b []byte) {
+	now := f.tick()
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		f.C[f.getOffset(x, y, i)] = now
+	}
+}
+
+// Test tests if an entry has been added within the lifetime of the filter.
+func (f *AgingFilter) Test(b []byte) bool {
+	now := f.tick()
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		c := f.C[f.getOffset(x, y, i)]
+		if c == 0 || now-c > agingTicks {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *AgingFilter) Size() int { return 4 * len(f.C) }
+
+func (f *AgingFilter) Reset() {
+	for i := range f.C {
+		f.C[i] = 0
+	}
+	f.epoch = f.Now()
+}
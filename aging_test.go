@@ -0,0 +1,42 @@
+package bloom
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for time dependent filters.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestAgingFilter_Expire(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	af := NewAging(1e4, 1e-4, time.Minute, doubleFNV)
+	af.Now = clock.Now
+	af.Reset()
+
+	old, fresh := []byte("old"), []byte("fresh")
+	af.Add(old)
+	clock.Advance(30 * time.Second)
+	af.Add(fresh)
+	if !af.Test(old) || !af.Test(fresh) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	clock.Advance(31 * time.Second)
+	if af.Test(old) {
+		t.Fatal("Entry older than the lifetime should have expired")
+	}
+	if !af.Test(fresh) {
+		t.Fatal("Entry within the lifetime should exist in filter but got false")
+	}
+	af.Add(old)
+	if !af.Test(old) {
+		t.Fatal("Adding again should renew the entry")
+	}
+	clock.Advance(time.Minute + time.Second)
+	if af.Test(old) || af.Test(fresh) {
+		t.Fatal("All entries should have expired")
+	}
+}
@@ -0,0 +1,20 @@
+package bloom
+
+import "unsafe"
+
+// hugePageSize is the size of the transparent huge pages WithHugePages aligns bit arrays to.
+const hugePageSize = 2 << 20
+
+// alignedBytes returns a zeroed slice of size bytes whose first byte is aligned to align,
+// a power of two, cut from a larger allocation so it stays managed by the garbage collector.
+func alignedBytes(size, align int) []byte {
+	b := make([]byte, size+align-1)
+	off := -int(uintptr(unsafe.Pointer(unsafe.SliceData(b)))) & (align - 1)
+	return b[off : off+size : off+size]
+}
+
+// alignedWords returns a zeroed slice of n words aligned like alignedBytes.
+func alignedWords(n, align int) []uint64 {
+	b := alignedBytes(8*n, max(align, 8))
+	return unsafe.Slice((*uint64)(unsafe.Pointer(unsafe.SliceData(b))), n)
+}
@@ -0,0 +1,9 @@
+//go:build !tinygo
+
+package bloom
+
+import "syscall"
+
+// adviseHugePages asks the kernel to back b with transparent huge pages. The advice is
+// best effort: kernels without them reject it, and b is then backed by normal pages.
+func adviseHugePages(b []byte) { _ = syscall.Madvise(b, syscall.MADV_HUGEPAGE) }
@@ -0,0 +1,6 @@
+//go:build !linux || tinygo
+
+package bloom
+
+// adviseHugePages does nothing on platforms without transparent huge pages.
+func adviseHugePages(b []byte) {}
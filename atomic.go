@@ -0,0 +1,133 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Lock-free Bloom Filter
+//
+// An atomic filter is a classic filter whose bits are set and tested with
+// atomic operations on 64-bit words, so it is safe for concurrent Add and
+// Test without locks. Bit i of the filter is bit i%64 of word i/64, the same
+// position as in a ClassicFilter of the same size, so it can be snapshotted
+// into one for encoding. Large filters can be built on all cores with
+// BulkAdd and then snapshotted.
+type AtomicFilter struct {
+	W []uint64 // accessed atomically
+	K int
+	H func([]byte) (uint64, uint64)
+}
+
+// NewAtomic creates a lock-free classic Bloom Filter that is optimal for n entries and false positive rate of p.
+func NewAtomic(n int, p float64, h func([]byte) (uint64, uint64)) *AtomicFilter {
+	m, k := optimal(n, p)
+	return &AtomicFilter{W: make([]uint64, elements(m/64, 8)), K: int(k), H: h}
+}
+
+// offsets returns the bit count of the filter and the double hashing step of an entry
+// with double hash x, y, so its offsets are x, x+y, x+2y, ... modulo the bit count.
+func (f *AtomicFilter) offsets(x, y uint64) (m, step uint64) {
+	return 64 * uint64(len(f.W)), nonzero(x, y)
+}
+
+func (f *AtomicFilter) Add(b []byte) { f.addHashes(f.H(b)) }
+
+func (f *AtomicFilter) Test(b []byte) bool { return f.testHashes(f.H(b)) }
+
+// TestAndAdd adds an entry and reports whether it was already in the filter. Of concurrent
+// callers adding the same entry, at least one sees it as new, but more than one may.
+func (f *AtomicFilter) TestAndAdd(b []byte) bool {
+	x, y := f.H(b)
+	present := true
+	m, y := f.offsets(x, y)
+	for i := 0; i < f.K; i, x = i+1, x+y {
+		offset := x % m
+		mask := uint64(1) << (offset % 64)
+		present = atomic.OrUint64(&f.W[offset/64], mask)&mask != 0 && present
+	}
+	return present
+}
+
+// addHashes adds an entry by its double hash.
+func (f *AtomicFilter) addHashes(x, y uint64) {
+	m, y := f.offsets(x, y)
+	for i := 0; i < f.K; i, x = i+1, x+y {
+		offset := x % m
+		atomic.OrUint64(&f.W[offset/64], 1<<(offset%64))
+	}
+}
+
+// testHashes tests an entry by its double hash.
+func (f *AtomicFilter) testHashes(x, y uint64) bool {
+	m, y := f.offsets(x, y)
+	for i := 0; i < f.K; i, x = i+1, x+y {
+		offset := x % m
+		if atomic.LoadUint64(&f.W[offset/64])&(1<<(offset%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// BulkAdd adds keys with workers goroutines, or one per CPU if workers is not positive,
+// and returns when all of them are added.
+func (f *AtomicFilter) BulkAdd(keys [][]byte, workers int) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = max(min(workers, len(keys)), 1)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		part := keys[w*len(keys)/workers : (w+1)*len(keys)/workers]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, k := range part {
+				f.Add(k)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BulkAddFrom adds keys received from a channel with workers goroutines, or one per CPU if
+// workers is not positive, and returns when the channel is closed and all of them are added.
+// Keys must not be modified after they are sent.
+func (f *AtomicFilter) BulkAddFrom(keys <-chan []byte, workers int) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range keys {
+				f.Add(k)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (f *AtomicFilter) Size() int { return 8 * len(f.W) }
+
+// Reset clears the filter word by word. Entries added concurrently may survive it.
+func (f *AtomicFilter) Reset() {
+	for i := range f.W {
+		atomic.StoreUint64(&f.W[i], 0)
+	}
+}
+
+// Snapshot copies the filter into a ClassicFilter with the same bits.
+// Entries added concurrently may or may not be included.
+func (f *AtomicFilter) Snapshot() *ClassicFilter {
+	b := make([]byte, 0, 8*len(f.W))
+	for i := range f.W {
+		b = binary.LittleEndian.AppendUint64(b, atomic.LoadUint64(&f.W[i]))
+	}
+	return &ClassicFilter{B: b, K: f.K, H: f.H}
+}
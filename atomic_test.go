@@ -0,0 +1,76 @@
+package bloom
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAtomicFilter(t *testing.T) {
+	f := NewAtomic(1e5, 1e-3, doubleSHA)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5000; i++ {
+				key := []byte(strconv.Itoa(g*10000 + i))
+				f.Add(key)
+				if !f.Test(key) {
+					t.Error("Should exist in filter but got false")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s := f.Snapshot()
+	for g := 0; g < 8; g++ {
+		if !s.Test([]byte(strconv.Itoa(g * 10000))) {
+			t.Fatal("Should exist in snapshot but got false")
+		}
+	}
+	fp := 0
+	for i := 0; i < 10000; i++ {
+		if s.Test([]byte("absent" + strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if fp > 30 {
+		t.Fatalf("False positive rate of snapshot too high: %d/10000", fp)
+	}
+	f.Reset()
+	if f.Test([]byte("0")) {
+		t.Fatal("Should not exist after reset but got true")
+	}
+}
+
+func TestAtomicFilter_BulkAdd(t *testing.T) {
+	keys := make([][]byte, 10000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	serial := NewAtomic(1e4, 1e-3, doubleSHA)
+	for _, k := range keys {
+		serial.Add(k)
+	}
+
+	bulk := NewAtomic(1e4, 1e-3, doubleSHA)
+	bulk.BulkAdd(keys, 0)
+	fromChan := NewAtomic(1e4, 1e-3, doubleSHA)
+	ch := make(chan []byte)
+	go func() {
+		for _, k := range keys {
+			ch <- k
+		}
+		close(ch)
+	}()
+	fromChan.BulkAddFrom(ch, 3)
+	for i := range serial.W {
+		if bulk.W[i] != serial.W[i] || fromChan.W[i] != serial.W[i] {
+			t.Fatal("Bulk loaded filter differs from serially loaded filter")
+		}
+	}
+	NewAtomic(10, 0.1, doubleSHA).BulkAdd(nil, 4) // no keys is fine
+}
@@ -0,0 +1,77 @@
+package bloom
+
+// Attenuated Bloom Filter
+//
+// An attenuated filter is a stack of D classic filters of the same
+// geometry, where level i holds the entries reachable in i hops, e.g. the
+// resources a peer can route to. A peer advertises its filter to its
+// neighbors, who Aggregate it one level down into their own.
+// See Rhea and Kubiatowicz, "Probabilistic Location and Routing" (2002).
+type AttenuatedFilter struct {
+	L []*ClassicFilter // levels
+}
+
+// NewAttenuated creates an attenuated Bloom Filter of d levels, each optimal for n entries and
+// false positive rate of p.
+func NewAttenuated(d, n int, p float64, h func([]byte) (uint64, uint64)) *AttenuatedFilter {
+	f := &AttenuatedFilter{L: make([]*ClassicFilter, d)}
+	for i := range f.L {
+		f.L[i] = newClassic(n, p, h)
+	}
+	return f
+}
+
+// AddAtLevel adds an entry to the given level.
+func (f *AttenuatedFilter) AddAtLevel(b []byte, level int) { f.L[level].Add(b) }
+
+// TestLevel returns the lowest level an entry is in, or -1 if it is in none.
+func (f *AttenuatedFilter) TestLevel(b []byte) int {
+	for i, l := range f.L {
+		if l.Test(b) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Aggregate merges the filter advertised by a neighbor into f, shifting it one
+// level down: entries at level i of other are reachable in i+1 hops from f.
+// The last level of other is dropped. Both filters must have the same depth, or else
+// ErrIncompatibleSize is returned, and levels compatible as for Union.
+func (f *AttenuatedFilter) Aggregate(other *AttenuatedFilter) error {
+	if len(f.L) != len(other.L) {
+		return ErrIncompatibleSize
+	}
+	for i := range f.L {
+		if err := compatible(f.L[i], other.L[i]); err != nil {
+			return err
+		}
+	}
+	for i := len(f.L) - 1; i > 0; i-- {
+		dst, src := f.L[i].B, other.L[i-1].B
+		for j := range dst {
+			dst[j] |= src[j]
+		}
+	}
+	return nil
+}
+
+// Add adds an entry to level 0.
+func (f *AttenuatedFilter) Add(b []byte) { f.AddAtLevel(b, 0) }
+
+// Test tests if an entry is in any level.
+func (f *AttenuatedFilter) Test(b []byte) bool { return f.TestLevel(b) >= 0 }
+
+func (f *AttenuatedFilter) Size() int {
+	size := 0
+	for _, l := range f.L {
+		size += l.Size()
+	}
+	return size
+}
+
+func (f *AttenuatedFilter) Reset() {
+	for _, l := range f.L {
+		l.Reset()
+	}
+}
@@ -0,0 +1,31 @@
+package bloom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAttenuatedFilter_Aggregate(t *testing.T) {
+	a := NewAttenuated(3, 1e3, 1e-4, doubleFNV)
+	b := NewAttenuated(3, 1e3, 1e-4, doubleFNV)
+	local, near, far := []byte("local"), []byte("near"), []byte("far")
+	a.Add(local)
+	b.AddAtLevel(near, 0)
+	b.AddAtLevel(far, 2)
+
+	if err := a.Aggregate(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.TestLevel(local); got != 0 {
+		t.Fatalf("local should be at level 0 but got %d", got)
+	}
+	if got := a.TestLevel(near); got != 1 {
+		t.Fatalf("near should be at level 1 but got %d", got)
+	}
+	if got := a.TestLevel(far); got != -1 {
+		t.Fatalf("far should be out of range but got %d", got)
+	}
+	if err := a.Aggregate(NewAttenuated(2, 1e3, 1e-4, doubleFNV)); !errors.Is(err, ErrIncompatibleSize) {
+		t.Fatalf("Aggregate of a different depth should fail but got %v", err)
+	}
+}
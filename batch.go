@@ -0,0 +1,181 @@
+package bloom
+
+import (
+	"runtime"
+	"sync"
+)
+
+// KeyHash is the double hash of a key, so a key can be hashed once and added to or tested
+// against several filters built with the same hash function, such as the windows of a
+// rotating filter or the filters of consecutive epochs.
+type KeyHash struct {
+	X, Y uint64
+}
+
+// HashKeys returns the double hashes of keys under h.
+func HashKeys(h func([]byte) (uint64, uint64), keys [][]byte) []KeyHash {
+	hs := make([]KeyHash, len(keys))
+	for i, key := range keys {
+		hs[i].X, hs[i].Y = h(key)
+	}
+	return hs
+}
+
+// HashFields returns the double hash under h of a key made of several fields, such as the
+// addresses and nonce of a connection, without concatenating them. Each field is hashed
+// on its own and the hashes are chained in order, starting from the number of fields, so
+// keys of different fields hash differently even if their concatenations are equal. The
+// hash of a key of one field is not that of the field as a plain key.
+func HashFields(h func([]byte) (uint64, uint64), fields ...[]byte) KeyHash {
+	k := KeyHash{X: uint64(len(fields)), Y: ^uint64(len(fields))}
+	for _, field := range fields {
+		x, y := h(field)
+		k.X, k.Y = splitmix64(k.X^x), splitmix64(k.Y^y)
+	}
+	return k
+}
+
+// HashKeysParallel returns the double hashes of keys under h like HashKeys, hashing them
+// with workers goroutines, or one per CPU if workers is not positive, which pays off for
+// large batches of long keys or slow hash functions.
+func HashKeysParallel(h func([]byte) (uint64, uint64), keys [][]byte, workers int) []KeyHash {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = max(min(workers, len(keys)), 1)
+	hs := make([]KeyHash, len(keys))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := w*len(keys)/workers, (w+1)*len(keys)/workers
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				hs[i].X, hs[i].Y = h(keys[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return hs
+}
+
+// HashFilter is a filter that can add and test keys by their KeyHash.
+// The hashes must come from the hash function of the filter.
+type HashFilter interface {
+	AddHash(KeyHash)
+	TestHash(KeyHash) bool
+}
+
+// AddHashes adds the keys with hashes hs to each of filters.
+func AddHashes(hs []KeyHash, filters ...HashFilter) {
+	for _, f := range filters {
+		for _, h := range hs {
+			f.AddHash(h)
+		}
+	}
+}
+
+// AddHash adds a key by its hash, mixing in the seed of the filter.
+// It panics if the filter is read-only.
+func (f *ClassicFilter) AddHash(h KeyHash) {
+	if f.readOnly {
+		panic(ErrReadOnly)
+	}
+	f.addHashes(f.mix(h.X, h.Y))
+}
+
+// TestHash tests a key by its hash, mixing in the seed of the filter.
+func (f *ClassicFilter) TestHash(h KeyHash) bool {
+	return f.testHashes(f.mix(h.X, h.Y))
+}
+
+// AddMany adds keys to the filter, checking the filter and computing its size once for
+// all of them. It panics if the filter is read-only.
+func (f *ClassicFilter) AddMany(keys [][]byte) {
+	if f.readOnly {
+		panic(ErrReadOnly)
+	}
+	m := f.BitCount()
+	for _, b := range keys {
+		x, y := f.hash(b)
+		probes := f.probes(x, y)
+		for i := 0; i < f.K; i++ {
+			offset := reduceOffset(f.Probe.finish(x, probes.next()), m)
+			if strict && !f.checkOffset(offset, m) {
+				continue
+			}
+			f.B[offset/8] |= 1 << (offset % 8)
+		}
+	}
+}
+
+// TestMany tests keys against the filter like AddMany, and returns whether each is in it.
+func (f *ClassicFilter) TestMany(keys [][]byte) []bool {
+	m := f.BitCount()
+	found := make([]bool, len(keys))
+	if f.constant {
+		for j, b := range keys {
+			found[j] = f.testAllHashes(f.hash(b))
+		}
+		return found
+	}
+	for j, b := range keys {
+		x, y := f.hash(b)
+		probes := f.probes(x, y)
+		found[j] = true
+		for i := 0; i < f.K; i++ {
+			offset := reduceOffset(f.Probe.finish(x, probes.next()), m)
+			if strict && !f.checkOffset(offset, m) {
+				continue
+			}
+			if f.B[offset/8]&(1<<(offset%8)) == 0 {
+				found[j] = false
+				break
+			}
+		}
+	}
+	return found
+}
+
+// AddFields adds a key made of fields, hashed with HashFields. It panics if the filter is read-only.
+func (f *ClassicFilter) AddFields(fields ...[]byte) { f.AddHash(HashFields(f.H, fields...)) }
+
+// TestFields tests a key made of fields, hashed with HashFields.
+func (f *ClassicFilter) TestFields(fields ...[]byte) bool {
+	return f.TestHash(HashFields(f.H, fields...))
+}
+
+func (f *AtomicFilter) AddHash(h KeyHash) { f.addHashes(h.X, h.Y) }
+
+func (f *AtomicFilter) TestHash(h KeyHash) bool { return f.testHashes(h.X, h.Y) }
+
+func (f *ShardedFilter) AddHash(h KeyHash) {
+	s := f.shard(h.X)
+	s.Lock()
+	s.f.addHashes(h.X, h.Y)
+	s.Unlock()
+}
+
+func (f *ShardedFilter) TestHash(h KeyHash) bool {
+	s := f.shard(h.X)
+	s.RLock()
+	defer s.RUnlock()
+	return s.f.testHashes(h.X, h.Y)
+}
+
+func (f *RotatingFilter) AddHash(h KeyHash) {
+	f.mu.Lock()
+	at := f.tick()
+	f.Active.AddHash(h)
+	f.mu.Unlock()
+	f.notify(at)
+}
+
+func (f *RotatingFilter) TestHash(h KeyHash) bool {
+	f.mu.Lock()
+	at := f.tick()
+	ok := f.Active.TestHash(h) || f.Previous.TestHash(h)
+	f.mu.Unlock()
+	f.notify(at)
+	return ok
+}
@@ -0,0 +1,219 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHashKeys(t *testing.T) {
+	keys := make([][]byte, 100)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	hs := HashKeys(doubleFNV, keys)
+
+	classic := newClassic(1e3, 1e-3, doubleFNV)
+	seeded := NewSeeded(1e3, 1e-3, 7, doubleFNV)
+	delta := NewDelta(1e3, 1e-3, doubleFNV)
+	filters := []HashFilter{
+		classic,
+		seeded,
+		delta,
+		NewAtomic(1e3, 1e-3, doubleFNV),
+		NewSharded(1e3, 1e-3, 4, doubleFNV),
+		NewRotating(1e3, 1e-3, time.Hour, doubleFNV),
+	}
+	AddHashes(hs, filters...)
+	for i, f := range filters {
+		for j, key := range keys {
+			if !f.(Filter).Test(key) || !f.TestHash(hs[j]) {
+				t.Fatalf("Filter %d should contain key %d added by its hash", i, j)
+			}
+		}
+	}
+
+	// adding by hash sets the same bits as adding the keys
+	for _, want := range []*ClassicFilter{newClassic(1e3, 1e-3, doubleFNV), NewSeeded(1e3, 1e-3, 7, doubleFNV)} {
+		for _, key := range keys {
+			want.Add(key)
+		}
+		got := classic
+		if want.Seed != 0 {
+			got = seeded
+		}
+		if !bytes.Equal(got.B, want.B) {
+			t.Fatal("Adding by hash should set the same bits as Add")
+		}
+	}
+	if delta.Pending() == 0 {
+		t.Fatal("Delta filter should track bits added by hash")
+	}
+}
+
+func TestClassicFilter_AddMany(t *testing.T) {
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	many := NewSeeded(1e3, 1e-3, 9, doubleFNV)
+	many.Probe = EnhancedDoubleHashing
+	one := many.Clone()
+	many.AddMany(keys[:500])
+	for _, key := range keys[:500] {
+		one.Add(key)
+	}
+	if !bytes.Equal(many.B, one.B) {
+		t.Fatal("AddMany should set the same bits as Add")
+	}
+	for i, found := range many.TestMany(keys) {
+		if found != one.Test(keys[i]) {
+			t.Fatalf("TestMany should agree with Test on key %d", i)
+		}
+	}
+
+	parallel := HashKeysParallel(doubleFNV, keys, 4)
+	for i, h := range HashKeys(doubleFNV, keys) {
+		if parallel[i] != h {
+			t.Fatalf("Parallel hash of key %d should match HashKeys", i)
+		}
+	}
+	if len(HashKeysParallel(doubleFNV, nil, 0)) != 0 {
+		t.Fatal("No keys should have no hashes")
+	}
+}
+
+func TestClassicFilter_testManyInterleaved(t *testing.T) {
+	keys := make([][]byte, 1000+5)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	for p := DoubleHashing; p.valid(); p++ {
+		bf := NewSeeded(500, 1e-2, 3, doubleFNV)
+		bf.Probe = p
+		bf.AddMany(keys[:500])
+		many := bf.TestMany(keys)
+		for i, found := range bf.testManyInterleaved(keys) {
+			if found != many[i] {
+				t.Fatalf("testManyInterleaved should agree with TestMany on key %d with %v probes", i, p)
+			}
+		}
+	}
+}
+
+// BenchmarkTestManyInterleaved compares TestMany with testManyInterleaved on a filter of
+// 1 GiB with half of its bits set, to be larger than the last-level cache. Setting it up
+// takes a while, so it runs only if BLOOM_BENCH_LARGE is set.
+func BenchmarkTestManyInterleaved(b *testing.B) {
+	if os.Getenv("BLOOM_BENCH_LARGE") == "" {
+		b.Skip("set BLOOM_BENCH_LARGE to benchmark on a filter of 1 GiB")
+	}
+	bf := NewWithBits(8<<30, 10, XXHash)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < len(bf.B); i += 8 {
+		binary.LittleEndian.PutUint64(bf.B[i:], r.Uint64())
+	}
+	keys := make([][]byte, 4096)
+	for i := range keys {
+		keys[i] = binary.AppendUvarint(nil, uint64(i))
+	}
+	b.Run("TestMany", func(b *testing.B) {
+		for i := 0; i < b.N; i += len(keys) {
+			bf.TestMany(keys)
+		}
+	})
+	b.Run("testManyInterleaved", func(b *testing.B) {
+		for i := 0; i < b.N; i += len(keys) {
+			bf.testManyInterleaved(keys)
+		}
+	})
+}
+
+func BenchmarkAddMany(b *testing.B) {
+	bf := newClassic(1e6, 1e-4, doubleFNV)
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += len(keys) {
+		bf.AddMany(keys)
+	}
+}
+
+func TestClassicFilter_AddFields(t *testing.T) {
+	bf := newClassic(1e3, 1e-3, doubleFNV)
+	src, dst, nonce := []byte{192, 0, 2, 1}, []byte{198, 51, 100, 7}, []byte("nonce")
+	bf.AddFields(src, dst, nonce)
+	if !bf.TestFields(src, dst, nonce) {
+		t.Fatal("Key of fields should be in the filter")
+	}
+	for _, fields := range [][][]byte{
+		{dst, src, nonce},
+		{src, dst},
+		{append(append(append([]byte(nil), src...), dst...), nonce...)},
+		{src[:2], append(src[2:], dst...), nonce},
+	} {
+		if bf.TestFields(fields...) {
+			t.Fatalf("Key of other fields %q should not be in the filter", fields)
+		}
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		bf.AddFields(src, dst, nonce)
+		bf.TestFields(src, dst, nonce)
+	}); n != 0 {
+		t.Fatalf("AddFields and TestFields should not allocate but made %v allocations", n)
+	}
+}
+
+// interleaveBatch is the number of keys testManyInterleaved probes together.
+const interleaveBatch = 16
+
+// testManyInterleaved tests keys against the filter like TestMany, for filters much larger
+// than the last-level cache, where every probe is a cache and TLB miss. It hashes the keys
+// in batches of 16 before reading any bit, and then reads the bits in rounds of one probe
+// of every key of the batch that is not known to be absent yet. The reads of a round do
+// not depend on each other, so their misses overlap instead of following one another.
+//
+// It is not part of the package because its bookkeeping costs more than TestMany saves
+// wherever the processor already overlaps the misses of consecutive keys: on a machine
+// whose random reads of a 1 GiB filter took 20ns, it was a third slower than TestMany. It
+// is kept here for BenchmarkTestManyInterleaved, to measure that on other machines.
+func (f *ClassicFilter) testManyInterleaved(keys [][]byte) []bool {
+	if f.constant {
+		// the interleaved probes stop at the first clear bit
+		return f.TestMany(keys)
+	}
+	m := f.BitCount()
+	found := make([]bool, len(keys))
+	var xs [interleaveBatch]uint64
+	var probes [interleaveBatch]probeSeq
+	for lo := 0; lo < len(keys); lo += interleaveBatch {
+		batch := keys[lo:min(lo+interleaveBatch, len(keys))]
+		for j, b := range batch {
+			x, y := f.hash(b)
+			xs[j], probes[j] = x, f.probes(x, y)
+		}
+		var absent uint32
+		for i := 0; i < f.K; i++ {
+			for j := range batch {
+				if absent&(1<<j) != 0 {
+					continue
+				}
+				offset := reduceOffset(f.Probe.finish(xs[j], probes[j].next()), m)
+				if f.B[offset/8]&(1<<(offset%8)) == 0 {
+					absent |= 1 << j
+				}
+			}
+		}
+		for j := range batch {
+			found[lo+j] = absent&(1<<j) == 0
+		}
+	}
+	return found
+}
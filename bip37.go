@@ -0,0 +1,351 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"iter"
+	"math"
+)
+
+// BIP 37 compatible Bloom Filter
+//
+// A BIP37Filter is the Bloom Filter of Bitcoin's connection Bloom filtering, BIP 37,
+// that SPV clients send in filterload messages: it hashes each entry with the
+// 32-bit MurmurHash3 seeded with i·0xFBA4C795 + nTweak for the i-th hash, sets
+// bits least significant first, is sized like Bitcoin Core's CBloomFilter
+// within the limits of the protocol, and reads and writes the payload of
+// filterload. MatchAndUpdate evaluates transactions like a full node does,
+// adding the outpoints of matched outputs as its flags say.
+type BIP37Filter struct {
+	Data      []byte
+	HashFuncs uint32
+	Tweak     uint32
+	Flags     BIP37Flags
+}
+
+// BIP37Flags are the nFlags of a filterload message, which control how MatchAndUpdate
+// updates the filter.
+type BIP37Flags uint8
+
+const (
+	BIP37UpdateNone         BIP37Flags = 0 // never add outpoints
+	BIP37UpdateAll          BIP37Flags = 1 // add the outpoint of every output that matches
+	BIP37UpdateP2PubKeyOnly BIP37Flags = 2 // add it only for pay-to-pubkey and multisig outputs
+	BIP37UpdateMask         BIP37Flags = 3
+)
+
+// The limits of BIP 37 on filters, beyond which peers reject a filterload.
+const (
+	BIP37MaxSize      = 36000 // bytes
+	BIP37MaxHashFuncs = 50
+)
+
+// ErrBIP37Limits is returned when encoding or decoding a BIP37Filter that exceeds the limits
+// of the protocol.
+var ErrBIP37Limits = errors.New("bloom: filter exceeds the limits of BIP 37")
+
+// NewBIP37 creates an empty filter for n entries and a false positive rate of p, like
+// CBloomFilter(n, p, tweak, flags) of Bitcoin Core: as large as they need, up to
+// BIP37MaxSize bytes and BIP37MaxHashFuncs hashes, without rounding the number of bits up.
+func NewBIP37(n int, p float64, tweak uint32, flags BIP37Flags) *BIP37Filter {
+	if n < 1 {
+		n = 1
+	}
+	bits := min(max(-1/(math.Ln2*math.Ln2)*float64(n)*math.Log(p), 0), BIP37MaxSize*8)
+	size := uint32(bits) / 8
+	// Bitcoin Core divides the bits by the entries in integers before multiplying by ln 2
+	funcs := min(uint32(float64(size*8/uint32(n))*math.Ln2), BIP37MaxHashFuncs)
+	return &BIP37Filter{Data: make([]byte, size), HashFuncs: funcs, Tweak: tweak, Flags: flags}
+}
+
+func (f *BIP37Filter) index(i uint32, b []byte) uint32 {
+	return murmur3Sum32(b, i*0xFBA4C795+f.Tweak) % uint32(len(f.Data)*8)
+}
+
+func (f *BIP37Filter) Add(b []byte) {
+	if len(f.Data) == 0 {
+		return // like Bitcoin Core, which avoids dividing by zero (CVE-2013-5700)
+	}
+	for i := uint32(0); i < f.HashFuncs; i++ {
+		n := f.index(i, b)
+		f.Data[n>>3] |= 1 << (n & 7)
+	}
+}
+
+// Test reports whether an entry may be in the filter, which is true of every entry for an
+// empty bit array, like Bitcoin Core.
+func (f *BIP37Filter) Test(b []byte) bool {
+	if len(f.Data) == 0 {
+		return true
+	}
+	for i := uint32(0); i < f.HashFuncs; i++ {
+		n := f.index(i, b)
+		if f.Data[n>>3]&(1<<(n&7)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *BIP37Filter) Size() int { return len(f.Data) }
+
+func (f *BIP37Filter) Reset() { clear(f.Data) }
+
+// WithinLimits reports whether the filter is within the limits of BIP 37, as a peer checks
+// a filterload.
+func (f *BIP37Filter) WithinLimits() bool {
+	return len(f.Data) <= BIP37MaxSize && f.HashFuncs <= BIP37MaxHashFuncs
+}
+
+// outPoint returns the serialization of an outpoint: the hash of its transaction and the
+// index of the output as a little-endian uint32.
+func outPoint(hash [32]byte, index uint32) []byte {
+	return binary.LittleEndian.AppendUint32(hash[:], index)
+}
+
+// AddOutPoint adds the outpoint of output index of the transaction of hash, which is in the
+// internal byte order of Bitcoin, the reverse of the hex shown by explorers.
+func (f *BIP37Filter) AddOutPoint(hash [32]byte, index uint32) { f.Add(outPoint(hash, index)) }
+
+// TestOutPoint reports whether an outpoint added by AddOutPoint may be in the filter.
+func (f *BIP37Filter) TestOutPoint(hash [32]byte, index uint32) bool {
+	return f.Test(outPoint(hash, index))
+}
+
+// BIP37Tx is what MatchAndUpdate needs of a transaction.
+type BIP37Tx struct {
+	Hash    [32]byte // txid, in internal byte order
+	Inputs  []BIP37TxIn
+	Outputs [][]byte // scriptPubKey of each output
+}
+
+// BIP37TxIn is an input of a BIP37Tx.
+type BIP37TxIn struct {
+	PrevHash  [32]byte // txid of the spent output, in internal byte order
+	PrevIndex uint32
+	ScriptSig []byte
+}
+
+// MatchAndUpdate reports whether tx matches the filter as BIP 37 defines, like
+// IsRelevantAndUpdate of Bitcoin Core: if its hash, a data element of the script of an
+// output, the outpoint spent by an input or a data element of the script of an input is in
+// the filter. The outpoint of an output matched by a data element is added to the filter if
+// the flags say, so that transactions spending it match too. An empty bit array matches
+// every transaction, as it does every entry.
+func (f *BIP37Filter) MatchAndUpdate(tx *BIP37Tx) bool {
+	if len(f.Data) == 0 {
+		return true // like Bitcoin Core, for which a zero-size filter matches all
+	}
+	found := f.Test(tx.Hash[:])
+outputs:
+	for i, script := range tx.Outputs {
+		for data := range scriptData(script) {
+			if !f.Test(data) {
+				continue
+			}
+			found = true
+			switch f.Flags & BIP37UpdateMask {
+			case BIP37UpdateAll:
+				f.AddOutPoint(tx.Hash, uint32(i))
+			case BIP37UpdateP2PubKeyOnly:
+				if isPayToPubKey(script) || isMultisig(script) {
+					f.AddOutPoint(tx.Hash, uint32(i))
+				}
+			}
+			continue outputs
+		}
+	}
+	if found {
+		return true
+	}
+	for _, in := range tx.Inputs {
+		if f.TestOutPoint(in.PrevHash, in.PrevIndex) {
+			return true
+		}
+		for data := range scriptData(in.ScriptSig) {
+			if f.Test(data) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Bitcoin script opcodes
+const (
+	opPushData1     = 0x4c
+	opPushData2     = 0x4d
+	opPushData4     = 0x4e
+	op1             = 0x51
+	op16            = 0x60
+	opCheckSig      = 0xac
+	opCheckMultisig = 0xae
+)
+
+// scriptOps calls fn with each opcode of script and the data it pushes, until fn returns
+// false or an opcode runs past the end of script, and reports whether it reached the end.
+func scriptOps(script []byte, fn func(op byte, data []byte) bool) bool {
+	for len(script) > 0 {
+		op := script[0]
+		script = script[1:]
+		var n uint64
+		switch {
+		case op < opPushData1:
+			n = uint64(op)
+		case op == opPushData1 && len(script) >= 1:
+			n, script = uint64(script[0]), script[1:]
+		case op == opPushData2 && len(script) >= 2:
+			n, script = uint64(binary.LittleEndian.Uint16(script)), script[2:]
+		case op == opPushData4 && len(script) >= 4:
+			n, script = uint64(binary.LittleEndian.Uint32(script)), script[4:]
+		case op <= opPushData4:
+			return false
+		}
+		if n > uint64(len(script)) || !fn(op, script[:n]) {
+			return false
+		}
+		script = script[n:]
+	}
+	return true
+}
+
+// scriptData returns the data elements of script that are not empty.
+func scriptData(script []byte) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		scriptOps(script, func(_ byte, data []byte) bool {
+			return len(data) == 0 || yield(data)
+		})
+	}
+}
+
+// validPubKey reports whether b has the size of a public key by its first byte, like
+// CPubKey::ValidSize of Bitcoin Core.
+func validPubKey(b []byte) bool {
+	switch {
+	case len(b) == 33:
+		return b[0] == 2 || b[0] == 3
+	case len(b) == 65:
+		return b[0] == 4 || b[0] == 6 || b[0] == 7
+	}
+	return false
+}
+
+// isPayToPubKey reports whether script is <pubkey> OP_CHECKSIG.
+func isPayToPubKey(script []byte) bool {
+	return len(script) > 0 && script[len(script)-1] == opCheckSig &&
+		len(script) == int(script[0])+2 && validPubKey(script[1:len(script)-1])
+}
+
+// isMultisig reports whether script is OP_m <pubkey>… OP_n OP_CHECKMULTISIG, with n keys.
+func isMultisig(script []byte) bool {
+	var ops []byte
+	keys := true // every data element between OP_m and OP_n is a key
+	complete := scriptOps(script, func(op byte, data []byte) bool {
+		if len(ops) > 0 && op <= opPushData4 {
+			keys = keys && validPubKey(data)
+		}
+		ops = append(ops, op)
+		return true
+	})
+	if !complete || !keys || len(ops) < 4 || ops[len(ops)-1] != opCheckMultisig {
+		return false
+	}
+	for _, op := range ops[1 : len(ops)-2] {
+		if op > opPushData4 {
+			return false
+		}
+	}
+	m, n := ops[0], ops[len(ops)-2]
+	return m >= op1 && m <= op16 && n >= m && n <= op16 && int(n-op1+1) == len(ops)-3
+}
+
+// WriteTo writes the filter as the payload of a filterload message: the bit array prefixed
+// by its CompactSize length, the number of hashes and the tweak as little-endian uint32s,
+// and the flags. It returns ErrBIP37Limits for a filter beyond the limits of BIP 37.
+func (f *BIP37Filter) WriteTo(w io.Writer) (int64, error) {
+	if !f.WithinLimits() {
+		return 0, ErrBIP37Limits
+	}
+	b := appendCompactSize(make([]byte, 0, 3+len(f.Data)+9), uint64(len(f.Data)))
+	b = append(b, f.Data...)
+	b = binary.LittleEndian.AppendUint32(b, f.HashFuncs)
+	b = binary.LittleEndian.AppendUint32(b, f.Tweak)
+	n, err := w.Write(append(b, byte(f.Flags)))
+	return int64(n), err
+}
+
+// ReadFrom reads the payload of a filterload message. It returns ErrBIP37Limits for a filter
+// beyond the limits of BIP 37, which a peer would reject.
+func (f *BIP37Filter) ReadFrom(r io.Reader) (int64, error) {
+	var buf [9]byte
+	n, err := io.ReadFull(r, buf[:1])
+	read := int64(n)
+	if err != nil {
+		return read, noEOF(err)
+	}
+	size := uint64(buf[0])
+	if size >= 0xfd {
+		// a CompactSize of 2, 4 or 8 more bytes
+		width := 2 << (size - 0xfd)
+		n, err = io.ReadFull(r, buf[1:1+width])
+		read += int64(n)
+		if err != nil {
+			return read, noEOF(err)
+		}
+		size = binary.LittleEndian.Uint64(buf[1:])
+	}
+	if size > BIP37MaxSize {
+		return read, ErrBIP37Limits
+	}
+	data := make([]byte, size+9)
+	n, err = io.ReadFull(r, data)
+	read += int64(n)
+	if err != nil {
+		return read, noEOF(err)
+	}
+	g := BIP37Filter{
+		Data:      data[:size:size],
+		HashFuncs: binary.LittleEndian.Uint32(data[size:]),
+		Tweak:     binary.LittleEndian.Uint32(data[size+4:]),
+		Flags:     BIP37Flags(data[size+8]),
+	}
+	if !g.WithinLimits() {
+		return read, ErrBIP37Limits
+	}
+	*f = g
+	return read, nil
+}
+
+// appendCompactSize appends the CompactSize encoding of n, Bitcoin's variable-length integer.
+func appendCompactSize(b []byte, n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return append(b, byte(n))
+	case n <= math.MaxUint16:
+		return binary.LittleEndian.AppendUint16(append(b, 0xfd), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.LittleEndian.AppendUint32(append(b, 0xfe), uint32(n))
+	}
+	return binary.LittleEndian.AppendUint64(append(b, 0xff), n)
+}
+
+// MarshalBinary encodes the filter as the payload of a filterload message.
+func (f *BIP37Filter) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := f.WriteTo(&buf)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary decodes the payload of a filterload message.
+func (f *BIP37Filter) UnmarshalBinary(b []byte) error {
+	r := bytes.NewReader(b)
+	if _, err := f.ReadFrom(r); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return ErrInvalidEncoding
+	}
+	return nil
+}
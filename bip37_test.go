@@ -0,0 +1,155 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Test vectors of bloom_create_insert_serialize and its tweaked variant in Bitcoin Core's
+// bloom_tests.
+func TestBIP37Filter_Compatible(t *testing.T) {
+	for _, c := range []struct {
+		tweak uint32
+		want  string
+	}{
+		{0, "03614e9b050000000000000001"},
+		{2147483649, "03ce4299050000000100008001"},
+	} {
+		f := NewBIP37(3, 0.01, c.tweak, BIP37UpdateAll)
+		f.Add(mustHex(t, "99108ad8ed9bb6274d3980bab5a85c048f0950c8"))
+		if !f.Test(mustHex(t, "99108ad8ed9bb6274d3980bab5a85c048f0950c8")) {
+			t.Fatal("Should exist in filter but got false")
+		}
+		if f.Test(mustHex(t, "19108ad8ed9bb6274d3980bab5a85c048f0950c8")) {
+			t.Fatal("A key differing by a bit should not be in the filter")
+		}
+		f.Add(mustHex(t, "b5a2c786d9ef4658287ced5914b37a1b4aa32eee"))
+		f.Add(mustHex(t, "b9300670b4c5366e95b2699e8b18bc75e5f729c5"))
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(data); got != c.want {
+			t.Fatalf("MarshalBinary = %s, want %s", got, c.want)
+		}
+		var g BIP37Filter
+		if err := g.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if g.Tweak != c.tweak || g.HashFuncs != 5 || g.Flags != BIP37UpdateAll || !g.Test(mustHex(t, "b9300670b4c5366e95b2699e8b18bc75e5f729c5")) {
+			t.Fatalf("The decoded filter should equal the encoded one, but got %+v", g)
+		}
+	}
+}
+
+func TestNewBIP37(t *testing.T) {
+	// sizes and hashes of CBloomFilter(n, p, 0, 0) of Bitcoin Core
+	for _, c := range []struct {
+		n          int
+		p          float64
+		size, hash uint32
+	}{
+		{3, 0.01, 3, 5},
+		{10, 0.01, 11, 5}, // 88 bits for 10 entries: 8 bits each, not 8.8
+		{7, 0.001, 12, 9},
+		{1000, 0.0001, 2396, 13},
+	} {
+		f := NewBIP37(c.n, c.p, 0, BIP37UpdateNone)
+		if uint32(len(f.Data)) != c.size || f.HashFuncs != c.hash {
+			t.Fatalf("NewBIP37(%d, %g) has %d bytes and %d hashes, want %d and %d", c.n, c.p, len(f.Data), f.HashFuncs, c.size, c.hash)
+		}
+	}
+}
+
+func TestBIP37Filter_Limits(t *testing.T) {
+	f := NewBIP37(1e6, 1e-9, 0, BIP37UpdateNone)
+	if len(f.Data) != BIP37MaxSize || f.HashFuncs > BIP37MaxHashFuncs || !f.WithinLimits() {
+		t.Fatal("A filter should be capped by the limits of BIP 37, but got", len(f.Data), f.HashFuncs)
+	}
+	if g := NewBIP37(1, 1e-30, 0, BIP37UpdateNone); g.HashFuncs != BIP37MaxHashFuncs {
+		t.Fatal("The number of hashes should be capped, but got", g.HashFuncs)
+	}
+	big := &BIP37Filter{Data: make([]byte, BIP37MaxSize+1), HashFuncs: 1}
+	if _, err := big.MarshalBinary(); err != ErrBIP37Limits {
+		t.Fatal("An oversized filter should not be encoded, but got", err)
+	}
+	// the CompactSize of 36001 is fd a1 8c
+	data := append([]byte{0xfd, 0xa1, 0x8c}, make([]byte, BIP37MaxSize+1+9)...)
+	if err := new(BIP37Filter).UnmarshalBinary(data); err != ErrBIP37Limits {
+		t.Fatal("An oversized filterload should be rejected, but got", err)
+	}
+	if n, err := (&BIP37Filter{Data: make([]byte, 300), HashFuncs: 51}).WriteTo(&bytes.Buffer{}); n != 0 || err != ErrBIP37Limits {
+		t.Fatal("Too many hashes should not be encoded, but got", err)
+	}
+	var empty BIP37Filter
+	if empty.Add([]byte("x")); !empty.Test([]byte("y")) || !empty.MatchAndUpdate(&BIP37Tx{}) {
+		t.Fatal("An empty bit array should match every entry and transaction, like Bitcoin Core")
+	}
+}
+
+func TestBIP37Filter_MatchAndUpdate(t *testing.T) {
+	pubKeyHash := mustHex(t, "99108ad8ed9bb6274d3980bab5a85c048f0950c8")
+	key := append([]byte{2}, bytes.Repeat([]byte{0xab}, 32)...)
+	other := append([]byte{3}, bytes.Repeat([]byte{0xcd}, 32)...)
+	p2pkh := append(append([]byte{0x76, 0xa9, 20}, pubKeyHash...), 0x88, 0xac)
+	p2pk := append(append([]byte{33}, key...), 0xac)
+	multisig := append(append(append(append([]byte{0x51, 33}, key...), 33), other...), 0x52, 0xae)
+	if !isPayToPubKey(p2pk) || isPayToPubKey(p2pkh) || !isMultisig(multisig) || isMultisig(p2pk) {
+		t.Fatal("Scripts should be told apart like the Solver of Bitcoin Core")
+	}
+	if isMultisig(append(append(append([]byte{0x51, 33}, key...), 0x76), 0x51, 0xae)) {
+		t.Fatal("Only pushes of keys may be between OP_m and OP_n")
+	}
+
+	tx := &BIP37Tx{Hash: [32]byte{1}, Outputs: [][]byte{{0x6a}, p2pkh, p2pk, multisig}}
+	spends := func(i uint32) *BIP37Tx {
+		return &BIP37Tx{Hash: [32]byte{2}, Inputs: []BIP37TxIn{{PrevHash: tx.Hash, PrevIndex: i, ScriptSig: []byte{1, 0}}}}
+	}
+	for _, c := range []struct {
+		flags BIP37Flags
+		added []uint32
+	}{
+		{BIP37UpdateNone, nil},
+		{BIP37UpdateAll, []uint32{1, 2, 3}},
+		{BIP37UpdateP2PubKeyOnly, []uint32{2, 3}},
+	} {
+		f := NewBIP37(10, 1e-6, 7, c.flags)
+		f.Add(pubKeyHash)
+		f.Add(key)
+		if !f.MatchAndUpdate(tx) {
+			t.Fatal("A transaction paying to a key of the filter should match")
+		}
+		for i := uint32(0); i < 4; i++ {
+			want := false
+			for _, a := range c.added {
+				want = want || a == i
+			}
+			if got := f.MatchAndUpdate(spends(i)); got != want {
+				t.Errorf("With flags %d, spending output %d should match %v but got %v", c.flags, i, want, got)
+			}
+		}
+	}
+
+	f := NewBIP37(10, 1e-6, 7, BIP37UpdateNone)
+	f.Add(key)
+	if !f.MatchAndUpdate(&BIP37Tx{Inputs: []BIP37TxIn{{ScriptSig: append([]byte{0x4c, 33}, key...)}}}) {
+		t.Fatal("A data element of an input script should match")
+	}
+	if f.MatchAndUpdate(&BIP37Tx{Inputs: []BIP37TxIn{{ScriptSig: append([]byte{0x4c, 34}, key...)}}}) {
+		t.Fatal("A push running past the end should not match")
+	}
+	f.Add(tx.Hash[:])
+	if !f.MatchAndUpdate(tx) {
+		t.Fatal("A transaction whose hash is in the filter should match")
+	}
+}
@@ -0,0 +1,180 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/bits"
+)
+
+// Bits and Blooms compatible Bloom Filter
+//
+// A BitsAndBloomsFilter is a Bloom Filter that is bit for bit compatible
+// with github.com/bits-and-blooms/bloom/v3: it hashes entries with the same
+// 128-bit MurmurHash3 scheme, probes the same M bits, and reads and writes
+// the binary and JSON encodings of that package, so existing filters can be
+// migrated without rebuilding them from their entries. Its probe scheme and
+// word-based bit array differ from those of ClassicFilter, which is why it is a
+// separate type.
+type BitsAndBloomsFilter struct {
+	W []uint64 // words of the bit array
+	M uint64   // number of bits
+	K int
+}
+
+// NewBitsAndBlooms creates an empty filter of m bits and k hashes, like bloom.New(m, k).
+func NewBitsAndBlooms(m uint64, k int) *BitsAndBloomsFilter {
+	if m < 1 {
+		m = 1
+	}
+	if k < 1 {
+		k = 1
+	}
+	return &BitsAndBloomsFilter{W: make([]uint64, (m+63)/64), M: m, K: k}
+}
+
+// baseHashes returns the four hashes of the bits-and-blooms probe scheme.
+func baseHashes(b []byte) [4]uint64 {
+	h1, h2 := murmur3Sum128(b, 0)
+	h3, h4 := murmur3Sum128Plus1(b, 0)
+	return [4]uint64{h1, h2, h3, h4}
+}
+
+func (f *BitsAndBloomsFilter) location(h [4]uint64, i int) uint64 {
+	ii := uint64(i)
+	return (h[ii%2] + ii*h[2+((ii+ii%2)%4)/2]) % f.M
+}
+
+func (f *BitsAndBloomsFilter) Add(b []byte) {
+	h := baseHashes(b)
+	for i := 0; i < f.K; i++ {
+		l := f.location(h, i)
+		f.W[l/64] |= 1 << (l % 64)
+	}
+}
+
+func (f *BitsAndBloomsFilter) Test(b []byte) bool {
+	h := baseHashes(b)
+	for i := 0; i < f.K; i++ {
+		l := f.location(h, i)
+		if f.W[l/64]&(1<<(l%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *BitsAndBloomsFilter) Size() int { return 8 * len(f.W) }
+
+func (f *BitsAndBloomsFilter) Reset() {
+	for i := range f.W {
+		f.W[i] = 0
+	}
+}
+
+// appendBitset appends the bits-and-blooms/bitset binary encoding of the bit array.
+func (f *BitsAndBloomsFilter) appendBitset(b []byte) []byte {
+	b = binary.BigEndian.AppendUint64(b, f.M)
+	for _, w := range f.W {
+		b = binary.BigEndian.AppendUint64(b, w)
+	}
+	return b
+}
+
+// WriteTo writes the filter in the binary encoding of bits-and-blooms/bloom.
+func (f *BitsAndBloomsFilter) WriteTo(w io.Writer) (int64, error) {
+	b := binary.BigEndian.AppendUint64(nil, f.M)
+	b = binary.BigEndian.AppendUint64(b, uint64(f.K))
+	n, err := w.Write(f.appendBitset(b))
+	return int64(n), err
+}
+
+// ReadFrom reads a filter in the binary encoding of bits-and-blooms/bloom.
+func (f *BitsAndBloomsFilter) ReadFrom(r io.Reader) (int64, error) {
+	var hdr [24]byte
+	n, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return int64(n), noEOF(err)
+	}
+	m := binary.BigEndian.Uint64(hdr[:])
+	k := binary.BigEndian.Uint64(hdr[8:])
+	length := binary.BigEndian.Uint64(hdr[16:])
+	if m == 0 || k == 0 || k > 1<<16 || length != m {
+		return int64(n), ErrInvalidEncoding
+	}
+	words := make([]uint64, 0, min((length+63)/64, readChunk/8))
+	read := int64(n)
+	var buf [8]byte
+	for uint64(len(words)) < (length+63)/64 {
+		c, err := io.ReadFull(r, buf[:])
+		read += int64(c)
+		if err != nil {
+			return read, noEOF(err)
+		}
+		words = append(words, binary.BigEndian.Uint64(buf[:]))
+	}
+	f.W, f.M, f.K = words, m, int(k)
+	return read, nil
+}
+
+// MarshalBinary encodes the filter in the binary encoding of bits-and-blooms/bloom.
+func (f *BitsAndBloomsFilter) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := f.WriteTo(&buf)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary decodes a filter in the binary encoding of bits-and-blooms/bloom.
+func (f *BitsAndBloomsFilter) UnmarshalBinary(b []byte) error {
+	r := bytes.NewReader(b)
+	if _, err := f.ReadFrom(r); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return ErrInvalidEncoding
+	}
+	return nil
+}
+
+// bitsAndBloomsJSON is the JSON encoding of bits-and-blooms/bloom.
+type bitsAndBloomsJSON struct {
+	M uint64 `json:"m"`
+	K uint64 `json:"k"`
+	B string `json:"b"` // base64 of the binary encoding of the bitset
+}
+
+// MarshalJSON encodes the filter in the JSON encoding of bits-and-blooms/bloom.
+func (f *BitsAndBloomsFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bitsAndBloomsJSON{
+		M: f.M,
+		K: uint64(f.K),
+		B: base64.URLEncoding.EncodeToString(f.appendBitset(nil)),
+	})
+}
+
+// UnmarshalJSON decodes a filter in the JSON encoding of bits-and-blooms/bloom.
+func (f *BitsAndBloomsFilter) UnmarshalJSON(b []byte) error {
+	var j bitsAndBloomsJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	bitset, err := base64.URLEncoding.DecodeString(j.B)
+	if err != nil {
+		return ErrInvalidEncoding
+	}
+	// reuse the binary decoder by prefixing m and k
+	data := binary.BigEndian.AppendUint64(nil, j.M)
+	data = binary.BigEndian.AppendUint64(data, j.K)
+	return f.UnmarshalBinary(append(data, bitset...))
+}
+
+// Count returns the number of set bits.
+func (f *BitsAndBloomsFilter) Count() int {
+	n := 0
+	for _, w := range f.W {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
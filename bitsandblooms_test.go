@@ -0,0 +1,50 @@
+package bloom
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+// Produced by github.com/bits-and-blooms/bloom/v3 with bloom.New(100, 3)
+// after adding "hello" and "world".
+const (
+	bitsAndBloomsBinary = "00000000000000640000000000000003000000000000006404402000000000500000000400000000"
+	bitsAndBloomsJSONed = `{"m":100,"k":3,"b":"AAAAAAAAAGQEQCAAAAAAUAAAAAQAAAAA"}`
+)
+
+func TestBitsAndBloomsFilter_Compatible(t *testing.T) {
+	f := NewBitsAndBlooms(100, 3)
+	f.Add([]byte("hello"))
+	f.Add([]byte("world"))
+	data, _ := f.MarshalBinary()
+	if got := hex.EncodeToString(data); got != bitsAndBloomsBinary {
+		t.Fatalf("MarshalBinary = %s, want %s", got, bitsAndBloomsBinary)
+	}
+	j, _ := json.Marshal(f)
+	if string(j) != bitsAndBloomsJSONed {
+		t.Fatalf("MarshalJSON = %s, want %s", j, bitsAndBloomsJSONed)
+	}
+}
+
+func TestBitsAndBloomsFilter_Import(t *testing.T) {
+	data, _ := hex.DecodeString(bitsAndBloomsBinary)
+	var fromBinary, fromJSON BitsAndBloomsFilter
+	if err := fromBinary.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(bitsAndBloomsJSONed), &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*BitsAndBloomsFilter{&fromBinary, &fromJSON} {
+		if f.M != 100 || f.K != 3 {
+			t.Fatalf("Decoded m = %d, k = %d", f.M, f.K)
+		}
+		if !f.Test([]byte("hello")) || !f.Test([]byte("world")) {
+			t.Fatal("Should exist in imported filter but got false")
+		}
+	}
+	if err := fromBinary.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("Truncated data should fail to decode")
+	}
+}
@@ -0,0 +1,106 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Bitset is a view of the bit array of a filter, sharing its memory, with bounds-checked
+// access to its bits, so tools can inspect and change filters without depending on how
+// the bits are stored. Bit i of a classic filter is the one probes reduced to i set.
+type Bitset struct {
+	b        []byte
+	m        uint64 // number of bits
+	readOnly bool
+}
+
+// Bits returns a view of the bit array of the filter.
+func (f *ClassicFilter) Bits() Bitset { return Bitset{f.B, f.BitCount(), f.readOnly} }
+
+// Len returns the number of bits, which is that of the filter rather than 8 times the
+// number of bytes, so the unused bits of the last byte are out of range.
+func (s Bitset) Len() uint64 { return s.m }
+
+// Get reports whether bit i is set. It panics if i is out of range.
+func (s Bitset) Get(i uint64) bool {
+	s.check(i)
+	return s.b[i/8]&(1<<(i%8)) != 0
+}
+
+// Set sets bit i. It panics if i is out of range or the filter is read-only.
+func (s Bitset) Set(i uint64) {
+	s.check(i)
+	if s.readOnly {
+		panic(ErrReadOnly)
+	}
+	s.b[i/8] |= 1 << (i % 8)
+}
+
+// Clear clears bit i, which can remove other entries sharing the bit along with the
+// one meant. It panics if i is out of range or the filter is read-only.
+func (s Bitset) Clear(i uint64) {
+	s.check(i)
+	if s.readOnly {
+		panic(ErrReadOnly)
+	}
+	s.b[i/8] &^= 1 << (i % 8)
+}
+
+// OnesCount returns the number of set bits.
+func (s Bitset) OnesCount() int {
+	n, b := 0, s.b
+	for ; len(b) >= 8; b = b[8:] {
+		n += bits.OnesCount64(binary.LittleEndian.Uint64(b))
+	}
+	for _, c := range b {
+		n += bits.OnesCount8(c)
+	}
+	return n - bits.OnesCount8(s.unused())
+}
+
+// unused returns the bits of the last byte past Len, which are not part of the filter.
+func (s Bitset) unused() byte {
+	if s.m%8 == 0 || uint64(len(s.b)) != byteLen(s.m) {
+		return 0
+	}
+	return s.b[len(s.b)-1] &^ (1<<(s.m%8) - 1)
+}
+
+// Words returns a copy of the bits as 64-bit words, bit i in bit i%64 of word i/64,
+// with the bits past Len in the last word clear.
+func (s Bitset) Words() []uint64 {
+	w := make([]uint64, (len(s.b)+7)/8)
+	for i, c := range s.b {
+		w[i/8] |= uint64(c) << (8 * (i % 8))
+	}
+	if unused := s.unused(); unused != 0 {
+		w[len(w)-1] &^= uint64(unused) << (8 * ((len(s.b) - 1) % 8))
+	}
+	return w
+}
+
+// orBits sets the bits of dst that are set in src, of the same length, a word at a time.
+func orBits(dst, src []byte) {
+	for ; len(dst) >= 8; dst, src = dst[8:], src[8:] {
+		binary.LittleEndian.PutUint64(dst, binary.LittleEndian.Uint64(dst)|binary.LittleEndian.Uint64(src))
+	}
+	for i := range dst {
+		dst[i] |= src[i]
+	}
+}
+
+// andBits clears the bits of dst that are clear in src, of the same length, a word at a time.
+func andBits(dst, src []byte) {
+	for ; len(dst) >= 8; dst, src = dst[8:], src[8:] {
+		binary.LittleEndian.PutUint64(dst, binary.LittleEndian.Uint64(dst)&binary.LittleEndian.Uint64(src))
+	}
+	for i := range dst {
+		dst[i] &= src[i]
+	}
+}
+
+func (s Bitset) check(i uint64) {
+	if i >= s.Len() {
+		panic("bloom: bit index out of range")
+	}
+}
@@ -0,0 +1,56 @@
+package bloom
+
+import "testing"
+
+func TestBitset(t *testing.T) {
+	bf := NewWithBits(72, 1, doubleFNV)
+	s := bf.Bits()
+	if s.Len() != 72 || s.OnesCount() != 0 {
+		t.Fatalf("Empty bitset of 72 bits should have none set but has %d of %d", s.OnesCount(), s.Len())
+	}
+	for _, i := range []uint64{0, 7, 8, 63, 64, 71} {
+		s.Set(i)
+	}
+	if s.OnesCount() != 6 || !s.Get(63) || s.Get(62) || bf.B[0] != 0x81 {
+		t.Fatal("Set bits should be shared with the filter")
+	}
+	w := s.Words()
+	if len(w) != 2 || w[0] != 1|1<<7|1<<8|1<<63 || w[1] != 1|1<<7 {
+		t.Fatalf("Words should hold bit i in bit i%%64 of word i/64 but got %x", w)
+	}
+	s.Clear(7)
+	if s.Get(7) || s.OnesCount() != 5 {
+		t.Fatal("Cleared bit should not be set")
+	}
+
+	for name, fn := range map[string]func(){
+		"out of range": func() { s.Get(72) },
+		"read-only":    func() { NewFromBytes(bf.B, 1, doubleFNV).Bits().Set(1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Access %s should panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestOrAndBits(t *testing.T) {
+	for n := 0; n < 20; n++ {
+		a, b := make([]byte, n), make([]byte, n)
+		for i := range a {
+			a[i], b[i] = byte(i*37), byte(i*91+5)
+		}
+		or, and := append([]byte(nil), a...), append([]byte(nil), a...)
+		orBits(or, b)
+		andBits(and, b)
+		for i := range a {
+			if or[i] != a[i]|b[i] || and[i] != a[i]&b[i] {
+				t.Fatalf("Byte %d of %d should be combined bitwise", i, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !purego && !tinygo
+
+package bloom
+
+// hasAVX2 is whether the processor and the operating system support AVX2.
+var hasAVX2 = detectAVX2()
+
+func detectAVX2() bool {
+	if leaves, _, _, _ := cpuid(0, 0); leaves < 7 {
+		return false
+	}
+	// AVX and OSXSAVE, and the operating system saving the XMM and YMM registers
+	if _, _, c, _ := cpuid(1, 0); c&(1<<27|1<<28) != 1<<27|1<<28 {
+		return false
+	}
+	if eax, _ := xgetbv(); eax&6 != 6 {
+		return false
+	}
+	_, b, _, _ := cpuid(7, 0)
+	return b&(1<<5) != 0
+}
+
+// missingBits returns a bit for each of the first n keys whose mask has bits its block does
+// not have, like missingGeneric.
+func missingBits(b []uint64, offs *[testBatch]uint64, masks *[testBatch][blockWords]uint64, n int) uint32 {
+	if hasAVX2 {
+		return missingAVX2(&b[0], offs, masks, n)
+	}
+	return missingGeneric(b, offs, masks, n)
+}
+
+//go:noescape
+func missingAVX2(b *uint64, offs *[testBatch]uint64, masks *[testBatch][blockWords]uint64, n int) uint32
+
+func cpuid(eax, ecx uint32) (a, b, c, d uint32)
+
+func xgetbv() (eax, edx uint32)
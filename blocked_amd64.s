@@ -0,0 +1,57 @@
+//go:build !purego && !tinygo
+
+#include "textflag.h"
+
+// func missingAVX2(b *uint64, offs *[testBatch]uint64, masks *[testBatch][blockWords]uint64, n int) uint32
+TEXT ·missingAVX2(SB), NOSPLIT, $0-36
+	MOVQ b+0(FP), SI
+	MOVQ offs+8(FP), DI
+	MOVQ masks+16(FP), DX
+	MOVQ n+24(FP), CX
+	XORL AX, AX
+	XORL BX, BX
+
+loop:
+	CMPQ BX, CX
+	JGE  done
+	MOVQ (DI)(BX*8), R8
+	LEAQ (SI)(R8*8), R8
+
+	// the bits of the mask of key BX that its block of 64 bytes at R8 does not have
+	VMOVDQU (R8), Y0
+	VMOVDQU 32(R8), Y1
+	VPANDN  (DX), Y0, Y0
+	VPANDN  32(DX), Y1, Y1
+	VPOR    Y0, Y1, Y0
+	VPTEST  Y0, Y0
+	JEQ     next
+	BTSL    BX, AX
+
+next:
+	ADDQ $64, DX
+	INCQ BX
+	JMP  loop
+
+done:
+	VZEROUPPER
+	MOVL AX, ret+32(FP)
+	RET
+
+// func cpuid(eax, ecx uint32) (a, b, c, d uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eax+0(FP), AX
+	MOVL ecx+4(FP), CX
+	CPUID
+	MOVL AX, a+8(FP)
+	MOVL BX, b+12(FP)
+	MOVL CX, c+16(FP)
+	MOVL DX, d+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	XORL   CX, CX
+	XGETBV
+	MOVL   AX, eax+0(FP)
+	MOVL   DX, edx+4(FP)
+	RET
@@ -0,0 +1,12 @@
+//go:build !purego && !tinygo
+
+package bloom
+
+// missingBits returns a bit for each of the first n keys whose mask has bits its block does
+// not have, like missingGeneric. NEON is part of every arm64 processor.
+func missingBits(b []uint64, offs *[testBatch]uint64, masks *[testBatch][blockWords]uint64, n int) uint32 {
+	return missingNEON(&b[0], offs, masks, n)
+}
+
+//go:noescape
+func missingNEON(b *uint64, offs *[testBatch]uint64, masks *[testBatch][blockWords]uint64, n int) uint32
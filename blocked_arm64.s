@@ -0,0 +1,49 @@
+//go:build !purego && !tinygo
+
+#include "textflag.h"
+
+// func missingNEON(b *uint64, offs *[testBatch]uint64, masks *[testBatch][blockWords]uint64, n int) uint32
+TEXT ·missingNEON(SB), NOSPLIT, $0-36
+	MOVD b+0(FP), R0
+	MOVD offs+8(FP), R1
+	MOVD masks+16(FP), R2
+	MOVD n+24(FP), R3
+	MOVD $0, R4
+	MOVD $0, R5
+
+loop:
+	CMP R3, R5
+	BGE done
+	MOVD (R1)(R5<<3), R6
+	ADD  R6<<3, R0, R6
+
+	// the bits of the mask of key R5 that its block of 64 bytes at R6 does not have,
+	// mask XOR (mask AND block)
+	VLD1   (R6), [V0.B16, V1.B16, V2.B16, V3.B16]
+	VLD1.P 64(R2), [V4.B16, V5.B16, V6.B16, V7.B16]
+	VAND   V0.B16, V4.B16, V0.B16
+	VAND   V1.B16, V5.B16, V1.B16
+	VAND   V2.B16, V6.B16, V2.B16
+	VAND   V3.B16, V7.B16, V3.B16
+	VEOR   V0.B16, V4.B16, V4.B16
+	VEOR   V1.B16, V5.B16, V5.B16
+	VEOR   V2.B16, V6.B16, V6.B16
+	VEOR   V3.B16, V7.B16, V7.B16
+	VORR   V4.B16, V5.B16, V4.B16
+	VORR   V6.B16, V7.B16, V6.B16
+	VORR   V4.B16, V6.B16, V4.B16
+	VMOV   V4.D[0], R7
+	VMOV   V4.D[1], R8
+	ORR    R7, R8, R7
+	CBZ    R7, next
+	MOVD   $1, R9
+	LSL    R5, R9, R9
+	ORR    R9, R4, R4
+
+next:
+	ADD $1, R5
+	B   loop
+
+done:
+	MOVW R4, ret+32(FP)
+	RET
@@ -0,0 +1,9 @@
+//go:build !(amd64 || arm64) || purego || tinygo
+
+package bloom
+
+// missingBits returns a bit for each of the first n keys whose mask has bits its block does
+// not have, like missingGeneric, which it is on platforms without an assembly version.
+func missingBits(b []uint64, offs *[testBatch]uint64, masks *[testBatch][blockWords]uint64, n int) uint32 {
+	return missingGeneric(b, offs, masks, n)
+}
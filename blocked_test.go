@@ -0,0 +1,145 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestBlockedFilter_Test(t *testing.T) {
+	bf := NewBlocked(1e6, 1e-4, doubleFNV)
+	buf := []byte("testing")
+	bf.Add(buf)
+	if !bf.Test(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if bf.Test([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
+
+func TestBlockedFilter_FalsePositive(t *testing.T) {
+	const (
+		n         = 1e5
+		expectFPR = 1e-3
+	)
+	bf := NewBlocked(n, expectFPR, doubleSHA)
+	for i := 0; i < n; i++ {
+		bf.Add([]byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < n; i++ {
+		if !bf.Test([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if bf.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	fpr := float64(fp) / n
+	t.Logf("Samples = %d, FP = %d, FPR = %.4f%%", int(n), fp, fpr*100)
+	if fpr > 2*expectFPR {
+		t.Fatalf("FPR %.4f%% is well above the target %.4f%%", fpr*100, expectFPR*100)
+	}
+}
+
+func BenchmarkBlockedTest(b *testing.B) {
+	b.StopTimer()
+	b.ReportAllocs()
+	bf := NewBlocked(1e6, 1e-4, doubleFNV)
+	buf := make([]byte, 20)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		binary.PutUvarint(buf, uint64(i))
+		bf.Test(buf)
+	}
+}
+
+func TestBlockedFilter_TestMany(t *testing.T) {
+	bf := NewBlocked(1e4, 1e-3, doubleSHA)
+	keys := make([][]byte, 2e4+7)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+		if i < 1e4 {
+			bf.Add(keys[i])
+		}
+	}
+	found := bf.TestMany(keys)
+	for i, key := range keys {
+		if found[i] != bf.Test(key) {
+			t.Fatalf("TestMany should agree with Test on key %d", i)
+		}
+	}
+}
+
+func BenchmarkBlockedTestMany(b *testing.B) {
+	bf := NewBlocked(1e7, 1e-4, doubleFNV)
+	keys := make([][]byte, 1024)
+	for i := range keys {
+		keys[i] = binary.AppendUvarint(nil, uint64(i))
+		bf.Add(keys[i])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += len(keys) {
+		bf.TestMany(keys)
+	}
+}
+
+func TestMissingBits(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	b := make([]uint64, 64*blockWords)
+	for i := range b {
+		b[i] = r.Uint64() | r.Uint64()
+	}
+	var offs [testBatch]uint64
+	var masks [testBatch][blockWords]uint64
+	for round := 0; round < 1000; round++ {
+		for j := range offs {
+			offs[j] = r.Uint64N(64) * blockWords
+			// masks of a few bits, some all in their block and some not
+			for w := range masks[j] {
+				masks[j][w] = b[offs[j]+uint64(w)] & r.Uint64() & r.Uint64() & r.Uint64()
+			}
+			if r.IntN(2) == 0 {
+				masks[j][r.IntN(blockWords)] |= 1 << r.IntN(64)
+			}
+		}
+		n := r.IntN(testBatch + 1)
+		if got, want := missingBits(b, &offs, &masks, n), missingGeneric(b, &offs, &masks, n); got != want {
+			t.Fatalf("missingBits of %d keys = %016b, want %016b", n, got, want)
+		}
+	}
+}
+
+// BenchmarkMissingBits compares the test of batches of keys against their blocks of the
+// platform, in assembly where there is one, with that in Go.
+func BenchmarkMissingBits(b *testing.B) {
+	bf := NewBlocked(1e7, 1e-4, doubleFNV)
+	r := rand.New(rand.NewPCG(1, 2))
+	var offs [testBatch]uint64
+	var masks [testBatch][blockWords]uint64
+	for j := range offs {
+		offs[j] = r.Uint64N(uint64(len(bf.B)/blockWords)) * blockWords
+		masks[j] = bf.blockMask(r.Uint64())
+		for w, m := range masks[j] {
+			bf.B[offs[j]+uint64(w)] |= m
+		}
+	}
+	for _, c := range []struct {
+		name    string
+		missing func([]uint64, *[testBatch]uint64, *[testBatch][blockWords]uint64, int) uint32
+	}{
+		{"platform", missingBits},
+		{"go", missingGeneric},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i += testBatch {
+				c.missing(bf.B, &offs, &masks, testBatch)
+			}
+		})
+	}
+}
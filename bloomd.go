@@ -0,0 +1,250 @@
+package bloom
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrBloomdKey is returned by a BloomdFilter for an entry that the line-based bloomd
+// protocol cannot carry: an empty one, or one holding a space, tab or line break.
+var ErrBloomdKey = errors.New("bloom: bloomd entries cannot be empty or hold whitespace")
+
+// ErrBloomdReply is returned by a BloomdFilter when the server sends a reply it does not expect.
+var ErrBloomdReply = errors.New("bloom: unexpected reply from bloomd")
+
+// bloomd Filter
+//
+// A bloomd filter is a client of a named filter of a bloomd server, which hashes and
+// stores the entries itself, so an application can swap an in-process filter for a shared
+// one by swapping its constructor. Entries travel as words of the text protocol, so they
+// cannot be empty or hold whitespace. AddMany and TestMany send one bulk or multi command
+// for a batch.
+//
+// The filter speaks over a connection the caller sets up and closes, and serializes the
+// commands of its goroutines. Add, Test and the other methods of Filter have no error
+// result, so they record the first error for Err, and Test reports false when it fails;
+// Insert, Lookup and their batch forms return it.
+type BloomdFilter struct {
+	name string
+	mu   sync.Mutex
+	rw   *bufio.ReadWriter
+	errs firstError
+}
+
+// NewBloomd returns a client of the filter name of the bloomd server reached over conn.
+// The filter must exist, or be made with Create.
+func NewBloomd(conn io.ReadWriter, name string) *BloomdFilter {
+	return &BloomdFilter{name: name, rw: bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))}
+}
+
+// Name returns the name of the filter on the server.
+func (f *BloomdFilter) Name() string { return f.name }
+
+// Create creates the filter on the server for n entries and false positive rate of p.
+// It succeeds if the filter exists already, whatever it was created for.
+func (f *BloomdFilter) Create(n int, p float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reply, err := f.do("create", f.name, "capacity="+strconv.Itoa(n), "prob="+strconv.FormatFloat(p, 'g', -1, 64))
+	if err == nil && reply != "Done" && reply != "Exists" {
+		err = bloomdError(reply)
+	}
+	return err
+}
+
+// Insert adds an entry to the filter.
+func (f *BloomdFilter) Insert(b []byte) error { return f.InsertMany([][]byte{b}) }
+
+// Lookup tests if an entry is in the filter.
+func (f *BloomdFilter) Lookup(b []byte) (bool, error) {
+	found, err := f.LookupMany([][]byte{b})
+	if err != nil {
+		return false, err
+	}
+	return found[0], nil
+}
+
+// InsertAndLookup adds an entry to the filter and reports whether it was already in it,
+// with one set command.
+func (f *BloomdFilter) InsertAndLookup(b []byte) (bool, error) {
+	added, err := f.command("set", [][]byte{b})
+	if err != nil {
+		return false, err
+	}
+	return !added[0], nil
+}
+
+// InsertMany adds entries to the filter with one bulk command.
+func (f *BloomdFilter) InsertMany(keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := f.command("bulk", keys)
+	return err
+}
+
+// LookupMany tests if entries are in the filter with one multi command.
+func (f *BloomdFilter) LookupMany(keys [][]byte) ([]bool, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return f.command("multi", keys)
+}
+
+func (f *BloomdFilter) Add(b []byte) { f.errs.record(f.Insert(b)) }
+
+func (f *BloomdFilter) Test(b []byte) bool {
+	found, err := f.Lookup(b)
+	f.errs.record(err)
+	return found
+}
+
+// TestAndAdd is InsertAndLookup recording its error for Err.
+func (f *BloomdFilter) TestAndAdd(b []byte) bool {
+	found, err := f.InsertAndLookup(b)
+	f.errs.record(err)
+	return found
+}
+
+// AddMany is InsertMany recording its error for Err.
+func (f *BloomdFilter) AddMany(keys [][]byte) { f.errs.record(f.InsertMany(keys)) }
+
+// TestMany is LookupMany recording its error for Err. An entry that could not be tested is
+// reported absent.
+func (f *BloomdFilter) TestMany(keys [][]byte) []bool {
+	found, err := f.LookupMany(keys)
+	f.errs.record(err)
+	if found == nil {
+		found = make([]bool, len(keys))
+	}
+	return found
+}
+
+// Size returns the number of bytes the server stores the filter in, or 0 if it cannot be
+// asked, recording the error for Err.
+func (f *BloomdFilter) Size() int {
+	info, err := f.Info()
+	if err != nil {
+		f.errs.record(err)
+		return 0
+	}
+	size, _ := strconv.Atoi(info["storage"])
+	return size
+}
+
+// Reset drops the filter and creates it again for the capacity and rate it had, recording
+// an error for Err. The server may refuse to create it while it is still deleting it.
+func (f *BloomdFilter) Reset() {
+	info, err := f.Info()
+	if err == nil {
+		f.mu.Lock()
+		var reply string
+		if reply, err = f.do("drop", f.name); err == nil && reply != "Done" {
+			err = bloomdError(reply)
+		}
+		f.mu.Unlock()
+	}
+	if err == nil {
+		n, _ := strconv.Atoi(info["capacity"])
+		p, _ := strconv.ParseFloat(info["probability"], 64)
+		err = f.Create(n, p)
+	}
+	f.errs.record(err)
+}
+
+// Err returns the first error of the methods without an error result since it was last
+// called, and clears it.
+func (f *BloomdFilter) Err() error { return f.errs.take() }
+
+// Info returns the statistics the server keeps for the filter, such as its capacity,
+// probability, size, the number of entries, and storage, its number of bytes.
+func (f *BloomdFilter) Info() (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reply, err := f.do("info", f.name)
+	if err != nil {
+		return nil, err
+	}
+	if reply != "START" {
+		return nil, bloomdError(reply)
+	}
+	info := make(map[string]string)
+	for {
+		line, err := f.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END" {
+			return info, nil
+		}
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, ErrBloomdReply
+		}
+		info[key] = value
+	}
+}
+
+// command sends a set, bulk or multi command of keys and returns the Yes or No the server
+// replies for each key.
+func (f *BloomdFilter) command(cmd string, keys [][]byte) ([]bool, error) {
+	args := make([]string, 0, 2+len(keys))
+	args = append(args, cmd, f.name)
+	for _, b := range keys {
+		if len(b) == 0 || bytes.ContainsAny(b, " \t\r\n") {
+			return nil, ErrBloomdKey
+		}
+		args = append(args, string(b))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reply, err := f.do(args...)
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(reply)
+	if len(words) != len(keys) {
+		return nil, bloomdError(reply)
+	}
+	found := make([]bool, len(keys))
+	for i, w := range words {
+		switch w {
+		case "Yes":
+			found[i] = true
+		case "No":
+		default:
+			return nil, bloomdError(reply)
+		}
+	}
+	return found, nil
+}
+
+// do sends a command line of words and returns the first line of the reply. f.mu must be held.
+func (f *BloomdFilter) do(words ...string) (string, error) {
+	f.rw.WriteString(strings.Join(words, " "))
+	f.rw.WriteByte('\n')
+	if err := f.rw.Flush(); err != nil {
+		return "", err
+	}
+	return f.readLine()
+}
+
+// readLine reads a line of a reply without its line break. f.mu must be held.
+func (f *BloomdFilter) readLine() (string, error) {
+	line, err := f.rw.ReadString('\n')
+	if err != nil {
+		return "", noEOF(err)
+	}
+	return strings.TrimSuffix(line[:len(line)-1], "\r"), nil
+}
+
+// bloomdError is an error reply of bloomd, such as "Filter does not exist" or
+// "Client Error: Bad arguments".
+type bloomdError string
+
+func (e bloomdError) Error() string { return "bloom: bloomd: " + string(e) }
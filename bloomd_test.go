@@ -0,0 +1,142 @@
+package bloom
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeBloomd serves the commands a BloomdFilter sends on conn from in-memory classic
+// filters, replying as bloomd does.
+func fakeBloomd(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	filters := make(map[string]*ClassicFilter)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) < 2 {
+			fmt.Fprint(conn, "Client Error: Command not supported\n")
+			continue
+		}
+		f := filters[args[1]]
+		var reply string
+		switch cmd, keys := args[0], args[2:]; {
+		case cmd == "create" && f != nil:
+			reply = "Exists"
+		case cmd == "create":
+			n, _ := strconv.Atoi(strings.TrimPrefix(keys[0], "capacity="))
+			p, _ := strconv.ParseFloat(strings.TrimPrefix(keys[1], "prob="), 64)
+			filters[args[1]] = New(n, p, doubleFNV).(*ClassicFilter)
+			reply = "Done"
+		case f == nil:
+			reply = "Filter does not exist"
+		case cmd == "drop":
+			delete(filters, args[1])
+			reply = "Done"
+		case cmd == "info":
+			reply = fmt.Sprintf("START\ncapacity %d\nprobability %g\nsize %d\nstorage %d\nEND", f.n, f.p, f.ApproximateCount(), f.Size())
+		case cmd == "set" || cmd == "bulk" || cmd == "multi":
+			words := make([]string, len(keys))
+			for i, k := range keys {
+				var found bool
+				if cmd == "multi" {
+					found = f.Test([]byte(k))
+				} else {
+					found = !f.TestAndAdd([]byte(k))
+				}
+				words[i] = map[bool]string{true: "Yes", false: "No"}[found]
+			}
+			reply = strings.Join(words, " ")
+		default:
+			reply = "Client Error: Command not supported"
+		}
+		fmt.Fprint(conn, reply+"\n")
+	}
+}
+
+// newFakeBloomd returns a client of the filter name served by fakeBloomd.
+func newFakeBloomd(t *testing.T, name string) *BloomdFilter {
+	client, server := net.Pipe()
+	go fakeBloomd(server)
+	t.Cleanup(func() { client.Close() })
+	return NewBloomd(client, name)
+}
+
+func TestBloomdFilter(t *testing.T) {
+	f := newFakeBloomd(t, "filter")
+	if err := f.Create(1e3, 1e-2); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Create(10, 1e-1); err != nil {
+		t.Fatal("Creating an existing filter should succeed but got", err)
+	}
+	f.Add([]byte("hello"))
+	if !f.Test([]byte("hello")) || f.Test([]byte("world")) {
+		t.Fatal("Only the added entry should be in the filter")
+	}
+	if f.TestAndAdd([]byte("world")) || !f.TestAndAdd([]byte("world")) {
+		t.Fatal("TestAndAdd should report an entry present only once added")
+	}
+
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	f.AddMany(keys)
+	for i, found := range f.TestMany(keys) {
+		if !found {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+	if got, want := f.Size(), New(1e3, 1e-2, doubleFNV).Size(); got != want {
+		t.Fatalf("Size should be the storage of the server filter, %d, but got %d", want, got)
+	}
+	if err := f.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Reset()
+	if f.Test([]byte("hello")) || f.Err() != nil {
+		t.Fatal("Reset filter should be empty")
+	}
+	if info, err := f.Info(); err != nil || info["capacity"] != "1000" {
+		t.Fatal("Reset filter should keep its capacity but got", info, err)
+	}
+}
+
+func TestBloomdFilter_Errors(t *testing.T) {
+	f := newFakeBloomd(t, "missing")
+	if err := f.Insert([]byte("hello")); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Insert should return the error reply but got %v", err)
+	}
+	f.Add([]byte("hello"))
+	f.Add([]byte("world"))
+	if f.Err() == nil || f.Err() != nil {
+		t.Fatal("Err should return the error of Add once")
+	}
+
+	if err := f.Create(10, 1e-2); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "hello world", "line\n"} {
+		if err := f.Insert([]byte(key)); err != ErrBloomdKey {
+			t.Fatalf("Insert of %q should fail with ErrBloomdKey but got %v", key, err)
+		}
+	}
+	// the connection stays usable after a rejected entry
+	if err := f.Insert([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	f.rw.Writer.Reset(failingWriter{})
+	if found := f.TestMany([][]byte{[]byte("hello")}); len(found) != 1 || found[0] || f.Err() == nil {
+		t.Fatal("TestMany over a broken connection should report entries absent and record the error")
+	}
+}
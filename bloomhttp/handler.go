@@ -0,0 +1,187 @@
+// Package bloomhttp serves a filter of github.com/OperatorFoundation/go-bloom over HTTP,
+// so a service can expose the filter it keeps and it can be queried with curl:
+//
+//	curl -X POST 'localhost:8080/add?key=alice'
+//	curl 'localhost:8080/test?key=alice'
+//	curl --data-binary @keys.txt localhost:8080/test
+//	curl -o filter.bin localhost:8080/snapshot
+//
+// Keys are given as key query parameters or, for batches, as the lines of the request
+// body, so they are text without line breaks. A Loader keeps a filter fetched from a URL,
+// such as the snapshot of another Handler, in service.
+package bloomhttp
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+)
+
+// maxBody is the largest request body a Handler reads.
+const maxBody = 64 << 20
+
+// Handler serves a filter with the endpoints below, answering with JSON:
+//
+//	POST /add               add the keys, {"added": n}
+//	GET, POST /test         test the keys, {"found": [true, false, ...]}
+//	POST /testandadd        add the keys in order, {"found": [...]} of whether each was in before
+//	GET /stats              {"size": bytes, "bits": m, "hashes": k, "count": n, "fill_ratio": r},
+//	                        with the fields the filter can tell
+//	GET /snapshot           the binary encoding of the filter, such as MarshalBinary of a ClassicFilter
+//	POST /reset             clear the filter
+//
+// HTTP serves requests concurrently, so the filter must be safe for concurrent use. Stats
+// and snapshots of a bloom.SafeFilter see the filter it wraps, under its lock.
+type Handler struct {
+	f   bloom.Filter
+	mux *http.ServeMux
+}
+
+// NewHandler returns a handler serving f. Mount it under a prefix with http.StripPrefix.
+func NewHandler(f bloom.Filter) *Handler {
+	h := &Handler{f: f, mux: http.NewServeMux()}
+	h.mux.HandleFunc("POST /add", h.add)
+	h.mux.HandleFunc("GET /test", h.test)
+	h.mux.HandleFunc("POST /test", h.test)
+	h.mux.HandleFunc("POST /testandadd", h.testAndAdd)
+	h.mux.HandleFunc("GET /stats", h.stats)
+	h.mux.HandleFunc("GET /snapshot", h.snapshot)
+	h.mux.HandleFunc("POST /reset", h.reset)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { h.mux.ServeHTTP(w, r) }
+
+// add adds the keys, failing with 409 Conflict at the first one an Inserter cannot add,
+// such as a key of a read-only filter.
+func (h *Handler) add(w http.ResponseWriter, r *http.Request) {
+	keys, ok := readKeys(w, r)
+	if !ok {
+		return
+	}
+	for i, key := range keys {
+		if inserter, ok := h.f.(bloom.Inserter); ok {
+			if err := inserter.Insert(key); err != nil {
+				writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "added": i})
+				return
+			}
+		} else {
+			h.f.Add(key)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"added": len(keys)})
+}
+
+func (h *Handler) test(w http.ResponseWriter, r *http.Request) {
+	keys, ok := readKeys(w, r)
+	if !ok {
+		return
+	}
+	found := make([]bool, len(keys))
+	for i, key := range keys {
+		found[i] = h.f.Test(key)
+	}
+	writeJSON(w, http.StatusOK, map[string][]bool{"found": found})
+}
+
+func (h *Handler) testAndAdd(w http.ResponseWriter, r *http.Request) {
+	keys, ok := readKeys(w, r)
+	if !ok {
+		return
+	}
+	found := make([]bool, len(keys))
+	for i, key := range keys {
+		found[i] = bloom.TestAndAdd(h.f, key)
+	}
+	writeJSON(w, http.StatusOK, map[string][]bool{"found": found})
+}
+
+func (h *Handler) stats(w http.ResponseWriter, _ *http.Request) {
+	stats := make(map[string]any)
+	h.do(func(f bloom.Filter) {
+		stats["size"] = f.Size()
+		if f, ok := f.(interface{ BitCount() uint64 }); ok {
+			stats["bits"] = f.BitCount()
+		}
+		if f, ok := f.(*bloom.ClassicFilter); ok {
+			stats["hashes"] = f.K
+		}
+		if f, ok := f.(bloom.ApproximateCounter); ok {
+			stats["count"] = f.ApproximateCount()
+		}
+		if f, ok := f.(interface{ FillRatio() float64 }); ok {
+			stats["fill_ratio"] = f.FillRatio()
+		}
+	})
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// snapshot sends the binary encoding of the filter, or 501 Not Implemented if it has none.
+func (h *Handler) snapshot(w http.ResponseWriter, _ *http.Request) {
+	var b []byte
+	err := errors.ErrUnsupported
+	h.do(func(f bloom.Filter) {
+		if m, ok := f.(encoding.BinaryMarshaler); ok {
+			b, err = m.MarshalBinary()
+		}
+	})
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		writeJSON(w, http.StatusNotImplemented, map[string]string{"error": "filter has no binary encoding"})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	default:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="filter.bin"`)
+		w.Write(b)
+	}
+}
+
+func (h *Handler) reset(w http.ResponseWriter, _ *http.Request) {
+	h.f.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// do calls fn with the filter, or the filter a SafeFilter wraps while holding its lock.
+func (h *Handler) do(fn func(bloom.Filter)) {
+	if s, ok := h.f.(*bloom.SafeFilter); ok {
+		s.Do(fn)
+	} else {
+		fn(h.f)
+	}
+}
+
+// readKeys returns the key query parameters of r followed by the lines of its body,
+// answering 400 Bad Request and returning false if there are none or the body is too large.
+func readKeys(w http.ResponseWriter, r *http.Request) ([][]byte, bool) {
+	var keys [][]byte
+	for _, key := range r.URL.Query()["key"] {
+		keys = append(keys, []byte(key))
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if line = bytes.TrimSuffix(line, []byte("\r")); len(line) > 0 {
+			keys = append(keys, line)
+		}
+	}
+	if len(keys) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no keys given"})
+		return nil, false
+	}
+	return keys, true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,98 @@
+package bloomhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+)
+
+// call sends a request to h and decodes its JSON reply into v, if it is not nil.
+func call(t *testing.T, h http.Handler, method, target, body string, v any) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, target, err, w.Body)
+		}
+	}
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	f := bloom.New(1e3, 1e-2, bloom.XXHash).(*bloom.ClassicFilter)
+	h := NewHandler(bloom.WrapSafe(f))
+
+	var added struct{ Added int }
+	if call(t, h, "POST", "/add?key=alice", "bob\r\ncarol\n", &added); added.Added != 3 {
+		t.Fatal("Add should add the keys of the query and body but added", added.Added)
+	}
+	var found struct{ Found []bool }
+	if call(t, h, "GET", "/test?key=alice&key=dave", "", &found); len(found.Found) != 2 || !found.Found[0] || found.Found[1] {
+		t.Fatal("Test should find only the added keys but got", found.Found)
+	}
+	if call(t, h, "POST", "/test", "bob\ncarol", &found); len(found.Found) != 2 || !found.Found[0] || !found.Found[1] {
+		t.Fatal("Test of a batch should find the added keys but got", found.Found)
+	}
+	if call(t, h, "POST", "/testandadd", "erin\nerin", &found); found.Found[0] || !found.Found[1] {
+		t.Fatal("TestAndAdd should add keys in order but got", found.Found)
+	}
+
+	var stats struct {
+		Size, Bits, Hashes int
+		Count              uint64
+	}
+	call(t, h, "GET", "/stats", "", &stats)
+	if stats.Size != f.Size() || stats.Bits != int(f.BitCount()) || stats.Hashes != f.K || stats.Count != f.ApproximateCount() {
+		t.Fatal("Stats should describe the wrapped filter but got", stats)
+	}
+
+	w := call(t, h, "GET", "/snapshot", "", nil)
+	g := new(bloom.ClassicFilter)
+	if err := g.UnmarshalBinary(w.Body.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	g.H = bloom.XXHash
+	if !g.Test([]byte("alice")) {
+		t.Fatal("Snapshot should hold the added keys")
+	}
+
+	if w := call(t, h, "POST", "/reset", "", nil); w.Code != http.StatusNoContent || f.Test([]byte("alice")) {
+		t.Fatal("Reset should clear the filter")
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	h := NewHandler(bloom.NewFromBytes(make([]byte, 64), 3, bloom.XXHash))
+	if w := call(t, h, "POST", "/add", "", nil); w.Code != http.StatusBadRequest {
+		t.Fatal("Add without keys should fail with 400 but got", w.Code)
+	}
+	if w := call(t, h, "POST", "/add?key=alice", "", nil); w.Code != http.StatusConflict {
+		t.Fatal("Add to a read-only filter should fail with 409 but got", w.Code)
+	}
+	if w := call(t, h, "GET", "/add?key=alice", "", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Fatal("Add should need POST but got", w.Code)
+	}
+
+	h = NewHandler(bloom.NewSafe(1e3, 1e-2, bloom.XXHash))
+	w := call(t, h, "POST", "/add", strings.Repeat("x", maxBody+1), nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatal("Add with a body over the limit should fail with 400 but got", w.Code)
+	}
+	w = call(t, NewHandler(bloom.WrapSafe(setFilter{})), "GET", "/snapshot", "", nil)
+	if w.Code != http.StatusNotImplemented {
+		t.Fatal("Snapshot of a filter without a binary encoding should fail with 501 but got", w.Code)
+	}
+}
+
+// setFilter is an exact Filter without a binary encoding.
+type setFilter map[string]bool
+
+func (s setFilter) Add(b []byte)       { s[string(b)] = true }
+func (s setFilter) Test(b []byte) bool { return s[string(b)] }
+func (s setFilter) Size() int          { return len(s) }
+func (s setFilter) Reset()             { clear(s) }
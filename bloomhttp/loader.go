@@ -0,0 +1,216 @@
+package bloomhttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+)
+
+// maxFilter is the largest filter a Loader fetches.
+const maxFilter = 1 << 30
+
+// ErrChecksum is returned by Load for a filter that does not match its checksum.
+var ErrChecksum = errors.New("bloomhttp: checksum mismatch")
+
+// Loader keeps a pre-built filter fetched from a URL in service, such as a blocklist
+// published to an object store for a fleet of proxies: Load fetches it, verifies its
+// checksum, decodes it and swaps it in for the filter that Filter and Test use, atomically,
+// so lookups never see a partial filter and never wait for a fetch. Run loads it again on
+// an interval, with the ETag of the last load, so the filter is fetched only when it
+// changed.
+//
+// Objects of S3-style stores are fetched with GET like any URL: public ones directly,
+// private ones through a presigned URL or a Client whose Transport signs requests.
+type Loader struct {
+	// Client fetches the filter, http.DefaultClient if nil.
+	Client *http.Client
+
+	// ChecksumURL, if not empty, is fetched for the SHA-256 the filter must have, as hex
+	// followed by anything, like the output of sha256sum. Without it, the SHA-256 of an
+	// X-Amz-Checksum-Sha256 header, which S3 sends for objects uploaded with a checksum, or a
+	// sha-256 Content-Digest is checked, if the response has one.
+	ChecksumURL string
+
+	// Interval is how often Run loads the filter, a minute if 0.
+	Interval time.Duration
+
+	// OnError, if not nil, is called by Run with the errors of loading the filter, which
+	// leave the filter in service as it was.
+	OnError func(error)
+
+	url    string
+	decode func([]byte) (bloom.Filter, error)
+
+	mu      sync.Mutex // serializes loads
+	etag    string
+	current atomic.Pointer[bloom.Filter]
+}
+
+// NewLoader returns a loader of the filter at url, decoded by decode. If decode is nil, the
+// filter is decoded with UnmarshalBinary of a ClassicFilter hashed with bloom.DefaultHash,
+// which reads the snapshots of a Handler of a ClassicFilter. A filter published encrypted
+// by bloom.MarshalEncrypted is decoded by a decode calling bloom.UnmarshalEncrypted.
+func NewLoader(url string, decode func([]byte) (bloom.Filter, error)) *Loader {
+	if decode == nil {
+		decode = func(b []byte) (bloom.Filter, error) {
+			f := &bloom.ClassicFilter{H: bloom.DefaultHash}
+			return f, f.UnmarshalBinary(b)
+		}
+	}
+	return &Loader{url: url, decode: decode}
+}
+
+// Filter returns the filter in service, or nil if none has been loaded.
+func (l *Loader) Filter() bloom.Filter {
+	if f := l.current.Load(); f != nil {
+		return *f
+	}
+	return nil
+}
+
+// Test tests an entry against the filter in service, and is false until one is loaded. The
+// filter is shared by concurrent calls, so it must be safe for concurrent Tests, which the
+// filters of this package are.
+func (l *Loader) Test(b []byte) bool {
+	f := l.Filter()
+	return f != nil && f.Test(b)
+}
+
+// Load fetches the filter and swaps it into service, and reports whether it did: it does
+// not if the filter did not change since the last load, by its ETag. On errors, the filter
+// in service is kept.
+func (l *Loader) Load(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("X-Amz-Checksum-Mode", "ENABLED")
+	if l.etag != "" && l.current.Load() != nil {
+		req.Header.Set("If-None-Match", l.etag)
+	}
+	resp, err := l.client().Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("bloomhttp: fetching %s: %s", l.url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFilter+1))
+	if err != nil {
+		return false, err
+	} else if len(data) > maxFilter {
+		return false, fmt.Errorf("bloomhttp: fetching %s: filter is larger than %d bytes", l.url, maxFilter)
+	}
+
+	want, err := l.checksum(ctx, resp.Header)
+	if err != nil {
+		return false, err
+	}
+	if sum := sha256.Sum256(data); want != nil && !bytes.Equal(sum[:], want) {
+		return false, ErrChecksum
+	}
+	f, err := l.decode(data)
+	if err != nil {
+		return false, err
+	}
+	l.current.Store(&f)
+	l.etag = resp.Header.Get("ETag")
+	return true, nil
+}
+
+// checksum returns the SHA-256 the filter must have, or nil if there is none to check.
+func (l *Loader) checksum(ctx context.Context, header http.Header) ([]byte, error) {
+	if l.ChecksumURL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.ChecksumURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := l.client().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("bloomhttp: fetching %s: %s", l.ChecksumURL, resp.Status)
+		}
+		line, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("bloomhttp: %s has no checksum", l.ChecksumURL)
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("bloomhttp: %s has no SHA-256 checksum", l.ChecksumURL)
+		}
+		return sum, nil
+	}
+	if s := header.Get("X-Amz-Checksum-Sha256"); s != "" {
+		return decodeSum(s)
+	}
+	// Content-Digest: sha-256=:base64:, sha-512=:base64:
+	for _, digest := range strings.Split(header.Get("Content-Digest"), ",") {
+		if s, ok := strings.CutPrefix(strings.TrimSpace(digest), "sha-256="); ok {
+			return decodeSum(strings.Trim(s, ":"))
+		}
+	}
+	return nil, nil
+}
+
+func decodeSum(s string) ([]byte, error) {
+	sum, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, ErrChecksum
+	}
+	return sum, nil
+}
+
+func (l *Loader) client() *http.Client {
+	if l.Client != nil {
+		return l.Client
+	}
+	return http.DefaultClient
+}
+
+// Run loads the filter and then loads it again every Interval until ctx is done, passing
+// errors to OnError, and returns the error of ctx.
+func (l *Loader) Run(ctx context.Context) error {
+	interval := l.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := l.Load(ctx); err != nil && l.OnError != nil && ctx.Err() == nil {
+			l.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package bloomhttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+)
+
+// objectStore serves a filter like an object store: with an ETag, answering If-None-Match,
+// and with the checksum header of S3 if checksum is set.
+type objectStore struct {
+	mu       sync.Mutex
+	data     []byte
+	checksum string
+}
+
+func (s *objectStore) put(t *testing.T, keys ...string) {
+	f := bloom.New(1e3, 1e-3, bloom.DefaultHash).(*bloom.ClassicFilter)
+	for _, k := range keys {
+		f.Add([]byte(k))
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data, s.checksum = data, base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := sha256.Sum256(s.data)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("X-Amz-Checksum-Mode") == "ENABLED" {
+		w.Header().Set("X-Amz-Checksum-Sha256", s.checksum)
+	}
+	w.Write(s.data)
+}
+
+func TestLoader(t *testing.T) {
+	store := &objectStore{}
+	store.put(t, "evil.example")
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	l := NewLoader(srv.URL+"/blocklist.bloom", nil)
+	if l.Test([]byte("evil.example")) || l.Filter() != nil {
+		t.Fatal("Nothing should be found before the filter is loaded")
+	}
+	if changed, err := l.Load(context.Background()); err != nil || !changed {
+		t.Fatal("The filter should be loaded, but got", changed, err)
+	}
+	if !l.Test([]byte("evil.example")) || l.Test([]byte("good.example")) {
+		t.Fatal("The loaded filter should hold its entries only")
+	}
+	if changed, err := l.Load(context.Background()); err != nil || changed {
+		t.Fatal("An unchanged filter should not be loaded again, but got", changed, err)
+	}
+
+	store.put(t, "evil.example", "worse.example")
+	if changed, err := l.Load(context.Background()); err != nil || !changed || !l.Test([]byte("worse.example")) {
+		t.Fatal("A changed filter should be swapped in, but got", changed, err)
+	}
+
+	store.mu.Lock()
+	store.checksum = base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	store.data = append(store.data[:len(store.data):len(store.data)], 0)
+	store.mu.Unlock()
+	if _, err := l.Load(context.Background()); err != ErrChecksum {
+		t.Fatal("A filter not matching its checksum should be rejected, but got", err)
+	}
+	if !l.Test([]byte("worse.example")) {
+		t.Fatal("A failed load should keep the filter in service")
+	}
+}
+
+func TestLoader_ChecksumURL(t *testing.T) {
+	store := &objectStore{}
+	store.put(t, "evil.example")
+	var sum string
+	mux := http.NewServeMux()
+	mux.Handle("/f", store)
+	mux.HandleFunc("/f.sha256", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(sum + "  f\n")) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	l := NewLoader(srv.URL+"/f", nil)
+	l.ChecksumURL = srv.URL + "/f.sha256"
+	store.mu.Lock()
+	digest := sha256.Sum256(store.data)
+	store.mu.Unlock()
+	sum = hex.EncodeToString(digest[:])
+	if _, err := l.Load(context.Background()); err != nil || !l.Test([]byte("evil.example")) {
+		t.Fatal("A filter matching the checksum file should be loaded, but got", err)
+	}
+	store.put(t, "other.example")
+	if _, err := l.Load(context.Background()); err != ErrChecksum {
+		t.Fatal("The checksum file should take precedence over the header, but got", err)
+	}
+	sum = "nope"
+	if _, err := l.Load(context.Background()); err == nil {
+		t.Fatal("A checksum file without a SHA-256 should fail the load")
+	}
+}
+
+func TestLoader_Run(t *testing.T) {
+	store := &objectStore{}
+	store.put(t, "a")
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	decodeErr := errors.New("bad filter")
+	var failing atomic.Bool
+	l := NewLoader(srv.URL, func(b []byte) (bloom.Filter, error) {
+		if failing.Load() {
+			return nil, decodeErr
+		}
+		f := &bloom.ClassicFilter{H: bloom.DefaultHash}
+		return f, f.UnmarshalBinary(b)
+	})
+	l.Interval = time.Millisecond
+	errs := make(chan error, 100)
+	l.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.Run(ctx) }()
+
+	for !l.Test([]byte("a")) {
+		time.Sleep(time.Millisecond)
+	}
+	failing.Store(true)
+	store.put(t, "b")
+	if err := <-errs; err != decodeErr {
+		t.Fatal("Run should report the errors of loading the filter, but got", err)
+	}
+	failing.Store(false)
+	for !l.Test([]byte("b")) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal("Run should return the error of its context, but got", err)
+	}
+}
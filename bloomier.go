@@ -0,0 +1,94 @@
+package bloom
+
+import (
+	"errors"
+	"sort"
+)
+
+// Bloomier Filter
+//
+// A bloomier filter is an immutable map from a known set of keys to small
+// values. Like a xor filter it stores a word in each of about 1.23 slots per
+// key such that the xor of the three slots of a key is its fingerprint and
+// value, taking V+F bits per slot. Query returns the value of a key, and
+// reports false for all but a fraction of 2^-F of the other keys, which get
+// an arbitrary value instead.
+// See Chazelle et al., "The Bloomier Filter: An Efficient Data Structure for
+// Static Support Lookup Tables" (2004).
+type BloomierFilter struct {
+	T    []uint64 // packed slots of F+V bits, three blocks of BlockLength slots
+	V    uint     // value size in bits, 1 to 16
+	F    uint     // fingerprint size in bits
+	N    uint32   // number of slots in a block
+	Seed uint64
+	H    func([]byte) (uint64, uint64)
+}
+
+// BuildBloomier builds a bloomier filter mapping the keys of m to their values,
+// which must fit in valueBits bits. Other keys are reported as missing with a
+// false positive rate of 2^-fingerprintBits.
+func BuildBloomier(m map[string]uint16, valueBits, fingerprintBits uint, h func([]byte) (uint64, uint64)) (*BloomierFilter, error) {
+	if valueBits < 1 || valueBits > 16 || fingerprintBits+valueBits > 64 {
+		return nil, errors.New("bloom: invalid bloomier filter value or fingerprint size")
+	}
+	hashes := make([]uint64, 0, len(m))
+	values := make(map[uint64]uint16, len(m))
+	for k, v := range m {
+		if v >= 1<<valueBits {
+			return nil, errors.New("bloom: bloomier filter value does not fit in value size")
+		}
+		x, _ := h([]byte(k))
+		if _, ok := values[x]; ok {
+			return nil, errors.New("bloom: hash collision between bloomier filter keys")
+		}
+		values[x] = v
+		hashes = append(hashes, x)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	f := &BloomierFilter{V: valueBits, F: fingerprintBits, H: h}
+	f.N = uint32((32+123*len(hashes)/100)/3) + 1
+	f.T = make([]uint64, packedWords(3*uint64(f.N), f.V+f.F))
+	for attempt := 0; attempt < xorMaxAttempts; attempt++ {
+		f.Seed = splitmix64(uint64(attempt))
+		order, ok := xorPeel(hashes, f.Seed, f.N)
+		if !ok {
+			continue
+		}
+		// the peeled seeded hashes are mapped back to the keys' values
+		byHash := make(map[uint64]uint16, len(hashes))
+		for _, x := range hashes {
+			byHash[splitmix64(x^f.Seed)] = values[x]
+		}
+		for _, s := range order {
+			w := f.fingerprint(s.hash)<<f.V | uint64(byHash[s.hash])
+			h0, h1, h2 := xorPositions(s.hash, f.N)
+			// the slot itself is still zero
+			w ^= f.get(h0) ^ f.get(h1) ^ f.get(h2)
+			setBits(f.T, uint64(s.index), f.V+f.F, w)
+		}
+		return f, nil
+	}
+	return nil, errors.New("bloom: failed to build bloomier filter")
+}
+
+func (f *BloomierFilter) get(i uint32) uint64 { return getBits(f.T, uint64(i), f.V+f.F) }
+
+func (f *BloomierFilter) fingerprint(hash uint64) uint64 {
+	return splitmix64(hash) & (1<<f.F - 1)
+}
+
+// Query returns the value of a key and whether the key is in the filter.
+func (f *BloomierFilter) Query(b []byte) (uint16, bool) {
+	x, _ := f.H(b)
+	hash := splitmix64(x ^ f.Seed)
+	h0, h1, h2 := xorPositions(hash, f.N)
+	w := f.get(h0) ^ f.get(h1) ^ f.get(h2)
+	if w>>f.V != f.fingerprint(hash) {
+		return 0, false
+	}
+	return uint16(w & (1<<f.V - 1)), true
+}
+
+// Size returns the size of the filter in bytes.
+func (f *BloomierFilter) Size() int { return 8 * len(f.T) }
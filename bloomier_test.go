@@ -0,0 +1,51 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBloomierFilter_Query(t *testing.T) {
+	const n = 1e4
+	m := make(map[string]uint16, n)
+	for i := 0; i < n; i++ {
+		m[fmt.Sprint(i)] = uint16(i % 4096)
+	}
+	bf, err := BuildBloomier(m, 12, 10, doubleSHA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range m {
+		got, ok := bf.Query([]byte(k))
+		if !ok || got != v {
+			t.Fatalf("%s: got %d, %v, want %d", k, got, ok, v)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if _, ok := bf.Query([]byte(fmt.Sprint(i))); ok {
+			fp++
+		}
+	}
+	fpr := float64(fp) / n
+	t.Logf("Size = %d, FPR = %.4f%%", bf.Size(), fpr*100)
+	if fpr > 2.0/1024 {
+		t.Fatalf("FPR %.4f%% is well above 2^-10", fpr*100)
+	}
+}
+
+func TestBloomierFilter_Invalid(t *testing.T) {
+	if _, err := BuildBloomier(map[string]uint16{"a": 16}, 4, 8, doubleFNV); err == nil {
+		t.Fatal("Value wider than the value size should fail")
+	}
+	if _, err := BuildBloomier(nil, 0, 8, doubleFNV); err == nil {
+		t.Fatal("Zero value size should fail")
+	}
+	bf, err := BuildBloomier(nil, 4, 8, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bf.Query([]byte("a")); ok {
+		t.Fatal("Empty filter should contain no key")
+	}
+}
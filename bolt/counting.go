@@ -0,0 +1,295 @@
+// Package bolt keeps counting Bloom filters of github.com/OperatorFoundation/go-bloom in
+// a bbolt database, so a filter survives restarts of the process that keeps it, such as
+// the replay-protection window of a server.
+package bolt
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+	"go.etcd.io/bbolt"
+)
+
+// ErrCorrupt is returned by Open for a bucket that does not hold a filter.
+var ErrCorrupt = errors.New("bloom: bucket does not hold a valid counting filter")
+
+// pageSize is the number of counters stored under one key.
+const pageSize = 4096
+
+// metaKey is the key of the geometry of the filter in its bucket; counter pages are stored
+// under their 8-byte big-endian index, which never equals it.
+var metaKey = []byte("meta")
+
+// Durable Counting Bloom Filter
+//
+// A CountingFilter is a counting Bloom filter with 8-bit saturating counters stored in a
+// bucket of a bbolt database, in pages of 4096 counters. Offsets are those of a
+// bloom.CountingFilter of the same geometry, and Counting loads the counters as one.
+//
+// Every change takes effect in one transaction, so a crash loses none of the entries
+// whose call returned and counts none twice: an entry is counted if and only if its
+// transaction committed. InsertMany and DeleteMany change a batch of entries in one
+// transaction, and changes of concurrent goroutines are batched into shared transactions
+// by bbolt. TestAndAdd tests and adds an entry in one transaction, so concurrent callers
+// adding the same entry see it as new exactly once.
+//
+// Add, Test and the other methods of bloom.Filter have no error result, so they record the
+// first error for Err, and Test reports false when it fails; Insert, Lookup and the other
+// methods returning errors return it. A CountingFilter is safe for concurrent use.
+type CountingFilter struct {
+	M uint64 // number of counters
+	K int
+	H func([]byte) (uint64, uint64)
+
+	db     *bbolt.DB
+	bucket []byte
+
+	mu  sync.Mutex
+	err error
+}
+
+// Open returns the counting filter stored in bucket of db, creating the bucket with a filter
+// that is optimal for n entries and false positive rate of p if it does not exist. A
+// filter that exists keeps the geometry it was created with. h must be the hash the filter
+// was created with.
+func Open(db *bbolt.DB, bucket string, n int, p float64, h func([]byte) (uint64, uint64)) (*CountingFilter, error) {
+	f := &CountingFilter{H: h, db: db, bucket: []byte(bucket)}
+	err := db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(f.bucket)
+		if err != nil {
+			return err
+		}
+		if meta := b.Get(metaKey); meta != nil {
+			if len(meta) != 12 {
+				return ErrCorrupt
+			}
+			f.M, f.K = binary.BigEndian.Uint64(meta), int(binary.BigEndian.Uint32(meta[8:]))
+			if f.M == 0 || f.K == 0 {
+				return ErrCorrupt
+			}
+			return nil
+		}
+		g := bloom.NewCountingWidth(n, p, 8, h)
+		f.M, f.K = g.M, g.K
+		meta := binary.BigEndian.AppendUint64(nil, f.M)
+		return b.Put(metaKey, binary.BigEndian.AppendUint32(meta, uint32(f.K)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Insert adds an entry to the filter.
+func (f *CountingFilter) Insert(b []byte) error { return f.InsertMany([][]byte{b}) }
+
+// InsertMany adds entries to the filter in one transaction.
+func (f *CountingFilter) InsertMany(keys [][]byte) error {
+	return f.db.Batch(func(tx *bbolt.Tx) error {
+		c := f.counters(tx)
+		for _, key := range keys {
+			c.add(f.offsets(key), 1)
+		}
+		return c.flush()
+	})
+}
+
+// Lookup tests if an entry is in the filter.
+func (f *CountingFilter) Lookup(b []byte) (found bool, err error) {
+	err = f.db.View(func(tx *bbolt.Tx) error {
+		found = f.counters(tx).all(f.offsets(b))
+		return nil
+	})
+	return found, err
+}
+
+// InsertAndLookup adds an entry to the filter and reports whether it was already in it, in
+// one transaction.
+func (f *CountingFilter) InsertAndLookup(b []byte) (found bool, err error) {
+	err = f.db.Batch(func(tx *bbolt.Tx) error {
+		c := f.counters(tx)
+		offsets := f.offsets(b)
+		found = c.all(offsets)
+		c.add(offsets, 1)
+		return c.flush()
+	})
+	return found, err
+}
+
+// Delete removes an entry from the filter and reports whether it was found. It leaves the
+// filter untouched if the entry is definitely not in it, and saturated counters as they are.
+func (f *CountingFilter) Delete(b []byte) (bool, error) {
+	found, err := f.DeleteMany([][]byte{b})
+	if err != nil {
+		return false, err
+	}
+	return found[0], nil
+}
+
+// DeleteMany removes entries from the filter in one transaction, as Delete does.
+func (f *CountingFilter) DeleteMany(keys [][]byte) (found []bool, err error) {
+	err = f.db.Batch(func(tx *bbolt.Tx) error {
+		c := f.counters(tx)
+		found = make([]bool, len(keys))
+		for i, key := range keys {
+			offsets := f.offsets(key)
+			if found[i] = c.all(offsets); found[i] {
+				c.add(offsets, -1)
+			}
+		}
+		return c.flush()
+	})
+	return found, err
+}
+
+func (f *CountingFilter) Add(b []byte) { f.record(f.Insert(b)) }
+
+func (f *CountingFilter) Test(b []byte) bool {
+	found, err := f.Lookup(b)
+	f.record(err)
+	return found
+}
+
+// TestAndAdd is InsertAndLookup recording its error for Err.
+func (f *CountingFilter) TestAndAdd(b []byte) bool {
+	found, err := f.InsertAndLookup(b)
+	f.record(err)
+	return found
+}
+
+// Remove is Delete recording its error for Err.
+func (f *CountingFilter) Remove(b []byte) bool {
+	found, err := f.Delete(b)
+	f.record(err)
+	return found
+}
+
+// AddMany is InsertMany recording its error for Err.
+func (f *CountingFilter) AddMany(keys [][]byte) { f.record(f.InsertMany(keys)) }
+
+// Size returns the number of bytes of the counters.
+func (f *CountingFilter) Size() int { return int(f.M) }
+
+// Reset clears the counters in one transaction, recording an error for Err.
+func (f *CountingFilter) Reset() {
+	f.record(f.db.Update(func(tx *bbolt.Tx) error {
+		meta := append([]byte(nil), tx.Bucket(f.bucket).Get(metaKey)...)
+		if err := tx.DeleteBucket(f.bucket); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(f.bucket)
+		if err != nil {
+			return err
+		}
+		return b.Put(metaKey, meta)
+	}))
+}
+
+// Err returns the first error of the methods without an error result since it was last
+// called, and clears it.
+func (f *CountingFilter) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.err
+	f.err = nil
+	return err
+}
+
+func (f *CountingFilter) record(err error) {
+	if err == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err == nil {
+		f.err = err
+	}
+}
+
+// Counting loads the counters as a bloom.CountingFilter of 8-bit counters and the same
+// entries, from one consistent view of the database.
+func (f *CountingFilter) Counting() (*bloom.CountingFilter, error) {
+	g := &bloom.CountingFilter{C: make([]byte, f.M), W: 8, M: f.M, K: f.K, H: f.H}
+	err := f.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(f.bucket).ForEach(func(k, v []byte) error {
+			if len(k) == 8 {
+				copy(g.C[min(binary.BigEndian.Uint64(k)*pageSize, f.M):], v)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// offsets returns the counter offsets of an entry, those of a bloom.CountingFilter of the
+// same geometry.
+func (f *CountingFilter) offsets(b []byte) []uint64 {
+	g := bloom.CountingFilter{W: 8, M: f.M, K: f.K, H: f.H}
+	return g.AppendOffsets(make([]uint64, 0, f.K), b)
+}
+
+// counters returns the counters of the filter in tx.
+func (f *CountingFilter) counters(tx *bbolt.Tx) *pages {
+	return &pages{b: tx.Bucket(f.bucket), m: f.M, dirty: make(map[uint64][]byte)}
+}
+
+// pages reads counter pages of a bucket in a transaction and writes those changed.
+type pages struct {
+	b     *bbolt.Bucket
+	m     uint64
+	dirty map[uint64][]byte
+}
+
+// get returns counter i.
+func (p *pages) get(i uint64) byte {
+	page, ok := p.dirty[i/pageSize]
+	if !ok {
+		page = p.b.Get(binary.BigEndian.AppendUint64(nil, i/pageSize))
+	}
+	if i%pageSize >= uint64(len(page)) {
+		return 0
+	}
+	return page[i%pageSize]
+}
+
+// all reports whether all counters at offsets are nonzero.
+func (p *pages) all(offsets []uint64) bool {
+	for _, o := range offsets {
+		if p.get(o) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds delta, 1 or -1, to the counters at offsets that are not saturated, and leaves
+// counters a -1 would take below zero at zero.
+func (p *pages) add(offsets []uint64, delta int) {
+	for _, o := range offsets {
+		page, ok := p.dirty[o/pageSize]
+		if !ok {
+			// values of bbolt are only valid in their transaction and must not be changed
+			page = make([]byte, min(pageSize, p.m-o/pageSize*pageSize))
+			copy(page, p.b.Get(binary.BigEndian.AppendUint64(nil, o/pageSize)))
+			p.dirty[o/pageSize] = page
+		}
+		if v := page[o%pageSize]; v < 255 && int(v)+delta >= 0 {
+			page[o%pageSize] = byte(int(v) + delta)
+		}
+	}
+}
+
+// flush writes the changed pages.
+func (p *pages) flush() error {
+	for i, page := range p.dirty {
+		if err := p.b.Put(binary.BigEndian.AppendUint64(nil, i), page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
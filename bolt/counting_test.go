@@ -0,0 +1,124 @@
+package bolt
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+	"go.etcd.io/bbolt"
+)
+
+func openDB(t *testing.T, path string) *bbolt.DB {
+	t.Helper()
+	db, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCountingFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.db")
+	db := openDB(t, path)
+	f, err := Open(db, "replay", 1e4, 1e-3, bloom.XXHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	if err := f.InsertMany(keys); err != nil {
+		t.Fatal(err)
+	}
+	f.Add([]byte("hello"))
+	if f.TestAndAdd([]byte("world")) || !f.TestAndAdd([]byte("world")) {
+		t.Fatal("TestAndAdd should report an entry present only once added")
+	}
+	if !f.Remove([]byte("hello")) || f.Test([]byte("hello")) || f.Remove([]byte("hello")) {
+		t.Fatal("A removed entry should be gone")
+	}
+	if err := f.Err(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// the filter survives reopening, whatever it is opened for
+	db = openDB(t, path)
+	defer db.Close()
+	g, err := Open(db, "replay", 10, 0.5, bloom.XXHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.M != f.M || g.K != f.K {
+		t.Fatal("Reopened filter should keep its geometry")
+	}
+	for i, key := range keys {
+		if !g.Test(key) {
+			t.Fatalf("%d should exist in the reopened filter but got false", i)
+		}
+	}
+
+	// the counters are those of a CountingFilter of the same entries
+	want := bloom.NewCountingWidth(1e4, 1e-3, 8, bloom.XXHash)
+	for _, key := range keys {
+		want.Add(key)
+	}
+	want.Add([]byte("world"))
+	want.Add([]byte("world"))
+	c, err := g.Counting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.C, want.C) {
+		t.Fatal("Loaded counters should be those of a CountingFilter of the same entries")
+	}
+
+	g.Reset()
+	if g.Test(keys[0]) || g.Err() != nil {
+		t.Fatal("Reset filter should be empty")
+	}
+	if h, err := Open(db, "replay", 10, 0.5, bloom.XXHash); err != nil || h.M != f.M {
+		t.Fatal("Reset filter should keep its geometry")
+	}
+}
+
+func TestCountingFilter_TestAndAddConcurrent(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "filter.db"))
+	defer db.Close()
+	f, err := Open(db, "replay", 1e3, 1e-3, bloom.XXHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var fresh atomic.Int32
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !f.TestAndAdd([]byte("nonce")) {
+				fresh.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if fresh.Load() != 1 {
+		t.Fatalf("Exactly one caller should see the entry as new but %d did", fresh.Load())
+	}
+}
+
+func TestCountingFilter_Corrupt(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "filter.db"))
+	defer db.Close()
+	db.Update(func(tx *bbolt.Tx) error {
+		b, _ := tx.CreateBucket([]byte("other"))
+		return b.Put(metaKey, []byte("nope"))
+	})
+	if _, err := Open(db, "other", 1e3, 1e-3, bloom.XXHash); err != ErrCorrupt {
+		t.Fatal("Opening a bucket of something else should fail with ErrCorrupt but got", err)
+	}
+}
@@ -0,0 +1,39 @@
+package bloom
+
+import "encoding"
+
+// The interfaces below are optional capabilities of filters beyond Filter, so generic code
+// can check what a filter supports with a type assertion. TestAndAdder and HashFilter are
+// capabilities too.
+
+// Inserter is a Filter that can report failing to add an entry, such as a full cuckoo
+// filter or a read-only classic filter.
+type Inserter interface {
+	Filter
+	Insert([]byte) error // add an entry or return why it cannot be added
+}
+
+// Remover is a Filter that can remove entries, such as a counting filter.
+type Remover interface {
+	Filter
+	Remove([]byte) bool // remove an entry and report if it was found
+}
+
+// MergeableFilter is a Filter that can add all entries of another filter of type F,
+// usually its own type, to itself.
+type MergeableFilter[F any] interface {
+	Filter
+	Merge(other F) error // add the entries of other or return why they cannot be
+}
+
+// SerializableFilter is a Filter with a binary encoding.
+type SerializableFilter interface {
+	Filter
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// ApproximateCounter is a filter that can estimate how many distinct entries it holds.
+type ApproximateCounter interface {
+	ApproximateCount() uint64
+}
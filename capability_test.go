@@ -0,0 +1,33 @@
+package bloom
+
+import "testing"
+
+func TestCapabilities(t *testing.T) {
+	for _, c := range []struct {
+		f    Filter
+		want func(Filter) bool
+	}{
+		{newClassic(100, 0.01, doubleFNV), func(f Filter) bool { _, ok := f.(Inserter); return ok }},
+		{NewCuckoo(100, 8, 4, doubleFNV), func(f Filter) bool { _, ok := f.(Inserter); return ok }},
+		{NewCounting(100, 0.01, doubleFNV), func(f Filter) bool { _, ok := f.(Remover); return ok }},
+		{NewCuckoo(100, 8, 4, doubleFNV), func(f Filter) bool { _, ok := f.(Remover); return ok }},
+		{newClassic(100, 0.01, doubleFNV), func(f Filter) bool { _, ok := f.(MergeableFilter[*ClassicFilter]); return ok }},
+		{newClassic(100, 0.01, doubleFNV), func(f Filter) bool { _, ok := f.(SerializableFilter); return ok }},
+		{newClassic(100, 0.01, doubleFNV), func(f Filter) bool { _, ok := f.(ApproximateCounter); return ok }},
+		{newClassic(100, 0.01, doubleFNV), func(f Filter) bool { _, ok := f.(TestAndAdder); return ok }},
+		{newClassic(100, 0.01, doubleFNV), func(f Filter) bool { _, ok := f.(HashFilter); return ok }},
+	} {
+		if !c.want(c.f) {
+			t.Fatalf("%T should have the capability", c.f)
+		}
+	}
+	if _, ok := Filter(NewCounting(100, 0.01, doubleFNV)).(SerializableFilter); ok {
+		t.Fatal("Counting filter should not be serializable")
+	}
+
+	var r Remover = NewCuckoo(100, 8, 4, doubleFNV)
+	r.Add([]byte("hello"))
+	if !r.Remove([]byte("hello")) || r.Test([]byte("hello")) {
+		t.Fatal("Removed entry should be gone")
+	}
+}
@@ -0,0 +1,91 @@
+package bloom
+
+import (
+	"errors"
+	"math"
+)
+
+// maxCascadeLayers caps the depth of a filter cascade.
+const maxCascadeLayers = 64
+
+// Filter Cascade
+//
+// A filter cascade represents a set exactly within a known universe made of
+// the set itself and a known exclude set. Layer 0 holds the include set,
+// layer 1 holds the excluded entries that are false positives of layer 0,
+// layer 2 the included entries that are false positives of layer 1, and so
+// on until no false positives are left. An entry of the universe is in the
+// set if the first layer it is missing from is odd, or if it is in all
+// layers and their number is odd. Entries outside of the universe still get
+// false positives like with a Bloom Filter.
+// See Larisch et al., "CRLite: A Scalable System for Pushing All TLS
+// Revocations to All Browsers" (2017).
+type FilterCascade struct {
+	L []*ClassicFilter // layers
+}
+
+// BuildCascade builds a filter cascade of the include set against the exclude set.
+// Both sets must be disjoint.
+func BuildCascade(include, exclude [][]byte, h func([]byte) (uint64, uint64)) (*FilterCascade, error) {
+	c := &FilterCascade{}
+	in, out := include, exclude
+	p := 0.5
+	if len(exclude) > 0 {
+		p = math.Min(0.5, math.Sqrt(0.5)*float64(len(include))/float64(len(exclude)))
+	}
+	for len(in) > 0 {
+		if len(c.L) == maxCascadeLayers {
+			return nil, errors.New("bloom: include and exclude sets of the cascade overlap")
+		}
+		layer := newClassic(len(in), p, layerHash(h, len(c.L)))
+		if len(layer.B) == 0 {
+			layer.B = make([]byte, 1)
+		}
+		if layer.K < 1 {
+			layer.K = 1
+		}
+		for _, b := range in {
+			layer.Add(b)
+		}
+		c.L = append(c.L, layer)
+		var fp [][]byte
+		for _, b := range out {
+			if layer.Test(b) {
+				fp = append(fp, b)
+			}
+		}
+		in, out = fp, in
+		p = 0.5
+	}
+	return c, nil
+}
+
+// layerHash returns h salted for layer i, so each layer has independent false positives.
+func layerHash(h func([]byte) (uint64, uint64), i int) func([]byte) (uint64, uint64) {
+	if i == 0 {
+		return h
+	}
+	return func(b []byte) (uint64, uint64) {
+		x, y := h(b)
+		return splitmix64(x ^ uint64(i)), splitmix64(y + uint64(i))
+	}
+}
+
+// Test tests if an entry is in the include set.
+func (c *FilterCascade) Test(b []byte) bool {
+	for i, l := range c.L {
+		if !l.Test(b) {
+			return i%2 == 1
+		}
+	}
+	return len(c.L)%2 == 1
+}
+
+// Size returns the size of the cascade in bytes.
+func (c *FilterCascade) Size() int {
+	size := 0
+	for _, l := range c.L {
+		size += l.Size()
+	}
+	return size
+}
@@ -0,0 +1,47 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFilterCascade_Exact(t *testing.T) {
+	var include, exclude [][]byte
+	for i := 0; i < 1e5; i++ {
+		k := []byte(fmt.Sprint(i))
+		if i%100 == 0 {
+			include = append(include, k)
+		} else {
+			exclude = append(exclude, k)
+		}
+	}
+	c, err := BuildCascade(include, exclude, doubleSHA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range include {
+		if !c.Test(k) {
+			t.Fatalf("%s should be included but got false", k)
+		}
+	}
+	for _, k := range exclude {
+		if c.Test(k) {
+			t.Fatalf("%s should be excluded but got true", k)
+		}
+	}
+	t.Logf("Layers = %d, Size = %d", len(c.L), c.Size())
+}
+
+func TestFilterCascade_Overlap(t *testing.T) {
+	keys := [][]byte{[]byte("a"), []byte("b")}
+	if _, err := BuildCascade(keys, keys, doubleFNV); err == nil {
+		t.Fatal("Overlapping sets should fail")
+	}
+	c, err := BuildCascade(nil, keys, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Test([]byte("a")) {
+		t.Fatal("Empty include set should contain nothing")
+	}
+}
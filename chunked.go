@@ -0,0 +1,157 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// chunkedShift is the log2 of the number of bytes of a segment of a chunked filter, 64 MiB.
+const chunkedShift = 26
+
+// Chunked Bloom Filter
+//
+// A chunked filter is a classic filter whose bit array is allocated in segments of
+// 64 MiB rather than as one slice, so that a filter of several gigabytes does not need
+// that much contiguous address space and the runtime can allocate and free it piecewise.
+// Its binary encoding is that of a ClassicFilter with the same bits.
+type ChunkedFilter struct {
+	K int
+	H func([]byte) (uint64, uint64)
+
+	segs  [][]byte
+	m     uint64 // number of bits
+	shift uint   // log2 of the segment size
+}
+
+// NewChunked creates a chunked classic Bloom Filter that is optimal for n entries and false
+// positive rate of p. Its bits are those of the filter New(n, p, h) would create.
+func NewChunked(n int, p float64, h func([]byte) (uint64, uint64)) *ChunkedFilter {
+	return newChunked(n, p, chunkedShift, h)
+}
+
+func newChunked(n int, p float64, shift uint, h func([]byte) (uint64, uint64)) *ChunkedFilter {
+	m, k := optimal(n, p)
+	f := &ChunkedFilter{K: max(int(k), 1), H: h, m: uint64(m), shift: shift}
+	f.segs = f.segments(uint64(BufferSize(n, p)))
+	return f
+}
+
+// segments returns the empty segments of a bit array of size bytes.
+func (f *ChunkedFilter) segments(size uint64) [][]byte {
+	var segs [][]byte
+	for off := uint64(0); off < size; off += 1 << f.shift {
+		segs = append(segs, make([]byte, min(1<<f.shift, size-off)))
+	}
+	return segs
+}
+
+func (f *ChunkedFilter) Add(b []byte) {
+	x, y := f.H(b)
+	probes := DoubleHashing.seq(x, nonzero(x, y))
+	for i := 0; i < f.K; i++ {
+		offset := reduceOffset(probes.next(), f.m)
+		byteOffset := offset / 8
+		f.segs[byteOffset>>f.shift][byteOffset&(1<<f.shift-1)] |= 1 << (offset % 8)
+	}
+}
+
+func (f *ChunkedFilter) Test(b []byte) bool {
+	x, y := f.H(b)
+	probes := DoubleHashing.seq(x, nonzero(x, y))
+	for i := 0; i < f.K; i++ {
+		offset := reduceOffset(probes.next(), f.m)
+		byteOffset := offset / 8
+		if f.segs[byteOffset>>f.shift][byteOffset&(1<<f.shift-1)]&(1<<(offset%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *ChunkedFilter) Size() int { return int(byteLen(f.m)) }
+
+func (f *ChunkedFilter) Reset() {
+	for _, seg := range f.segs {
+		clear(seg)
+	}
+}
+
+// Classic copies the filter into a ClassicFilter with the same bits.
+func (f *ChunkedFilter) Classic() *ClassicFilter {
+	b := make([]byte, 0, byteLen(f.m))
+	for _, seg := range f.segs {
+		b = append(b, seg...)
+	}
+	return &ClassicFilter{B: b, K: f.K, H: f.H, m: f.m}
+}
+
+// WriteTo writes the binary encoding of the filter to w, the same as the WriteTo of a
+// ClassicFilter with the same bits, one segment at a time.
+func (f *ChunkedFilter) WriteTo(w io.Writer) (int64, error) {
+	h := header{Version: formatVersion, Flags: flagChecksum, K: uint32(f.K), M: f.m}
+	n, err := w.Write(h.append(make([]byte, 0, headerSize)))
+	written := int64(n)
+	if err != nil {
+		return written, err
+	}
+	var crc uint32
+	for _, seg := range f.segs {
+		n, err := w.Write(seg)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		crc = crc32.Update(crc, crc32.IEEETable, seg)
+	}
+	n, err = w.Write(binary.LittleEndian.AppendUint32(nil, crc))
+	return written + int64(n), err
+}
+
+// ReadFrom reads a filter written by WriteTo, or by the WriteTo or MarshalBinary of a
+// ClassicFilter without a seed, deflate compression or a probe scheme other than
+// DoubleHashing, reading the bit array one segment at a time. It returns ErrInvalidEncoding
+// for other encodings and ErrCorruptFilter if the checksum does not match.
+// H must be set to the hash function the filter was built with.
+func (f *ChunkedFilter) ReadFrom(r io.Reader) (int64, error) {
+	buf := make([]byte, headerSize)
+	n, err := io.ReadFull(r, buf)
+	read := int64(n)
+	if err != nil {
+		return read, noEOF(err)
+	}
+	h, _, err := parseHeader(buf)
+	if err != nil {
+		return read, err
+	}
+	if h.Flags&^flagChecksum != 0 || h.K == 0 || h.K > maxHashes || h.M == 0 {
+		return read, ErrInvalidEncoding
+	}
+	if f.shift == 0 {
+		f.shift = chunkedShift
+	}
+	var segs [][]byte
+	var crc uint32
+	for off, size := uint64(0), byteLen(h.M); off < size; off += 1 << f.shift {
+		seg, n, err := readBits(r, nil, min(1<<f.shift, size-off))
+		read += int64(n)
+		if err != nil {
+			return read, err
+		}
+		segs = append(segs, seg)
+		crc = crc32.Update(crc, crc32.IEEETable, seg)
+	}
+	if h.Flags&flagChecksum != 0 {
+		var sum [4]byte
+		n, err := io.ReadFull(r, sum[:])
+		read += int64(n)
+		if err != nil {
+			return read, noEOF(err)
+		}
+		if crc != binary.LittleEndian.Uint32(sum[:]) {
+			return read, ErrCorruptFilter
+		}
+	}
+	f.K, f.segs, f.m = int(h.K), segs, h.M
+	return read, nil
+}
@@ -0,0 +1,65 @@
+package bloom
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestChunkedFilter(t *testing.T) {
+	f := newChunked(1e4, 1e-3, 10, doubleSHA) // segments of 1 KiB
+	c := New(1e4, 1e-3, doubleSHA).(*ClassicFilter)
+	if len(f.segs) < 2 || f.Size() != c.Size() {
+		t.Fatalf("Chunked filter should be split into segments of the size of a classic filter, has %d of %d bytes", len(f.segs), f.Size())
+	}
+	for i := 0; i < 1e4; i++ {
+		key := []byte(strconv.Itoa(i))
+		f.Add(key)
+		c.Add(key)
+	}
+	if !bytes.Equal(f.Classic().B, c.B) {
+		t.Fatal("Chunked filter should set the bits of a classic filter")
+	}
+	for i := 0; i < 1e4; i++ {
+		if !f.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Entry %d should be in the filter", i)
+		}
+	}
+	f.Reset()
+	if f.Test([]byte("0")) {
+		t.Fatal("Reset filter should be empty")
+	}
+}
+
+func TestChunkedFilter_WriteTo(t *testing.T) {
+	f := newChunked(1e4, 1e-3, 10, doubleSHA)
+	for i := 0; i < 1e3; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := f.Classic().MarshalBinary()
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatal("Chunked filter should encode like a classic filter")
+	}
+
+	g := &ChunkedFilter{H: doubleSHA, shift: 9}
+	if n, err := g.ReadFrom(bytes.NewReader(want)); err != nil || n != int64(len(want)) {
+		t.Fatalf("ReadFrom should read the whole encoding, got %d, %v", n, err)
+	}
+	if len(g.segs) <= len(f.segs) || !bytes.Equal(g.Classic().B, f.Classic().B) || !g.Test([]byte("999")) {
+		t.Fatal("Decoded filter should have the same bits")
+	}
+
+	want[len(want)-1] ^= 1
+	if _, err := g.ReadFrom(bytes.NewReader(want)); !errors.Is(err, ErrCorruptFilter) {
+		t.Fatalf("Corrupt encoding should fail with ErrCorruptFilter, got %v", err)
+	}
+	seeded, _ := NewSeeded(1e3, 1e-3, 1, doubleSHA).MarshalBinary()
+	if _, err := g.ReadFrom(bytes.NewReader(seeded)); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("Seeded encoding should fail with ErrInvalidEncoding, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+// Command bloom builds and queries Bloom filters saved in the file format of
+// github.com/OperatorFoundation/go-bloom, for shell pipelines and cron jobs.
+//
+// Usage:
+//
+//	bloom build -o filter.bloom [-n entries] [-p rate] [-m bits -k hashes] [file ...]
+//	bloom test -f filter.bloom [-v] [file ...]
+//	bloom merge -o merged.bloom filter.bloom ...
+//	bloom stats [-regions n] filter.bloom ...
+//
+// Keys are the lines of the files, or of standard input if none are given. Build sizes the
+// filter for -n entries and false positive rate -p, or for the number of keys it reads if
+// -n is not given; -m and -k give its geometry instead. Test prints the keys that may be in
+// the filter, or with -v those that are not, and like grep exits with status 1 if it
+// printed none. Merge writes the union of filters of the same geometry. Stats describes
+// filters, and with -regions the density of their set bits in that many regions, which
+// shows a hash that sets bits unevenly.
+//
+// Every command takes -hash, the name of a registered hash function: default, murmur3 or
+// xxhash. The file format does not record it, so a filter must be tested with the hash it
+// was built with.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+)
+
+// errNoMatch makes a command exit with status 1 without a message.
+var errNoMatch = errors.New("no keys printed")
+
+// errUsage makes a command exit with status 2 once its flag set has printed why.
+var errUsage = errors.New("usage")
+
+func main() {
+	out := bufio.NewWriter(os.Stdout)
+	code := (&cli{stdin: os.Stdin, stdout: out, stderr: os.Stderr}).run(os.Args[1:])
+	if err := out.Flush(); err != nil && code == 0 {
+		fmt.Fprintln(os.Stderr, "bloom:", err)
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// cli runs commands over its standard streams.
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// run runs the command of args and returns its exit status.
+func (c *cli) run(args []string) int {
+	commands := map[string]func(*cli, []string) error{
+		"build": (*cli).build,
+		"test":  (*cli).test,
+		"merge": (*cli).merge,
+		"stats": (*cli).stats,
+	}
+	if len(args) == 0 || commands[args[0]] == nil {
+		fmt.Fprintln(c.stderr, "usage: bloom build|test|merge|stats [flags] [file ...]")
+		return 2
+	}
+	switch err := commands[args[0]](c, args[1:]); {
+	case err == nil:
+		return 0
+	case errors.Is(err, errNoMatch):
+		return 1
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		fmt.Fprintln(c.stderr, "bloom:", err)
+		return 1
+	}
+}
+
+// flags returns the flag set of command name, with the -hash flag every command takes.
+func (c *cli) flags(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("bloom "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs, fs.String("hash", "default", "name of the registered hash `function`")
+}
+
+// parse parses args with fs and returns the hash function named by hash.
+func parse(fs *flag.FlagSet, args []string, hash *string) (func([]byte) (uint64, uint64), error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	h, ok := bloom.LookupHash(*hash)
+	if !ok {
+		return nil, fmt.Errorf("unknown hash %q", *hash)
+	}
+	return h, nil
+}
+
+func (c *cli) build(args []string) error {
+	fs, hash := c.flags("build")
+	out := fs.String("o", "", "`path` of the filter file to write")
+	n := fs.Int("n", 0, "number of `entries` to size the filter for, the number of keys if 0")
+	p := fs.Float64("p", 0.01, "false positive `rate` to size the filter for")
+	m := fs.Uint64("m", 0, "number of `bits` of the filter, instead of -n and -p")
+	k := fs.Int("k", 0, "number of `hashes` of the filter, with -m")
+	h, err := parse(fs, args, hash)
+	if err != nil {
+		return err
+	}
+	if *out == "" || (*m == 0) != (*k == 0) {
+		fmt.Fprintln(c.stderr, "bloom build: -o is required, and -m and -k go together")
+		return errUsage
+	}
+
+	var keys [][]byte
+	err = c.eachKey(fs.Args(), func(key []byte) { keys = append(keys, append([]byte(nil), key...)) })
+	if err != nil {
+		return err
+	}
+	var f *bloom.ClassicFilter
+	if *m != 0 {
+		f = bloom.NewWithBits(*m, *k, h)
+	} else if f, err = bloom.NewChecked(max(*n, len(keys), 1), *p, h); err != nil {
+		return err
+	}
+	f.AddMany(keys)
+	return bloom.SaveFile(*out, f)
+}
+
+func (c *cli) test(args []string) error {
+	fs, hash := c.flags("test")
+	path := fs.String("f", "", "`path` of the filter file")
+	invert := fs.Bool("v", false, "print the keys that are not in the filter")
+	h, err := parse(fs, args, hash)
+	if err != nil {
+		return err
+	}
+	if *path == "" {
+		fmt.Fprintln(c.stderr, "bloom test: -f is required")
+		return errUsage
+	}
+	f, err := load(*path, h)
+	if err != nil {
+		return err
+	}
+	printed := false
+	err = c.eachKey(fs.Args(), func(key []byte) {
+		if f.Test(key) != *invert {
+			printed = true
+			c.stdout.Write(key)
+			c.stdout.Write([]byte{'\n'})
+		}
+	})
+	if err == nil && !printed {
+		err = errNoMatch
+	}
+	return err
+}
+
+func (c *cli) merge(args []string) error {
+	fs, hash := c.flags("merge")
+	out := fs.String("o", "", "`path` of the filter file to write")
+	h, err := parse(fs, args, hash)
+	if err != nil {
+		return err
+	}
+	if *out == "" || fs.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "bloom merge: -o and at least one filter are required")
+		return errUsage
+	}
+	filters := make([]*bloom.ClassicFilter, fs.NArg())
+	for i, path := range fs.Args() {
+		if filters[i], err = load(path, h); err != nil {
+			return err
+		}
+	}
+	f, err := bloom.Union(filters...)
+	if err != nil {
+		return err
+	}
+	return bloom.SaveFile(*out, f)
+}
+
+func (c *cli) stats(args []string) error {
+	fs, hash := c.flags("stats")
+	regions := fs.Int("regions", 0, "print the density of set bits in this many `regions`")
+	h, err := parse(fs, args, hash)
+	if err != nil {
+		return err
+	}
+	for _, path := range fs.Args() {
+		f, err := load(path, h)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "%s: bits=%d hashes=%d bytes=%d probe=%v fill=%.4f entries≈%d fpr≈%.3g\n",
+			path, f.BitCount(), f.K, f.Size(), f.Probe, f.FillRatio(), f.ApproximateCount(), f.CurrentFalsePositiveRate())
+		if *regions > 0 {
+			fmt.Fprint(c.stdout, f.Bits().Distribution(*regions))
+		}
+	}
+	return nil
+}
+
+// load loads the classic filter of the file at path.
+func load(path string, h func([]byte) (uint64, uint64)) (*bloom.ClassicFilter, error) {
+	f, err := bloom.LoadFile(path, h)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f.(*bloom.ClassicFilter), nil
+}
+
+// eachKey calls fn with each line of the files at paths, or of standard input if there are
+// none. The line is only valid until fn returns.
+func (c *cli) eachKey(paths []string, fn func([]byte)) error {
+	if len(paths) == 0 {
+		return scanKeys(c.stdin, fn)
+	}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = scanKeys(file, fn)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func scanKeys(r io.Reader, fn func([]byte)) error {
+	lines := bufio.NewScanner(r)
+	lines.Buffer(nil, 1<<24)
+	for lines.Scan() {
+		fn(lines.Bytes())
+	}
+	return lines.Err()
+}
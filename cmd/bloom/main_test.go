@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCLI runs the command of args with stdin and returns its exit status and output.
+func runCLI(t *testing.T, stdin string, args ...string) (int, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	c := &cli{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}
+	code := c.run(args)
+	return code, stdout.String() + stderr.String()
+}
+
+func TestCLI(t *testing.T) {
+	dir := t.TempDir()
+	a, b, merged := filepath.Join(dir, "a.bloom"), filepath.Join(dir, "b.bloom"), filepath.Join(dir, "merged.bloom")
+
+	if code, out := runCLI(t, "alice\nbob\n", "build", "-o", a, "-m", "1024", "-k", "4"); code != 0 {
+		t.Fatal("build failed:", out)
+	}
+	keys := filepath.Join(dir, "keys.txt")
+	if err := os.WriteFile(keys, []byte("carol\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, out := runCLI(t, "", "build", "-o", b, "-m", "1024", "-k", "4", keys); code != 0 {
+		t.Fatal("build from a file failed:", out)
+	}
+
+	if code, out := runCLI(t, "alice\ncarol\nbob\n", "test", "-f", a); code != 0 || out != "alice\nbob\n" {
+		t.Fatalf("test should print the keys in the filter but exited %d with %q", code, out)
+	}
+	if code, out := runCLI(t, "alice\ncarol\n", "test", "-v", "-f", a); code != 0 || out != "carol\n" {
+		t.Fatalf("test -v should print the keys not in the filter but exited %d with %q", code, out)
+	}
+	if code, out := runCLI(t, "carol\n", "test", "-f", a); code != 1 || out != "" {
+		t.Fatalf("test printing no keys should exit 1 but exited %d with %q", code, out)
+	}
+	if code, _ := runCLI(t, "alice\n", "test", "-hash", "xxhash", "-f", a); code != 1 {
+		t.Fatal("test with another hash should not find the keys")
+	}
+
+	if code, out := runCLI(t, "", "merge", "-o", merged, a, b); code != 0 {
+		t.Fatal("merge failed:", out)
+	}
+	if code, out := runCLI(t, "alice\nbob\ncarol\n", "test", "-f", merged); code != 0 || out != "alice\nbob\ncarol\n" {
+		t.Fatalf("merged filter should hold the keys of both but exited %d with %q", code, out)
+	}
+	if code, out := runCLI(t, "", "stats", merged); code != 0 || !strings.Contains(out, "bits=1024 hashes=4 bytes=128") || !strings.Contains(out, "entries≈3") {
+		t.Fatal("stats should describe the filter but got", out)
+	}
+	if code, out := runCLI(t, "", "stats", "-regions", "4", merged); code != 0 || strings.Count(out, "\n") != 6 || !strings.Contains(out, "clustered=") {
+		t.Fatal("stats -regions should print the density of each region but got", out)
+	}
+}
+
+func TestCLI_Sizing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.bloom")
+	keys := strings.Repeat("x\n", 1000)
+	runCLI(t, keys, "build", "-o", path, "-p", "0.01")
+	_, fromKeys := runCLI(t, "", "stats", path)
+	runCLI(t, "", "build", "-o", path, "-n", "1000", "-p", "0.01")
+	_, fromN := runCLI(t, "", "stats", path)
+	if got, want := strings.Fields(fromKeys)[1], strings.Fields(fromN)[1]; got != want {
+		t.Fatalf("build without -n should size the filter for the keys read, %s, but got %s", want, got)
+	}
+}
+
+func TestCLI_Errors(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"frobnicate"},
+		{"build"},
+		{"build", "-o", "f", "-m", "64"},
+		{"test"},
+		{"merge", "-o", "f"},
+		{"stats", "-bogus"},
+	} {
+		if code, _ := runCLI(t, "", args...); code != 2 {
+			t.Errorf("%q should exit with status 2 but got %d", args, code)
+		}
+	}
+	if code, out := runCLI(t, "", "stats", "-hash", "nope", "f"); code != 1 || !strings.Contains(out, "unknown hash") {
+		t.Fatal("an unknown hash should fail but got", out)
+	}
+	if code, out := runCLI(t, "", "stats", filepath.Join(t.TempDir(), "missing")); code != 1 || !strings.Contains(out, "missing") {
+		t.Fatal("a missing filter should fail naming it but got", out)
+	}
+}
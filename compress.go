@@ -0,0 +1,72 @@
+package bloom
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// flagDeflate marks an encoding whose bit array is compressed with DEFLATE and
+// preceded by the compressed length as a little-endian uint64.
+const flagDeflate = 1
+
+// MarshalCompressed encodes the filter like MarshalBinary, with the bit array compressed
+// and the checksum taken before compression.
+// Sparse filters take a small fraction of their size. UnmarshalBinary and ReadFrom
+// decode both encodings.
+func (f *ClassicFilter) MarshalCompressed() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := f.WriteCompressedTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteCompressedTo writes the encoding of MarshalCompressed to w.
+// The bit array is compressed in memory first, so its length can precede it.
+func (f *ClassicFilter) WriteCompressedTo(w io.Writer) (int64, error) {
+	var z bytes.Buffer
+	zw, _ := flate.NewWriter(&z, flate.BestCompression)
+	zw.Write(f.B)
+	zw.Close()
+	h := f.header(flagDeflate | flagChecksum)
+	b := h.append(make([]byte, 0, headerSize+8))
+	b = binary.LittleEndian.AppendUint64(b, uint64(z.Len()))
+	n, err := w.Write(b)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := z.WriteTo(w)
+	if err != nil {
+		return int64(n) + m, err
+	}
+	c, err := w.Write(binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(f.B)))
+	return int64(n+c) + m, err
+}
+
+// readDeflated reads the compressed length and a compressed bit array of size bytes from r,
+// into bits if it has that size.
+func readDeflated(r io.Reader, bits []byte, size uint64) ([]byte, int, error) {
+	var buf [8]byte
+	n, err := io.ReadFull(r, buf[:])
+	if err != nil {
+		return nil, n, noEOF(err)
+	}
+	data, m, err := readBits(r, nil, binary.LittleEndian.Uint64(buf[:]))
+	n += m
+	if err != nil {
+		return nil, n, err
+	}
+	zr := flate.NewReader(bytes.NewReader(data))
+	bits, _, err = readBits(zr, bits, size)
+	if err != nil {
+		return nil, n, ErrInvalidEncoding
+	}
+	// the stream must end with the bit array
+	if m, err := zr.Read(buf[:1]); m != 0 || err != io.EOF {
+		return nil, n, ErrInvalidEncoding
+	}
+	return bits, n, nil
+}
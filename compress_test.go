@@ -0,0 +1,76 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestClassicFilter_MarshalCompressed(t *testing.T) {
+	bf := newClassic(1e5, 1e-3, doubleFNV)
+	for i := 0; i < 100; i++ {
+		bf.Add([]byte{byte(i)})
+	}
+	data, err := bf.MarshalCompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > len(bf.B)/10 {
+		t.Fatalf("Compressed sparse filter is %d bytes of %d", len(data), len(bf.B))
+	}
+
+	decoded := ClassicFilter{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.K != bf.K || !bytes.Equal(decoded.B, bf.B) {
+		t.Fatal("Decoded filter differs from encoded filter")
+	}
+
+	var buf bytes.Buffer
+	n, err := bf.WriteCompressedTo(&buf)
+	if err != nil || n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("WriteCompressedTo wrote %d bytes, err %v", n, err)
+	}
+	buf.WriteString("trailing")
+	decoded = ClassicFilter{}
+	if n, err := decoded.ReadFrom(&buf); err != nil || n != int64(len(data)) {
+		t.Fatalf("ReadFrom read %d bytes, err %v", n, err)
+	}
+	if !bytes.Equal(decoded.B, bf.B) || buf.String() != "trailing" {
+		t.Fatal("ReadFrom should read exactly the compressed filter")
+	}
+
+	for _, bad := range [][]byte{data[:len(data)-1], append(append([]byte(nil), data...), 0)} {
+		if err := decoded.UnmarshalBinary(bad); err == nil {
+			t.Fatal("Malformed compressed data should fail to decode")
+		}
+	}
+	binary.LittleEndian.PutUint64(data[6:], 64) // fewer bits than the stream holds
+	if err := decoded.UnmarshalBinary(data); err != ErrInvalidEncoding {
+		t.Fatalf("Extra bits error = %v, want %v", err, ErrInvalidEncoding)
+	}
+}
+
+func TestClassicFilter_UnmarshalCompressedFields(t *testing.T) {
+	bf := newClassic(1e3, 1e-3, doubleFNV)
+	bf.Add([]byte("hello"))
+	plain, _ := bf.MarshalBinary()
+	compressed, _ := bf.MarshalCompressed()
+	var decoded []ClassicFilter
+	for _, data := range [][]byte{plain, compressed} {
+		g := newClassic(10, 0.1, doubleFNV)
+		g.SetConstantTime(true)
+		if err := g.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, *g)
+	}
+	a, b := decoded[0], decoded[1]
+	if !a.constant || !b.constant || a.Capacity() != b.Capacity() || a.TargetRate() != b.TargetRate() || !a.Equal(&b) {
+		t.Fatalf("A filter should decode the same compressed or not, but got capacities %d and %d, constant time %v and %v", a.Capacity(), b.Capacity(), a.constant, b.constant)
+	}
+	if a.Capacity() == 10 {
+		t.Fatal("Decoding should replace the capacity of the filter")
+	}
+}
@@ -0,0 +1,118 @@
+package bloom
+
+// Counting Bloom Filter
+//
+// Each position of the filter is a small saturating counter instead of a
+// single bit, which makes it possible to remove entries. A counter that
+// reaches its maximum value sticks there and is never decremented again,
+// since its true count is no longer known.
+type CountingFilter struct {
+	C []byte // packed counters
+	W uint   // width of a counter in bits: 1, 2, 4 or 8
+	M uint64 // number of counters
+	K int
+	H func([]byte) (uint64, uint64)
+}
+
+// NewCounting creates a counting Bloom Filter with 4-bit counters that is optimal
+// for n entries and false positive rate of p.
+func NewCounting(n int, p float64, h func([]byte) (uint64, uint64)) *CountingFilter {
+	return NewCountingWidth(n, p, 4, h)
+}
+
+// NewCountingWidth creates a counting Bloom Filter with counters of w bits each.
+// w must be 1, 2, 4 or 8.
+func NewCountingWidth(n int, p float64, w uint, h func([]byte) (uint64, uint64)) *CountingFilter {
+	switch w {
+	case 1, 2, 4, 8:
+	default:
+		panic("bloom: counter width must be 1, 2, 4 or 8")
+	}
+	m, k := optimal(n, p)
+	return &CountingFilter{C: make([]byte, elements(m*float64(w)/8, 1)), W: w, M: uint64(m), K: int(k), H: h}
+}
+
+func (f *CountingFilter) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return (x + uint64(i)*y) % f.M
+}
+
+// AppendOffsets appends the offsets of the counters of an entry to dst and returns the
+// extended slice, for filters that keep the counters of this geometry elsewhere. It does not
+// read the counters, so C may be nil.
+func (f *CountingFilter) AppendOffsets(dst []uint64, b []byte) []uint64 {
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		dst = append(dst, f.getOffset(x, y, i))
+	}
+	return dst
+}
+
+func (f *CountingFilter) max() byte { return byte(1<<f.W - 1) }
+
+func (f *CountingFilter) get(i uint64) byte {
+	bit := i * uint64(f.W)
+	return (f.C[bit/8] >> (bit % 8)) & f.max()
+}
+
+func (f *CountingFilter) set(i uint64, v byte) {
+	if strict && v > f.max() {
+		violate(f, "counter", "counter %d overflows its %d bits with %d", i, f.W, v)
+	}
+	bit := i * uint64(f.W)
+	shift := bit % 8
+	f.C[bit/8] = f.C[bit/8]&^(f.max()<<shift) | v<<shift
+}
+
+func (f *CountingFilter) Add(b []byte) {
+	x, y := f.H(b)
+	if strict {
+		checkHash(f, x, y)
+	}
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		if v := f.get(offset); v < f.max() {
+			f.set(offset, v+1)
+		}
+	}
+}
+
+func (f *CountingFilter) Test(b []byte) bool {
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		if f.get(f.getOffset(x, y, i)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove removes an entry from the filter. It reports false and leaves the
+// filter untouched if the entry is definitely not in the filter.
+// Saturated counters are left as they are.
+func (f *CountingFilter) Remove(b []byte) bool {
+	if !f.Test(b) {
+		return false
+	}
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		if v := f.get(offset); v == 0 {
+			// an entry not added, whose probes repeat an offset
+			if strict {
+				violate(f, "counter", "counter %d underflows", offset)
+			}
+		} else if v < f.max() {
+			f.set(offset, v-1)
+		}
+	}
+	return true
+}
+
+func (f *CountingFilter) Size() int { return len(f.C) }
+
+func (f *CountingFilter) Reset() {
+	for i := range f.C {
+		f.C[i] = 0
+	}
+}
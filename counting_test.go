@@ -0,0 +1,60 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCountingFilter_Remove(t *testing.T) {
+	cf := NewCounting(1e4, 1e-4, doubleFNV)
+	buf := []byte("testing")
+	cf.Add(buf)
+	cf.Add(buf)
+	if !cf.Test(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if !cf.Remove(buf) {
+		t.Fatal("Remove of an added entry should succeed")
+	}
+	if !cf.Test(buf) {
+		t.Fatal("Entry added twice should survive a single Remove")
+	}
+	cf.Remove(buf)
+	if cf.Test(buf) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if cf.Remove(buf) {
+		t.Fatal("Remove of a missing entry should fail")
+	}
+}
+
+func TestCountingFilter_Saturation(t *testing.T) {
+	for _, w := range []uint{1, 2, 4, 8} {
+		cf := NewCountingWidth(1e3, 1e-2, w, doubleFNV)
+		buf := []byte("saturate")
+		for i := 0; i < 300; i++ {
+			cf.Add(buf)
+		}
+		for i := 0; i < 300; i++ {
+			cf.Remove(buf)
+		}
+		if !cf.Test(buf) {
+			t.Fatalf("w=%d: saturated counters should never be decremented", w)
+		}
+	}
+}
+
+func TestCountingFilter_NoFalseNegatives(t *testing.T) {
+	cf := NewCounting(1e4, 1e-3, doubleFNV)
+	for i := 0; i < 1e4; i++ {
+		cf.Add([]byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < 1e4; i += 2 {
+		cf.Remove([]byte(fmt.Sprint(i)))
+	}
+	for i := 1; i < 1e4; i += 2 {
+		if !cf.Test([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package bloom
+
+import (
+	"bytes"
+	"math"
+	"sort"
+)
+
+// Count-Min Sketch
+//
+// A count-min sketch keeps D rows of W counters and estimates the count of a
+// key as the minimum of its counter in each row. Estimates are never below
+// the true count and exceed it by at most epsilon times the total count with
+// probability 1-delta. With conservative update only the counters at the
+// current minimum are raised, which reduces overestimates.
+// See Cormode and Muthukrishnan, "An Improved Data Stream Summary: The Count-Min
+// Sketch and its Applications" (2005).
+type CountMinSketch struct {
+	C            []uint64 // D rows of W counters
+	W            uint64   // counters per row
+	D            int      // number of rows
+	H            func([]byte) (uint64, uint64)
+	Conservative bool // use conservative update
+
+	total uint64
+	topK  int
+	top   []HeavyHitter
+}
+
+// HeavyHitter is a key with its estimated count.
+type HeavyHitter struct {
+	Key   []byte
+	Count uint64
+}
+
+// NewCountMin creates a count-min sketch whose estimates exceed the true count by at most
+// epsilon times the total count with probability 1-delta.
+func NewCountMin(epsilon, delta float64, h func([]byte) (uint64, uint64)) *CountMinSketch {
+	w := uint64(math.Ceil(math.E / epsilon))
+	d := int(math.Ceil(math.Log(1 / delta)))
+	if d < 1 {
+		d = 1
+	}
+	return &CountMinSketch{C: make([]uint64, w*uint64(d)), W: w, D: d, H: h}
+}
+
+func (s *CountMinSketch) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return uint64(i)*s.W + (x+uint64(i)*y)%s.W
+}
+
+func (s *CountMinSketch) estimate(x, y uint64) uint64 {
+	min := uint64(math.MaxUint64)
+	for i := 0; i < s.D; i++ {
+		if c := s.C[s.getOffset(x, y, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// AddCount adds c occurrences of a key to the sketch.
+func (s *CountMinSketch) AddCount(b []byte, c uint64) {
+	x, y := s.H(b)
+	s.total += c
+	if s.Conservative {
+		v := s.estimate(x, y) + c
+		for i := 0; i < s.D; i++ {
+			if offset := s.getOffset(x, y, i); s.C[offset] < v {
+				s.C[offset] = v
+			}
+		}
+	} else {
+		for i := 0; i < s.D; i++ {
+			s.C[s.getOffset(x, y, i)] += c
+		}
+	}
+	if s.topK > 0 {
+		s.track(b, s.estimate(x, y))
+	}
+}
+
+// Estimate returns an estimate of the count of a key.
+func (s *CountMinSketch) Estimate(b []byte) uint64 {
+	x, y := s.H(b)
+	return s.estimate(x, y)
+}
+
+// Total returns the total count added to the sketch.
+func (s *CountMinSketch) Total() uint64 { return s.total }
+
+// TrackTop makes the sketch keep track of the k keys with the highest estimated
+// counts added from now on.
+func (s *CountMinSketch) TrackTop(k int) {
+	s.topK = k
+	s.top = nil
+}
+
+func (s *CountMinSketch) track(b []byte, count uint64) {
+	min := -1
+	for i := range s.top {
+		if bytes.Equal(s.top[i].Key, b) {
+			s.top[i].Count = count
+			return
+		}
+		if min < 0 || s.top[i].Count < s.top[min].Count {
+			min = i
+		}
+	}
+	key := append([]byte(nil), b...)
+	if len(s.top) < s.topK {
+		s.top = append(s.top, HeavyHitter{key, count})
+	} else if count > s.top[min].Count {
+		s.top[min] = HeavyHitter{key, count}
+	}
+}
+
+// Top returns the tracked keys with the highest estimated counts, highest first.
+func (s *CountMinSketch) Top() []HeavyHitter {
+	top := append([]HeavyHitter(nil), s.top...)
+	sort.Slice(top, func(i, j int) bool { return top[i].Count > top[j].Count })
+	return top
+}
+
+// HeavyHitters returns the tracked keys whose estimated count is at least
+// phi times the total count, highest first.
+func (s *CountMinSketch) HeavyHitters(phi float64) []HeavyHitter {
+	var hh []HeavyHitter
+	for _, h := range s.Top() {
+		if float64(h.Count) >= phi*float64(s.total) {
+			hh = append(hh, h)
+		}
+	}
+	return hh
+}
+
+// Add adds a single occurrence of a key to the sketch.
+func (s *CountMinSketch) Add(b []byte) { s.AddCount(b, 1) }
+
+// Test tests if a key may have been added to the sketch.
+func (s *CountMinSketch) Test(b []byte) bool { return s.Estimate(b) > 0 }
+
+func (s *CountMinSketch) Size() int { return 8 * len(s.C) }
+
+// Halve halves every count, rounding down, so counts from long ago weigh less than recent
+// ones, as TinyLFU ages its frequencies. Tracked keys with a count of 1 are dropped.
+func (s *CountMinSketch) Halve() {
+	for i := range s.C {
+		s.C[i] >>= 1
+	}
+	s.total >>= 1
+	top := s.top[:0]
+	for _, h := range s.top {
+		if h.Count >>= 1; h.Count > 0 {
+			top = append(top, h)
+		}
+	}
+	s.top = top
+}
+
+func (s *CountMinSketch) Reset() {
+	for i := range s.C {
+		s.C[i] = 0
+	}
+	s.total = 0
+	s.top = nil
+}
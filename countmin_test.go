@@ -0,0 +1,74 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCountMinSketch_Estimate(t *testing.T) {
+	const (
+		epsilon = 1e-3
+		delta   = 1e-3
+	)
+	for _, conservative := range []bool{false, true} {
+		s := NewCountMin(epsilon, delta, doubleSHA)
+		s.Conservative = conservative
+		for i := 0; i < 1e4; i++ {
+			s.AddCount([]byte(fmt.Sprint(i)), uint64(i%7+1))
+		}
+		bound := uint64(epsilon * float64(s.Total()))
+		over := uint64(0)
+		for i := 0; i < 1e4; i++ {
+			want := uint64(i%7 + 1)
+			got := s.Estimate([]byte(fmt.Sprint(i)))
+			if got < want {
+				t.Fatalf("%d: estimate %d is below the true count %d", i, got, want)
+			}
+			if got-want > over {
+				over = got - want
+			}
+		}
+		t.Logf("Conservative = %v, max overestimate = %d, bound = %d", conservative, over, bound)
+		if over > bound {
+			t.Fatalf("Overestimate %d is above the bound %d", over, bound)
+		}
+	}
+}
+
+func TestCountMinSketch_HeavyHitters(t *testing.T) {
+	s := NewCountMin(1e-3, 1e-3, doubleFNV)
+	s.TrackTop(3)
+	for i := 0; i < 1e4; i++ {
+		s.Add([]byte(fmt.Sprint(i)))
+		if i%10 == 0 {
+			s.Add([]byte("heavy"))
+		}
+		if i%20 == 0 {
+			s.Add([]byte("medium"))
+		}
+	}
+	hh := s.HeavyHitters(0.01)
+	if len(hh) != 2 || string(hh[0].Key) != "heavy" || string(hh[1].Key) != "medium" {
+		t.Fatalf("Unexpected heavy hitters %+v", hh)
+	}
+	if hh[0].Count < 1000 {
+		t.Fatalf("heavy should have a count of at least 1000 but got %d", hh[0].Count)
+	}
+}
+
+func TestCountMinSketch_Halve(t *testing.T) {
+	s := NewCountMin(1e-3, 1e-3, doubleFNV)
+	s.TrackTop(2)
+	s.AddCount([]byte("a"), 9)
+	s.Add([]byte("b"))
+	s.Halve()
+	if got := s.Estimate([]byte("a")); got != 4 {
+		t.Errorf("Estimate after Halve = %d, want 4", got)
+	}
+	if s.Test([]byte("b")) || s.Total() != 5 {
+		t.Errorf("Halve should drop a count of 1 but got %d of a total of %d", s.Estimate([]byte("b")), s.Total())
+	}
+	if top := s.Top(); len(top) != 1 || string(top[0].Key) != "a" || top[0].Count != 4 {
+		t.Errorf("Top after Halve = %+v", top)
+	}
+}
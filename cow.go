@@ -0,0 +1,120 @@
+package bloom
+
+// cowChunkSize is the number of bytes of the bit array copied at a time.
+const cowChunkSize = 4096
+
+// Copy-on-write Bloom Filter
+//
+// A copy-on-write filter is a classic filter whose bit array is split into
+// chunks that are shared with its snapshots. Taking a snapshot copies only
+// the chunk table; the writer copies a chunk the first time it changes it
+// after a snapshot, so snapshots stay unchanged and can be queried by any
+// number of goroutines while the writer goes on adding entries.
+type CopyOnWriteFilter struct {
+	K int
+	H func([]byte) (uint64, uint64)
+
+	chunks   []*cowChunk
+	m        uint64 // number of bits
+	gen      uint64 // generation of the chunks the filter owns
+	readOnly bool
+}
+
+// cowChunk is a chunk of the bit array and the generation of the filter that owns it.
+type cowChunk struct {
+	gen uint64
+	b   []byte
+}
+
+// NewCopyOnWrite creates a copy-on-write classic Bloom Filter that is optimal for n entries and
+// false positive rate of p. Add, Reset and Snapshot must be called by one goroutine at a time.
+func NewCopyOnWrite(n int, p float64, h func([]byte) (uint64, uint64)) *CopyOnWriteFilter {
+	m, k := optimal(n, p)
+	size := BufferSize(n, p)
+	f := &CopyOnWriteFilter{K: int(k), H: h, m: uint64(m)}
+	for off := 0; off < size; off += cowChunkSize {
+		f.chunks = append(f.chunks, &cowChunk{b: make([]byte, min(cowChunkSize, size-off))})
+	}
+	return f
+}
+
+// Snapshot returns a read-only view of the filter as it is now, which later changes to the
+// filter do not affect. Add and Reset panic on it with ErrReadOnly.
+func (f *CopyOnWriteFilter) Snapshot() *CopyOnWriteFilter {
+	if f.readOnly {
+		return f
+	}
+	s := *f
+	s.chunks = append([]*cowChunk(nil), f.chunks...)
+	s.readOnly = true
+	f.gen++ // every chunk is shared now
+	return &s
+}
+
+// ReadOnly reports whether the filter is a snapshot.
+func (f *CopyOnWriteFilter) ReadOnly() bool { return f.readOnly }
+
+func (f *CopyOnWriteFilter) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return (x + uint64(i)*y) % f.m
+}
+
+func (f *CopyOnWriteFilter) Add(b []byte) {
+	if f.readOnly {
+		panic(ErrReadOnly)
+	}
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		byteOffset := offset / 8
+		c := f.chunks[byteOffset/cowChunkSize]
+		mask := byte(1) << (offset % 8)
+		if c.b[byteOffset%cowChunkSize]&mask != 0 {
+			continue
+		}
+		if c.gen != f.gen {
+			c = &cowChunk{gen: f.gen, b: append([]byte(nil), c.b...)}
+			f.chunks[byteOffset/cowChunkSize] = c
+		}
+		c.b[byteOffset%cowChunkSize] |= mask
+	}
+}
+
+func (f *CopyOnWriteFilter) Test(b []byte) bool {
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		byteOffset := offset / 8
+		if f.chunks[byteOffset/cowChunkSize].b[byteOffset%cowChunkSize]&(1<<(offset%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *CopyOnWriteFilter) Size() int { return int(byteLen(f.m)) }
+
+// Reset resets the filter to initial state, replacing shared chunks rather than clearing them.
+func (f *CopyOnWriteFilter) Reset() {
+	if f.readOnly {
+		panic(ErrReadOnly)
+	}
+	for i, c := range f.chunks {
+		if c.gen != f.gen {
+			f.chunks[i] = &cowChunk{gen: f.gen, b: make([]byte, len(c.b))}
+			continue
+		}
+		for j := range c.b {
+			c.b[j] = 0
+		}
+	}
+}
+
+// Classic copies the filter into a ClassicFilter with the same bits.
+func (f *CopyOnWriteFilter) Classic() *ClassicFilter {
+	b := make([]byte, 0, byteLen(f.m))
+	for _, c := range f.chunks {
+		b = append(b, c.b...)
+	}
+	return &ClassicFilter{B: b, K: f.K, H: f.H, m: f.m}
+}
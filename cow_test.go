@@ -0,0 +1,58 @@
+package bloom
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCopyOnWriteFilter(t *testing.T) {
+	f := NewCopyOnWrite(1e5, 1e-3, doubleSHA)
+	f.Add([]byte("hello"))
+	s := f.Snapshot()
+
+	// readers query the snapshot while the writer goes on
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if !s.Test([]byte("hello")) {
+					t.Error("Should exist in snapshot but got false")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	wg.Wait()
+
+	if !f.Test([]byte("hello")) || !f.Test([]byte("999")) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if s.Test([]byte("999")) && s.Test([]byte("998")) && s.Test([]byte("997")) {
+		t.Fatal("Snapshot should not see entries added after it")
+	}
+	s2 := f.Snapshot()
+	f.Reset()
+	if f.Test([]byte("hello")) || !s2.Test([]byte("999")) || !s.Test([]byte("hello")) {
+		t.Fatal("Reset should not affect snapshots")
+	}
+
+	ref := newClassic(1e5, 1e-3, doubleSHA)
+	ref.Add([]byte("hello"))
+	if got := s.Classic(); !bytes.Equal(got.B, ref.B) {
+		t.Fatal("Snapshot bits differ from a classic filter with the same entries")
+	}
+
+	defer func() {
+		if recover() != ErrReadOnly {
+			t.Fatal("Add to a snapshot should panic with ErrReadOnly")
+		}
+	}()
+	s.Add([]byte("nope"))
+}
@@ -0,0 +1,220 @@
+package bloom
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// ErrFull is returned when an entry cannot be inserted because the filter is full.
+var ErrFull = errors.New("bloom: filter is full")
+
+// MaxLoadFactor is the load factor a cuckoo filter with the given bucket size
+// can reliably reach before insertions start to fail.
+func MaxLoadFactor(bucketSize int) float64 {
+	switch {
+	case bucketSize <= 1:
+		return 0.5
+	case bucketSize == 2:
+		return 0.84
+	case bucketSize < 8:
+		return 0.95
+	default:
+		return 0.98
+	}
+}
+
+// maxKicks is the number of relocations tried before an insertion fails.
+const maxKicks = 500
+
+// Cuckoo Filter
+//
+// A cuckoo filter stores a small fingerprint of each entry in one of two
+// candidate buckets, relocating existing fingerprints cuckoo-style to make
+// room. Unlike a Bloom Filter it supports true deletion and is smaller at low
+// false positive rates. The false positive rate is about 2*bucketSize/2^bits.
+// See Fan et al., "Cuckoo Filter: Practically Better Than Bloom" (2014).
+type CuckooFilter struct {
+	T []byte // packed fingerprints, 0 marks an empty slot
+	F uint   // fingerprint size in bits, 1 to 16
+	N int    // bucket size, number of fingerprints per bucket
+	H func([]byte) (uint64, uint64)
+
+	mask   uint64 // number of buckets minus one
+	count  int
+	victim uint64 // index of the fingerprint that could not be placed
+	vfp    uint16 // fingerprint that could not be placed, 0 if none
+	rand   *rand.Rand
+}
+
+// NewCuckoo creates a cuckoo filter for n entries using fingerprints of bits bits
+// and buckets of bucketSize fingerprints.
+func NewCuckoo(n int, bits uint, bucketSize int, h func([]byte) (uint64, uint64)) *CuckooFilter {
+	if bits < 1 || bits > 16 {
+		panic("bloom: fingerprint size must be between 1 and 16 bits")
+	}
+	if bucketSize < 1 {
+		panic("bloom: bucket size must be positive")
+	}
+	want := uint64(float64(n)/float64(bucketSize)/MaxLoadFactor(bucketSize)) + 1
+	buckets := uint64(1)
+	for buckets < want {
+		buckets <<= 1
+	}
+	slots := buckets * uint64(bucketSize)
+	return &CuckooFilter{
+		T:    make([]byte, (slots*uint64(bits)+7)/8),
+		F:    bits,
+		N:    bucketSize,
+		H:    h,
+		mask: buckets - 1,
+		rand: rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+func (f *CuckooFilter) get(slot uint64) uint16 {
+	bit := slot * uint64(f.F)
+	var w uint32
+	for i := uint64(0); i < 3 && bit/8+i < uint64(len(f.T)); i++ {
+		w |= uint32(f.T[bit/8+i]) << (8 * i)
+	}
+	return uint16(w>>(bit%8)) & (1<<f.F - 1)
+}
+
+func (f *CuckooFilter) set(slot uint64, fp uint16) {
+	bit := slot * uint64(f.F)
+	shift := bit % 8
+	mask := uint32(1<<f.F-1) << shift
+	v := uint32(fp) << shift
+	for i := uint64(0); i < 3 && bit/8+i < uint64(len(f.T)); i++ {
+		b := &f.T[bit/8+i]
+		*b = *b&^byte(mask>>(8*i)) | byte(v>>(8*i))
+	}
+}
+
+// locate returns the fingerprint and the first candidate bucket of an entry.
+func (f *CuckooFilter) locate(b []byte) (uint16, uint64) {
+	x, y := f.H(b)
+	fp := uint16(y%(1<<f.F-1)) + 1
+	return fp, x & f.mask
+}
+
+// alt returns the other candidate bucket of a fingerprint stored in bucket i.
+func (f *CuckooFilter) alt(i uint64, fp uint16) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & f.mask
+}
+
+func (f *CuckooFilter) insertInto(i uint64, fp uint16) bool {
+	for s := uint64(0); s < uint64(f.N); s++ {
+		if slot := i*uint64(f.N) + s; f.get(slot) == 0 {
+			f.set(slot, fp)
+			return true
+		}
+	}
+	return false
+}
+
+func (f *CuckooFilter) deleteFrom(i uint64, fp uint16) bool {
+	for s := uint64(0); s < uint64(f.N); s++ {
+		if slot := i*uint64(f.N) + s; f.get(slot) == fp {
+			f.set(slot, 0)
+			return true
+		}
+	}
+	return false
+}
+
+func (f *CuckooFilter) contains(i uint64, fp uint16) bool {
+	for s := uint64(0); s < uint64(f.N); s++ {
+		if f.get(i*uint64(f.N)+s) == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// Insert adds an entry to the filter and returns ErrFull if there is no room left for it.
+func (f *CuckooFilter) Insert(b []byte) error {
+	if f.vfp != 0 {
+		return ErrFull
+	}
+	fp, i := f.locate(b)
+	if f.insertInto(i, fp) || f.insertInto(f.alt(i, fp), fp) {
+		f.count++
+		return nil
+	}
+	if f.rand.Intn(2) == 1 {
+		i = f.alt(i, fp)
+	}
+	for kick := 0; kick < maxKicks; kick++ {
+		slot := i*uint64(f.N) + uint64(f.rand.Intn(f.N))
+		old := f.get(slot)
+		f.set(slot, fp)
+		fp = old
+		i = f.alt(i, fp)
+		if f.insertInto(i, fp) {
+			f.count++
+			return nil
+		}
+	}
+	// keep the last evicted fingerprint so no entry is lost
+	f.vfp, f.victim = fp, i
+	f.count++
+	return ErrFull
+}
+
+// Add adds an entry to the filter. Use Insert to find out whether the entry fit.
+func (f *CuckooFilter) Add(b []byte) { _ = f.Insert(b) }
+
+func (f *CuckooFilter) Test(b []byte) bool {
+	fp, i := f.locate(b)
+	j := f.alt(i, fp)
+	if f.vfp == fp && (f.victim == i || f.victim == j) {
+		return true
+	}
+	return f.contains(i, fp) || f.contains(j, fp)
+}
+
+// Remove is Delete, so a cuckoo filter is a Remover.
+func (f *CuckooFilter) Remove(b []byte) bool { return f.Delete(b) }
+
+// Delete removes an entry from the filter and reports whether it was found.
+// Only delete entries that were added before, or unrelated entries that
+// share the fingerprint may be removed instead.
+func (f *CuckooFilter) Delete(b []byte) bool {
+	fp, i := f.locate(b)
+	j := f.alt(i, fp)
+	switch {
+	case f.deleteFrom(i, fp), f.deleteFrom(j, fp):
+	case f.vfp == fp && (f.victim == i || f.victim == j):
+		f.vfp = 0
+		f.count--
+		return true
+	default:
+		return false
+	}
+	f.count--
+	if f.vfp != 0 {
+		// there is room now, try to place the victim again
+		if f.insertInto(f.victim, f.vfp) || f.insertInto(f.alt(f.victim, f.vfp), f.vfp) {
+			f.vfp = 0
+		}
+	}
+	return true
+}
+
+// Count returns the number of entries in the filter.
+func (f *CuckooFilter) Count() int { return f.count }
+
+// LoadFactor returns the fraction of occupied slots.
+func (f *CuckooFilter) LoadFactor() float64 {
+	return float64(f.count) / float64((f.mask+1)*uint64(f.N))
+}
+
+func (f *CuckooFilter) Size() int { return len(f.T) }
+
+func (f *CuckooFilter) Reset() {
+	for i := range f.T {
+		f.T[i] = 0
+	}
+	f.count, f.vfp = 0, 0
+}
@@ -0,0 +1,73 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCuckooFilter_Delete(t *testing.T) {
+	cf := NewCuckoo(1e4, 12, 4, doubleFNV)
+	buf := []byte("testing")
+	if err := cf.Insert(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !cf.Test(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if !cf.Delete(buf) {
+		t.Fatal("Delete of an added entry should succeed")
+	}
+	if cf.Test(buf) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if cf.Delete(buf) {
+		t.Fatal("Delete of a missing entry should fail")
+	}
+}
+
+func TestCuckooFilter_Fill(t *testing.T) {
+	for _, tc := range []struct {
+		bits       uint
+		bucketSize int
+	}{{8, 1}, {8, 2}, {12, 4}, {16, 8}, {7, 4}} {
+		const n = 1e4
+		cf := NewCuckoo(n, tc.bits, tc.bucketSize, doubleSHA)
+		for i := 0; i < n; i++ {
+			if err := cf.Insert([]byte(fmt.Sprint(i))); err != nil {
+				t.Fatalf("bits=%d, bucket=%d: insert %d failed at load %.2f: %v", tc.bits, tc.bucketSize, i, cf.LoadFactor(), err)
+			}
+		}
+		for i := 0; i < n; i++ {
+			if !cf.Test([]byte(fmt.Sprint(i))) {
+				t.Fatalf("bits=%d, bucket=%d: %d should exist in filter but got false", tc.bits, tc.bucketSize, i)
+			}
+		}
+		for i := 0; i < n; i++ {
+			if !cf.Delete([]byte(fmt.Sprint(i))) {
+				t.Fatalf("bits=%d, bucket=%d: delete %d failed", tc.bits, tc.bucketSize, i)
+			}
+		}
+		if cf.Count() != 0 {
+			t.Fatalf("bits=%d, bucket=%d: count should be 0 but got %d", tc.bits, tc.bucketSize, cf.Count())
+		}
+	}
+}
+
+func TestCuckooFilter_Full(t *testing.T) {
+	cf := NewCuckoo(100, 16, 4, doubleSHA)
+	var err error
+	i := 0
+	for ; err == nil && i < 1e4; i++ {
+		err = cf.Insert([]byte(fmt.Sprint(i)))
+	}
+	if !errors.Is(err, ErrFull) {
+		t.Fatalf("Should eventually fail with ErrFull but got %v", err)
+	}
+	t.Logf("Full after %d entries, load factor %.2f", i, cf.LoadFactor())
+	for j := 0; j < i; j++ {
+		if !cf.Test([]byte(fmt.Sprint(j))) {
+			t.Fatalf("%d should exist in filter but got false", j)
+		}
+	}
+}
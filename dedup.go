@@ -0,0 +1,54 @@
+package bloom
+
+import (
+	"context"
+	"iter"
+)
+
+// Dedup returns a sequence of the entries of seq that are not in f, adding each to f with
+// TestAndAdd as the sequence is ranged over, so a pipeline sees each record once. A false
+// positive of f drops a record seen for the first time, at the rate f was sized for. To
+// drop only the duplicates of recent records, pass a RotatingFilter, which forgets records
+// after one to two intervals, or a SaturatingFilter rotating on saturation. A concurrent
+// pipeline should pass a filter safe for concurrent use, such as a SafeFilter, whose
+// TestAndAdd lets a record through exactly once.
+func Dedup(f Filter, seq iter.Seq[[]byte]) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		for b := range seq {
+			if !TestAndAdd(f, b) && !yield(b) {
+				return
+			}
+		}
+	}
+}
+
+// DedupChan forwards the records of in that are not in f to the returned channel, as Dedup
+// does, until in is closed or ctx is done, and then closes it. The channel is unbuffered,
+// so records are read from in only as fast as they are received.
+func DedupChan(ctx context.Context, f Filter, in <-chan []byte) <-chan []byte {
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		for {
+			var b []byte
+			var ok bool
+			select {
+			case b, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			if TestAndAdd(f, b) {
+				continue
+			}
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,78 @@
+package bloom
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func records(keys ...string) [][]byte {
+	var b [][]byte
+	for _, k := range keys {
+		b = append(b, []byte(k))
+	}
+	return b
+}
+
+func TestDedup(t *testing.T) {
+	bf := New(1e3, 1e-3, doubleFNV)
+	var got []string
+	for b := range Dedup(bf, slices.Values(records("a", "b", "a", "c", "b", "d"))) {
+		got = append(got, string(b))
+	}
+	if !slices.Equal(got, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("Dedup = %v", got)
+	}
+	for range Dedup(bf, slices.Values(records("e", "f"))) {
+		break
+	}
+	if !bf.Test([]byte("e")) || bf.Test([]byte("f")) {
+		t.Error("Dedup should stop adding when the range stops")
+	}
+}
+
+func TestDedup_Window(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	rf := NewRotating(1e3, 1e-3, time.Minute, doubleFNV)
+	rf.Now = clock.Now
+	rf.Reset()
+	var got []string
+	for _, k := range []string{"a", "a", "+2m", "a", "b"} {
+		if k == "+2m" {
+			clock.Advance(2 * time.Minute)
+			continue
+		}
+		for b := range Dedup(rf, slices.Values(records(k))) {
+			got = append(got, string(b))
+		}
+	}
+	if !slices.Equal(got, []string{"a", "a", "b"}) {
+		t.Fatalf("Dedup over a rotating filter = %v, want a record again once out of the window", got)
+	}
+}
+
+func TestDedupChan(t *testing.T) {
+	in := make(chan []byte)
+	out := DedupChan(context.Background(), NewSafe(1e3, 1e-3, doubleFNV), in)
+	go func() {
+		defer close(in)
+		for _, b := range records("a", "b", "a", "b", "c") {
+			in <- b
+		}
+	}()
+	var got []string
+	for b := range out {
+		got = append(got, string(b))
+	}
+	if !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Fatalf("DedupChan = %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out = DedupChan(ctx, New(1e3, 1e-3, doubleFNV), make(chan []byte))
+	cancel()
+	if _, ok := <-out; ok {
+		t.Fatal("DedupChan should close its channel once ctx is done")
+	}
+}
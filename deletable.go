@@ -0,0 +1,108 @@
+package bloom
+
+// Deletable Bloom Filter
+//
+// A deletable filter splits its bits into R regions and keeps one extra bit
+// per region that records whether two entries ever set the same bit in it.
+// Bits in collision free regions belong to a single entry and can be reset,
+// so an entry can be removed whenever at least one of its bits lies in such
+// a region. This costs only R extra bits over a classic filter.
+// See Rothenberg et al., "The Deletable Bloom Filter: A New Member of the
+// Bloom Family" (2010).
+type DeletableFilter struct {
+	B []byte // bits
+	C []byte // collision bit of each region
+	R uint64 // number of regions
+	K int
+	H func([]byte) (uint64, uint64)
+}
+
+// NewDeletable creates a deletable Bloom Filter that is optimal for n entries and false positive
+// rate of p, with r collision regions.
+func NewDeletable(n int, p float64, r int, h func([]byte) (uint64, uint64)) *DeletableFilter {
+	m, k := optimal(n, p)
+	bits := uint64(m)
+	if r < 1 {
+		r = 1
+	}
+	if uint64(r) > bits {
+		r = int(bits)
+	}
+	return &DeletableFilter{
+		B: make([]byte, elements(m/8, 1)),
+		C: make([]byte, (r+7)/8),
+		R: uint64(r),
+		K: int(k),
+		H: h,
+	}
+}
+
+func (f *DeletableFilter) bits() uint64 { return 8 * uint64(len(f.B)) }
+
+func (f *DeletableFilter) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return (x + uint64(i)*y) % f.bits()
+}
+
+// region returns the collision region of a bit offset.
+func (f *DeletableFilter) region(offset uint64) uint64 {
+	return offset * f.R / f.bits()
+}
+
+func (f *DeletableFilter) collided(offset uint64) bool {
+	r := f.region(offset)
+	return f.C[r/8]&(1<<(r%8)) != 0
+}
+
+func (f *DeletableFilter) Add(b []byte) {
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		if f.B[offset/8]&(1<<(offset%8)) != 0 {
+			r := f.region(offset)
+			f.C[r/8] |= 1 << (r % 8)
+		}
+		f.B[offset/8] |= 1 << (offset % 8)
+	}
+}
+
+func (f *DeletableFilter) Test(b []byte) bool {
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		if f.B[offset/8]&(1<<(offset%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove removes an entry by resetting its bits in collision free regions. It
+// reports false if the entry is not in the filter or if all of its bits lie
+// in regions with collisions, in which case the filter is left untouched.
+func (f *DeletableFilter) Remove(b []byte) bool {
+	if !f.Test(b) {
+		return false
+	}
+	x, y := f.H(b)
+	removed := false
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		if !f.collided(offset) {
+			f.B[offset/8] &^= 1 << (offset % 8)
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (f *DeletableFilter) Size() int { return len(f.B) + len(f.C) }
+
+func (f *DeletableFilter) Reset() {
+	for i := range f.B {
+		f.B[i] = 0
+	}
+	for i := range f.C {
+		f.C[i] = 0
+	}
+}
@@ -0,0 +1,57 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDeletableFilter_Remove(t *testing.T) {
+	df := NewDeletable(1e4, 1e-4, 1e3, doubleFNV)
+	buf := []byte("testing")
+	df.Add(buf)
+	if !df.Remove(buf) {
+		t.Fatal("Remove from an otherwise empty filter should succeed")
+	}
+	if df.Test(buf) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if df.Remove(buf) {
+		t.Fatal("Remove of a missing entry should fail")
+	}
+}
+
+func TestDeletableFilter_NoFalseNegatives(t *testing.T) {
+	const n = 1e4
+	df := NewDeletable(n, 1e-3, 1e4, doubleSHA)
+	for i := 0; i < n; i++ {
+		df.Add([]byte(fmt.Sprint(i)))
+	}
+	removed := 0
+	for i := 0; i < n; i += 2 {
+		if df.Remove([]byte(fmt.Sprint(i))) {
+			removed++
+		}
+	}
+	t.Logf("Removed %d of %d", removed, int(n/2))
+	if removed == 0 {
+		t.Fatal("Some entries should have been removable")
+	}
+	for i := 1; i < n; i += 2 {
+		if !df.Test([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+}
+
+func TestDeletableFilter_Collided(t *testing.T) {
+	df := NewDeletable(100, 1e-2, 1, doubleFNV)
+	for i := 0; i < 100; i++ {
+		df.Add([]byte(fmt.Sprint(i)))
+	}
+	if df.Remove([]byte("1")) {
+		t.Fatal("Remove should fail when the only region has collisions")
+	}
+	if !df.Test([]byte("1")) {
+		t.Fatal("Failed Remove should leave the entry in the filter")
+	}
+}
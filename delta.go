@@ -0,0 +1,187 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"net/netip"
+	"slices"
+)
+
+// deltaReset marks a delta that clears the bit array before setting its bits.
+const deltaReset = 1
+
+// Change-tracking Bloom Filter
+//
+// A delta filter is a classic filter that records the bits newly set since
+// the last delta was encoded, so replicas can be kept in sync by applying
+// small deltas instead of receiving the whole bit array each time. A delta
+// holds the number of bits of the filter and the gaps between the offsets of
+// the set bits as varints; a delta of a filter that was reset since the last
+// one clears the replica first.
+type DeltaFilter struct {
+	*ClassicFilter
+
+	set   []uint64 // offsets of the bits set since the last delta
+	reset bool
+}
+
+// NewDelta creates a change-tracking classic filter that is optimal for n entries and false positive rate of p.
+func NewDelta(n int, p float64, h func([]byte) (uint64, uint64)) *DeltaFilter {
+	return &DeltaFilter{ClassicFilter: newClassic(n, p, h)}
+}
+
+// Add adds an entry and records the bits it sets in the next delta. It panics if the filter
+// is read-only.
+func (f *DeltaFilter) Add(b []byte) { f.testAndAdd(f.hash(b)) }
+
+// AddHash adds a key by its hash and records the bits it sets in the next delta.
+func (f *DeltaFilter) AddHash(h KeyHash) { f.testAndAdd(f.mix(h.X, h.Y)) }
+
+// TestAndAdd adds an entry and reports whether it was already in the filter, recording
+// the bits it sets in the next delta.
+func (f *DeltaFilter) TestAndAdd(b []byte) bool { return f.testAndAdd(f.hash(b)) }
+
+// AddMany adds keys like ClassicFilter.AddMany, recording the bits they set in the next delta.
+func (f *DeltaFilter) AddMany(keys [][]byte) {
+	for _, b := range keys {
+		f.Add(b)
+	}
+}
+
+// AddString adds the string s without allocating, recording the bits it sets in the next delta.
+func (f *DeltaFilter) AddString(s string) { f.Add(stringBytes(s)) }
+
+// AddUint64 adds the 64-bit integer v like ClassicFilter.AddUint64, recording the bits it
+// sets in the next delta.
+func (f *DeltaFilter) AddUint64(v uint64) { addUint64(f, v) }
+
+// AddUint32 adds the 32-bit integer v like ClassicFilter.AddUint32, recording the bits it
+// sets in the next delta.
+func (f *DeltaFilter) AddUint32(v uint32) { addUint32(f, v) }
+
+// AddIP adds the IP address a like ClassicFilter.AddIP, recording the bits it sets in the
+// next delta.
+func (f *DeltaFilter) AddIP(a netip.Addr) { addIP(f, a) }
+
+// AddFields adds a key made of fields, hashed with HashFields, recording the bits it sets in
+// the next delta.
+func (f *DeltaFilter) AddFields(fields ...[]byte) { f.AddHash(HashFields(f.H, fields...)) }
+
+// Merge adds all entries of other to the filter in place like ClassicFilter.Merge,
+// recording the bits it sets in the next delta.
+func (f *DeltaFilter) Merge(other *ClassicFilter) error {
+	n, m := len(f.set), f.BitCount()
+	if !f.readOnly && compatible(f.ClassicFilter, other) == nil && len(other.B) == len(f.B) {
+		for i, b := range other.B {
+			for set := b &^ f.B[i]; set != 0; set &= set - 1 {
+				if offset := uint64(i)*8 + uint64(bits.TrailingZeros8(set)); offset < m {
+					f.set = append(f.set, offset)
+				}
+			}
+		}
+	}
+	if err := f.ClassicFilter.Merge(other); err != nil {
+		f.set = f.set[:n]
+		return err
+	}
+	return nil
+}
+
+// testAndAdd adds an entry by its double hash like ClassicFilter.testAndAddHashes, reading
+// and setting every probe whatever their bits, and records the offsets of the bits it sets.
+func (f *DeltaFilter) testAndAdd(x, y uint64) bool {
+	if f.readOnly {
+		panic(ErrReadOnly)
+	}
+	m, probes := f.BitCount(), f.probes(x, y)
+	present := byte(1)
+	for i := 0; i < f.K; i++ {
+		offset := reduceOffset(f.Probe.finish(x, probes.next()), m)
+		if strict && !f.checkOffset(offset, m) {
+			continue
+		}
+		bit := f.B[offset/8] >> (offset % 8) & 1
+		present &= bit
+		f.B[offset/8] |= 1 << (offset % 8)
+		// keep the offset only if its bit was clear, without branching on the bit
+		f.set = append(f.set, offset)
+		f.set = f.set[:len(f.set)-int(bit)]
+	}
+	return present != 0
+}
+
+func (f *DeltaFilter) Reset() {
+	f.ClassicFilter.Reset()
+	f.set = f.set[:0]
+	f.reset = true
+}
+
+// Pending returns the number of bits set since the last delta.
+func (f *DeltaFilter) Pending() int { return len(f.set) }
+
+// EncodeDelta returns the changes since the last delta, or since the filter was created, and
+// starts recording anew. Deltas must be applied in the order they were encoded.
+func (f *DeltaFilter) EncodeDelta() []byte {
+	slices.Sort(f.set)
+	var flags byte
+	if f.reset {
+		flags = deltaReset
+	}
+	d := []byte{flags}
+	d = binary.AppendUvarint(d, f.BitCount())
+	d = binary.AppendUvarint(d, uint64(len(f.set)))
+	var last uint64
+	for _, offset := range f.set {
+		d = binary.AppendUvarint(d, offset-last)
+		last = offset
+	}
+	f.set, f.reset = f.set[:0], false
+	return d
+}
+
+// ApplyDelta sets the bits of a delta encoded by a DeltaFilter of the same size.
+// It returns ErrIncompatible if the sizes differ, and changes nothing if the delta is invalid.
+func (f *ClassicFilter) ApplyDelta(d []byte) error {
+	if f.readOnly {
+		return ErrReadOnly
+	}
+	if len(d) == 0 || d[0]&^deltaReset != 0 {
+		return ErrInvalidEncoding
+	}
+	reset := d[0] == deltaReset
+	d = d[1:]
+	m, n := binary.Uvarint(d)
+	if n <= 0 {
+		return ErrInvalidEncoding
+	}
+	if m != f.BitCount() {
+		return ErrIncompatible
+	}
+	d = d[n:]
+	count, n := binary.Uvarint(d)
+	if n <= 0 || count > uint64(len(d)) {
+		return ErrInvalidEncoding
+	}
+	d = d[n:]
+	offsets := make([]uint64, count)
+	var offset uint64
+	for i := range offsets {
+		gap, n := binary.Uvarint(d)
+		if n <= 0 || gap >= m-offset || i > 0 && gap == 0 {
+			return ErrInvalidEncoding
+		}
+		offset += gap
+		offsets[i] = offset
+		d = d[n:]
+	}
+	if len(d) != 0 {
+		return ErrInvalidEncoding
+	}
+	if reset {
+		f.Reset()
+	}
+	for _, offset := range offsets {
+		f.B[offset/8] |= 1 << (offset % 8)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package bloom
+
+import (
+	"bytes"
+	"net/netip"
+	"strconv"
+	"testing"
+)
+
+func TestDeltaFilter(t *testing.T) {
+	primary := NewDelta(1e4, 1e-3, doubleSHA)
+	replica := newClassic(1e4, 1e-3, doubleSHA)
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 100; i++ {
+			primary.Add([]byte(strconv.Itoa(round*100 + i)))
+		}
+		// adding again changes nothing
+		primary.Add([]byte(strconv.Itoa(round * 100)))
+		if n := primary.Pending(); n == 0 || n > 100*primary.K {
+			t.Fatalf("Pending = %d", n)
+		}
+		d := primary.EncodeDelta()
+		if len(d) > 2*100*primary.K {
+			t.Fatalf("Delta of %d bits is %d bytes", 100*primary.K, len(d))
+		}
+		if err := replica.ApplyDelta(d); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(replica.B, primary.B) {
+			t.Fatalf("Round %d: replica differs from primary", round)
+		}
+	}
+	if primary.Pending() != 0 {
+		t.Fatal("Encoding a delta should clear the pending changes")
+	}
+
+	primary.Reset()
+	primary.Add([]byte("hello"))
+	if err := replica.ApplyDelta(primary.EncodeDelta()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(replica.B, primary.B) {
+		t.Fatal("Reset should be replicated")
+	}
+
+	if err := newClassic(10, 0.1, doubleSHA).ApplyDelta(primary.EncodeDelta()); err != ErrIncompatible {
+		t.Fatalf("Size mismatch error = %v, want %v", err, ErrIncompatible)
+	}
+	primary.Add([]byte("world"))
+	d := primary.EncodeDelta()
+	for _, bad := range [][]byte{nil, d[:len(d)-1], append(d, 0), append([]byte{2}, d[1:]...)} {
+		if err := replica.ApplyDelta(bad); err != ErrInvalidEncoding {
+			t.Fatalf("Malformed delta error = %v, want %v", err, ErrInvalidEncoding)
+		}
+	}
+}
+
+// TestDeltaFilter_Adders checks that the ways of adding to a classic filter are recorded
+// in the delta of a DeltaFilter rather than promoted past it.
+func TestDeltaFilter_Adders(t *testing.T) {
+	other := newClassic(1e3, 1e-3, doubleSHA)
+	other.Add([]byte("a"))
+	for name, add := range map[string]func(*DeltaFilter){
+		"AddMany":   func(f *DeltaFilter) { f.AddMany([][]byte{[]byte("a"), []byte("b")}) },
+		"AddString": func(f *DeltaFilter) { f.AddString("a") },
+		"AddUint64": func(f *DeltaFilter) { f.AddUint64(1) },
+		"AddUint32": func(f *DeltaFilter) { f.AddUint32(1) },
+		"AddIP":     func(f *DeltaFilter) { f.AddIP(netip.MustParseAddr("10.0.0.1")) },
+		"AddFields": func(f *DeltaFilter) { f.AddFields([]byte("a"), []byte("b")) },
+		"Merge":     func(f *DeltaFilter) { f.Merge(other) },
+	} {
+		primary := NewDelta(1e3, 1e-3, doubleSHA)
+		replica := newClassic(1e3, 1e-3, doubleSHA)
+		add(primary)
+		if primary.Pending() == 0 {
+			t.Fatalf("%s: no bits pending", name)
+		}
+		if err := replica.ApplyDelta(primary.EncodeDelta()); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(replica.B, primary.B) {
+			t.Fatalf("%s: replica differs from primary", name)
+		}
+	}
+}
+
+func TestDeltaFilter_TestAndAdd(t *testing.T) {
+	f := NewDelta(1e3, 1e-3, doubleSHA)
+	f.SetConstantTime(true)
+	if f.TestAndAdd([]byte("a")) {
+		t.Fatal("TestAndAdd of a new entry = true")
+	}
+	n := f.Pending()
+	if n == 0 || n > f.K {
+		t.Fatalf("Pending = %d, want 1 to %d", n, f.K)
+	}
+	if !f.TestAndAdd([]byte("a")) || f.Pending() != n {
+		t.Fatalf("TestAndAdd of an entry in the filter should record nothing but Pending = %d, want %d", f.Pending(), n)
+	}
+	if err := newClassic(1e3, 1e-3, doubleSHA).ApplyDelta(f.EncodeDelta()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeltaFilter_AddFields(t *testing.T) {
+	primary := NewDelta(1e3, 1e-3, doubleSHA)
+	replica := newClassic(1e3, 1e-3, doubleSHA)
+	primary.AddFields([]byte("10.0.0.1"), []byte("443"))
+	if err := replica.ApplyDelta(primary.EncodeDelta()); err != nil {
+		t.Fatal(err)
+	}
+	if !replica.TestFields([]byte("10.0.0.1"), []byte("443")) {
+		t.Fatal("Replica should have the key added with AddFields")
+	}
+}
+
+func TestDeltaFilter_Merge(t *testing.T) {
+	f := NewDelta(1e3, 1e-3, doubleSHA)
+	f.Add([]byte("a"))
+	f.EncodeDelta()
+	o := newClassic(1e3, 1e-3, doubleSHA)
+	o.Add([]byte("a"))
+	if err := f.Merge(o); err != nil {
+		t.Fatal(err)
+	}
+	if f.Pending() != 0 {
+		t.Fatalf("Merging bits already set should record none, but %d are pending", f.Pending())
+	}
+	if err := f.Merge(newClassic(10, 0.1, doubleSHA)); err == nil || f.Pending() != 0 {
+		t.Fatalf("Merging an incompatible filter = %v with %d bits pending", err, f.Pending())
+	}
+}
@@ -0,0 +1,128 @@
+package bloom
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// suspiciousZ is the number of standard deviations from the mean density beyond which a
+// region is suspicious: uniform bits get that far once in about 1.7 million regions.
+const suspiciousZ = 5
+
+// Distribution is the density of the set bits of a bit array per region, as returned by
+// Bitset.Distribution. The bits of a filter with a good hash are set uniformly at random,
+// so each region has about the mean density; regions much denser or sparser than it show
+// a hash that maps entries unevenly, which raises the false positive rate above the one
+// the filter was sized for.
+type Distribution struct {
+	Regions []float64 // fraction of the bits set in each region, of Len/len(Regions) bits
+	Mean    float64   // fraction of all the bits set
+	StdDev  float64   // standard deviation of the densities of the regions
+
+	// Expected is the standard deviation the densities have if the bits are set uniformly
+	// at random: that of a binomial of Mean over the bits of a region.
+	Expected float64
+
+	// Suspicious are the regions whose density is more than 5 Expected from Mean.
+	Suspicious []int
+}
+
+// Distribution returns the density of set bits in each of regions regions of the bit array,
+// which it clamps to the number of bits.
+func (s Bitset) Distribution(regions int) Distribution {
+	regions = int(min(uint64(max(regions, 1)), max(s.m, 1)))
+	d := Distribution{Regions: make([]float64, regions)}
+	if s.m == 0 {
+		return d
+	}
+	var set uint64
+	counts := make([]uint64, regions)
+	for i := range counts {
+		lo, hi := s.region(i, regions)
+		counts[i] = s.onesIn(lo, hi)
+		d.Regions[i] = float64(counts[i]) / float64(hi-lo)
+		set += counts[i]
+	}
+	d.Mean = float64(set) / float64(s.m)
+	var sum float64
+	for _, r := range d.Regions {
+		sum += (r - d.Mean) * (r - d.Mean)
+	}
+	d.StdDev = math.Sqrt(sum / float64(regions))
+	d.Expected = math.Sqrt(d.Mean * (1 - d.Mean) / (float64(s.m) / float64(regions)))
+	for i, r := range d.Regions {
+		lo, hi := s.region(i, regions)
+		// the expected deviation of the region itself, whose size may differ by a bit
+		dev := math.Sqrt(d.Mean * (1 - d.Mean) / float64(hi-lo))
+		if math.Abs(r-d.Mean) > suspiciousZ*dev || (dev == 0 && r != d.Mean) {
+			d.Suspicious = append(d.Suspicious, i)
+		}
+	}
+	return d
+}
+
+// region returns the bits [lo, hi) of region i of n.
+func (s Bitset) region(i, n int) (lo, hi uint64) {
+	q, r := s.m/uint64(n), s.m%uint64(n)
+	// the first r regions have a bit more
+	lo = uint64(i)*q + min(uint64(i), r)
+	hi = lo + q
+	if uint64(i) < r {
+		hi++
+	}
+	return lo, hi
+}
+
+// onesIn returns the number of set bits in [lo, hi).
+func (s Bitset) onesIn(lo, hi uint64) uint64 {
+	var n uint64
+	for ; lo < hi && lo%8 != 0; lo++ {
+		n += uint64(s.b[lo/8] >> (lo % 8) & 1)
+	}
+	for ; lo+8 <= hi; lo += 8 {
+		n += uint64(bits.OnesCount8(s.b[lo/8]))
+	}
+	for ; lo < hi; lo++ {
+		n += uint64(s.b[lo/8] >> (lo % 8) & 1)
+	}
+	return n
+}
+
+// Clustered reports whether the set bits are unevenly distributed: some regions are
+// suspicious, or the densities vary more than those of uniform bits, by a chi-squared test
+// of their variance at 5 standard deviations.
+func (d Distribution) Clustered() bool {
+	if len(d.Suspicious) > 0 {
+		return true
+	}
+	dof := float64(len(d.Regions) - 1)
+	if dof == 0 || d.Expected == 0 {
+		return false
+	}
+	chi2 := float64(len(d.Regions)) * (d.StdDev / d.Expected) * (d.StdDev / d.Expected)
+	return chi2 > dof+suspiciousZ*math.Sqrt(2*dof)
+}
+
+// String returns a text histogram of the densities, a line per region with a bar of its
+// density, suspicious regions marked with "!", followed by a summary.
+func (d Distribution) String() string {
+	var sb strings.Builder
+	suspicious := make(map[int]bool, len(d.Suspicious))
+	for _, i := range d.Suspicious {
+		suspicious[i] = true
+	}
+	const width = 50
+	for i, r := range d.Regions {
+		mark := ' '
+		if suspicious[i] {
+			mark = '!'
+		}
+		bar := int(math.Round(r * width))
+		fmt.Fprintf(&sb, "%5d %c %.4f %s\n", i, mark, r, strings.Repeat("#", bar))
+	}
+	fmt.Fprintf(&sb, "mean=%.4f stddev=%.4g expected=%.4g suspicious=%d clustered=%t\n",
+		d.Mean, d.StdDev, d.Expected, len(d.Suspicious), d.Clustered())
+	return sb.String()
+}
@@ -0,0 +1,67 @@
+package bloom
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBitset_Distribution(t *testing.T) {
+	bf := newClassic(1e5, 1e-3, doubleSHA)
+	for i := range 100000 {
+		bf.Add([]byte(strconv.Itoa(i)))
+	}
+	d := bf.Bits().Distribution(64)
+	if len(d.Regions) != 64 {
+		t.Fatalf("Distribution should have 64 regions but got %d", len(d.Regions))
+	}
+	if d.Mean != bf.FillRatio() {
+		t.Errorf("Mean = %v, want the fill ratio %v", d.Mean, bf.FillRatio())
+	}
+	if d.Clustered() || len(d.Suspicious) != 0 {
+		t.Errorf("A good hash should not look clustered but got %v", d)
+	}
+	if d.StdDev > 2*d.Expected {
+		t.Errorf("StdDev = %v, want about Expected %v", d.StdDev, d.Expected)
+	}
+
+	// a hash that only reaches the first half of the filter, and is degenerate for strict mode
+	recordViolations(t)
+	half := func(b []byte) (uint64, uint64) {
+		x, _ := doubleSHA(b)
+		return x % (bf.BitCount() / 2), 0
+	}
+	skewed := NewWithBits(bf.BitCount(), 1, half)
+	for i := range 10000 {
+		skewed.Add([]byte(strconv.Itoa(i)))
+	}
+	d = skewed.Bits().Distribution(64)
+	if !d.Clustered() || len(d.Suspicious) != 64 {
+		t.Errorf("A hash reaching half the filter should flag every region but got %d", len(d.Suspicious))
+	}
+	if !strings.Contains(d.String(), "   32 ! 0.0000 \n") || !strings.Contains(d.String(), "clustered=true") {
+		t.Errorf("String() = %s", d)
+	}
+}
+
+func TestBitset_DistributionRegions(t *testing.T) {
+	bf := NewWithBits(100, 1, doubleSHA)
+	for i := 0; i < 100; i += 3 {
+		bf.Bits().Set(uint64(i))
+	}
+	d := bf.Bits().Distribution(7)
+	var set float64
+	for i, r := range d.Regions {
+		lo, hi := bf.Bits().region(i, 7)
+		set += r * float64(hi-lo)
+	}
+	if int(set+0.5) != bf.setBits() {
+		t.Errorf("Regions should cover each bit once, counting %v set bits of %d", set, bf.setBits())
+	}
+	if got := len(bf.Bits().Distribution(1000).Regions); got != 100 {
+		t.Errorf("Regions should be clamped to the bits but got %d", got)
+	}
+	if d := NewWithBits(64, 1, doubleSHA).Bits().Distribution(8); d.Clustered() || d.Mean != 0 {
+		t.Errorf("An empty filter should not look clustered but got %v", d)
+	}
+}
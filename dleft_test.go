@@ -0,0 +1,61 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDLeftFilter_Remove(t *testing.T) {
+	df := NewDLeft(1e4, 1e-4, doubleFNV)
+	buf := []byte("testing")
+	df.Add(buf)
+	df.Add(buf)
+	if !df.Test(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	df.Remove(buf)
+	if !df.Test(buf) {
+		t.Fatal("Entry added twice should survive a single Remove")
+	}
+	df.Remove(buf)
+	if df.Test(buf) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if df.Remove(buf) {
+		t.Fatal("Remove of a missing entry should fail")
+	}
+}
+
+func TestDLeftFilter_FalsePositive(t *testing.T) {
+	const (
+		n         = 1e5
+		expectFPR = 1e-3
+	)
+	df := NewDLeft(n, expectFPR, doubleSHA)
+	for i := 0; i < n; i++ {
+		if err := df.Insert([]byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Insert %d failed: %v", i, err)
+		}
+	}
+	for i := 0; i < n; i += 2 {
+		if !df.Remove([]byte(fmt.Sprint(i))) {
+			t.Fatalf("Remove %d failed", i)
+		}
+	}
+	for i := 1; i < n; i += 2 {
+		if !df.Test([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if df.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	fpr := float64(fp) / n
+	t.Logf("Size = %d, FP = %d, FPR = %.4f%%", df.Size(), fp, fpr*100)
+	if fpr > expectFPR {
+		t.Fatalf("FPR %.4f%% is above the target %.4f%%", fpr*100, expectFPR*100)
+	}
+}
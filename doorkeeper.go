@@ -0,0 +1,91 @@
+package bloom
+
+// Doorkeeper
+//
+// A doorkeeper is the admission front end of a TinyLFU cache: a classic filter of the
+// entries seen in the current sample, in front of an optional count-min sketch of how
+// often they were seen. The first sighting of an entry only sets its bits in the filter,
+// so the entries seen once, most of a long-tailed workload, never reach the sketch, and a
+// cache can admit an entry on its second sighting. Every Sample sightings the filter is
+// cleared and the counts of the sketch are halved, so frequencies follow the recent
+// workload. A doorkeeper is not safe for concurrent use.
+// See Einziger et al., "TinyLFU: A Highly Efficient Cache Admission Policy" (2017).
+type Doorkeeper struct {
+	Filter *ClassicFilter  // entries seen in the current sample
+	Sketch *CountMinSketch // frequencies of the entries seen again, or nil
+	Sample int             // sightings between resets
+
+	seen int // sightings in the current sample
+}
+
+// NewDoorkeeper creates a doorkeeper resetting every sample sightings, whose filter is
+// optimal for sample entries and false positive rate of p. sketch, if not nil, counts the
+// entries seen again; TinyLFU sizes it for the number of entries of the cache.
+func NewDoorkeeper(sample int, p float64, sketch *CountMinSketch, h func([]byte) (uint64, uint64)) *Doorkeeper {
+	return &Doorkeeper{Filter: newClassic(sample, p, h), Sketch: sketch, Sample: sample}
+}
+
+// Record counts a sighting of an entry and reports whether it was seen before in the
+// current sample, which admits it on its second sighting.
+func (d *Doorkeeper) Record(b []byte) bool {
+	seen := d.Filter.TestAndAdd(b)
+	if seen && d.Sketch != nil {
+		d.Sketch.Add(b)
+	}
+	if d.seen++; d.seen >= d.Sample {
+		d.age()
+	}
+	return seen
+}
+
+// age clears the filter and halves the counts of the sketch.
+func (d *Doorkeeper) age() {
+	d.seen = 0
+	d.Filter.Reset()
+	if d.Sketch != nil {
+		d.Sketch.Halve()
+	}
+}
+
+// Frequency returns the estimated number of sightings of an entry in about the last
+// sample: its count in the sketch plus one if it is in the filter. Without a sketch it is
+// 0 or 1.
+func (d *Doorkeeper) Frequency(b []byte) uint64 {
+	var n uint64
+	if d.Filter.Test(b) {
+		n = 1
+	}
+	if d.Sketch != nil {
+		n += d.Sketch.Estimate(b)
+	}
+	return n
+}
+
+// Admit reports whether a cache should admit candidate in place of victim, the entry it
+// would evict: whether candidate was seen more often.
+func (d *Doorkeeper) Admit(candidate, victim []byte) bool {
+	return d.Frequency(candidate) > d.Frequency(victim)
+}
+
+// Add counts a sighting of an entry, like Record.
+func (d *Doorkeeper) Add(b []byte) { d.Record(b) }
+
+// Test tests if an entry was seen in the current sample.
+func (d *Doorkeeper) Test(b []byte) bool { return d.Filter.Test(b) }
+
+func (d *Doorkeeper) Size() int {
+	size := d.Filter.Size()
+	if d.Sketch != nil {
+		size += d.Sketch.Size()
+	}
+	return size
+}
+
+// Reset forgets every sighting.
+func (d *Doorkeeper) Reset() {
+	d.seen = 0
+	d.Filter.Reset()
+	if d.Sketch != nil {
+		d.Sketch.Reset()
+	}
+}
@@ -0,0 +1,53 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDoorkeeper(t *testing.T) {
+	d := NewDoorkeeper(1000, 1e-3, NewCountMin(1e-3, 1e-3, doubleFNV), doubleFNV)
+	hot, cold := []byte("hot"), []byte("cold")
+	if d.Record(hot) {
+		t.Fatal("The first sighting should not admit")
+	}
+	if !d.Record(hot) {
+		t.Fatal("The second sighting should admit")
+	}
+	for range 8 {
+		d.Record(hot)
+	}
+	d.Record(cold)
+	if got := d.Frequency(hot); got != 10 {
+		t.Errorf("Frequency = %d, want 10", got)
+	}
+	if d.Sketch.Test(cold) || d.Frequency(cold) != 1 {
+		t.Error("An entry seen once should only be in the filter")
+	}
+	if !d.Admit(hot, cold) || d.Admit(cold, hot) {
+		t.Error("Admit should prefer the entry seen more often")
+	}
+
+	for i := range 1000 - 11 {
+		d.Record([]byte(fmt.Sprint(i)))
+	}
+	if d.Test(hot) || d.Test(cold) {
+		t.Error("The filter should be cleared after a sample")
+	}
+	if got := d.Frequency(hot); got != 4 {
+		t.Errorf("Frequency after a sample = %d, want the sketch halved to 4", got)
+	}
+	d.Reset()
+	if d.Frequency(hot) != 0 {
+		t.Error("Reset should forget every sighting")
+	}
+}
+
+func TestDoorkeeper_NoSketch(t *testing.T) {
+	d := NewDoorkeeper(100, 1e-2, nil, doubleFNV)
+	var f Filter = d
+	f.Add([]byte("a"))
+	if !f.Test([]byte("a")) || d.Frequency([]byte("a")) != 1 || d.Size() != d.Filter.Size() {
+		t.Error("A doorkeeper without a sketch should only have its filter")
+	}
+}
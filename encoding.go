@@ -0,0 +1,374 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// formatVersion is the version of the binary encoding of filters.
+// Encodings are little-endian on every platform, so filters written on one
+// architecture decode identically on any other.
+const formatVersion = 1
+
+// headerSize is the size of the binary encoding header: version, flags,
+// K as uint32 and the number of bits as uint64, little-endian. Seeded filters
+// follow it with their seed as uint64.
+const headerSize = 1 + 1 + 4 + 8
+
+// ErrInvalidEncoding is returned when decoding data that is not a valid encoding of a filter.
+var ErrInvalidEncoding = errors.New("bloom: invalid filter encoding")
+
+// ErrUnsupportedVersion is returned when decoding data of an unknown format version.
+var ErrUnsupportedVersion = errors.New("bloom: unsupported filter encoding version")
+
+// ErrCorruptFilter is returned when decoding or loading a filter whose checksum does not match.
+var ErrCorruptFilter = errors.New("bloom: filter checksum mismatch")
+
+// ErrUnregisteredHash is returned when gob encoding a filter whose hash function is not
+// registered, or decoding one whose hash function name is unknown.
+var ErrUnregisteredHash = errors.New("bloom: hash function is not registered")
+
+// flagChecksum marks an encoding whose bit array is followed by its CRC-32 (IEEE),
+// little-endian. Encodings without it, written before it was added, are still decoded.
+const flagChecksum = 2
+
+// flagSeeded marks an encoding of a filter with a seed, which follows the header.
+const flagSeeded = 8
+
+// maxHashes is the largest K of a decoded classic filter: more than the 1075 hashes New
+// chooses for the smallest rate there is, and few enough that an encoding crafted with a
+// larger K cannot make every Add and Test of the decoded filter take seconds.
+const maxHashes = 2048
+
+// header is the common header of encoded filters.
+type header struct {
+	Version byte
+	Flags   byte
+	K       uint32
+	M       uint64 // number of bits
+	Seed    uint64 // if Flags has flagSeeded
+}
+
+func (h header) append(b []byte) []byte {
+	b = append(b, h.Version, h.Flags)
+	b = binary.LittleEndian.AppendUint32(b, h.K)
+	b = binary.LittleEndian.AppendUint64(b, h.M)
+	if h.Flags&flagSeeded != 0 {
+		b = binary.LittleEndian.AppendUint64(b, h.Seed)
+	}
+	return b
+}
+
+// headerLen returns the length of the header starting with the flags byte flags.
+func headerLen(flags byte) int {
+	if flags&flagSeeded != 0 {
+		return headerSize + 8
+	}
+	return headerSize
+}
+
+func parseHeader(b []byte) (header, []byte, error) {
+	if len(b) < headerSize || len(b) < headerLen(b[1]) {
+		return header{}, nil, ErrInvalidEncoding
+	}
+	h := header{
+		Version: b[0],
+		Flags:   b[1],
+		K:       binary.LittleEndian.Uint32(b[2:]),
+		M:       binary.LittleEndian.Uint64(b[6:]),
+	}
+	if h.Version != formatVersion {
+		return header{}, nil, ErrUnsupportedVersion
+	}
+	if h.Flags&flagSeeded != 0 {
+		h.Seed = binary.LittleEndian.Uint64(b[headerSize:])
+		if h.Seed == 0 {
+			return header{}, nil, ErrInvalidEncoding
+		}
+	}
+	return h, b[headerLen(h.Flags):], nil
+}
+
+// MarshalBinary encodes the format version, K, the number of bits, the seed if it is not 0,
+// the bit array and its checksum. The hash function is not encoded.
+func (f *ClassicFilter) MarshalBinary() ([]byte, error) {
+	b := f.header(flagChecksum).append(make([]byte, 0, headerSize+8+len(f.B)+4))
+	b = append(b, f.B...)
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(f.B)), nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary or MarshalCompressed.
+// It returns ErrCorruptFilter if the checksum does not match, and leaves the filter as it
+// was if decoding fails. Either encoding replaces the geometry, bits and capacity of the
+// filter and keeps its hash function, read-only and constant time settings.
+// H must be set to the hash function the filter was built with.
+func (f *ClassicFilter) UnmarshalBinary(b []byte) error {
+	h, bits, err := parseHeader(b)
+	if err != nil {
+		return err
+	}
+	if h.Flags&flagDeflate != 0 {
+		r := bytes.NewReader(b)
+		g := *f
+		g.B = nil // read into a new bit array, leaving f as it was on failure
+		if _, err := g.ReadFrom(r); err != nil {
+			return err
+		}
+		if r.Len() != 0 {
+			return ErrInvalidEncoding
+		}
+		*f = g
+		return nil
+	}
+	length := byteLen(h.M)
+	size := length
+	if h.Flags&flagChecksum != 0 {
+		size += 4
+	}
+	if h.Flags&^(flagChecksum|flagProbe|flagSeeded) != 0 || h.K == 0 || h.K > maxHashes || h.M == 0 || uint64(len(bits)) != size {
+		return ErrInvalidEncoding
+	}
+	if h.Flags&flagChecksum != 0 {
+		if err := checkBits(bits[:length], bits[length:]); err != nil {
+			return err
+		}
+		bits = bits[:length]
+	}
+	f.K, f.Probe, f.Seed, f.m, f.n, f.p = int(h.K), probeOf(h), h.Seed, h.M, 0, 0
+	f.B = append([]byte(nil), bits...)
+	return nil
+}
+
+// checkBits returns ErrCorruptFilter unless crc is the little-endian CRC-32 of bits.
+func checkBits(bits, crc []byte) error {
+	if crc32.ChecksumIEEE(bits) != binary.LittleEndian.Uint32(crc) {
+		return ErrCorruptFilter
+	}
+	return nil
+}
+
+// GobEncode encodes the filter along with the name of its Hasher, or the name its hash
+// function is registered under with RegisterHash.
+func (f *ClassicFilter) GobEncode() ([]byte, error) {
+	name, ok := f.hashName()
+	if !ok {
+		return nil, ErrUnregisteredHash
+	}
+	b := binary.AppendUvarint(nil, uint64(len(name)))
+	b = append(b, name...)
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(b, data...), nil
+}
+
+// GobDecode decodes a filter encoded by GobEncode and restores its hash function from the registry.
+func (f *ClassicFilter) GobDecode(b []byte) error { return f.gobDecode(b, nil) }
+
+// gobDecode decodes a filter encoded by GobEncode, with the hash function registered
+// under its name or, if there is none, h if it is not nil.
+func (f *ClassicFilter) gobDecode(b []byte, h func([]byte) (uint64, uint64)) error {
+	n, size := binary.Uvarint(b)
+	if size <= 0 || uint64(len(b)-size) < n {
+		return ErrInvalidEncoding
+	}
+	name := string(b[size : size+int(n)])
+	if registered, ok := LookupHash(name); ok {
+		h = registered
+	} else if h == nil {
+		return ErrUnregisteredHash
+	} else {
+		name = ""
+	}
+	g := ClassicFilter{H: h, name: name}
+	if err := g.UnmarshalBinary(b[size+int(n):]); err != nil {
+		return err
+	}
+	*f = g
+	return nil
+}
+
+// jsonFilter is the JSON encoding of a classic filter.
+type jsonFilter struct {
+	Version int    `json:"version"`
+	K       int    `json:"k"`
+	M       uint64 `json:"m"`
+	Hash    string `json:"hash,omitempty"`
+	Probe   Probe  `json:"probe,omitempty"`
+	Seed    uint64 `json:"seed,omitempty"`
+	Bits    []byte `json:"bits"` // base64
+}
+
+// MarshalJSON encodes the format version, K, the number of bits, the name of the hash
+// function if it is known, the probe scheme unless it is DoubleHashing, the seed unless it
+// is 0, and the bit array in base64.
+func (f *ClassicFilter) MarshalJSON() ([]byte, error) {
+	name, _ := f.hashName()
+	return json.Marshal(jsonFilter{
+		Version: formatVersion,
+		K:       f.K,
+		M:       f.BitCount(),
+		Hash:    name,
+		Probe:   f.Probe,
+		Seed:    f.Seed,
+		Bits:    f.B,
+	})
+}
+
+// UnmarshalJSON decodes a filter encoded by MarshalJSON. A named hash function is looked
+// up in the registry; otherwise H must be set to the hash function the
+// filter was built with. It returns ErrReadOnly if the filter is read-only.
+func (f *ClassicFilter) UnmarshalJSON(b []byte) error {
+	if f.readOnly {
+		return ErrReadOnly
+	}
+	var j jsonFilter
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.Version != formatVersion {
+		return ErrUnsupportedVersion
+	}
+	if j.K <= 0 || j.K > maxHashes || j.M == 0 || uint64(len(j.Bits)) != byteLen(j.M) || !j.Probe.valid() {
+		return ErrInvalidEncoding
+	}
+	if j.Hash != "" {
+		if h, ok := LookupHash(j.Hash); ok {
+			f.H = h
+		} else if f.H == nil {
+			return ErrUnregisteredHash
+		}
+	}
+	f.B, f.K, f.Probe, f.Seed, f.name, f.m, f.n, f.p = j.Bits, j.K, j.Probe, j.Seed, j.Hash, j.M, 0, 0
+	return nil
+}
+
+// EncodeString encodes the filter as unpadded URL-safe base64 of MarshalBinary or
+// MarshalCompressed, whichever is shorter, so it can be pasted into configuration files
+// and environment variables.
+func (f *ClassicFilter) EncodeString() string {
+	b, _ := f.MarshalBinary()
+	if z, _ := f.MarshalCompressed(); len(z) < len(b) {
+		b = z
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeString decodes a filter encoded by EncodeString.
+// H must be set to the hash function the filter was built with.
+func (f *ClassicFilter) DecodeString(s string) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrInvalidEncoding
+	}
+	return f.UnmarshalBinary(b)
+}
+
+// readChunk is the size of the chunks a bit array of unknown size is read in,
+// so a corrupt header cannot make ReadFrom allocate more than the stream holds.
+const readChunk = 1 << 20
+
+// WriteTo writes the binary encoding of the filter to w without building it in memory.
+// The number of bits in the header tells ReadFrom where the filter ends.
+func (f *ClassicFilter) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(f.header(flagChecksum).append(make([]byte, 0, headerSize+8)))
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(f.B)
+	if err != nil {
+		return int64(n + m), err
+	}
+	c, err := w.Write(binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(f.B)))
+	return int64(n + m + c), err
+}
+
+// ReadFrom reads a filter written by WriteTo, WriteCompressedTo or their Marshal
+// counterparts from r, reading exactly up to its end. The bit array is reused if it has
+// the right size and the filter is not read-only, in which case its contents are undefined
+// if reading fails. It returns ErrCorruptFilter if the checksum does not match.
+// H must set to the hash function the filter was built with.
+func (f *ClassicFilter) ReadFrom(r io.Reader) (int64, error) {
+	buf := make([]byte, headerSize, headerSize+8)
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return int64(n), noEOF(err)
+	}
+	if size := headerLen(buf[1]); size > headerSize {
+		buf = buf[:size]
+		m, err := io.ReadFull(r, buf[headerSize:])
+		n += m
+		if err != nil {
+			return int64(n), noEOF(err)
+		}
+	}
+	h, _, err := parseHeader(buf)
+	if err != nil {
+		return int64(n), err
+	}
+	if h.Flags&^(flagDeflate|flagChecksum|flagProbe|flagSeeded) != 0 || h.K == 0 || h.K > maxHashes || h.M == 0 {
+		return int64(n), ErrInvalidEncoding
+	}
+	read := int64(n)
+	bits := f.B
+	if f.readOnly {
+		bits = nil
+	}
+	if h.Flags&flagDeflate != 0 {
+		bits, n, err = readDeflated(r, bits, byteLen(h.M))
+	} else {
+		bits, n, err = readBits(r, bits, byteLen(h.M))
+	}
+	read += int64(n)
+	if err != nil {
+		return read, err
+	}
+	if h.Flags&flagChecksum != 0 {
+		var crc [4]byte
+		n, err := io.ReadFull(r, crc[:])
+		read += int64(n)
+		if err != nil {
+			return read, noEOF(err)
+		}
+		if err := checkBits(bits, crc[:]); err != nil {
+			return read, err
+		}
+	}
+	f.K, f.B, f.Probe, f.Seed, f.m, f.n, f.p = int(h.K), bits, probeOf(h), h.Seed, h.M, 0, 0
+	return read, nil
+}
+
+// readBits reads a bit array of size bytes from r into bits if it has that size,
+// or else into a new slice grown in chunks.
+func readBits(r io.Reader, bits []byte, size uint64) ([]byte, int, error) {
+	if uint64(len(bits)) == size {
+		n, err := io.ReadFull(r, bits)
+		return bits, n, noEOF(err)
+	}
+	bits = nil
+	read := 0
+	for uint64(len(bits)) < size {
+		chunk := min(size-uint64(len(bits)), readChunk)
+		bits = append(bits, make([]byte, chunk)...)
+		n, err := io.ReadFull(r, bits[uint64(len(bits))-chunk:])
+		read += n
+		if err != nil {
+			return nil, read, noEOF(err)
+		}
+	}
+	return bits, read, nil
+}
+
+// noEOF turns an EOF in the middle of a filter into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
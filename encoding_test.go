@@ -0,0 +1,284 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*ClassicFilter)(nil)
+	_ encoding.BinaryUnmarshaler = (*ClassicFilter)(nil)
+)
+
+func TestClassicFilter_MarshalBinary(t *testing.T) {
+	bf := New(1e4, 1e-4, doubleFNV).(*ClassicFilter)
+	bf.Add([]byte("hello"))
+	data, err := bf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &ClassicFilter{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.K != bf.K || !bytes.Equal(decoded.B, bf.B) {
+		t.Fatal("Decoded filter differs from the original")
+	}
+	if !decoded.Test([]byte("hello")) {
+		t.Fatal("Should exist in decoded filter but got false")
+	}
+}
+
+// The encoding is fixed little-endian, so every platform must produce and accept these bytes.
+const classicGolden = "010206000000b8030000000000000000001000000000040000000000000000000000000000100000" +
+	"000000000000000000000000400000000000000000000000000000000000000000000000000000000000000000000001" +
+	"000000000000000000000000000004000000000000000000000000000000000000000000000000000000000000d6ceccd7"
+
+func TestClassicFilter_MarshalBinaryGolden(t *testing.T) {
+	bf := NewWithBits(952, 6, doubleSHA)
+	bf.Add([]byte("hello"))
+	data, _ := bf.MarshalBinary()
+	if got := hex.EncodeToString(data); got != classicGolden {
+		t.Fatalf("MarshalBinary = %s, want %s", got, classicGolden)
+	}
+	data, _ = hex.DecodeString(classicGolden)
+	decoded := &ClassicFilter{H: doubleSHA}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Test([]byte("hello")) {
+		t.Fatal("Should exist in decoded filter but got false")
+	}
+}
+
+func TestClassicFilter_UnmarshalBinaryInvalid(t *testing.T) {
+	bf := New(1e3, 1e-2, doubleFNV).(*ClassicFilter)
+	data, _ := bf.MarshalBinary()
+	decoded := &ClassicFilter{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data[:len(data)-1]); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("Truncated data should fail with ErrInvalidEncoding but got %v", err)
+	}
+	if err := decoded.UnmarshalBinary(data[:3]); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("Truncated header should fail with ErrInvalidEncoding but got %v", err)
+	}
+	data[headerSize] ^= 1
+	if err := decoded.UnmarshalBinary(data); !errors.Is(err, ErrCorruptFilter) {
+		t.Fatalf("Flipped bit should fail with ErrCorruptFilter but got %v", err)
+	}
+	if _, err := decoded.ReadFrom(bytes.NewReader(data)); !errors.Is(err, ErrCorruptFilter) {
+		t.Fatalf("Flipped bit should fail ReadFrom with ErrCorruptFilter but got %v", err)
+	}
+	data[0] = 99
+	if err := decoded.UnmarshalBinary(data); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("Unknown version should fail with ErrUnsupportedVersion but got %v", err)
+	}
+}
+
+func TestClassicFilter_UnmarshalBinaryUnchecked(t *testing.T) {
+	// encoding of version 1 before checksums were added
+	bf := New(1e3, 1e-2, doubleFNV).(*ClassicFilter)
+	bf.Add([]byte("hello"))
+	data := header{Version: formatVersion, K: uint32(bf.K), M: bf.BitCount()}.append(nil)
+	data = append(data, bf.B...)
+	decoded := &ClassicFilter{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Test([]byte("hello")) {
+		t.Fatal("Should exist in decoded filter but got false")
+	}
+}
+
+func TestClassicFilter_Gob(t *testing.T) {
+	RegisterHash("test-fnv", doubleFNV)
+	bf := New(1e4, 1e-4, doubleFNV)
+	bf.Add([]byte("hello"))
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(bf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded *ClassicFilter
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Test([]byte("hello")) {
+		t.Fatal("Should exist in decoded filter but got false")
+	}
+	if decoded.Test([]byte("world")) {
+		t.Fatal("Should missing in decoded filter but got true")
+	}
+
+	unregistered := New(1e3, 1e-2, doubleSHA)
+	if err := gob.NewEncoder(&buf).Encode(unregistered); !errors.Is(err, ErrUnregisteredHash) {
+		t.Fatalf("Encoding with an unregistered hash should fail but got %v", err)
+	}
+}
+
+func TestClassicFilter_JSON(t *testing.T) {
+	RegisterHash("test-fnv", doubleFNV)
+	bf := New(1e3, 1e-3, doubleFNV)
+	bf.Add([]byte("hello"))
+	data, err := json.Marshal(map[string]Filter{"filter": bf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]*ClassicFilter
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	f := decoded["filter"]
+	if !f.Test([]byte("hello")) {
+		t.Fatal("Should exist in decoded filter but got false")
+	}
+	if !bytes.Equal(f.B, bf.(*ClassicFilter).B) {
+		t.Fatal("Decoded bits differ from the original")
+	}
+
+	// unnamed hashes must be provided by the caller
+	data, _ = json.Marshal(New(1e3, 1e-3, doubleSHA))
+	if err := json.Unmarshal(data, &ClassicFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	var bad ClassicFilter
+	if err := json.Unmarshal([]byte(`{"version":1,"k":3,"m":16,"bits":"AA=="}`), &bad); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("Mismatched bit count should fail with ErrInvalidEncoding but got %v", err)
+	}
+}
+
+func TestClassicFilter_UnmarshalJSONInto(t *testing.T) {
+	data, _ := json.Marshal(New(1e3, 1e-2, doubleSHA))
+	f := New(1e5, 1e-6, doubleSHA).(*ClassicFilter)
+	if err := json.Unmarshal(data, f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Capacity() == 1e5 || f.TargetRate() == 1e-6 {
+		t.Fatalf("Decoding should replace the capacity and rate of the filter but got %d and %g", f.Capacity(), f.TargetRate())
+	}
+
+	ro := NewFromBytes(make([]byte, len(f.B)), f.K, doubleSHA)
+	if err := json.Unmarshal(data, ro); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Decoding into a read-only filter should fail with ErrReadOnly but got %v", err)
+	}
+}
+
+func TestClassicFilter_EncodeString(t *testing.T) {
+	for _, n := range []int{0, 10, 1000} {
+		bf := New(1000, 1e-2, doubleSHA).(*ClassicFilter)
+		for i := 0; i < n; i++ {
+			bf.Add([]byte{byte(i), byte(i >> 8)})
+		}
+		s := bf.EncodeString()
+		if n == 0 && len(s) > len(bf.B)/4 {
+			t.Fatalf("Empty filter of %d bytes encoded in %d characters", len(bf.B), len(s))
+		}
+		decoded := &ClassicFilter{H: doubleSHA}
+		if err := decoded.DecodeString(s); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded.B, bf.B) {
+			t.Fatal("Decoded filter differs from the original")
+		}
+	}
+	if err := (&ClassicFilter{}).DecodeString("not base64!"); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("Invalid base64 should fail with ErrInvalidEncoding but got %v", err)
+	}
+}
+
+func TestClassicFilter_WriteTo(t *testing.T) {
+	bf := New(1e4, 1e-4, doubleFNV).(*ClassicFilter)
+	bf.Add([]byte("hello"))
+	var buf bytes.Buffer
+	n, err := bf.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo returned %d but wrote %d bytes", n, buf.Len())
+	}
+	data, _ := bf.MarshalBinary()
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("WriteTo and MarshalBinary should produce the same encoding")
+	}
+	buf.WriteString("trailer")
+
+	for _, decoded := range []*ClassicFilter{
+		{H: doubleFNV},
+		{H: doubleFNV, B: make([]byte, len(bf.B))}, // reused bit array
+	} {
+		r := bytes.NewReader(buf.Bytes())
+		n, err := decoded.ReadFrom(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(data)) {
+			t.Fatalf("ReadFrom read %d bytes, want %d", n, len(data))
+		}
+		if rest := r.Len(); rest != len("trailer") {
+			t.Fatalf("ReadFrom should stop at the end of the filter, %d bytes left", rest)
+		}
+		if !decoded.Test([]byte("hello")) {
+			t.Fatal("Should exist in decoded filter but got false")
+		}
+	}
+
+	if _, err := (&ClassicFilter{}).ReadFrom(bytes.NewReader(data[:len(data)-1])); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Truncated stream should fail with io.ErrUnexpectedEOF but got %v", err)
+	}
+}
+
+func TestClassicFilter_DecodeNoBits(t *testing.T) {
+	empty := &ClassicFilter{K: 3, H: doubleFNV}
+	data, _ := empty.MarshalBinary()
+	js, _ := empty.MarshalJSON()
+	pb, _ := ToProto(empty)
+	if err := new(ClassicFilter).UnmarshalBinary(data); err != ErrInvalidEncoding {
+		t.Fatalf("UnmarshalBinary of no bits = %v, want %v", err, ErrInvalidEncoding)
+	}
+	if _, err := new(ClassicFilter).ReadFrom(bytes.NewReader(data)); err != ErrInvalidEncoding {
+		t.Fatalf("ReadFrom of no bits = %v, want %v", err, ErrInvalidEncoding)
+	}
+	if err := new(ClassicFilter).UnmarshalJSON(js); err != ErrInvalidEncoding {
+		t.Fatalf("UnmarshalJSON of no bits = %v, want %v", err, ErrInvalidEncoding)
+	}
+	if _, err := FromProto(pb, doubleFNV); err != ErrInvalidEncoding {
+		t.Fatalf("FromProto of no bits = %v, want %v", err, ErrInvalidEncoding)
+	}
+	for _, b := range [][]byte{data, js, fileHeader(empty)} {
+		if _, err := LoadAny(b, doubleFNV); err == nil {
+			t.Fatal("LoadAny of no bits should fail")
+		}
+	}
+}
+
+func TestClassicFilter_DecodeTooManyHashes(t *testing.T) {
+	bf := NewWithBits(64, maxHashes+1, doubleFNV)
+	data, _ := bf.MarshalBinary()
+	js, _ := bf.MarshalJSON()
+	pb, _ := ToProto(bf)
+	if err := new(ClassicFilter).UnmarshalBinary(data); err != ErrInvalidEncoding {
+		t.Fatalf("UnmarshalBinary of %d hashes = %v, want %v", bf.K, err, ErrInvalidEncoding)
+	}
+	if _, err := new(ClassicFilter).ReadFrom(bytes.NewReader(data)); err != ErrInvalidEncoding {
+		t.Fatalf("ReadFrom of %d hashes = %v, want %v", bf.K, err, ErrInvalidEncoding)
+	}
+	if err := new(ClassicFilter).UnmarshalJSON(js); err != ErrInvalidEncoding {
+		t.Fatalf("UnmarshalJSON of %d hashes = %v, want %v", bf.K, err, ErrInvalidEncoding)
+	}
+	if _, err := FromProto(pb, doubleFNV); err != ErrInvalidEncoding {
+		t.Fatalf("FromProto of %d hashes = %v, want %v", bf.K, err, ErrInvalidEncoding)
+	}
+	if _, err := LoadAny(append(fileHeader(bf), bf.B...), doubleFNV); err == nil {
+		t.Fatal("LoadAny of too many hashes should fail")
+	}
+	most := NewWithBits(64, maxHashes, doubleFNV)
+	data, _ = most.MarshalBinary()
+	if err := new(ClassicFilter).UnmarshalBinary(data); err != nil {
+		t.Fatal("A filter of the most hashes should decode, but got", err)
+	}
+}
@@ -0,0 +1,141 @@
+package bloom
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding"
+	"errors"
+	"os"
+)
+
+// ErrDecrypt is returned for an encrypted filter that the key does not decrypt, because it
+// is not the key the filter was encrypted with or the encrypted filter was changed.
+var ErrDecrypt = errors.New("bloom: cannot decrypt filter")
+
+// sealMagic starts every encrypted filter.
+var sealMagic = [4]byte{'B', 'L', 'M', 'E'}
+
+// sealVersion is the version of the encryption: AES-GCM with a random 96-bit nonce.
+const sealVersion = 1
+
+// sealHeaderSize is the size of the magic number and version, which are authenticated
+// along with the ciphertext.
+const sealHeaderSize = len(sealMagic) + 1
+
+// seal encrypts data under key, of 16, 24 or 32 bytes for AES-128, AES-192 or AES-256, as
+// the magic number, the version, a random nonce and the ciphertext with its tag.
+func seal(data, key []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, sealHeaderSize+aead.NonceSize(), sealHeaderSize+aead.NonceSize()+len(data)+aead.Overhead())
+	copy(out, sealMagic[:])
+	out[len(sealMagic)] = sealVersion
+	nonce := out[sealHeaderSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, data, out[:sealHeaderSize]), nil
+}
+
+// unseal decrypts data encrypted by seal under key.
+func unseal(sealed, key []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < sealHeaderSize || [4]byte(sealed) != sealMagic {
+		return nil, ErrInvalidEncoding
+	}
+	if sealed[len(sealMagic)] != sealVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	if len(sealed) < sealHeaderSize+aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidEncoding
+	}
+	nonce, ciphertext := sealed[sealHeaderSize:sealHeaderSize+aead.NonceSize()], sealed[sealHeaderSize+aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, sealed[:sealHeaderSize])
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// MarshalEncrypted encodes f with its MarshalBinary and encrypts the encoding with AES-GCM
+// under key, of 16, 24 or 32 bytes for AES-128, AES-192 or AES-256, so the entries of a
+// filter shipped through untrusted storage, such as a CDN, cannot be tested by anyone
+// without the key. The encryption is randomized, so encrypting a filter twice gives
+// different results. It returns aes.KeySizeError for a key of another size.
+func MarshalEncrypted(f encoding.BinaryMarshaler, key []byte) ([]byte, error) {
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return seal(data, key)
+}
+
+// UnmarshalEncrypted decrypts data encrypted by MarshalEncrypted under key and decodes it
+// into f with its UnmarshalBinary. It returns ErrDecrypt if key does not decrypt it.
+func UnmarshalEncrypted(f encoding.BinaryUnmarshaler, data, key []byte) error {
+	data, err := unseal(data, key)
+	if err != nil {
+		return err
+	}
+	return f.UnmarshalBinary(data)
+}
+
+// SaveFileEncrypted writes a filter to a file at path like SaveFile, encrypted like
+// MarshalEncrypted. It emits an EventSave.
+func SaveFileEncrypted(path string, f Filter, key []byte) error {
+	err := saveFileEncrypted(path, f, key)
+	emit(Event{Kind: EventSave, Filter: f, Path: path, Err: err})
+	return err
+}
+
+func saveFileEncrypted(path string, f Filter, key []byte) error {
+	cf, ok := f.(*ClassicFilter)
+	if !ok {
+		return ErrUnsupportedType
+	}
+	data, err := seal(append(fileHeader(cf), cf.B...), key)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// LoadFileEncrypted reads a filter written by SaveFileEncrypted with key, using h as its
+// hash function. It returns ErrDecrypt if key does not decrypt the file. It emits an
+// EventLoad.
+func LoadFileEncrypted(path string, h func([]byte) (uint64, uint64), key []byte) (Filter, error) {
+	f, err := loadFileEncrypted(path, h, key)
+	if err != nil {
+		emit(Event{Kind: EventLoad, Path: path, Err: err})
+		return nil, err
+	}
+	emit(Event{Kind: EventLoad, Filter: f, Path: path})
+	return f, nil
+}
+
+func loadFileEncrypted(path string, h func([]byte) (uint64, uint64), key []byte) (*ClassicFilter, error) {
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := unseal(sealed, key)
+	if err != nil {
+		return nil, err
+	}
+	return readFile(bytes.NewReader(data), h)
+}
@@ -0,0 +1,89 @@
+package bloom
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarshalEncrypted(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	bf := newClassic(1e3, 1e-3, doubleFNV)
+	bf.Add([]byte("user@example.com"))
+	data, err := MarshalEncrypted(bf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := bf.MarshalBinary()
+	if bytes.Contains(data, plain[len(plain)-len(bf.B):]) {
+		t.Fatal("The bit array should not appear in the encrypted filter")
+	}
+	if again, _ := MarshalEncrypted(bf, key); bytes.Equal(again, data) {
+		t.Fatal("Encrypting twice should use different nonces")
+	}
+
+	got := &ClassicFilter{H: doubleFNV}
+	if err := UnmarshalEncrypted(got, data, key); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(bf) {
+		t.Fatal("The decrypted filter should equal the original")
+	}
+
+	wrong := bytes.Repeat([]byte{8}, 32)
+	if err := UnmarshalEncrypted(got, data, wrong); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("UnmarshalEncrypted with the wrong key = %v, want ErrDecrypt", err)
+	}
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)/2] ^= 1
+	if err := UnmarshalEncrypted(got, tampered, key); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("UnmarshalEncrypted of a changed filter = %v, want ErrDecrypt", err)
+	}
+	tampered = bytes.Clone(data)
+	tampered[4] = 2
+	if err := UnmarshalEncrypted(got, tampered, key); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("UnmarshalEncrypted of another version = %v, want ErrUnsupportedVersion", err)
+	}
+	if err := UnmarshalEncrypted(got, plain, key); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("UnmarshalEncrypted of a plain filter = %v, want ErrInvalidEncoding", err)
+	}
+	if err := UnmarshalEncrypted(got, data[:20], key); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("UnmarshalEncrypted of a truncated filter = %v, want ErrInvalidEncoding", err)
+	}
+	var sizeErr aes.KeySizeError
+	if _, err := MarshalEncrypted(bf, key[:10]); !errors.As(err, &sizeErr) {
+		t.Errorf("MarshalEncrypted with a key of 10 bytes = %v, want aes.KeySizeError", err)
+	}
+}
+
+func TestSaveFileEncrypted(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 16)
+	path := filepath.Join(t.TempDir(), "blocklist.bloom.enc")
+	bf := New(1e3, 1e-3, doubleFNV)
+	bf.Add([]byte("user@example.com"))
+	if err := SaveFileEncrypted(path, bf, key); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadFileEncrypted(path, doubleFNV, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.(*ClassicFilter).Equal(bf.(*ClassicFilter)) {
+		t.Fatal("The loaded filter should equal the saved one")
+	}
+	if _, err := LoadFile(path, doubleFNV); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("LoadFile of an encrypted file = %v, want ErrInvalidEncoding", err)
+	}
+	if _, err := LoadFileEncrypted(path, doubleFNV, bytes.Repeat([]byte{2}, 16)); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("LoadFileEncrypted with the wrong key = %v, want ErrDecrypt", err)
+	}
+	if _, err := LoadFileEncrypted(path+".missing", doubleFNV, key); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadFileEncrypted of a missing file = %v", err)
+	}
+	if err := SaveFileEncrypted(path, NewCuckoo(10, 12, 4, doubleFNV), key); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("SaveFileEncrypted of a cuckoo filter = %v, want ErrUnsupportedType", err)
+	}
+}
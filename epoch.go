@@ -0,0 +1,56 @@
+package bloom
+
+import "sync/atomic"
+
+// Epoch-based Bloom Filter
+//
+// An epoch filter is a filter for one writer and many readers. The writer
+// adds entries to a copy-on-write filter and publishes them together with
+// Publish, which swaps an atomic pointer to a snapshot; readers test the
+// last published snapshot without locks, so they never see some bits of an
+// entry but not others. Only the chunks changed during an epoch are copied.
+type EpochFilter struct {
+	w         *CopyOnWriteFilter
+	published atomic.Pointer[CopyOnWriteFilter]
+	epoch     atomic.Uint64
+	pending   int
+}
+
+// NewEpoch creates an epoch-based classic Bloom Filter that is optimal for n entries and false
+// positive rate of p. Add, Reset and Publish must be called by one goroutine at a time; Test may
+// be called by any number of goroutines.
+func NewEpoch(n int, p float64, h func([]byte) (uint64, uint64)) *EpochFilter {
+	f := &EpochFilter{w: NewCopyOnWrite(n, p, h)}
+	f.published.Store(f.w.Snapshot())
+	return f
+}
+
+// Add stages an entry, which Test sees after the next Publish.
+func (f *EpochFilter) Add(b []byte) {
+	f.w.Add(b)
+	f.pending++
+}
+
+// Test tests if an entry is in the last published epoch.
+func (f *EpochFilter) Test(b []byte) bool { return f.published.Load().Test(b) }
+
+func (f *EpochFilter) Size() int { return f.w.Size() }
+
+// Reset stages clearing the filter, which Test sees after the next Publish.
+func (f *EpochFilter) Reset() {
+	f.w.Reset()
+	f.pending++
+}
+
+// Publish makes all staged changes visible to Test at once and starts a new epoch.
+func (f *EpochFilter) Publish() {
+	f.published.Store(f.w.Snapshot())
+	f.pending = 0
+	f.epoch.Add(1)
+}
+
+// Pending returns the number of changes staged since the last Publish.
+func (f *EpochFilter) Pending() int { return f.pending }
+
+// Epoch returns the number of times the filter was published.
+func (f *EpochFilter) Epoch() uint64 { return f.epoch.Load() }
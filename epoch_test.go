@@ -0,0 +1,53 @@
+package bloom
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEpochFilter(t *testing.T) {
+	f := NewEpoch(1e4, 1e-3, doubleSHA)
+	f.Add([]byte("hello"))
+	if f.Test([]byte("hello")) || f.Pending() != 1 {
+		t.Fatal("Staged entry should not be visible before Publish")
+	}
+	f.Publish()
+	if !f.Test([]byte("hello")) || f.Epoch() != 1 || f.Pending() != 0 {
+		t.Fatal("Should exist in filter after Publish but got false")
+	}
+
+	// readers see every entry of an epoch once they see any of it
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100 || !stop.Load(); n++ {
+				epoch := int(f.Epoch())
+				for i := 0; i < 10*(epoch-1); i++ {
+					if !f.Test([]byte(strconv.Itoa(i))) {
+						t.Errorf("Entry %d of epoch %d is missing", i, epoch)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 10; i < 1000; i++ {
+		f.Add([]byte(strconv.Itoa(i - 10)))
+		if i%10 == 9 {
+			f.Publish()
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+
+	f.Reset()
+	f.Publish()
+	if f.Test([]byte("hello")) {
+		t.Fatal("Should not exist after a published reset but got true")
+	}
+}
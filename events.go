@@ -0,0 +1,94 @@
+package bloom
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+)
+
+// EventKind is the kind of an Event.
+type EventKind int
+
+const (
+	EventRotate    EventKind = iota + 1 // a RotatingFilter rotated
+	EventSaturate                       // a filter reached its capacity
+	EventMergeFail                      // Merge, Union or Intersect failed
+	EventLoad                           // a filter was loaded from a file, or failed to be
+	EventSave                           // a filter was saved to a file, or failed to be
+)
+
+var eventNames = [...]string{
+	EventRotate:    "rotate",
+	EventSaturate:  "saturate",
+	EventMergeFail: "merge_fail",
+	EventLoad:      "load",
+	EventSave:      "save",
+}
+
+func (k EventKind) String() string {
+	if k <= 0 || int(k) >= len(eventNames) {
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+	return eventNames[k]
+}
+
+// Event is an operational event of a filter, passed to the handler of SetEventHandler.
+type Event struct {
+	Kind   EventKind
+	Filter Filter // the filter, or nil if there is none, as for a failed load
+	Name   string // name of a filter of a Manager, if it is one
+	Path   string // file of EventLoad and EventSave
+	Err    error  // why the operation failed, if it did
+}
+
+var eventHandler atomic.Pointer[func(Event)]
+
+// SetEventHandler makes h receive the events of all filters, such as SlogHandler, and
+// returns the previous handler, nil if there was none. A nil h stops the events. The
+// handler is called synchronously, from the goroutine of the operation and possibly with
+// the lock of a filter held, so it must be quick and must not call the filter.
+func SetEventHandler(h func(Event)) func(Event) {
+	var old *func(Event)
+	if h == nil {
+		old = eventHandler.Swap(nil)
+	} else {
+		old = eventHandler.Swap(&h)
+	}
+	if old == nil {
+		return nil
+	}
+	return *old
+}
+
+// emit passes e to the event handler, if there is one.
+func emit(e Event) {
+	if h := eventHandler.Load(); h != nil {
+		(*h)(e)
+	}
+}
+
+// SlogHandler returns an event handler logging to l: failures and saturation at
+// slog.LevelWarn, and other events at slog.LevelInfo.
+func SlogHandler(l *slog.Logger) func(Event) {
+	return func(e Event) {
+		level := slog.LevelInfo
+		if e.Err != nil || e.Kind == EventSaturate {
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{slog.String("event", e.Kind.String())}
+		if e.Filter != nil {
+			attrs = append(attrs, slog.String("type", fmt.Sprintf("%T", e.Filter)), slog.Int("size", e.Filter.Size()))
+		}
+		if e.Name != "" {
+			attrs = append(attrs, slog.String("name", e.Name))
+		}
+		if e.Path != "" {
+			attrs = append(attrs, slog.String("path", e.Path))
+		}
+		if e.Err != nil {
+			attrs = append(attrs, slog.Any("error", e.Err))
+		}
+		l.LogAttrs(context.Background(), level, "bloom: "+e.Kind.String(), attrs...)
+	}
+}
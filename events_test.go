@@ -0,0 +1,150 @@
+package bloom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordEvents makes the events of the test recorded in the returned function's result.
+func recordEvents(t *testing.T) func() []Event {
+	var mu sync.Mutex
+	var events []Event
+	old := SetEventHandler(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+	t.Cleanup(func() { SetEventHandler(old) })
+	return func() []Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(events)
+	}
+}
+
+func kinds(events []Event) []EventKind {
+	var kinds []EventKind
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+	}
+	return kinds
+}
+
+func TestEvents(t *testing.T) {
+	events := recordEvents(t)
+
+	rf := NewRotating(1e3, 1e-2, 0, doubleFNV)
+	rf.Rotate()
+
+	sf := NewScalable(10, 1e-2, doubleFNV)
+	for i := range 11 {
+		sf.Add([]byte(fmt.Sprint(i)))
+	}
+
+	a, b := NewWithBits(1024, 3, doubleFNV), NewWithBits(2048, 3, doubleFNV)
+	if err := a.Merge(b); err == nil {
+		t.Fatal("Merge of filters of different sizes should fail")
+	}
+
+	path := filepath.Join(t.TempDir(), "filter.bloom")
+	if err := SaveFile(path, a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(path, doubleFNV); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(path+".missing", doubleFNV); err == nil {
+		t.Fatal("LoadFile of a missing file should fail")
+	}
+
+	got := events()
+	want := []EventKind{EventRotate, EventSaturate, EventMergeFail, EventSave, EventLoad, EventLoad}
+	if !slices.Equal(kinds(got), want) {
+		t.Fatalf("Events = %v, want %v", kinds(got), want)
+	}
+	if got[0].Filter != rf || got[1].Filter != sf || got[2].Filter != a || got[2].Err == nil {
+		t.Errorf("Events should have their filter and error but got %+v", got[:3])
+	}
+	if got[3].Path != path || got[4].Err != nil || got[4].Filter == nil {
+		t.Errorf("The save and load should succeed but got %+v", got[3:5])
+	}
+	if !errors.Is(got[5].Err, os.ErrNotExist) || got[5].Filter != nil {
+		t.Errorf("The failed load should have its error but got %+v", got[5])
+	}
+}
+
+func TestEvents_Manager(t *testing.T) {
+	events := recordEvents(t)
+	m, err := OpenManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := m.Create("tenant", FilterSpec{Capacity: 3, Rate: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		f.Add([]byte(key))
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	got := events()
+	if !slices.Equal(kinds(got), []EventKind{EventSaturate, EventSave}) {
+		t.Fatalf("Events = %v", kinds(got))
+	}
+	for _, e := range got {
+		if e.Name != "tenant" || e.Filter != f {
+			t.Errorf("%v should name the managed filter but got %+v", e.Kind, e)
+		}
+	}
+}
+
+func TestSetEventHandler(t *testing.T) {
+	var n int
+	h := func(Event) { n++ }
+	old := SetEventHandler(h)
+	defer SetEventHandler(old)
+	if prev := SetEventHandler(nil); prev == nil {
+		t.Fatal("SetEventHandler should return the previous handler")
+	}
+	NewRotating(1e3, 1e-2, 0, doubleFNV).Rotate()
+	if n != 0 {
+		t.Fatal("A nil handler should stop the events")
+	}
+	if SetEventHandler(nil) != nil {
+		t.Fatal("SetEventHandler should return nil without a previous handler")
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := SlogHandler(slog.New(slog.NewTextHandler(&buf, nil)))
+	h(Event{Kind: EventSave, Filter: NewWithBits(1024, 3, doubleFNV), Path: "f.bloom"})
+	h(Event{Kind: EventLoad, Path: "g.bloom", Err: ErrCorruptFilter})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Log = %q", buf.String())
+	}
+	for _, want := range []string{"level=INFO", `msg="bloom: save"`, "type=*bloom.ClassicFilter", "size=128", "path=f.bloom"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("%q should contain %q", lines[0], want)
+		}
+	}
+	for _, want := range []string{"level=WARN", "event=load", "error="} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("%q should contain %q", lines[1], want)
+		}
+	}
+	if got := EventKind(0).String(); got != "EventKind(0)" {
+		t.Errorf("EventKind(0).String() = %q", got)
+	}
+}
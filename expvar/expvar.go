@@ -0,0 +1,91 @@
+// Package expvar publishes statistics of filters of github.com/OperatorFoundation/go-bloom
+// with the standard expvar package, for services that expose /debug/vars but do not run
+// Prometheus. It is separate from package bloom because importing expvar registers
+// /debug/vars on http.DefaultServeMux.
+package expvar
+
+import (
+	"expvar"
+	"sync/atomic"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+)
+
+// PublishedFilter is a bloom.Filter that counts its inserts, tests and hits and publishes them
+// with expvar, for services that expose /debug/vars. The counters are atomic, so the
+// filter is as safe for concurrent use as the filter it wraps.
+type PublishedFilter struct {
+	f                    bloom.Filter
+	inserts, tests, hits atomic.Uint64
+}
+
+// Publish wraps f and publishes its statistics with expvar under name, as a map of:
+//
+//	inserts        entries added, by Add or TestAndAdd
+//	tests          entries tested, by Test or TestAndAdd
+//	hits           tests that found the entry
+//	size           size of the filter in bytes
+//	fill_ratio     fraction of bits set, of a bloom.SafeFilter wrapping a bloom.ClassicFilter
+//	estimated_fpr  CurrentFalsePositiveRate, of the same
+//
+// The statistics are read when the variables are served, concurrently with the calls, so
+// the fill ratio and false positive rate are read under the lock of a bloom.SafeFilter and left
+// out for other filters. Like expvar.Publish, it panics if name is already published.
+func Publish(name string, f bloom.Filter) *PublishedFilter {
+	p := &PublishedFilter{f: f}
+	expvar.Publish(name, expvar.Func(p.stats))
+	return p
+}
+
+func (p *PublishedFilter) stats() any {
+	stats := map[string]any{
+		"inserts": p.inserts.Load(),
+		"tests":   p.tests.Load(),
+		"hits":    p.hits.Load(),
+		"size":    p.f.Size(),
+	}
+	if s, ok := p.f.(*bloom.SafeFilter); ok {
+		s.Do(func(f bloom.Filter) {
+			if c, ok := f.(*bloom.ClassicFilter); ok {
+				stats["fill_ratio"] = c.FillRatio()
+				stats["estimated_fpr"] = c.CurrentFalsePositiveRate()
+			}
+		})
+	}
+	return stats
+}
+
+// Unwrap returns the wrapped filter.
+func (p *PublishedFilter) Unwrap() bloom.Filter { return p.f }
+
+func (p *PublishedFilter) Add(b []byte) {
+	p.f.Add(b)
+	p.inserts.Add(1)
+}
+
+func (p *PublishedFilter) Test(b []byte) bool {
+	found := p.f.Test(b)
+	p.count(found)
+	return found
+}
+
+// TestAndAdd adds an entry and reports whether it was already in the filter, with one call
+// if the wrapped filter is a bloom.TestAndAdder. It counts as an insert and a test.
+func (p *PublishedFilter) TestAndAdd(b []byte) bool {
+	found := bloom.TestAndAdd(p.f, b)
+	p.inserts.Add(1)
+	p.count(found)
+	return found
+}
+
+func (p *PublishedFilter) Size() int { return p.f.Size() }
+
+// Reset resets the wrapped filter. The counters keep counting, as expvar counters do.
+func (p *PublishedFilter) Reset() { p.f.Reset() }
+
+func (p *PublishedFilter) count(found bool) {
+	p.tests.Add(1)
+	if found {
+		p.hits.Add(1)
+	}
+}
@@ -0,0 +1,43 @@
+package expvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"math"
+	"testing"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+)
+
+func TestPublish(t *testing.T) {
+	safe := bloom.NewSafe(1e3, 1e-2, bloom.XXHash)
+	f := Publish("bloom_test_publish", safe)
+	f.Add([]byte("hello"))
+	f.Test([]byte("hello"))
+	f.Test([]byte("world"))
+	f.TestAndAdd([]byte("world"))
+
+	var stats struct {
+		Inserts, Tests, Hits uint64
+		Size                 int
+		FillRatio            *float64 `json:"fill_ratio"`
+		EstimatedFPR         float64  `json:"estimated_fpr"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("bloom_test_publish").String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Inserts != 2 || stats.Tests != 3 || stats.Hits != 1 || stats.Size != safe.Size() {
+		t.Fatal("Published counters should count the calls but got", stats)
+	}
+	var c *bloom.ClassicFilter
+	safe.Do(func(f bloom.Filter) { c = f.(*bloom.ClassicFilter) })
+	if stats.FillRatio == nil || *stats.FillRatio != c.FillRatio() || math.Abs(stats.EstimatedFPR-c.CurrentFalsePositiveRate()) > 1e-12 {
+		t.Fatal("The fill ratio and rate of a safe classic filter should be published but got", stats)
+	}
+
+	Publish("bloom_test_publish_unsafe", bloom.New(1e3, 1e-2, bloom.XXHash))
+	var unsafe map[string]any
+	if s := expvar.Get("bloom_test_publish_unsafe").String(); json.Unmarshal([]byte(s), &unsafe) != nil || unsafe["fill_ratio"] != nil {
+		t.Fatal("The fill ratio of a filter read without its lock should be left out but got", s)
+	}
+}
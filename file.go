@@ -0,0 +1,167 @@
+package bloom
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// fileMagic starts every filter file.
+var fileMagic = [4]byte{'B', 'L', 'M', 'F'}
+
+// fileVersion is the version of the file layout around the filter encoding.
+const fileVersion = 1
+
+// filter type tags of the file format and of the versioned encodings of other filters
+const (
+	typeClassic byte = 1
+	typeGCS     byte = 2
+	typeRibbon  byte = 3
+	typeXor     byte = 4
+	typeKeyed   byte = 5
+)
+
+// fileHeaderSize is the size of everything in a filter file before the bit array,
+// which is 8 bytes more for seeded filters.
+const fileHeaderSize = len(fileMagic) + 2 + headerSize + 4
+
+// ErrUnsupportedType is returned when saving or loading a filter type the file format does not support.
+var ErrUnsupportedType = errors.New("bloom: unsupported filter type")
+
+// SaveFile writes a filter to a file at path, atomically replacing any existing file.
+// The file starts with a magic number, the file version, the filter type,
+// the header of the binary encoding of the filter and a CRC-32 checksum of
+// its bit array, followed by the bit array. It emits an EventSave.
+func SaveFile(path string, f Filter) error {
+	err := saveFile(path, f)
+	emit(Event{Kind: EventSave, Filter: f, Path: path, Err: err})
+	return err
+}
+
+func saveFile(path string, f Filter) error {
+	cf, ok := f.(*ClassicFilter)
+	if !ok {
+		return ErrUnsupportedType
+	}
+	return writeFileAtomic(path, fileHeader(cf), cf.B)
+}
+
+// writeFileAtomic writes the concatenation of parts to a file at path, atomically
+// replacing any existing file.
+func writeFileAtomic(path string, parts ...[]byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// write errors stick to w and are returned by Flush
+	w := bufio.NewWriter(tmp)
+	for _, p := range parts {
+		w.Write(p)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFile reads a filter written by SaveFile, using h as its hash function.
+// It returns ErrCorruptFilter if the checksum of the bit array does not match. It emits an
+// EventLoad.
+func LoadFile(path string, h func([]byte) (uint64, uint64)) (Filter, error) {
+	f, err := loadFile(path, h)
+	if err != nil {
+		emit(Event{Kind: EventLoad, Path: path, Err: err})
+		return nil, err
+	}
+	emit(Event{Kind: EventLoad, Filter: f, Path: path})
+	return f, nil
+}
+
+func loadFile(path string, h func([]byte) (uint64, uint64)) (*ClassicFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readFile(bufio.NewReader(file), h)
+}
+
+// readFile reads a filter in the file format from r.
+func readFile(r io.Reader, h func([]byte) (uint64, uint64)) (*ClassicFilter, error) {
+	buf := make([]byte, fileHeaderSize, fileHeaderSize+8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, ErrInvalidEncoding
+	}
+	if size := fileHeaderLen(buf[7]); size > fileHeaderSize {
+		buf = buf[:size]
+		if _, err := io.ReadFull(r, buf[fileHeaderSize:]); err != nil {
+			return nil, ErrInvalidEncoding
+		}
+	}
+	hdr, crc, err := parseFileHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+	var bits []byte
+	for size := byteLen(hdr.M); uint64(len(bits)) < size; {
+		chunk := min(size-uint64(len(bits)), readChunk)
+		bits = append(bits, make([]byte, chunk)...)
+		if _, err := io.ReadFull(r, bits[uint64(len(bits))-chunk:]); err != nil {
+			return nil, ErrCorruptFilter
+		}
+	}
+	if crc32.ChecksumIEEE(bits) != crc {
+		return nil, ErrCorruptFilter
+	}
+	return &ClassicFilter{B: bits, K: int(hdr.K), H: h, Probe: probeOf(hdr), Seed: hdr.Seed, m: hdr.M}, nil
+}
+
+// fileHeader returns everything in the file of a filter before its bit array.
+func fileHeader(f *ClassicFilter) []byte {
+	b := make([]byte, 0, fileHeaderSize+8)
+	b = append(b, fileMagic[:]...)
+	b = append(b, fileVersion, typeClassic)
+	b = f.header(0).append(b)
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(f.B))
+}
+
+// parseFileHeader parses the file header of a classic filter and returns its
+// encoding header and the checksum of its bit array.
+func parseFileHeader(b []byte) (header, uint32, error) {
+	if len(b) < fileHeaderSize || [4]byte(b) != fileMagic {
+		return header{}, 0, ErrInvalidEncoding
+	}
+	if b[4] != fileVersion {
+		return header{}, 0, ErrUnsupportedVersion
+	}
+	if b[5] != typeClassic {
+		return header{}, 0, ErrUnsupportedType
+	}
+	h, rest, err := parseHeader(b[6:])
+	if err != nil {
+		return header{}, 0, err
+	}
+	if h.Flags&^(flagProbe|flagSeeded) != 0 || h.K == 0 || h.K > maxHashes || h.M == 0 || len(rest) < 4 {
+		return header{}, 0, ErrInvalidEncoding
+	}
+	return h, binary.LittleEndian.Uint32(rest), nil
+}
+
+// fileHeaderLen returns the length of the file header whose encoding header has the flags byte flags.
+func fileHeaderLen(flags byte) int {
+	return fileHeaderSize - headerSize + headerLen(flags)
+}
@@ -0,0 +1,61 @@
+package bloom
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.bloom")
+	bf := New(1e4, 1e-4, doubleFNV)
+	bf.Add([]byte("hello"))
+	if err := SaveFile(path, bf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFile(path, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Test([]byte("hello")) {
+		t.Fatal("Should exist in loaded filter but got false")
+	}
+	if loaded.Test([]byte("world")) {
+		t.Fatal("Should missing in loaded filter but got true")
+	}
+
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 1
+	os.WriteFile(path, data, 0o644)
+	if _, err := LoadFile(path, doubleFNV); !errors.Is(err, ErrCorruptFilter) {
+		t.Fatalf("Flipped bit should fail with ErrCorruptFilter but got %v", err)
+	}
+	os.WriteFile(path, data[:len(data)-10], 0o644)
+	if _, err := LoadFile(path, doubleFNV); !errors.Is(err, ErrCorruptFilter) {
+		t.Fatalf("Truncated file should fail with ErrCorruptFilter but got %v", err)
+	}
+	os.WriteFile(path, []byte("not a filter at all"), 0o644)
+	if _, err := LoadFile(path, doubleFNV); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("Bad magic should fail with ErrInvalidEncoding but got %v", err)
+	}
+	if err := SaveFile(path, NewCounting(10, 0.1, doubleFNV)); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("Unsupported type should fail with ErrUnsupportedType but got %v", err)
+	}
+}
+
+func TestSaveFile_Seeded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.bloom")
+	bf := NewSeeded(1e4, 1e-4, 0x5eed, doubleFNV)
+	bf.Add([]byte("hello"))
+	if err := SaveFile(path, bf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFile(path, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lf := loaded.(*ClassicFilter); lf.Seed != bf.Seed || !lf.Test([]byte("hello")) {
+		t.Fatal("Loaded filter should keep the seed")
+	}
+}
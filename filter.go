@@ -2,10 +2,37 @@
 package bloom
 
 import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"iter"
 	"math"
+	"math/bits"
 )
 
+// ErrReadOnly is returned when modifying a read-only filter.
+var ErrReadOnly = errors.New("bloom: filter is read-only")
+
+// ErrInvalidCapacity is returned by NewChecked when the number of entries is not positive.
+var ErrInvalidCapacity = errors.New("bloom: number of entries must be positive")
+
+// ErrInvalidRate is returned by NewChecked when the false positive rate is not between 0 and 1.
+var ErrInvalidRate = errors.New("bloom: false positive rate must be between 0 and 1")
+
+// ErrTooLarge is returned by NewChecked, and the panic of other constructors, when a filter
+// would need more memory than a slice can hold on the platform, math.MaxInt bytes.
+var ErrTooLarge = errors.New("bloom: filter is too large for this platform")
+
+// ErrNilHash is returned by NewChecked, and by decoders such as FromProto, when the hash
+// function is nil.
+var ErrNilHash = errors.New("bloom: hash function is nil")
+
 // Filter is a generic Bloom Filter
+//
+// Add and Test of the classic, seeded, atomic, blocked, partitioned and counting filters
+// do not allocate, and neither do the hashes of this package, so those filters allocate on
+// their hot path only if their hash does. Others may: a ScalableFilter allocates when it
+// grows, and the remote filters and a TieredFilter writing behind allocate on every call.
 type Filter interface {
 	Add([]byte)       // add an entry to the filter
 	Test([]byte) bool // test if an entry is in the filter
@@ -13,37 +40,343 @@ type Filter interface {
 	Reset()           // reset the filter to initial state
 }
 
+// TestAndAdder is a Filter that can add an entry and report whether it was already in it
+// with one hash of the entry. Add itself reports nothing, so that every Filter can implement
+// it; an entry for which TestAndAdd returns false is new, which is enough to count inserts
+// of distinct entries, up to false positives.
+type TestAndAdder interface {
+	Filter
+	TestAndAdd([]byte) bool // add an entry and report if it was in the filter
+}
+
+// TestAndAdd adds an entry to f and reports whether it was already in it, with one pass
+// if f is a TestAndAdder, or Test followed by Add otherwise.
+func TestAndAdd(f Filter, b []byte) bool {
+	if f, ok := f.(TestAndAdder); ok {
+		return f.TestAndAdd(b)
+	}
+	present := f.Test(b)
+	if !present {
+		f.Add(b)
+	}
+	return present
+}
+
 // Classic Bloom Filter
 type ClassicFilter struct {
-	B []byte
-	K int
-	H func([]byte) (uint64, uint64)
+	B     []byte
+	K     int
+	H     func([]byte) (uint64, uint64)
+	Probe Probe  // how offsets are derived from the two hashes, DoubleHashing by default
+	Seed  uint64 // mixed into the hashes if it is not 0, see NewSeeded
+
+	name     string  // name of the hash, if it was given a Hasher or decoded by name
+	m        uint64  // number of bits probes are reduced modulo, 8·len(B) if it is 0
+	n        int     // number of entries the filter was made for, if it is known
+	p        float64 // false positive rate the filter was made for, if it is known
+	readOnly bool
+	constant bool // Test reads every probe, see SetConstantTime
 }
 
 // New creates a classic Bloom Filter that is optimal for n entries and false positive rate of p.
-// H is a double hash that takes an entry and returns two different hashes.
+// H is a double hash that takes an entry and returns two different hashes. It should take
+// both from one pass over the entry, as the hashes of this package and hashes made with
+// From64 do, rather than hash the entry twice.
+//
+// Like every constructor of the package taking a rate, New panics with ErrInvalidRate if p
+// is not positive, and makes a filter of one word, the least there is, for n below 1 or
+// p of 1 or more. NewChecked returns errors for all of these instead.
+//
+// The size is computed in floating point with functions of package math, which some
+// architectures implement in assembly, so New with the same n and p could in rare cases
+// choose a different number of bits on another platform. Filters to be shared between
+// platforms should be shipped encoded, which records their geometry, or made with
+// NewWithBits.
 func New(n int, p float64, h func([]byte) (uint64, uint64)) Filter {
-	k := -math.Log(p) * math.Log2E   // number of hashes
-	m := float64(n) * k * math.Log2E // number of bits
-	return &ClassicFilter{B: make([]byte, int(m/8)), K: int(k), H: h}
+	return newClassic(n, p, h)
 }
 
-func (f *ClassicFilter) getOffset(x, y uint64, i int) uint64 {
-	return (x + uint64(i)*y) % (8 * uint64(len(f.B)))
+// NewChecked creates a classic Bloom Filter like New, but returns ErrInvalidCapacity,
+// ErrInvalidRate or ErrNilHash for parameters that would make the filter fail or
+// misbehave once used, instead of creating it.
+func NewChecked(n int, p float64, h func([]byte) (uint64, uint64)) (*ClassicFilter, error) {
+	switch {
+	case n <= 0:
+		return nil, ErrInvalidCapacity
+	case !(p > 0 && p < 1):
+		return nil, ErrInvalidRate
+	case h == nil:
+		return nil, ErrNilHash
+	}
+	if m, _ := optimal(n, p); !fits(m/8, 1) {
+		return nil, ErrTooLarge
+	}
+	return newClassic(n, p, h), nil
 }
 
-func (f *ClassicFilter) Add(b []byte) {
+// NewWithHasher creates a classic Bloom Filter that is optimal for n entries and false positive rate of p,
+// hashed with h and recording its name.
+func NewWithHasher(n int, p float64, h Hasher) *ClassicFilter {
+	f := newClassic(n, p, hashFunc(h))
+	f.name = h.Name()
+	return f
+}
+
+// NewPowerOfTwo creates a classic Bloom Filter for n entries and false positive rate of p
+// with the number of bits rounded up to a power of two, so offsets are reduced with a mask
+// instead of a division. Its false positive rate is at most p, and lower unless the
+// optimal size is a power of two.
+func NewPowerOfTwo(n int, p float64, h func([]byte) (uint64, uint64)) *ClassicFilter {
+	f := newClassicBits(n, p, make([]byte, powerOfTwoSize(n, p)), h)
+	f.m = 8 * uint64(len(f.B))
+	return f
+}
+
+// NewWithBits creates a classic Bloom Filter of exactly m bits, stored in m/8 bytes rounded
+// up, and k hashes, to match the geometry of a filter specified elsewhere.
+func NewWithBits(m uint64, k int, h func([]byte) (uint64, uint64)) *ClassicFilter {
+	if m < 1 || k < 1 {
+		panic("bloom: classic filter needs at least one bit and one hash")
+	}
+	return &ClassicFilter{B: make([]byte, byteLen(m)), K: k, H: h, m: m}
+}
+
+// NewDefault creates a classic Bloom Filter that is optimal for n entries and false positive rate of p,
+// using DefaultHash.
+func NewDefault(n int, p float64) Filter {
+	return newClassic(n, p, DefaultHash)
+}
+
+// NewFromBytes wraps the bit array b of a classic filter with k hashes as a read-only
+// filter without copying it, for filters in memory-mapped files or embedded with go:embed.
+// The filter has all 8·len(b) bits, so b must come from a filter whose BitCount is that,
+// such as one of NewPowerOfTwo; LoadFile and OpenMmap keep the bit count of any filter.
+// Add and Reset panic with ErrReadOnly, and Insert returns it.
+func NewFromBytes(b []byte, k int, h func([]byte) (uint64, uint64)) *ClassicFilter {
+	return &ClassicFilter{B: b, K: k, H: h, readOnly: true}
+}
+
+// NewWithBuffer creates a classic Bloom Filter that is optimal for n entries and false
+// positive rate of p over the first BufferSize(n, p) bytes of buf, which it clears, so
+// workloads that create and discard many filters can take their memory from a pool or an
+// arena. Reset keeps the buffer. It panics if the capacity of buf is smaller than that.
+func NewWithBuffer(n int, p float64, buf []byte, h func([]byte) (uint64, uint64)) *ClassicFilter {
+	size := BufferSize(n, p)
+	if cap(buf) < size {
+		panic("bloom: buffer is smaller than the filter")
+	}
+	f := newClassicBits(n, p, buf[:size], h)
+	clear(f.B)
+	return f
+}
+
+// NewSeeded creates a classic Bloom Filter that is optimal for n entries and false positive rate of p,
+// whose hashes are mixed with seed. Filters of the same keys with different seeds have
+// independent false positives, so an entry must be a false positive of all of them to pass
+// a stack of filters. The seed is included in every encoding of the filter.
+func NewSeeded(n int, p float64, seed uint64, h func([]byte) (uint64, uint64)) *ClassicFilter {
+	f := newClassic(n, p, h)
+	f.Seed = seed
+	return f
+}
+
+// NewRandomSeeded creates a classic Bloom Filter like NewSeeded with a random seed.
+func NewRandomSeeded(n int, p float64, h func([]byte) (uint64, uint64)) (*ClassicFilter, error) {
+	var seed [8]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	return NewSeeded(n, p, binary.LittleEndian.Uint64(seed[:]), h), nil
+}
+
+func newClassic(n int, p float64, h func([]byte) (uint64, uint64)) *ClassicFilter {
+	return newClassicBits(n, p, make([]byte, BufferSize(n, p)), h)
+}
+
+// newClassicBits creates a classic filter of the optimal number of bits for n entries and
+// false positive rate of p over the bit array b, which holds at least that many.
+func newClassicBits(n int, p float64, b []byte, h func([]byte) (uint64, uint64)) *ClassicFilter {
+	m, k := optimal(n, p)
+	// rates above one half would round to no hashes
+	return &ClassicFilter{B: b, K: max(int(k), 1), H: h, m: uint64(m), n: n, p: p}
+}
+
+// BufferSize returns the number of bytes of the bit array of a classic filter that is
+// optimal for n entries and false positive rate of p, which NewWithBuffer needs.
+func BufferSize(n int, p float64) int {
+	m, _ := optimal(n, p)
+	return elements(m/8, 1)
+}
+
+// byteLen returns the number of bytes that hold m bits.
+func byteLen(m uint64) uint64 { return m/8 + min(m%8, 1) }
+
+// fits reports whether a slice of n elements of size bytes, with n rounded up, fits in
+// memory that a slice can address.
+func fits(n float64, size int) bool { return math.Ceil(n)*float64(size) < math.MaxInt }
+
+// elements returns n rounded up to a whole number of elements of size bytes. It panics with
+// ErrTooLarge if they do not fit in a slice, rather than let the conversion to int wrap
+// to a wrong size, as it would on 32-bit platforms for filters of a few hundred million
+// entries.
+func elements(n float64, size int) int {
+	if !fits(n, size) {
+		panic(ErrTooLarge)
+	}
+	return int(math.Ceil(n))
+}
+
+// powerOfTwoSize returns the number of bytes of the bit array of NewPowerOfTwo.
+func powerOfTwoSize(n int, p float64) int {
+	shift := bits.Len(uint(BufferSize(n, p) - 1))
+	if shift >= bits.UintSize-1 {
+		panic(ErrTooLarge)
+	}
+	return 1 << shift
+}
+
+// optimal returns the number of bits m and the number of hashes k
+// that are optimal for n entries and false positive rate of p.
+// The optimal k is -log2(p), for m = -n·ln(p)/ln(2)², but k must be a whole number,
+// so m is the least number of bits whose rate (1-e^(-kn/m))^k with k rounded is at most p,
+// and at least 64.
+func optimal(n int, p float64) (m, k float64) {
+	p = checkRate(p)
+	k = max(math.Round(-math.Log2(p)), 1)                          // number of hashes
+	m = math.Ceil(-k * float64(n) / math.Log1p(-math.Pow(p, 1/k))) // number of bits
+	if !(m >= 64) {
+		// at least one word, for no entries or rates of 1 or more, which need no bits, and
+		// for filters so small that tests of their size would divide by zero
+		m = 64
+	}
+	return m, k
+}
+
+// checkRate returns the false positive rate p of a new filter, clamped to 1, since higher
+// rates ask for no more than any filter gives. It panics with ErrInvalidRate if p is not
+// positive, or NaN, which would ask for an infinite filter.
+func checkRate(p float64) float64 {
+	if !(p > 0) {
+		panic(ErrInvalidRate)
+	}
+	return min(p, 1)
+}
+
+// probes returns the probes of an entry with double hash x, y.
+func (f *ClassicFilter) probes(x, y uint64) probeSeq {
+	return f.Probe.seq(x, nonzero(x, y))
+}
+
+// reduceOffset reduces a probe to a bit offset modulo m, with a mask instead of a division
+// if m is a power of two, which gives the same offset.
+func reduceOffset(probe, m uint64) uint64 {
+	if m&(m-1) == 0 {
+		return probe & (m - 1)
+	}
+	return probe % m
+}
+
+// nonzero returns y, or a hash derived from x if y is 0, since a second hash of 0 would put
+// all probes of an entry on one offset. Entries whose second hash is not exactly 0 keep
+// their offsets, so filters built before the guard was added still find them.
+func nonzero(x, y uint64) uint64 {
+	if y == 0 {
+		return splitmix64(x) | 1
+	}
+	return y
+}
+
+// hash returns the double hash of an entry, mixed with the seed of the filter if it has one.
+func (f *ClassicFilter) hash(b []byte) (uint64, uint64) {
 	x, y := f.H(b)
+	if strict {
+		checkHash(f, x, y)
+	}
+	return f.mix(x, y)
+}
+
+// mix mixes the double hash x, y of an entry with the seed of the filter if it has one.
+func (f *ClassicFilter) mix(x, y uint64) (uint64, uint64) {
+	if f.Seed != 0 {
+		x, y = splitmix64(x^f.Seed), splitmix64(y^f.Seed)
+	}
+	return x, y
+}
+
+// header returns the header of the binary encoding of the filter with flags.
+func (f *ClassicFilter) header(flags byte) header {
+	h := header{Version: formatVersion, Flags: flags | f.Probe.flags(), K: uint32(f.K), M: f.BitCount()}
+	if f.Seed != 0 {
+		h.Flags |= flagSeeded
+		h.Seed = f.Seed
+	}
+	return h
+}
+
+// Add adds an entry to the filter. It panics if the filter is read-only.
+func (f *ClassicFilter) Add(b []byte) {
+	if f.readOnly {
+		panic(ErrReadOnly)
+	}
+	x, y := f.hash(b)
+	f.addHashes(x, y)
+}
+
+func (f *ClassicFilter) Test(b []byte) bool {
+	x, y := f.hash(b)
+	return f.testHashes(x, y)
+}
+
+// TestAndAdd adds an entry to the filter and reports whether it was already in it,
+// in one pass over its bits. It returns false exactly when the entry set at least one bit.
+// It panics if the filter is read-only.
+func (f *ClassicFilter) TestAndAdd(b []byte) bool {
+	if f.readOnly {
+		panic(ErrReadOnly)
+	}
+	x, y := f.hash(b)
+	return f.testAndAddHashes(x, y)
+}
+
+// addHashes adds an entry by its double hash.
+func (f *ClassicFilter) addHashes(x, y uint64) {
+	m, probes := f.BitCount(), f.probes(x, y)
 	for i := 0; i < f.K; i++ {
-		offset := f.getOffset(x, y, i)
+		offset := reduceOffset(f.Probe.finish(x, probes.next()), m)
+		if strict && !f.checkOffset(offset, m) {
+			continue
+		}
 		f.B[offset/8] |= 1 << (offset % 8)
 	}
 }
 
-func (f *ClassicFilter) Test(b []byte) bool {
-	x, y := f.H(b)
+// testAndAddHashes adds an entry by its double hash and reports whether it was present.
+// It reads and sets every probe whatever their bits, in constant time.
+func (f *ClassicFilter) testAndAddHashes(x, y uint64) bool {
+	m, probes := f.BitCount(), f.probes(x, y)
+	present := byte(1)
 	for i := 0; i < f.K; i++ {
-		offset := f.getOffset(x, y, i)
+		offset := reduceOffset(f.Probe.finish(x, probes.next()), m)
+		if strict && !f.checkOffset(offset, m) {
+			continue
+		}
+		present &= f.B[offset/8] >> (offset % 8)
+		f.B[offset/8] |= 1 << (offset % 8)
+	}
+	return present&1 != 0
+}
+
+// testHashes tests an entry by its double hash.
+func (f *ClassicFilter) testHashes(x, y uint64) bool {
+	if f.constant {
+		return f.testAllHashes(x, y)
+	}
+	m, probes := f.BitCount(), f.probes(x, y)
+	for i := 0; i < f.K; i++ {
+		offset := reduceOffset(f.Probe.finish(x, probes.next()), m)
+		if strict && !f.checkOffset(offset, m) {
+			continue
+		}
 		if f.B[offset/8]&(1<<(offset%8)) == 0 {
 			return false
 		}
@@ -51,10 +384,118 @@ func (f *ClassicFilter) Test(b []byte) bool {
 	return true
 }
 
+// testAllHashes tests an entry by its double hash like testHashes, reading every probe and
+// combining their bits without branches, so it takes as long whatever the bits are.
+func (f *ClassicFilter) testAllHashes(x, y uint64) bool {
+	m, probes := f.BitCount(), f.probes(x, y)
+	present := byte(1)
+	for i := 0; i < f.K; i++ {
+		offset := reduceOffset(f.Probe.finish(x, probes.next()), m)
+		if strict && !f.checkOffset(offset, m) {
+			continue
+		}
+		present &= f.B[offset/8] >> (offset % 8)
+	}
+	return present&1 != 0
+}
+
+// SetConstantTime makes Test, TestHash and TestMany of the filter read all K probes of an
+// entry and combine their bits without branches, rather than return at the first clear
+// bit, so their timing does not tell whether an entry is in the filter, nor how many of
+// its bits are set, to an adversary who can time them, as in a handshake. TestAndAdd
+// always does. The memory the probes read still depends on the entry, as it would for any
+// filter, so the caches can tell which entry was tested.
+func (f *ClassicFilter) SetConstantTime(on bool) { f.constant = on }
+
+// ConstantTime reports whether the filter tests in constant time, see SetConstantTime.
+func (f *ClassicFilter) ConstantTime() bool { return f.constant }
+
 func (f *ClassicFilter) Size() int { return len(f.B) }
 
+// HashCount returns the number of hashes of an entry, K.
+func (f *ClassicFilter) HashCount() int { return f.K }
+
+// BitCount returns the number of bits of the filter, m, which probes are reduced modulo.
+// It is the optimal number for the filters of New and the like, which is not a multiple
+// of 8 in general, so the last byte of B may have unused bits.
+func (f *ClassicFilter) BitCount() uint64 {
+	if f.m != 0 {
+		return f.m
+	}
+	return 8 * uint64(len(f.B))
+}
+
+// Capacity returns the number of entries the filter was made for. Encodings do not record
+// it, so for decoded filters it is derived from the number of bits and K as if they were
+// optimal.
+func (f *ClassicFilter) Capacity() int {
+	if f.n > 0 || f.K == 0 {
+		return f.n
+	}
+	return int(float64(f.BitCount()) * math.Ln2 / float64(f.K))
+}
+
+// TargetRate returns the false positive rate the filter was made for, which is derived
+// from K for decoded filters like Capacity.
+func (f *ClassicFilter) TargetRate() float64 {
+	if f.p > 0 {
+		return f.p
+	}
+	return math.Pow(0.5, float64(f.K))
+}
+
+// Reset resets the filter to initial state. It panics if the filter is read-only.
 func (f *ClassicFilter) Reset() {
-	for i := range f.B {
-		f.B[i] = 0
+	if f.readOnly {
+		panic(ErrReadOnly)
+	}
+	clear(f.B)
+}
+
+// Insert adds an entry to the filter, or returns ErrReadOnly if the filter is read-only.
+func (f *ClassicFilter) Insert(b []byte) error {
+	if f.readOnly {
+		return ErrReadOnly
+	}
+	f.Add(b)
+	return nil
+}
+
+// Clone returns a deep copy of the filter, which can be changed and discarded without
+// affecting f. The copy of a read-only filter is not read-only.
+func (f *ClassicFilter) Clone() *ClassicFilter {
+	c := *f
+	c.B, c.readOnly = append([]byte(nil), f.B...), false
+	return &c
+}
+
+// Resize returns a new filter that is optimal for n entries and false positive rate of p,
+// with the hash, probe scheme and seed of f, holding the entries yielded by source. Bloom
+// filters cannot enumerate their entries, so source must yield them again, for example
+// from the store the filter indexes.
+func (f *ClassicFilter) Resize(n int, p float64, source iter.Seq[[]byte]) *ClassicFilter {
+	r := newClassic(n, p, f.H)
+	r.Probe, r.Seed, r.name = f.Probe, f.Seed, f.name
+	for b := range source {
+		r.Add(b)
+	}
+	return r
+}
+
+// ReadOnly reports whether the filter is read-only.
+func (f *ClassicFilter) ReadOnly() bool { return f.readOnly }
+
+// Hasher returns the hash of the filter with its name, which is empty if the filter was
+// not created with a Hasher and its hash is not registered.
+func (f *ClassicFilter) Hasher() Hasher {
+	name, _ := f.hashName()
+	return NewHasher(name, f.H)
+}
+
+// hashName returns the name of the hash of the filter, if it is known.
+func (f *ClassicFilter) hashName() (string, bool) {
+	if f.name != "" {
+		return f.name, true
 	}
+	return HashName(f.H)
 }
@@ -1,10 +1,17 @@
 package bloom
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"slices"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func doubleFNV(b []byte) (uint64, uint64) {
@@ -17,6 +24,12 @@ func doubleFNV(b []byte) (uint64, uint64) {
 	return x, y
 }
 
+// doubleSHA is slow but well distributed, for tests that measure the FPR.
+func doubleSHA(b []byte) (uint64, uint64) {
+	sum := sha256.Sum256(b)
+	return binary.LittleEndian.Uint64(sum[:8]), binary.LittleEndian.Uint64(sum[8:16])
+}
+
 func TestClassicFilter_Test(t *testing.T) {
 	bf := New(1e6, 1e-4, doubleFNV)
 	buf := []byte("testing")
@@ -53,6 +66,122 @@ func TestFalsePositive(t *testing.T) {
 	t.Logf("Samples = %d, FP = %d, FPR = %.4f%%", int(n), fp, fpr*100)
 }
 
+func TestOptimal(t *testing.T) {
+	// rate returns the false positive rate of a filter of m bits and k hashes with n entries.
+	rate := func(n int, m, k float64) float64 { return math.Pow(-math.Expm1(-k*float64(n)/m), k) }
+	for _, p := range []float64{0.5, 0.1, 0.01, 1e-3, 1e-4, 1e-6} {
+		for _, n := range []int{1, 10, 1000, 1e6} {
+			bf := newClassic(n, p, doubleFNV)
+			k, m := float64(bf.K), float64(bf.BitCount())
+			if want := max(math.Round(-math.Log2(p)), 1); k != want {
+				t.Errorf("Filter for %d entries at %v should have %v hashes but has %v", n, p, want, k)
+			}
+			if r := rate(n, m, k); r > p {
+				t.Errorf("Filter for %d entries at %v has a rate of %v", n, p, r)
+			}
+			if m > 64 && rate(n, m-8, k) <= p {
+				t.Errorf("Filter for %d entries at %v should not have a byte more than needed", n, p)
+			}
+		}
+	}
+}
+
+func TestDegenerateSizes(t *testing.T) {
+	h := DefaultHash
+	for _, c := range []struct {
+		n int
+		p float64
+	}{{0, 0.01}, {-1, 0.01}, {1, 0.9}, {2, 0.6}, {1, 1}, {0, 1}} {
+		filters := map[string]Filter{
+			"aging":       NewAging(c.n, c.p, time.Hour, h),
+			"atomic":      NewAtomic(c.n, c.p, h),
+			"blocked":     NewBlocked(c.n, c.p, h),
+			"chunked":     NewChunked(c.n, c.p, h),
+			"classic":     New(c.n, c.p, h),
+			"counting":    NewCounting(c.n, c.p, h),
+			"cow":         NewCopyOnWrite(c.n, c.p, h),
+			"deletable":   NewDeletable(c.n, c.p, 4, h),
+			"partitioned": NewPartitioned(c.n, c.p, h),
+			"power-of-2":  NewPowerOfTwo(c.n, c.p, h),
+			"quotient":    NewQuotient(c.n, c.p, h),
+			"shifting":    NewShifting(c.n, c.p, 2, h),
+			"sparse":      NewSparse(c.n, c.p, h),
+			"spectral":    NewSpectral(c.n, c.p, h),
+			"weighted":    NewWeighted(c.n, c.p, h, func([]byte) float64 { return 1 }),
+		}
+		for name, f := range filters {
+			if f.Size() < 8 {
+				t.Errorf("%s filter for %d entries at %v should have at least a word but has %d bytes", name, c.n, c.p, f.Size())
+			}
+			f.Add([]byte("hello"))
+			if !f.Test([]byte("hello")) {
+				t.Errorf("%s filter for %d entries at %v should hold an entry", name, c.n, c.p)
+			}
+		}
+	}
+}
+
+func TestInvalidRate(t *testing.T) {
+	constructors := map[string]func(p float64){
+		"classic":     func(p float64) { New(1e3, p, doubleFNV) },
+		"blocked":     func(p float64) { NewBlocked(1e3, p, doubleFNV) },
+		"d-left":      func(p float64) { NewDLeft(1e3, p, doubleFNV) },
+		"quotient":    func(p float64) { NewQuotient(1e3, p, doubleFNV) },
+		"split block": func(p float64) { NewSplitBlock(1e3, p) },
+	}
+	for name, create := range constructors {
+		for _, p := range []float64{0, -0.1, math.NaN()} {
+			func() {
+				defer func() {
+					if r := recover(); r != ErrInvalidRate {
+						t.Errorf("%s filter at %v should panic with ErrInvalidRate but got %v", name, p, r)
+					}
+				}()
+				create(p)
+			}()
+		}
+		create(2) // clamped
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	if _, err := NewChecked(math.MaxInt, 1e-3, doubleFNV); err != ErrTooLarge {
+		t.Fatalf("NewChecked(MaxInt) should fail with ErrTooLarge but got %v", err)
+	}
+	constructors := map[string]func(){
+		"classic":      func() { New(math.MaxInt, 1e-3, doubleFNV) },
+		"power of two": func() { NewPowerOfTwo(math.MaxInt/2, 1e-3, doubleFNV) },
+		"blocked":      func() { NewBlocked(math.MaxInt, 1e-3, doubleFNV) },
+		"atomic":       func() { NewAtomic(math.MaxInt, 1e-3, doubleFNV) },
+		"counting":     func() { NewCounting(math.MaxInt, 1e-3, doubleFNV) },
+	}
+	for name, create := range constructors {
+		func() {
+			defer func() {
+				if r := recover(); r != ErrTooLarge {
+					t.Errorf("%s filter should panic with ErrTooLarge but got %v", name, r)
+				}
+			}()
+			create()
+		}()
+	}
+}
+
+func TestFalsePositiveRate(t *testing.T) {
+	const n, filters = 1e4, 10
+	for _, p := range []float64{0.1, 0.01, 1e-3} {
+		fpr := 0.0
+		for seed := uint64(1); seed <= filters; seed++ {
+			fpr += fpRate(NewSeeded(n, p, seed, DefaultHash), n) / filters
+		}
+		// allow three standard deviations of the 1e5 samples of each filter
+		if limit := p + 3*math.Sqrt(p/(filters*1e5)); fpr > limit {
+			t.Errorf("False positive rate at %v should be at most %v but is %v", p, limit, fpr)
+		}
+		t.Logf("p = %v, FPR = %v", p, fpr)
+	}
+}
+
 func BenchmarkAdd(b *testing.B) {
 	b.StopTimer()
 	b.ReportAllocs()
@@ -76,3 +205,481 @@ func BenchmarkTest(b *testing.B) {
 		bf.Test(buf)
 	}
 }
+
+func TestNewFromBytes(t *testing.T) {
+	bf := NewPowerOfTwo(1000, 0.01, doubleFNV)
+	bf.Add([]byte("hello"))
+	ro := NewFromBytes(bf.B, bf.K, doubleFNV)
+	if !ro.ReadOnly() || !ro.Test([]byte("hello")) {
+		t.Fatal("Should exist in wrapped filter but got false")
+	}
+	bf.Add([]byte("world"))
+	if !ro.Test([]byte("world")) {
+		t.Fatal("Wrapped filter should share memory with its bytes")
+	}
+	if err := ro.Insert([]byte("nope")); err != ErrReadOnly {
+		t.Fatalf("Insert error = %v, want %v", err, ErrReadOnly)
+	}
+	defer func() {
+		if recover() != ErrReadOnly {
+			t.Fatal("Add to a read-only filter should panic with ErrReadOnly")
+		}
+	}()
+	ro.Add([]byte("nope"))
+}
+
+func TestClassicFilter_TestAndAdd(t *testing.T) {
+	bf := New(1000, 0.01, doubleFNV).(*ClassicFilter)
+	if bf.TestAndAdd([]byte("hello")) {
+		t.Fatal("New entry should not be present")
+	}
+	if !bf.TestAndAdd([]byte("hello")) || !bf.Test([]byte("hello")) {
+		t.Fatal("Added entry should be present")
+	}
+}
+
+func TestClassicFilter_TestAndAddCountsNew(t *testing.T) {
+	bf := newClassic(1e3, 1e-3, doubleFNV)
+	added := 0
+	for i := 0; i < 2e3; i++ {
+		before := bf.setBits()
+		if !bf.TestAndAdd([]byte(strconv.Itoa(i % 1e3))) {
+			added++
+			if bf.setBits() == before {
+				t.Fatalf("Entry %d reported as new should set a bit", i)
+			}
+		} else if bf.setBits() != before {
+			t.Fatalf("Entry %d reported as present should set no bit", i)
+		}
+	}
+	if added > 1e3 || added < 990 {
+		t.Fatalf("About 1000 distinct entries should be new but %d were", added)
+	}
+}
+
+func TestTestAndAdd(t *testing.T) {
+	delta := NewDelta(1000, 0.01, doubleFNV)
+	for _, f := range []Filter{
+		New(1000, 0.01, doubleFNV),
+		NewAtomic(1000, 0.01, doubleFNV),
+		NewRotating(1000, 0.01, time.Hour, doubleFNV),
+		delta,
+		NewCounting(1000, 0.01, doubleFNV), // not a TestAndAdder
+	} {
+		for i := 0; i < 100; i++ {
+			if TestAndAdd(f, []byte(strconv.Itoa(i))) {
+				t.Fatalf("%T: new entry %d should not be present", f, i)
+			}
+		}
+		for i := 0; i < 100; i++ {
+			if !TestAndAdd(f, []byte(strconv.Itoa(i))) || !f.Test([]byte(strconv.Itoa(i))) {
+				t.Fatalf("%T: added entry %d should be present", f, i)
+			}
+		}
+	}
+	if delta.Pending() == 0 {
+		t.Fatal("Delta filter should track bits set by TestAndAdd")
+	}
+}
+
+func TestClassicFilter_ZeroSecondHash(t *testing.T) {
+	// a single 64-bit hash passed off as a double hash, which strict mode reports
+	recordViolations(t)
+	single := func(b []byte) (uint64, uint64) {
+		x, _ := doubleSHA(b)
+		return x, 0
+	}
+	bf := New(1e4, 1e-3, single)
+	for i := 0; i < 1e4; i++ {
+		bf.Add([]byte(strconv.Itoa(i)))
+	}
+	fp := 0
+	for i := 0; i < 1e5; i++ {
+		if bf.Test([]byte("absent" + strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if fp > 200 {
+		t.Fatalf("False positive rate with a zero second hash is %d/100000, want about 100", fp)
+	}
+}
+
+func TestNewSeeded(t *testing.T) {
+	a := NewSeeded(1e3, 1e-2, 1, doubleFNV)
+	b := NewSeeded(1e3, 1e-2, 2, doubleFNV)
+	for i := 0; i < 1e3; i++ {
+		a.Add([]byte(strconv.Itoa(i)))
+		b.Add([]byte(strconv.Itoa(i)))
+	}
+	fpA, fpBoth := 0, 0
+	for i := 1000; i < 100000; i++ {
+		x := []byte(strconv.Itoa(i))
+		if a.Test(x) {
+			fpA++
+			if b.Test(x) {
+				fpBoth++
+			}
+		}
+	}
+	t.Logf("FP = %d, FP of both = %d", fpA, fpBoth)
+	if fpA == 0 || fpBoth*10 > fpA {
+		t.Fatalf("Filters with different seeds should have independent false positives but %d of %d are shared", fpBoth, fpA)
+	}
+
+	r, err := NewRandomSeeded(1e3, 1e-2, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Seed == 0 {
+		t.Fatal("Random seed should not be 0")
+	}
+}
+
+func TestNewSeeded_Encoding(t *testing.T) {
+	bf := NewSeeded(1e3, 1e-3, 0x5eed, doubleFNV)
+	bf.Add([]byte("hello"))
+	data, _ := bf.MarshalBinary()
+	decoded := &ClassicFilter{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bf.WriteTo(&buf)
+	fromStream := &ClassicFilter{H: doubleFNV}
+	if _, err := fromStream.ReadFrom(&buf); err != nil || buf.Len() != 0 {
+		t.Fatal(err, buf.Len())
+	}
+	fromString := &ClassicFilter{H: doubleFNV}
+	if err := fromString.DecodeString(bf.EncodeString()); err != nil {
+		t.Fatal(err)
+	}
+	z, _ := bf.MarshalCompressed()
+	fromCompressed := &ClassicFilter{H: doubleFNV}
+	if err := fromCompressed.UnmarshalBinary(z); err != nil {
+		t.Fatal(err)
+	}
+	j, _ := json.Marshal(bf)
+	fromJSON := &ClassicFilter{H: doubleFNV}
+	if err := json.Unmarshal(j, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := ToProto(bf)
+	fromProto, err := FromProto(p, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*ClassicFilter{decoded, fromStream, fromString, fromCompressed, fromJSON, fromProto.(*ClassicFilter)} {
+		if f.Seed != bf.Seed || !bytes.Equal(f.B, bf.B) || !f.Test([]byte("hello")) {
+			t.Fatal("Decoded filter should keep the seed")
+		}
+	}
+
+	// a seed of 0 means the filter is not seeded
+	data[1] |= flagSeeded
+	data = append(data[:headerSize], append(make([]byte, 8), data[headerSize:]...)...)
+	if err := decoded.UnmarshalBinary(data); err != ErrInvalidEncoding {
+		t.Fatalf("Zero seed should fail with ErrInvalidEncoding but got %v", err)
+	}
+}
+
+func TestClassicFilter_Clone(t *testing.T) {
+	bf := NewSeeded(1000, 0.01, 3, doubleFNV)
+	bf.Probe = EnhancedDoubleHashing
+	bf.Add([]byte("hello"))
+	c := bf.Clone()
+	c.Add([]byte("world"))
+	if !c.Test([]byte("hello")) || !c.Test([]byte("world")) {
+		t.Fatal("Clone should keep the entries and parameters of the filter")
+	}
+	if bf.Test([]byte("world")) {
+		t.Fatal("Adding to a clone should not change the filter")
+	}
+	if NewFromBytes(bf.B, bf.K, doubleFNV).Clone().ReadOnly() {
+		t.Fatal("Clone of a read-only filter should not be read-only")
+	}
+}
+
+func TestClassicFilter_Parameters(t *testing.T) {
+	bf := NewSeeded(1e4, 1e-3, 1, doubleFNV)
+	if bf.HashCount() != bf.K || byteLen(bf.BitCount()) != uint64(len(bf.B)) || bf.Capacity() != 1e4 || bf.TargetRate() != 1e-3 {
+		t.Fatalf("Parameters should be those the filter was made with but got %d, %d, %d, %v",
+			bf.HashCount(), bf.BitCount(), bf.Capacity(), bf.TargetRate())
+	}
+	data, _ := bf.MarshalBinary()
+	decoded := &ClassicFilter{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if n := decoded.Capacity(); n < 0.85e4 || n > 1.15e4 {
+		t.Fatalf("Derived capacity should be about 1e4 but got %d", n)
+	}
+	if p := decoded.TargetRate(); p < 0.5e-3 || p > 2e-3 {
+		t.Fatalf("Derived rate should be about 1e-3 but got %v", p)
+	}
+}
+
+func TestNewWithBits(t *testing.T) {
+	bf := NewWithBits(1001, 3, doubleFNV)
+	if bf.BitCount() != 1001 || len(bf.B) != 126 || bf.K != 3 {
+		t.Fatalf("Filter should have 1001 bits and 3 hashes but got %d and %d", bf.BitCount(), bf.K)
+	}
+	bf.Add([]byte("hello"))
+	if !bf.Test([]byte("hello")) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Filter without hashes should panic")
+		}
+	}()
+	NewWithBits(1000, 0, doubleFNV)
+}
+
+func TestClassicFilter_BitCount(t *testing.T) {
+	bf := newClassic(1e3, 1e-2, doubleFNV)
+	if m, _ := optimal(1e3, 1e-2); bf.BitCount() != uint64(m) || bf.BitCount()%8 == 0 {
+		t.Fatalf("Filter should have the optimal %v bits, not a multiple of 8, but has %d", m, bf.BitCount())
+	}
+	for i := 0; i < 1e4; i++ {
+		bf.Add([]byte(strconv.Itoa(i)))
+	}
+	if unused := bf.B[len(bf.B)-1] >> (bf.BitCount() % 8); unused != 0 {
+		t.Fatalf("Probes should stay below the bit count but set %08b of the last byte", bf.B[len(bf.B)-1])
+	}
+
+	// every encoding keeps the bit count
+	binary, _ := bf.MarshalBinary()
+	compressed, _ := bf.MarshalCompressed()
+	for name, data := range map[string][]byte{"binary": binary, "compressed": compressed} {
+		decoded := &ClassicFilter{H: doubleFNV}
+		if err := decoded.UnmarshalBinary(data); err != nil || !decoded.Equal(bf) {
+			t.Fatalf("Decoded %s filter should equal the original, got %v", name, err)
+		}
+	}
+	js, _ := json.Marshal(bf)
+	decoded := &ClassicFilter{H: doubleFNV}
+	if err := json.Unmarshal(js, decoded); err != nil || !decoded.Equal(bf) {
+		t.Fatalf("Decoded JSON filter should equal the original, got %v", err)
+	}
+	pb, _ := ToProto(bf)
+	if f, err := FromProto(pb, doubleFNV); err != nil || !f.(*ClassicFilter).Equal(bf) {
+		t.Fatalf("Decoded proto filter should equal the original, got %v", err)
+	}
+
+	// bits of the last byte past the bit count are not part of the filter
+	for i := range bf.B {
+		bf.B[i] = 0xff
+	}
+	if n := bf.Bits().OnesCount(); n != int(bf.BitCount()) {
+		t.Fatalf("Full filter should have all %d bits set but has %d", bf.BitCount(), n)
+	}
+}
+
+func TestNewWithBuffer(t *testing.T) {
+	buf := make([]byte, 0, BufferSize(1e3, 1e-3)+10)
+	buf = append(buf, 0xff)
+	bf := NewWithBuffer(1e3, 1e-3, buf, doubleFNV)
+	want := newClassic(1e3, 1e-3, doubleFNV)
+	if &bf.B[0] != &buf[:1][0] || bf.Size() != want.Size() || bf.K != want.K || bf.Capacity() != 1e3 {
+		t.Fatal("Filter should be sized for its entries over the buffer")
+	}
+	if bf.Test([]byte("hello")) || bf.FillRatio() != 0 {
+		t.Fatal("Filter over a buffer should be empty")
+	}
+	bf.Add([]byte("hello"))
+	bf.Reset()
+	if &bf.B[0] != &buf[:1][0] || bf.Test([]byte("hello")) {
+		t.Fatal("Reset should clear the buffer in place")
+	}
+	if n := testing.AllocsPerRun(10, func() { NewWithBuffer(1e3, 1e-3, buf, doubleFNV) }); n > 1 {
+		t.Fatalf("Filter over a buffer should not allocate its bits but made %v allocations", n)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Buffer smaller than the filter should panic")
+		}
+	}()
+	NewWithBuffer(1e4, 1e-3, buf, doubleFNV)
+}
+
+func TestNewChecked(t *testing.T) {
+	for _, c := range []struct {
+		n   int
+		p   float64
+		h   func([]byte) (uint64, uint64)
+		err error
+	}{
+		{0, 0.01, doubleFNV, ErrInvalidCapacity},
+		{-1, 0.01, doubleFNV, ErrInvalidCapacity},
+		{100, 0, doubleFNV, ErrInvalidRate},
+		{100, 1, doubleFNV, ErrInvalidRate},
+		{100, math.NaN(), doubleFNV, ErrInvalidRate},
+		{100, 0.01, nil, ErrNilHash},
+	} {
+		if _, err := NewChecked(c.n, c.p, c.h); err != c.err {
+			t.Fatalf("NewChecked(%d, %v) should fail with %v but got %v", c.n, c.p, c.err, err)
+		}
+	}
+
+	// parameters at the edges still make a working filter
+	for _, p := range []float64{0.9, 0.5, 1e-9} {
+		bf, err := NewChecked(1, p, doubleFNV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bf.Add([]byte("hello"))
+		if !bf.Test([]byte("hello")) || bf.K < 1 {
+			t.Fatalf("Filter for rate %v should work but has K = %d", p, bf.K)
+		}
+	}
+}
+
+func TestClassicFilter_Resize(t *testing.T) {
+	keys := make([][]byte, 5000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	bf := NewSeeded(1000, 0.01, 5, doubleFNV)
+	bf.AddMany(keys)
+	r := bf.Resize(10000, 0.001, slices.Values(keys))
+	if r.Seed != bf.Seed || r.Capacity() != 10000 || r.Size() <= bf.Size() {
+		t.Fatal("Resized filter should keep the seed and have the new size")
+	}
+	for _, key := range keys {
+		if !r.Test(key) {
+			t.Fatalf("Entry %s should be in the resized filter", key)
+		}
+	}
+	if rate := r.CurrentFalsePositiveRate(); rate > 0.002 || rate >= bf.CurrentFalsePositiveRate() {
+		t.Fatalf("Resized filter should have a rate close to 0.001 but has %v", rate)
+	}
+}
+
+func TestNewPowerOfTwo(t *testing.T) {
+	for _, n := range []int{1, 100, 1000, 1 << 14} {
+		bf := NewPowerOfTwo(n, 0.01, doubleFNV)
+		if m := bf.BitCount(); m&(m-1) != 0 || m < newClassic(n, 0.01, doubleFNV).BitCount() {
+			t.Fatalf("Filter for %d entries should have a power of two bits but has %d", n, m)
+		}
+	}
+	// masking gives the offsets a division would, so encodings keep working
+	bf := NewPowerOfTwo(1000, 0.01, doubleFNV)
+	bf.Add([]byte("hello"))
+	x, y := doubleFNV([]byte("hello"))
+	for i := 0; i < bf.K; i++ {
+		offset := bf.Probe.probe(x, nonzero(x, y), i) % bf.BitCount()
+		if !bf.Bits().Get(offset) {
+			t.Fatalf("Bit %d of probe %d should be set", offset, i)
+		}
+	}
+	if rate := fpRate(NewPowerOfTwo(1e4, 1e-3, doubleSHA), 1e4); rate > 1e-3 {
+		t.Fatalf("Rate %v should be at most 1e-3", rate)
+	}
+	if _, ok := NewWithOptions(1000, 0.01, WithPowerOfTwo()).(*ClassicFilter); !ok {
+		t.Fatal("Power of two option should make a classic filter")
+	}
+}
+
+func BenchmarkTest_PowerOfTwo(b *testing.B) {
+	bf := NewPowerOfTwo(1e6, 1e-4, doubleFNV)
+	buf := make([]byte, 20)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		binary.PutUvarint(buf, uint64(i))
+		bf.Test(buf)
+	}
+}
+
+// builtinHashes are the hashes of the package, by name.
+var builtinHashes = map[string]func([]byte) (uint64, uint64){
+	"default":        DefaultHash,
+	"murmur3":        Murmur3Hash,
+	"murmur3-seeded": Murmur3Seeded(7),
+	"xxhash":         XXHash,
+	"xxhash-seeded":  XXHashSeeded(7, 11),
+}
+
+func TestClassicFilter_ConstantTime(t *testing.T) {
+	for _, probe := range []Probe{DoubleHashing, EnhancedDoubleHashing, IndependentHashing} {
+		bf := newClassic(1e3, 1e-2, doubleFNV)
+		bf.Probe = probe
+		ct := bf.Clone()
+		ct.SetConstantTime(true)
+		if !ct.ConstantTime() || bf.ConstantTime() {
+			t.Fatal("SetConstantTime should set the mode of the filter only")
+		}
+		keys := make([][]byte, 3000)
+		for i := range keys {
+			keys[i] = []byte(strconv.Itoa(i))
+			if i%3 == 0 {
+				bf.Add(keys[i])
+				ct.Add(keys[i])
+			}
+		}
+		for _, b := range keys {
+			if bf.Test(b) != ct.Test(b) || bf.TestHash(HashFields(bf.H, b)) != ct.TestHash(HashFields(ct.H, b)) {
+				t.Fatalf("%v: constant-time Test of %q should agree with Test", probe, b)
+			}
+		}
+		want := bf.TestMany(keys)
+		if !slices.Equal(ct.TestMany(keys), want) || !slices.Equal(ct.testManyInterleaved(keys), want) {
+			t.Fatalf("%v: constant-time TestMany should agree with TestMany", probe)
+		}
+		if c := ct.Clone(); !c.ConstantTime() {
+			t.Fatal("Clone should keep the constant-time mode")
+		}
+	}
+}
+
+func TestZeroAllocs(t *testing.T) {
+	key := []byte("an entry of a few dozen bytes, 0123456789")
+	for name, h := range builtinHashes {
+		filters := map[string]Filter{
+			"classic":     newClassic(1e4, 1e-3, h),
+			"seeded":      NewSeeded(1e4, 1e-3, 1, h),
+			"constant":    NewWithOptions(1e4, 1e-3, WithHash(h), WithConstantTime()),
+			"power-of-2":  NewPowerOfTwo(1e4, 1e-3, h),
+			"atomic":      NewAtomic(1e4, 1e-3, h),
+			"blocked":     NewBlocked(1e4, 1e-3, h),
+			"partitioned": NewPartitioned(1e4, 1e-3, h),
+			"counting":    NewCounting(1e4, 1e-3, h),
+		}
+		for kind, f := range filters {
+			if n := testing.AllocsPerRun(100, func() {
+				f.Add(key)
+				f.Test(key)
+			}); n != 0 {
+				t.Errorf("Add and Test of a %s filter with %s should not allocate but made %v allocations", kind, name, n)
+			}
+		}
+	}
+}
+
+// BenchmarkTest_ConstantTime compares the tests of absent entries, which usually stop at
+// the first probe, with and without constant time.
+func BenchmarkTest_ConstantTime(b *testing.B) {
+	for _, constant := range []bool{false, true} {
+		bf := newClassic(1e6, 1e-4, DefaultHash)
+		bf.SetConstantTime(constant)
+		key := []byte("absent")
+		b.Run(fmt.Sprint("constant=", constant), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bf.Test(key)
+			}
+		})
+	}
+}
+
+func BenchmarkAddTest_Hashes(b *testing.B) {
+	key := []byte("an entry of a few dozen bytes, 0123456789")
+	for name, h := range builtinHashes {
+		bf := newClassic(1e6, 1e-4, h)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bf.Add(key)
+				bf.Test(key)
+			}
+		})
+	}
+}
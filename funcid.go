@@ -0,0 +1,19 @@
+//go:build !tinygo
+
+package bloom
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// funcPC returns the code pointer of a function value, which is the same for every closure
+// made by a function literal.
+func funcPC(h func([]byte) (uint64, uint64)) uintptr { return reflect.ValueOf(h).Pointer() }
+
+// funcID returns the identity of a function value: the address of its closure, which is
+// the same for every value of a top-level function and differs between closures made by
+// separate calls, even of the same function literal, unlike its code pointer.
+func funcID(h func([]byte) (uint64, uint64)) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&h))
+}
@@ -0,0 +1,22 @@
+//go:build tinygo
+
+package bloom
+
+import "unsafe"
+
+// funcValue is a function value as TinyGo represents it: the context of a closure, nil for
+// a top-level function, and the code pointer, rather than a pointer to a closure. Its
+// reflect does not take the pointer of functions.
+type funcValue struct{ context, fn unsafe.Pointer }
+
+func funcPC(h func([]byte) (uint64, uint64)) uintptr {
+	return uintptr((*funcValue)(unsafe.Pointer(&h)).fn)
+}
+
+func funcID(h func([]byte) (uint64, uint64)) uintptr {
+	v := (*funcValue)(unsafe.Pointer(&h))
+	if v.context != nil {
+		return uintptr(v.context)
+	}
+	return uintptr(v.fn)
+}
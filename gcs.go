@@ -0,0 +1,166 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// Golomb-Coded Set
+//
+// A golomb-coded set is an immutable, compressed representation of a set of
+// keys. Each key is hashed to a value in [0, N*2^P), and the sorted
+// differences between values are Golomb-Rice coded with parameter P. It
+// takes about P+1.5 bits per key for a false positive rate of 2^-P, close to
+// the optimum, which makes it suited for transmission. Test decodes the set
+// sequentially and takes time linear in its size.
+type GCS struct {
+	D []byte // Golomb-Rice coded differences
+	N uint64 // number of keys
+	P uint8  // Golomb-Rice parameter, log2 of the inverse false positive rate
+	H func([]byte) (uint64, uint64)
+}
+
+// BuildGCS builds a golomb-coded set of keys with a false positive rate of 2^-p.
+func BuildGCS(keys [][]byte, p uint8, h func([]byte) (uint64, uint64)) (*GCS, error) {
+	if p < 1 || p > 32 {
+		return nil, errors.New("bloom: GCS parameter must be between 1 and 32")
+	}
+	s := &GCS{N: uint64(len(keys)), P: p, H: h}
+	values := make([]uint64, len(keys))
+	for i, k := range keys {
+		values[i] = s.value(k)
+	}
+	values = unique(values)
+
+	var w bitWriter
+	last := uint64(0)
+	for _, v := range values {
+		d := v - last
+		last = v
+		for q := d >> p; q > 0; q-- {
+			w.writeBit(1)
+		}
+		w.writeBit(0)
+		w.writeBits(d, uint(p))
+	}
+	s.D = w.b
+	return s, nil
+}
+
+// value maps a key to [0, N*2^P).
+func (s *GCS) value(b []byte) uint64 {
+	x, _ := s.H(b)
+	hi, _ := bits.Mul64(x, s.N<<s.P)
+	return hi
+}
+
+// Test tests if an entry is in the set.
+func (s *GCS) Test(b []byte) bool {
+	if s.N == 0 {
+		return false
+	}
+	target := s.value(b)
+	r := bitReader{b: s.D}
+	v := uint64(0)
+	for {
+		q := uint64(0)
+		for {
+			bit, ok := r.readBit()
+			if !ok {
+				return false
+			}
+			if bit == 0 {
+				break
+			}
+			q++
+		}
+		rem, ok := r.readBits(uint(s.P))
+		if !ok {
+			return false
+		}
+		v += q<<s.P | rem
+		if v == target {
+			return true
+		} else if v > target {
+			return false
+		}
+	}
+}
+
+// Size returns the size of the set in bytes.
+func (s *GCS) Size() int { return len(s.D) }
+
+// MarshalBinary encodes a version header, the parameters and the coded set.
+// The hash function is not encoded.
+func (s *GCS) MarshalBinary() ([]byte, error) {
+	b := appendTyped(make([]byte, 0, typedHeaderSize+9+len(s.D)), typeGCS)
+	b = binary.LittleEndian.AppendUint64(b, s.N)
+	b = append(b, s.P)
+	return append(b, s.D...), nil
+}
+
+// UnmarshalBinary decodes a set encoded by MarshalBinary, or by its versions before the
+// encoding had a version header.
+// H must be set to the hash function the set was built with.
+func (s *GCS) UnmarshalBinary(b []byte) error {
+	b, err := parseTyped(b, typeGCS)
+	if err != nil {
+		return err
+	}
+	if len(b) < 9 || b[8] < 1 || b[8] > 32 {
+		return errors.New("bloom: invalid GCS encoding")
+	}
+	s.N = binary.LittleEndian.Uint64(b)
+	s.P = b[8]
+	s.D = append([]byte(nil), b[9:]...)
+	return nil
+}
+
+// bitWriter writes bits most significant first.
+type bitWriter struct {
+	b []byte
+	n uint // bits used in the last byte
+}
+
+func (w *bitWriter) writeBit(bit byte) {
+	if w.n%8 == 0 {
+		w.b = append(w.b, 0)
+		w.n = 0
+	}
+	w.b[len(w.b)-1] |= bit << (7 - w.n)
+	w.n++
+}
+
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	for i := n; i > 0; i-- {
+		w.writeBit(byte(v>>(i-1)) & 1)
+	}
+}
+
+// bitReader reads bits most significant first.
+type bitReader struct {
+	b []byte
+	i uint64 // bit position
+}
+
+func (r *bitReader) readBit() (byte, bool) {
+	if r.i/8 >= uint64(len(r.b)) {
+		return 0, false
+	}
+	bit := r.b[r.i/8] >> (7 - r.i%8) & 1
+	r.i++
+	return bit, true
+}
+
+func (r *bitReader) readBits(n uint) (uint64, bool) {
+	var v uint64
+	for ; n > 0; n-- {
+		bit, ok := r.readBit()
+		if !ok {
+			return 0, false
+		}
+		v = v<<1 | uint64(bit)
+	}
+	return v, true
+}
@@ -0,0 +1,62 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGCS_Test(t *testing.T) {
+	const n = 1e4
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+	}
+	s, err := BuildGCS(keys, 10, doubleSHA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i += 10 {
+		if !s.Test(keys[i]) {
+			t.Fatalf("%s should exist in set but got false", keys[i])
+		}
+	}
+	fp := 0
+	for i := n; i < n+1e3; i++ {
+		if s.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	bitsPerKey := float64(8*s.Size()) / n
+	t.Logf("Size = %d, bits/key = %.2f, FP = %d", s.Size(), bitsPerKey, fp)
+	if bitsPerKey > 12 {
+		t.Fatalf("%.2f bits per key is well above P+1.5", bitsPerKey)
+	}
+	if fp > 5 {
+		t.Fatalf("Too many false positives: %d", fp)
+	}
+}
+
+func TestGCS_MarshalBinary(t *testing.T) {
+	keys := [][]byte{[]byte("hello"), []byte("world")}
+	s, err := BuildGCS(keys, 16, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &GCS{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if !decoded.Test(k) {
+			t.Fatalf("%s should exist in decoded set but got false", k)
+		}
+	}
+	empty, _ := BuildGCS(nil, 8, doubleFNV)
+	if empty.Test([]byte("hello")) {
+		t.Fatal("Empty set should contain nothing")
+	}
+}
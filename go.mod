@@ -0,0 +1,3 @@
+module github.com/OperatorFoundation/go-bloom
+
+go 1.23
@@ -0,0 +1,172 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ErrReply is returned by a Client when the server sends a reply it does not expect.
+var ErrReply = errors.New("bloom: unexpected reply from the filter service")
+
+// Client is a Filter served by a FilterService, with one call per entry; AddMany,
+// TestMany and their error-returning counterparts send a batch of entries in one.
+//
+// Add, Test and the other methods of Filter have no error result, so they record the
+// first error for Err, and Test reports false when it fails; Insert, Lookup and their
+// batch forms return it. A Client is safe for concurrent use.
+type Client struct {
+	// Timeout bounds each call if it is not 0.
+	Timeout time.Duration
+
+	conn grpc.ClientConnInterface
+	name string
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewClient returns a client of the filter name of the server reached over conn, usually
+// a *grpc.ClientConn. The name is ignored by a server of one filter.
+func NewClient(conn grpc.ClientConnInterface, name string) *Client {
+	return &Client{conn: conn, name: name}
+}
+
+// Stats is what a server reports of a filter.
+type Stats struct {
+	Size             int    // size of the filter in bytes
+	Counted          bool   // whether the filter estimates the number of entries
+	ApproximateCount uint64 // estimated number of entries, if Counted
+}
+
+// Insert adds an entry to the filter.
+func (c *Client) Insert(b []byte) error { return c.InsertMany([][]byte{b}) }
+
+// Lookup tests if an entry is in the filter.
+func (c *Client) Lookup(b []byte) (bool, error) {
+	found, err := c.LookupMany([][]byte{b})
+	if err != nil {
+		return false, err
+	}
+	return found[0], nil
+}
+
+// InsertAndLookup adds an entry to the filter and reports whether it was already in it,
+// with one call.
+func (c *Client) InsertAndLookup(b []byte) (bool, error) {
+	found, err := c.InsertAndLookupMany([][]byte{b})
+	if err != nil {
+		return false, err
+	}
+	return found[0], nil
+}
+
+// InsertMany adds entries to the filter with one call.
+func (c *Client) InsertMany(keys [][]byte) error {
+	return c.invoke("Add", &KeysRequest{Filter: c.name, Keys: keys}, &Empty{})
+}
+
+// LookupMany tests if entries are in the filter with one call.
+func (c *Client) LookupMany(keys [][]byte) ([]bool, error) { return c.keys("Test", keys) }
+
+// InsertAndLookupMany adds entries to the filter in order with one call and reports for
+// each whether it was in the filter before.
+func (c *Client) InsertAndLookupMany(keys [][]byte) ([]bool, error) {
+	return c.keys("TestAndAdd", keys)
+}
+
+// Stats returns the size of the filter and, if it can tell, how many entries it holds.
+func (c *Client) Stats() (Stats, error) {
+	var reply StatsReply
+	if err := c.invoke("Stats", &FilterRequest{Filter: c.name}, &reply); err != nil {
+		return Stats{}, err
+	}
+	return Stats{Size: int(reply.Size), Counted: reply.Counted, ApproximateCount: reply.ApproximateCount}, nil
+}
+
+func (c *Client) Add(b []byte) { c.record(c.Insert(b)) }
+
+func (c *Client) Test(b []byte) bool {
+	found, err := c.Lookup(b)
+	c.record(err)
+	return found
+}
+
+// TestAndAdd is InsertAndLookup recording its error for Err.
+func (c *Client) TestAndAdd(b []byte) bool {
+	found, err := c.InsertAndLookup(b)
+	c.record(err)
+	return found
+}
+
+// AddMany is InsertMany recording its error for Err.
+func (c *Client) AddMany(keys [][]byte) { c.record(c.InsertMany(keys)) }
+
+// TestMany is LookupMany recording its error for Err. An entry that could not be tested is
+// reported absent.
+func (c *Client) TestMany(keys [][]byte) []bool {
+	found, err := c.LookupMany(keys)
+	c.record(err)
+	if found == nil {
+		found = make([]bool, len(keys))
+	}
+	return found
+}
+
+// Size returns the size of the filter in bytes, or 0 if it cannot be asked, recording the
+// error for Err.
+func (c *Client) Size() int {
+	stats, err := c.Stats()
+	c.record(err)
+	return stats.Size
+}
+
+// Reset clears the filter for every client of it, recording an error for Err.
+func (c *Client) Reset() { c.record(c.invoke("Reset", &FilterRequest{Filter: c.name}, &Empty{})) }
+
+// Err returns the first error of the methods without an error result since it was last
+// called, and clears it.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.err
+	c.err = nil
+	return err
+}
+
+func (c *Client) record(err error) {
+	if err == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// keys calls the method of KeysRequest and FoundReply, checking that the reply has one
+// result per key.
+func (c *Client) keys(method string, keys [][]byte) ([]bool, error) {
+	var reply FoundReply
+	if err := c.invoke(method, &KeysRequest{Filter: c.name, Keys: keys}, &reply); err != nil {
+		return nil, err
+	}
+	if len(reply.Found) != len(keys) {
+		return nil, ErrReply
+	}
+	return reply.Found, nil
+}
+
+func (c *Client) invoke(method string, in, out any) error {
+	ctx := context.Background()
+	if c.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	return c.conn.Invoke(ctx, "/"+serviceName+"/"+method, in, out)
+}
@@ -0,0 +1,89 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve serves s on an in-memory listener and returns a connection to it.
+func serve(t *testing.T, s *Server) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	s.Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestClient(t *testing.T) {
+	f := bloom.NewSafe(1e3, 1e-2, bloom.XXHash)
+	c := NewClient(serve(t, NewServer(f)), "")
+	var _ bloom.Inserter = c
+	var _ bloom.TestAndAdder = c
+
+	c.Add([]byte("hello"))
+	if !c.Test([]byte("hello")) || c.Test([]byte("world")) {
+		t.Fatal("Only the added entry should be in the filter")
+	}
+	if !f.Test([]byte("hello")) {
+		t.Fatal("Entries added by the client should be in the served filter")
+	}
+	if c.TestAndAdd([]byte("world")) || !c.TestAndAdd([]byte("world")) {
+		t.Fatal("TestAndAdd should report an entry present only once added")
+	}
+
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	c.AddMany(keys)
+	for i, found := range c.TestMany(keys) {
+		if !found {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+	if found, err := c.InsertAndLookupMany([][]byte{[]byte("new"), []byte("new")}); err != nil || found[0] || !found[1] {
+		t.Fatal("InsertAndLookupMany should add entries in order but got", found, err)
+	}
+	if c.Size() != f.Size() {
+		t.Fatalf("Size should be %d but got %d", f.Size(), c.Size())
+	}
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Reset()
+	if c.Test([]byte("hello")) || c.Err() != nil {
+		t.Fatal("Reset filter should be empty")
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	conn := serve(t, NewRegistryServer(func(string) bloom.Filter { return nil }))
+	c := NewClient(conn, "missing")
+	if err := c.Insert([]byte("hello")); err == nil {
+		t.Fatal("Insert to a missing filter should fail")
+	}
+	c.Add([]byte("hello"))
+	c.Add([]byte("world"))
+	if c.Err() == nil || c.Err() != nil {
+		t.Fatal("Err should return the error of Add once")
+	}
+	if found := c.TestMany([][]byte{[]byte("hello")}); len(found) != 1 || found[0] || c.Err() == nil {
+		t.Fatal("TestMany of a missing filter should report entries absent and record the error")
+	}
+}
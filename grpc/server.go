@@ -0,0 +1,107 @@
+package grpc
+
+import (
+	"context"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves filters as FilterService. gRPC runs the calls of a server concurrently,
+// so the filters must be safe for concurrent use, such as a bloom.SafeFilter or a
+// bloom.AtomicFilter.
+type Server struct {
+	lookup func(name string) bloom.Filter
+}
+
+// NewServer returns a server of f, whatever filter name a request gives.
+func NewServer(f bloom.Filter) *Server {
+	return &Server{lookup: func(string) bloom.Filter { return f }}
+}
+
+// NewRegistryServer returns a server of the filters lookup returns for the filter names of
+// requests. A request for a name lookup returns nil for fails with code NotFound.
+func NewRegistryServer(lookup func(name string) bloom.Filter) *Server {
+	return &Server{lookup: lookup}
+}
+
+// Register registers FilterService on r, usually a *grpc.Server:
+//
+//	s := grpc.NewServer()
+//	bloomgrpc.NewServer(bloom.NewSafe(1e6, 1e-3, bloom.XXHash)).Register(s)
+//	s.Serve(lis)
+func (s *Server) Register(r grpc.ServiceRegistrar) { r.RegisterService(&serviceDesc, s) }
+
+func (s *Server) filter(name string) (bloom.Filter, error) {
+	f := s.lookup(name)
+	if f == nil {
+		return nil, status.Errorf(codes.NotFound, "bloom: no filter %q", name)
+	}
+	return f, nil
+}
+
+// add adds the keys, failing with code FailedPrecondition for the first one an Inserter
+// cannot add, such as a key of a read-only filter.
+func (s *Server) add(_ context.Context, in *KeysRequest) (*Empty, error) {
+	f, err := s.filter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range in.Keys {
+		if inserter, ok := f.(bloom.Inserter); ok {
+			if err := inserter.Insert(key); err != nil {
+				return nil, status.Error(codes.FailedPrecondition, err.Error())
+			}
+		} else {
+			f.Add(key)
+		}
+	}
+	return &Empty{}, nil
+}
+
+func (s *Server) test(_ context.Context, in *KeysRequest) (*FoundReply, error) {
+	f, err := s.filter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(in.Keys))
+	for i, key := range in.Keys {
+		found[i] = f.Test(key)
+	}
+	return &FoundReply{Found: found}, nil
+}
+
+func (s *Server) testAndAdd(_ context.Context, in *KeysRequest) (*FoundReply, error) {
+	f, err := s.filter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(in.Keys))
+	for i, key := range in.Keys {
+		found[i] = bloom.TestAndAdd(f, key)
+	}
+	return &FoundReply{Found: found}, nil
+}
+
+func (s *Server) stats(_ context.Context, in *FilterRequest) (*StatsReply, error) {
+	f, err := s.filter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+	reply := &StatsReply{Size: uint64(f.Size())}
+	if c, ok := f.(bloom.ApproximateCounter); ok {
+		reply.Counted, reply.ApproximateCount = true, c.ApproximateCount()
+	}
+	return reply, nil
+}
+
+func (s *Server) reset(_ context.Context, in *FilterRequest) (*Empty, error) {
+	f, err := s.filter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+	f.Reset()
+	return &Empty{}, nil
+}
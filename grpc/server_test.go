@@ -0,0 +1,75 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+func TestServer_Registry(t *testing.T) {
+	filters := map[string]bloom.Filter{
+		"users":  bloom.NewSafe(1e3, 1e-2, bloom.XXHash),
+		"frozen": bloom.NewFromBytes(make([]byte, 64), 3, bloom.XXHash),
+	}
+	conn := serve(t, NewRegistryServer(func(name string) bloom.Filter { return filters[name] }))
+
+	users := NewClient(conn, "users")
+	if err := users.Insert([]byte("alice")); err != nil {
+		t.Fatal(err)
+	}
+	if !filters["users"].Test([]byte("alice")) {
+		t.Fatal("Entries should be added to the filter of the name requested")
+	}
+	if stats, err := users.Stats(); err != nil || stats.Size != filters["users"].Size() || stats.Counted {
+		t.Fatal("Stats should report the size of the filter and no count but got", stats, err)
+	}
+
+	err := NewClient(conn, "frozen").Insert([]byte("alice"))
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatal("Insert to a read-only filter should fail with FailedPrecondition but got", err)
+	}
+	err = NewClient(conn, "missing").Insert([]byte("alice"))
+	if status.Code(err) != codes.NotFound {
+		t.Fatal("Insert to a missing filter should fail with NotFound but got", err)
+	}
+}
+
+func TestServer_Stats(t *testing.T) {
+	f := bloom.NewWithBits(1024, 3, bloom.XXHash)
+	f.Add([]byte("hello"))
+	reply, err := NewServer(f).stats(context.Background(), &FilterRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reply.Counted || reply.ApproximateCount != f.ApproximateCount() || reply.Size != uint64(f.Size()) {
+		t.Fatal("Stats should report the count of a counting filter but got", reply)
+	}
+}
+
+// The messages should encode as those of filter_service.proto.
+func TestMessages(t *testing.T) {
+	for _, c := range []struct {
+		m    protoadapt.MessageV1
+		want []byte
+	}{
+		{&KeysRequest{Filter: "f", Keys: [][]byte{[]byte("a"), {}}}, []byte{0x0a, 1, 'f', 0x12, 1, 'a', 0x12, 0}},
+		{&FoundReply{Found: []bool{true, false}}, []byte{0x0a, 2, 1, 0}},
+		{&StatsReply{Size: 300, Counted: true, ApproximateCount: 1}, []byte{0x08, 0xac, 0x02, 0x10, 1, 0x18, 1}},
+		{&FilterRequest{Filter: "f"}, []byte{0x0a, 1, 'f'}},
+		{&Empty{}, nil},
+	} {
+		got, err := proto.Marshal(protoadapt.MessageV2Of(c.m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, c.want) {
+			t.Fatalf("%T should encode as %x but got %x", c.m, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package grpc serves filters of github.com/OperatorFoundation/go-bloom over gRPC and
+// calls them, so one process can keep a filter, such as the dedup filter of a pipeline,
+// for many producers. The service is FilterService of filter_service.proto, which clients
+// in other languages can be generated from.
+//
+// The messages are written by hand, with the struct tags protoc-gen-go once generated,
+// so the package depends on google.golang.org/grpc and its protobuf runtime but needs no
+// generated code.
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// serviceName is the full name of FilterService of filter_service.proto.
+const serviceName = "bloom.FilterService"
+
+// FilterRequest is the FilterRequest message of filter_service.proto.
+type FilterRequest struct {
+	Filter string `protobuf:"bytes,1,opt,name=filter,proto3"`
+}
+
+// KeysRequest is the KeysRequest message of filter_service.proto.
+type KeysRequest struct {
+	Filter string   `protobuf:"bytes,1,opt,name=filter,proto3"`
+	Keys   [][]byte `protobuf:"bytes,2,rep,name=keys,proto3"`
+}
+
+// FoundReply is the FoundReply message of filter_service.proto.
+type FoundReply struct {
+	Found []bool `protobuf:"varint,1,rep,packed,name=found,proto3"`
+}
+
+// StatsReply is the StatsReply message of filter_service.proto.
+type StatsReply struct {
+	Size             uint64 `protobuf:"varint,1,opt,name=size,proto3"`
+	Counted          bool   `protobuf:"varint,2,opt,name=counted,proto3"`
+	ApproximateCount uint64 `protobuf:"varint,3,opt,name=approximate_count,json=approximateCount,proto3"`
+}
+
+// Empty is the Empty message of filter_service.proto.
+type Empty struct{}
+
+// The methods below make the messages protocol buffer messages of the original API,
+// which the protobuf runtime and the codec of gRPC accept.
+
+func (m *FilterRequest) Reset()         { *m = FilterRequest{} }
+func (m *FilterRequest) String() string { return text(m) }
+func (*FilterRequest) ProtoMessage()    {}
+
+func (m *KeysRequest) Reset()         { *m = KeysRequest{} }
+func (m *KeysRequest) String() string { return text(m) }
+func (*KeysRequest) ProtoMessage()    {}
+
+func (m *FoundReply) Reset()         { *m = FoundReply{} }
+func (m *FoundReply) String() string { return text(m) }
+func (*FoundReply) ProtoMessage()    {}
+
+func (m *StatsReply) Reset()         { *m = StatsReply{} }
+func (m *StatsReply) String() string { return text(m) }
+func (*StatsReply) ProtoMessage()    {}
+
+func (m *Empty) Reset()         { *m = Empty{} }
+func (m *Empty) String() string { return text(m) }
+func (*Empty) ProtoMessage()    {}
+
+// text formats m in the text format, as String of generated messages does.
+func text(m protoadapt.MessageV1) string { return prototext.Format(protoadapt.MessageV2Of(m)) }
+
+// serviceDesc describes FilterService for grpc.Server.RegisterService.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Add", (*Server).add),
+		unary("Test", (*Server).test),
+		unary("TestAndAdd", (*Server).testAndAdd),
+		unary("Stats", (*Server).stats),
+		unary("Reset", (*Server).reset),
+	},
+	Metadata: "filter_service.proto",
+}
+
+// unary describes the method name of FilterService served by call.
+func unary[Req, Reply any](name string, call func(*Server, context.Context, *Req) (*Reply, error)) grpc.MethodDesc {
+	handler := func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := new(Req)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(*Server), ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}
+		return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+			return call(srv.(*Server), ctx, req.(*Req))
+		})
+	}
+	return grpc.MethodDesc{MethodName: name, Handler: handler}
+}
@@ -0,0 +1,175 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// Guava compatible Bloom Filter
+//
+// A GuavaFilter is a Bloom Filter that is bit for bit compatible with the
+// BloomFilter of Google Guava: it hashes entries with the 128-bit MurmurHash3
+// of either of its strategies, probes the same bits of the same array of
+// longs, and reads and writes the serialized form of BloomFilter.writeTo and
+// BloomFilter.readFrom, so JVM services and Go programs can exchange filters.
+// Guava hashes what the funnel of a filter puts, so entries match only if the
+// JVM side funnels the same bytes: Funnels.byteArrayFunnel() or
+// Funnels.stringFunnel(UTF_8) for the bytes of a byte array or a UTF-8 string.
+type GuavaFilter struct {
+	W        []uint64 // the longs of the bit array
+	K        int
+	Strategy GuavaStrategy
+}
+
+// GuavaStrategy is a hashing strategy of Guava's BloomFilterStrategies, by its ordinal.
+type GuavaStrategy byte
+
+const (
+	// GuavaMurmur128Mitz32 is MURMUR128_MITZ_32, of filters written by Guava before 12.0.
+	GuavaMurmur128Mitz32 GuavaStrategy = 0
+	// GuavaMurmur128Mitz64 is MURMUR128_MITZ_64, which BloomFilter.create uses.
+	GuavaMurmur128Mitz64 GuavaStrategy = 1
+)
+
+// NewGuava creates an empty filter sized for n entries and false positive rate of p, with
+// the number of bits and hashes of Guava's BloomFilter.create(funnel, n, p).
+func NewGuava(n int, p float64) *GuavaFilter {
+	if n < 1 {
+		n = 1
+	}
+	if p == 0 {
+		p = math.SmallestNonzeroFloat64
+	}
+	m := max(int64(-float64(n)*math.Log(p)/(math.Ln2*math.Ln2)), 1)
+	k := max(int(math.Round(float64(m)/float64(n)*math.Ln2)), 1)
+	return &GuavaFilter{W: make([]uint64, (m+63)/64), K: k, Strategy: GuavaMurmur128Mitz64}
+}
+
+// bitSize returns the number of bits of the filter, which Guava rounds up to whole longs.
+func (f *GuavaFilter) bitSize() uint64 { return 64 * uint64(len(f.W)) }
+
+// probe calls fn with the index of each bit of an entry, until fn returns false.
+func (f *GuavaFilter) probe(b []byte, fn func(uint64) bool) {
+	h1, h2 := murmur3Sum128(b, 0)
+	size := f.bitSize()
+	if f.Strategy == GuavaMurmur128Mitz32 {
+		hash1, hash2 := int32(h1), int32(h1>>32)
+		for i := int32(1); i <= int32(f.K); i++ {
+			combined := hash1 + i*hash2
+			if combined < 0 {
+				combined = ^combined
+			}
+			if !fn(uint64(combined) % size) {
+				return
+			}
+		}
+		return
+	}
+	combined := h1
+	for i := 0; i < f.K; i++ {
+		if !fn((combined & math.MaxInt64) % size) {
+			return
+		}
+		combined += h2
+	}
+}
+
+func (f *GuavaFilter) Add(b []byte) {
+	f.probe(b, func(i uint64) bool {
+		f.W[i/64] |= 1 << (i % 64)
+		return true
+	})
+}
+
+func (f *GuavaFilter) Test(b []byte) bool {
+	found := true
+	f.probe(b, func(i uint64) bool {
+		found = f.W[i/64]&(1<<(i%64)) != 0
+		return found
+	})
+	return found
+}
+
+func (f *GuavaFilter) Size() int { return 8 * len(f.W) }
+
+func (f *GuavaFilter) Reset() {
+	for i := range f.W {
+		f.W[i] = 0
+	}
+}
+
+// Count returns the number of set bits.
+func (f *GuavaFilter) Count() int {
+	n := 0
+	for _, w := range f.W {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// WriteTo writes the filter in the serialized form of Guava's BloomFilter.writeTo: the
+// ordinal of the strategy, the number of hashes, the number of longs as a big-endian
+// int32, and the longs, big-endian. It returns ErrInvalidEncoding for a filter that form
+// cannot hold, of more than 255 hashes or 2³¹-1 longs.
+func (f *GuavaFilter) WriteTo(w io.Writer) (int64, error) {
+	if f.K < 1 || f.K > math.MaxUint8 || len(f.W) > math.MaxInt32 || f.Strategy > GuavaMurmur128Mitz64 {
+		return 0, ErrInvalidEncoding
+	}
+	b := make([]byte, 0, 6+8*len(f.W))
+	b = append(b, byte(f.Strategy), byte(f.K))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(f.W)))
+	for _, w := range f.W {
+		b = binary.BigEndian.AppendUint64(b, w)
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// ReadFrom reads a filter in the serialized form of Guava's BloomFilter.readFrom.
+func (f *GuavaFilter) ReadFrom(r io.Reader) (int64, error) {
+	var hdr [6]byte
+	n, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return int64(n), noEOF(err)
+	}
+	strategy, k := GuavaStrategy(hdr[0]), int(hdr[1])
+	length := binary.BigEndian.Uint32(hdr[2:])
+	if strategy > GuavaMurmur128Mitz64 || k == 0 || length == 0 || length > math.MaxInt32 {
+		return int64(n), ErrInvalidEncoding
+	}
+	words := make([]uint64, 0, min(uint64(length), readChunk/8))
+	read := int64(n)
+	var buf [8]byte
+	for uint32(len(words)) < length {
+		c, err := io.ReadFull(r, buf[:])
+		read += int64(c)
+		if err != nil {
+			return read, noEOF(err)
+		}
+		words = append(words, binary.BigEndian.Uint64(buf[:]))
+	}
+	f.W, f.K, f.Strategy = words, k, strategy
+	return read, nil
+}
+
+// MarshalBinary encodes the filter in the serialized form of Guava's BloomFilter.
+func (f *GuavaFilter) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := f.WriteTo(&buf)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary decodes a filter in the serialized form of Guava's BloomFilter.
+func (f *GuavaFilter) UnmarshalBinary(b []byte) error {
+	r := bytes.NewReader(b)
+	if _, err := f.ReadFrom(r); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return ErrInvalidEncoding
+	}
+	return nil
+}
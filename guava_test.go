@@ -0,0 +1,100 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// unencodedChars returns the bytes Guava's Funnels.unencodedCharsFunnel puts for an ASCII
+// string: its UTF-16 code units, little-endian.
+func unencodedChars(s string) []byte {
+	b := make([]byte, 0, 2*len(s))
+	for _, c := range s {
+		b = append(b, byte(c), 0)
+	}
+	return b
+}
+
+// The false positives of Guava's BloomFilterTest, of filters created for 1e6 entries and
+// a rate of 0.03 holding the even numbers below 2e6: the odd ones below 900, and their
+// number below 2e6.
+func TestGuavaFilter_KnownFalsePositives(t *testing.T) {
+	utf8 := func(s string) []byte { return []byte(s) }
+	for _, c := range []struct {
+		name     string
+		strategy GuavaStrategy
+		funnel   func(string) []byte
+		under900 []int
+		total    int
+	}{
+		{"Mitz32", GuavaMurmur128Mitz32, unencodedChars, []int{49, 51, 59, 163, 199, 321, 325, 363, 367, 469, 545, 561, 727, 769, 773, 781}, 29824},
+		{"Mitz64", GuavaMurmur128Mitz64, unencodedChars, []int{15, 25, 287, 319, 381, 399, 421, 465, 529, 697, 767, 857}, 30104},
+		{"Mitz64Utf8", GuavaMurmur128Mitz64, utf8, []int{89, 129, 471, 723, 751, 835, 871}, 29763},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f := NewGuava(1000000, 0.03)
+			f.Strategy = c.strategy
+			for i := 0; i < 2000000; i += 2 {
+				f.Add(c.funnel(strconv.Itoa(i)))
+			}
+			var under900 []int
+			total := 0
+			for i := 1; i < 2000000; i += 2 {
+				if f.Test(c.funnel(strconv.Itoa(i))) {
+					total++
+					if i < 900 {
+						under900 = append(under900, i)
+					}
+				}
+			}
+			if !slices.Equal(under900, c.under900) || total != c.total {
+				t.Fatalf("Got false positives %v and %d in total, want %v and %d", under900, total, c.under900, c.total)
+			}
+		})
+	}
+}
+
+func TestNewGuava(t *testing.T) {
+	// BloomFilter.create(funnel, 1000000, 0.03) has 7298440 bits, rounded up to longs, and 5 hashes
+	f := NewGuava(1000000, 0.03)
+	if len(f.W) != 114039 || f.K != 5 || f.Strategy != GuavaMurmur128Mitz64 {
+		t.Fatalf("Got %d longs, %d hashes and strategy %d", len(f.W), f.K, f.Strategy)
+	}
+}
+
+func TestGuavaFilter_Serialized(t *testing.T) {
+	f := NewGuava(100, 0.01)
+	f.Add([]byte("hello"))
+	f.Add([]byte("world"))
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// strategy 1, 7 hashes, 15 longs
+	if want := "0107" + "0000000f"; hex.EncodeToString(data[:6]) != want || len(data) != 6+15*8 {
+		t.Fatalf("The header should be %s and %d longs follow, but got %x and %d bytes", want, 15, data[:6], len(data))
+	}
+	var g GuavaFilter
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test([]byte("hello")) || !g.Test([]byte("world")) || g.Count() != f.Count() {
+		t.Fatal("Should exist in decoded filter but got false")
+	}
+	again, _ := g.MarshalBinary()
+	if !bytes.Equal(again, data) {
+		t.Fatal("Encoding a decoded filter should give the same bytes")
+	}
+
+	for _, bad := range [][]byte{data[:len(data)-1], append(data, 0), {2, 7, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}, {1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}} {
+		if err := g.UnmarshalBinary(bad); err == nil {
+			t.Fatalf("%x should fail to decode", bad)
+		}
+	}
+	if _, err := (&GuavaFilter{W: make([]uint64, 1), K: 256}).MarshalBinary(); err != ErrInvalidEncoding {
+		t.Fatal("More than 255 hashes should not be encoded, but got", err)
+	}
+}
@@ -0,0 +1,126 @@
+package bloom
+
+import "sync"
+
+// Hasher is a named double hash. Filters created with a Hasher record its name, so that
+// their encodings name the hash even when it is a closure that cannot be registered, and
+// filters can be checked to share a hash.
+type Hasher interface {
+	Hash128([]byte) (uint64, uint64)
+	Name() string
+}
+
+// NewHasher returns a Hasher of a double hash under a name.
+func NewHasher(name string, h func([]byte) (uint64, uint64)) Hasher { return namedHash{name, h} }
+
+type namedHash struct {
+	name string
+	h    func([]byte) (uint64, uint64)
+}
+
+func (n namedHash) Hash128(b []byte) (uint64, uint64) { return n.h(b) }
+func (n namedHash) Name() string                      { return n.name }
+
+// hashFunc returns the double hash of h, unwrapping a Hasher made by NewHasher, so filters
+// of the same hash function have the same H.
+func hashFunc(h Hasher) func([]byte) (uint64, uint64) {
+	if n, ok := h.(namedHash); ok {
+		return n.h
+	}
+	return h.Hash128
+}
+
+// LookupHasher returns the hash registered under name as a Hasher.
+func LookupHasher(name string) (Hasher, bool) {
+	h, ok := LookupHash(name)
+	if !ok {
+		return nil, false
+	}
+	return NewHasher(name, h), true
+}
+
+// hashes is the registry of named hash functions.
+var hashes = struct {
+	sync.RWMutex
+	byName map[string]func([]byte) (uint64, uint64)
+	byFunc map[uintptr]string
+}{
+	byName: make(map[string]func([]byte) (uint64, uint64)),
+	byFunc: make(map[uintptr]string),
+}
+
+// DefaultHash is the double hash used by NewDefault: the 64-bit xxHash of an entry, and a
+// SplitMix64 finalization of it as the second hash. It is registered as "default".
+func DefaultHash(b []byte) (uint64, uint64) {
+	x := xxhash64(b, 0)
+	return x, splitmix64(x)
+}
+
+// Murmur3Hash is a double hash that is the two halves of the 128-bit MurmurHash3 (x64) of an
+// entry with seed 0. It is registered as "murmur3".
+func Murmur3Hash(b []byte) (uint64, uint64) { return murmur3Sum128(b, 0) }
+
+// Murmur3Seeded returns a double hash like Murmur3Hash with another seed.
+func Murmur3Seeded(seed uint32) func([]byte) (uint64, uint64) {
+	return func(b []byte) (uint64, uint64) { return murmur3Sum128(b, seed) }
+}
+
+// XXHash is a double hash that is the 64-bit xxHash of an entry with seeds 0 and 1.
+// It is registered as "xxhash". It hashes an entry twice, which DefaultHash does not.
+func XXHash(b []byte) (uint64, uint64) { return xxhash64(b, 0), xxhash64(b, 1) }
+
+// XXHashSeeded returns a double hash like XXHash with other seeds.
+func XXHashSeeded(seed1, seed2 uint64) func([]byte) (uint64, uint64) {
+	return func(b []byte) (uint64, uint64) { return xxhash64(b, seed1), xxhash64(b, seed2) }
+}
+
+// From64 returns a double hash of one 64-bit hash h of an entry, whose second hash is a
+// SplitMix64 finalization of the first like that of DefaultHash, so an entry is hashed
+// once per operation.
+func From64(h func([]byte) uint64) func([]byte) (uint64, uint64) {
+	return func(b []byte) (uint64, uint64) {
+		x := h(b)
+		return x, splitmix64(x)
+	}
+}
+
+func init() {
+	RegisterHash("default", DefaultHash)
+	RegisterHash("murmur3", Murmur3Hash)
+	RegisterHash("xxhash", XXHash)
+}
+
+// RegisterHash registers a double hash under a name, so that filters using it can be
+// gob encoded and decoded with their hash function restored. It panics if
+// the name is already registered to a different function.
+// Closures created by the same function literal cannot be told apart, so
+// register top-level functions only.
+func RegisterHash(name string, h func([]byte) (uint64, uint64)) {
+	hashes.Lock()
+	defer hashes.Unlock()
+	p := funcPC(h)
+	if old, ok := hashes.byName[name]; ok && funcPC(old) != p {
+		panic("bloom: hash " + name + " is already registered")
+	}
+	hashes.byName[name] = h
+	hashes.byFunc[p] = name
+}
+
+// LookupHash returns the hash registered under name.
+func LookupHash(name string) (func([]byte) (uint64, uint64), bool) {
+	hashes.RLock()
+	defer hashes.RUnlock()
+	h, ok := hashes.byName[name]
+	return h, ok
+}
+
+// HashName returns the name a hash is registered under.
+func HashName(h func([]byte) (uint64, uint64)) (string, bool) {
+	if h == nil {
+		return "", false
+	}
+	hashes.RLock()
+	defer hashes.RUnlock()
+	name, ok := hashes.byFunc[funcPC(h)]
+	return name, ok
+}
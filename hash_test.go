@@ -0,0 +1,125 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestRegisterHash(t *testing.T) {
+	RegisterHash("test-fnv", doubleFNV)
+	RegisterHash("test-fnv", doubleFNV) // registering again is fine
+	if name, ok := HashName(doubleFNV); !ok || name != "test-fnv" {
+		t.Fatalf("HashName = %q, %v", name, ok)
+	}
+	if _, ok := HashName(doubleSHA); ok {
+		t.Fatal("Unregistered hash should have no name")
+	}
+	if _, ok := LookupHash("test-fnv"); !ok {
+		t.Fatal("Registered hash should be found")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Registering a name twice should panic")
+		}
+	}()
+	RegisterHash("test-fnv", doubleSHA)
+}
+
+func TestDefaultHash(t *testing.T) {
+	bf := NewDefault(1e4, 1e-3)
+	for i := 0; i < 1e4; i++ {
+		bf.Add([]byte(strconv.Itoa(i)))
+	}
+	fp := 0
+	for i := 0; i < 1e4; i++ {
+		if !bf.Test([]byte(strconv.Itoa(i))) {
+			t.Fatal("Should exist in filter but got false")
+		}
+		if bf.Test([]byte("absent" + strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if fp > 20 {
+		t.Fatalf("False positive rate too high: %d/10000", fp)
+	}
+	if name, ok := HashName(DefaultHash); !ok || name != "default" {
+		t.Fatalf("DefaultHash is registered as %q, %v", name, ok)
+	}
+}
+
+func TestBundledHashes(t *testing.T) {
+	b := []byte("hello")
+	if x, y := Murmur3Hash(b); x != 0xcbd8a7b341bd9b02 || y != 0x5b1e906a48ae1d19 {
+		t.Fatalf("Murmur3Hash = %#x, %#x", x, y)
+	}
+	if x, y := Murmur3Seeded(0)(b); x != 0xcbd8a7b341bd9b02 || y != 0x5b1e906a48ae1d19 {
+		t.Fatalf("Murmur3Seeded(0) = %#x, %#x", x, y)
+	}
+	if x, y := XXHash(b); x != xxhash64(b, 0) || y != xxhash64(b, 1) || x == y {
+		t.Fatalf("XXHash = %#x, %#x", x, y)
+	}
+	if x, y := XXHashSeeded(0, 1)(b); x != xxhash64(b, 0) || y != xxhash64(b, 1) {
+		t.Fatalf("XXHashSeeded(0, 1) = %#x, %#x", x, y)
+	}
+	for name, h := range map[string]func([]byte) (uint64, uint64){"murmur3": Murmur3Hash, "xxhash": XXHash} {
+		if got, _ := HashName(h); got != name {
+			t.Fatalf("Hash is registered as %q, want %q", got, name)
+		}
+	}
+}
+
+func TestFrom64(t *testing.T) {
+	h := From64(func(b []byte) uint64 { return xxhash64(b, 0) })
+	for _, b := range [][]byte{nil, []byte("hello"), []byte("a longer entry of more than thirty-two bytes")} {
+		x, y := h(b)
+		if wx, wy := DefaultHash(b); x != wx || y != wy {
+			t.Fatalf("From64 of xxHash should be DefaultHash, got %#x, %#x for %q", x, y, b)
+		}
+	}
+}
+
+// sipTest is SipHash under the key of TestHasher, as a top-level function that can be registered.
+func sipTest(b []byte) (uint64, uint64) { return sipHash128([16]byte{1}, b) }
+
+func TestHasher(t *testing.T) {
+	key := [16]byte{1}
+	bf := NewWithHasher(1e3, 1e-2, NewHasher("sip-test", SipHash(key)))
+	bf.Add([]byte("hello"))
+	if got := bf.Hasher().Name(); got != "sip-test" {
+		t.Fatalf("Hasher name = %q, want %q", got, "sip-test")
+	}
+	// closures cannot be registered by function, but a Hasher names them in encodings
+	data, err := json.Marshal(bf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &ClassicFilter{H: SipHash(key)}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hasher().Name() != "sip-test" || !decoded.Test([]byte("hello")) {
+		t.Fatal("Decoded filter should keep the hash name and entries")
+	}
+
+	RegisterHash("sip-test", sipTest)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(bf); err != nil {
+		t.Fatal(err)
+	}
+	var fromGob ClassicFilter
+	if err := gob.NewDecoder(&buf).Decode(&fromGob); err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := LookupHasher("sip-test"); !ok || h.Name() != fromGob.Hasher().Name() || !fromGob.Test([]byte("hello")) {
+		t.Fatal("Gob decoded filter should restore the registered hasher")
+	}
+	if _, ok := LookupHasher("nope"); ok {
+		t.Fatal("Unknown hasher should not be found")
+	}
+	if got := New(10, 0.1, DefaultHash).(*ClassicFilter).Hasher().Name(); got != "default" {
+		t.Fatalf("Registered hash name = %q, want %q", got, "default")
+	}
+}
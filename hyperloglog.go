@@ -0,0 +1,112 @@
+package bloom
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// HyperLogLog
+//
+// A HyperLogLog estimates the number of distinct entries added to it using
+// 2^P registers of one byte, with a standard error of about 1.04/sqrt(2^P).
+// It only uses the first hash of the double hash.
+// See Flajolet et al., "HyperLogLog: the analysis of a near-optimal
+// cardinality estimation algorithm" (2007).
+type HyperLogLog struct {
+	R []uint8 // registers
+	P uint8   // precision, 4 to 18
+	H func([]byte) (uint64, uint64)
+}
+
+// hllVersion is the version of the HyperLogLog binary encoding.
+const hllVersion = 1
+
+// NewHyperLogLog creates a HyperLogLog with 2^p registers, 4 <= p <= 18.
+func NewHyperLogLog(p uint8, h func([]byte) (uint64, uint64)) *HyperLogLog {
+	if p < 4 || p > 18 {
+		panic("bloom: HyperLogLog precision must be between 4 and 18")
+	}
+	return &HyperLogLog{R: make([]uint8, 1<<p), P: p, H: h}
+}
+
+// Add adds an entry to the HyperLogLog.
+func (l *HyperLogLog) Add(b []byte) {
+	x, _ := l.H(b)
+	i := x >> (64 - l.P)
+	rank := uint8(bits.LeadingZeros64(x<<l.P|1<<(l.P-1))) + 1
+	if rank > l.R[i] {
+		l.R[i] = rank
+	}
+}
+
+// Count returns an estimate of the number of distinct entries added.
+func (l *HyperLogLog) Count() uint64 {
+	m := float64(len(l.R))
+	sum, zeros := 0.0, 0
+	for _, r := range l.R {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	var alpha float64
+	switch len(l.R) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	e := alpha * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// small range correction with linear counting
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(e + 0.5)
+}
+
+// Merge merges other into l, so l estimates the number of distinct entries
+// added to either. Both must have the same precision, or else ErrIncompatibleSize is
+// returned, and the same hash function, or else ErrIncompatibleHash is returned.
+func (l *HyperLogLog) Merge(other *HyperLogLog) error {
+	if l.P != other.P {
+		return ErrIncompatibleSize
+	}
+	if funcID(l.H) != funcID(other.H) {
+		return ErrIncompatibleHash
+	}
+	for i, r := range other.R {
+		if r > l.R[i] {
+			l.R[i] = r
+		}
+	}
+	return nil
+}
+
+// Reset resets the HyperLogLog to initial state.
+func (l *HyperLogLog) Reset() {
+	for i := range l.R {
+		l.R[i] = 0
+	}
+}
+
+// MarshalBinary encodes the precision and the registers.
+// The hash function is not encoded.
+func (l *HyperLogLog) MarshalBinary() ([]byte, error) {
+	return append([]byte{hllVersion, l.P}, l.R...), nil
+}
+
+// UnmarshalBinary decodes a HyperLogLog encoded by MarshalBinary.
+// H must be set to the hash function the HyperLogLog was built with.
+func (l *HyperLogLog) UnmarshalBinary(b []byte) error {
+	if len(b) < 2 || b[0] != hllVersion || b[1] < 4 || b[1] > 18 || len(b)-2 != 1<<b[1] {
+		return errors.New("bloom: invalid HyperLogLog encoding")
+	}
+	l.P = b[1]
+	l.R = append([]uint8(nil), b[2:]...)
+	return nil
+}
@@ -0,0 +1,66 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHyperLogLog_Count(t *testing.T) {
+	for _, n := range []int{10, 1e3, 1e5} {
+		l := NewHyperLogLog(14, doubleSHA)
+		for i := 0; i < n; i++ {
+			l.Add([]byte(fmt.Sprint(i)))
+			l.Add([]byte(fmt.Sprint(i))) // duplicates don't count
+		}
+		got := l.Count()
+		relErr := math.Abs(float64(got)-float64(n)) / float64(n)
+		t.Logf("n = %d, estimate = %d, error = %.2f%%", n, got, relErr*100)
+		if relErr > 0.03 {
+			t.Fatalf("n = %d: estimate %d is off by %.2f%%", n, got, relErr*100)
+		}
+	}
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	a := NewHyperLogLog(12, doubleSHA)
+	b := NewHyperLogLog(12, doubleSHA)
+	for i := 0; i < 1e4; i++ {
+		a.Add([]byte(fmt.Sprint(i)))
+		b.Add([]byte(fmt.Sprint(i + 5e3)))
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Count(); math.Abs(float64(got)-1.5e4)/1.5e4 > 0.05 {
+		t.Fatalf("Merged estimate %d is far from 15000", got)
+	}
+	if err := a.Merge(NewHyperLogLog(10, doubleSHA)); !errors.Is(err, ErrIncompatibleSize) {
+		t.Fatalf("Merge of a different precision should fail but got %v", err)
+	}
+	if err := a.Merge(NewHyperLogLog(a.P, doubleFNV)); err != ErrIncompatibleHash {
+		t.Fatalf("Merge of a different hash should fail but got %v", err)
+	}
+}
+
+func TestHyperLogLog_MarshalBinary(t *testing.T) {
+	l := NewHyperLogLog(8, doubleFNV)
+	for i := 0; i < 100; i++ {
+		l.Add([]byte(fmt.Sprint(i)))
+	}
+	data, err := l.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &HyperLogLog{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Count() != l.Count() {
+		t.Fatalf("Decoded estimate %d differs from %d", decoded.Count(), l.Count())
+	}
+	if err := decoded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("Truncated data should fail to decode")
+	}
+}
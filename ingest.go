@@ -0,0 +1,47 @@
+package bloom
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// AddFromReader adds the keys read from r to f and returns how many it added. Keys are
+// split with split, bufio.ScanLines if it is nil, so the keys of a line-delimited list
+// can be added with AddFromReader(f, r, nil) and those of a length-prefixed stream with
+// AddFromReader(f, r, ScanLengthPrefixed). Every token is added, empty ones included.
+// The count is that of the keys added before the first error.
+func AddFromReader(f Filter, r io.Reader, split bufio.SplitFunc) (int, error) {
+	if split == nil {
+		split = bufio.ScanLines
+	}
+	s := bufio.NewScanner(r)
+	s.Split(split)
+	n := 0
+	for s.Scan() {
+		f.Add(s.Bytes())
+		n++
+	}
+	return n, s.Err()
+}
+
+// ScanLengthPrefixed is a bufio.SplitFunc that splits a stream of keys each prefixed with
+// its length as a uvarint. It returns ErrInvalidEncoding for a malformed length, and
+// io.ErrUnexpectedEOF for a stream ending within a key.
+func ScanLengthPrefixed(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	n, size := binary.Uvarint(data)
+	switch {
+	case size < 0:
+		return 0, nil, ErrInvalidEncoding
+	case size == 0 && atEOF && len(data) > 0:
+		return 0, nil, io.ErrUnexpectedEOF
+	case size == 0:
+		return 0, nil, nil
+	case uint64(len(data)-size) < n:
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	return size + int(n), data[size : size+int(n)], nil
+}
@@ -0,0 +1,41 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestAddFromReader(t *testing.T) {
+	bf := New(1e3, 1e-3, doubleFNV)
+	n, err := AddFromReader(bf, strings.NewReader("example.com\r\nexample.org\n\nexample.net"), nil)
+	if err != nil || n != 4 {
+		t.Fatalf("Should add 4 lines but added %d with %v", n, err)
+	}
+	for _, key := range []string{"example.com", "example.org", "", "example.net"} {
+		if !bf.Test([]byte(key)) {
+			t.Fatalf("Line %q should be in the filter", key)
+		}
+	}
+
+	var stream []byte
+	for _, key := range []string{"a", "", "a\nb", strings.Repeat("x", 300)} {
+		stream = binary.AppendUvarint(stream, uint64(len(key)))
+		stream = append(stream, key...)
+	}
+	bf = New(1e3, 1e-3, doubleFNV)
+	n, err = AddFromReader(bf, iotest.OneByteReader(strings.NewReader(string(stream))), ScanLengthPrefixed)
+	if err != nil || n != 4 {
+		t.Fatalf("Should add 4 keys but added %d with %v", n, err)
+	}
+	if !bf.Test([]byte("a\nb")) || !bf.Test([]byte(strings.Repeat("x", 300))) || bf.Test([]byte("b")) {
+		t.Fatal("Length-prefixed keys should be added whole")
+	}
+
+	n, err = AddFromReader(bf, strings.NewReader(string(stream[:len(stream)-1])), ScanLengthPrefixed)
+	if err != io.ErrUnexpectedEOF || n != 3 {
+		t.Fatalf("Truncated stream should add 3 keys and fail with io.ErrUnexpectedEOF but added %d with %v", n, err)
+	}
+}
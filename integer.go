@@ -0,0 +1,70 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sync"
+)
+
+// keyBufs holds the buffers integer and address entries are encoded in. A buffer on the
+// stack would escape to the heap through the hash function, which cannot be seen into.
+var keyBufs = sync.Pool{New: func() any { return new([16]byte) }}
+
+// AddUint64 adds the 64-bit integer v, as an entry of its 8 little-endian bytes, so
+// AddUint64(v) and Add(binary.LittleEndian.AppendUint64(nil, v)) add the same entry.
+func (f *ClassicFilter) AddUint64(v uint64) { addUint64(f, v) }
+
+func addUint64(f Filter, v uint64) {
+	b := keyBufs.Get().(*[16]byte)
+	f.Add(binary.LittleEndian.AppendUint64(b[:0], v))
+	keyBufs.Put(b)
+}
+
+// TestUint64 tests if the 64-bit integer v is in the filter, as an entry like AddUint64.
+func (f *ClassicFilter) TestUint64(v uint64) bool {
+	b := keyBufs.Get().(*[16]byte)
+	defer keyBufs.Put(b)
+	return f.Test(binary.LittleEndian.AppendUint64(b[:0], v))
+}
+
+// AddUint32 adds the 32-bit integer v, as an entry of its 4 little-endian bytes.
+func (f *ClassicFilter) AddUint32(v uint32) { addUint32(f, v) }
+
+func addUint32(f Filter, v uint32) {
+	b := keyBufs.Get().(*[16]byte)
+	f.Add(binary.LittleEndian.AppendUint32(b[:0], v))
+	keyBufs.Put(b)
+}
+
+// TestUint32 tests if the 32-bit integer v is in the filter, as an entry like AddUint32.
+func (f *ClassicFilter) TestUint32(v uint32) bool {
+	b := keyBufs.Get().(*[16]byte)
+	defer keyBufs.Put(b)
+	return f.Test(binary.LittleEndian.AppendUint32(b[:0], v))
+}
+
+// AddIP adds the IP address a, as an entry of its 4 or 16 bytes, so an IPv4 address and
+// the same address mapped to IPv6 are different entries. The zone is not added.
+func (f *ClassicFilter) AddIP(a netip.Addr) { addIP(f, a) }
+
+func addIP(f Filter, a netip.Addr) {
+	b := keyBufs.Get().(*[16]byte)
+	*b = a.As16()
+	if a.Is4() {
+		f.Add(b[12:])
+	} else {
+		f.Add(b[:])
+	}
+	keyBufs.Put(b)
+}
+
+// TestIP tests if the IP address a is in the filter, as an entry like AddIP.
+func (f *ClassicFilter) TestIP(a netip.Addr) bool {
+	b := keyBufs.Get().(*[16]byte)
+	defer keyBufs.Put(b)
+	*b = a.As16()
+	if a.Is4() {
+		return f.Test(b[12:])
+	}
+	return f.Test(b[:])
+}
@@ -0,0 +1,55 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+)
+
+func TestClassicFilter_AddUint64(t *testing.T) {
+	bf := newClassic(1e3, 1e-3, doubleFNV)
+	bf.AddUint64(1 << 40)
+	bf.AddUint32(7)
+	if !bf.TestUint64(1<<40) || !bf.Test(binary.LittleEndian.AppendUint64(nil, 1<<40)) || bf.TestUint64(7) {
+		t.Fatal("Integers should be added as their little-endian bytes")
+	}
+	if !bf.TestUint32(7) || bf.TestUint32(8) {
+		t.Fatal("32-bit integers should be added as their 4 bytes")
+	}
+
+	v4, v6 := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")
+	bf.AddIP(v4)
+	bf.AddIP(v6)
+	if !bf.TestIP(v4) || !bf.TestIP(v6) || !bf.Test(v4.AsSlice()) || !bf.Test(v6.AsSlice()) {
+		t.Fatal("Addresses should be added as their bytes")
+	}
+	if bf.TestIP(netip.AddrFrom16(v4.As16())) || bf.TestIP(netip.MustParseAddr("192.0.2.2")) {
+		t.Fatal("Other addresses should not be in the filter")
+	}
+}
+
+func TestIntegerZeroAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool allocates under the race detector")
+	}
+	bf := newClassic(1e3, 1e-3, DefaultHash)
+	a := netip.MustParseAddr("2001:db8::1")
+	if n := testing.AllocsPerRun(100, func() {
+		bf.AddUint64(1)
+		bf.TestUint64(1)
+		bf.AddUint32(2)
+		bf.TestUint32(2)
+		bf.AddIP(a)
+		bf.TestIP(a)
+	}); n != 0 {
+		t.Fatalf("Integer and address entries should not allocate but made %v allocations", n)
+	}
+}
+
+func BenchmarkAddUint64(b *testing.B) {
+	bf := newClassic(1e6, 1e-4, DefaultHash)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bf.AddUint64(uint64(i))
+	}
+}
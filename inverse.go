@@ -0,0 +1,57 @@
+package bloom
+
+import (
+	"bytes"
+	"sync/atomic"
+)
+
+// Inverse Bloom Filter
+//
+// An inverse filter is a fixed size table that remembers the last entry
+// hashed to each slot. Newer entries overwrite older ones, so it can have
+// false negatives but no false positives: Test only reports true if the very
+// same entry is still in its slot. It is safe for concurrent use and all
+// operations are lock free.
+type InverseFilter struct {
+	S []atomic.Pointer[[]byte] // slots
+	H func([]byte) (uint64, uint64)
+}
+
+// NewInverse creates an inverse Bloom Filter of size slots.
+func NewInverse(size int, h func([]byte) (uint64, uint64)) *InverseFilter {
+	return &InverseFilter{S: make([]atomic.Pointer[[]byte], size), H: h}
+}
+
+func (f *InverseFilter) slot(b []byte) *atomic.Pointer[[]byte] {
+	x, _ := f.H(b)
+	return &f.S[x%uint64(len(f.S))]
+}
+
+// Add stores a copy of the entry in its slot.
+func (f *InverseFilter) Add(b []byte) {
+	c := append([]byte(nil), b...)
+	f.slot(b).Store(&c)
+}
+
+// Test reports whether the entry is still in its slot.
+// False means the entry was either never added or has been overwritten.
+func (f *InverseFilter) Test(b []byte) bool {
+	p := f.slot(b).Load()
+	return p != nil && bytes.Equal(*p, b)
+}
+
+// Observe stores the entry in its slot and reports whether it was already there.
+func (f *InverseFilter) Observe(b []byte) bool {
+	c := append([]byte(nil), b...)
+	p := f.slot(b).Swap(&c)
+	return p != nil && bytes.Equal(*p, b)
+}
+
+// Size returns the size of the slot table in bytes, not counting the stored entries.
+func (f *InverseFilter) Size() int { return 8 * len(f.S) }
+
+func (f *InverseFilter) Reset() {
+	for i := range f.S {
+		f.S[i].Store(nil)
+	}
+}
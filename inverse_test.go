@@ -0,0 +1,42 @@
+package bloom
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestInverseFilter_Observe(t *testing.T) {
+	inf := NewInverse(1e3, doubleFNV)
+	buf := []byte("testing")
+	if inf.Observe(buf) {
+		t.Fatal("First sighting should report false")
+	}
+	if !inf.Observe(buf) {
+		t.Fatal("Second sighting should report true")
+	}
+	buf[0] = 'T'
+	if inf.Test(buf) {
+		t.Fatal("Filter should keep its own copy of entries")
+	}
+}
+
+func TestInverseFilter_NoFalsePositives(t *testing.T) {
+	inf := NewInverse(100, doubleFNV)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1e4; i++ {
+				inf.Add([]byte(fmt.Sprint(w, "-", i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+	for i := 0; i < 1e4; i++ {
+		if inf.Test([]byte(fmt.Sprint("missing-", i))) {
+			t.Fatal("Should never report an entry that was not added")
+		}
+	}
+}
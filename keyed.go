@@ -0,0 +1,86 @@
+package bloom
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrWrongKey is returned when decoding a keyed filter with another key than it was built with.
+var ErrWrongKey = errors.New("bloom: filter was built with a different key")
+
+// SipHash returns a double hash that is the 128-bit SipHash-2-4 of an entry under key.
+// Without the key, nobody can tell which entries collide in a filter using it.
+func SipHash(key [16]byte) func([]byte) (uint64, uint64) {
+	return func(b []byte) (uint64, uint64) { return sipHash128(key, b) }
+}
+
+// Keyed Bloom Filter
+//
+// A keyed filter is a classic filter hashed with SipHash under a secret key,
+// so that clients who can choose the entries it is tested with cannot craft
+// ones that are false positives in every filter. The key is never encoded
+// with the filter; it has to be stored separately and supplied to
+// DecodeKeyed, which checks it against a check value in the encoding.
+type KeyedFilter struct {
+	*ClassicFilter
+
+	key [16]byte
+}
+
+// NewKeyed creates a keyed classic Bloom Filter that is optimal for n entries and false positive
+// rate of p, hashed with SipHash under key.
+func NewKeyed(n int, p float64, key [16]byte) *KeyedFilter {
+	return &KeyedFilter{ClassicFilter: newClassic(n, p, SipHash(key)), key: key}
+}
+
+// NewRandomKeyed creates a keyed filter like NewKeyed with a random key from crypto/rand.
+func NewRandomKeyed(n int, p float64) (*KeyedFilter, error) {
+	var key [16]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, err
+	}
+	return NewKeyed(n, p, key), nil
+}
+
+// Key returns the secret key of the filter.
+func (f *KeyedFilter) Key() [16]byte { return f.key }
+
+// keyCheck returns a value that identifies a key without revealing it.
+func keyCheck(key [16]byte) uint64 {
+	x, _ := sipHash128(key, nil)
+	return x
+}
+
+// MarshalBinary encodes a version header and a check value of the key followed by the
+// binary encoding of the classic filter. The key itself is not encoded.
+func (f *KeyedFilter) MarshalBinary() ([]byte, error) {
+	data, err := f.ClassicFilter.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := appendTyped(make([]byte, 0, typedHeaderSize+8+len(data)), typeKeyed)
+	b = binary.LittleEndian.AppendUint64(b, keyCheck(f.key))
+	return append(b, data...), nil
+}
+
+// DecodeKeyed decodes a filter encoded by KeyedFilter.MarshalBinary with the key it was built
+// with, or by its versions before the encoding had a version header.
+// It returns ErrWrongKey if the key is not that key.
+func DecodeKeyed(b []byte, key [16]byte) (*KeyedFilter, error) {
+	b, err := parseTyped(b, typeKeyed)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 8 {
+		return nil, ErrInvalidEncoding
+	}
+	if binary.LittleEndian.Uint64(b) != keyCheck(key) {
+		return nil, ErrWrongKey
+	}
+	f := &ClassicFilter{H: SipHash(key)}
+	if err := f.UnmarshalBinary(b[8:]); err != nil {
+		return nil, err
+	}
+	return &KeyedFilter{ClassicFilter: f, key: key}, nil
+}
@@ -0,0 +1,31 @@
+package bloom
+
+import "testing"
+
+func TestKeyedFilter(t *testing.T) {
+	kf, err := NewRandomKeyed(1e4, 1e-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kf.Add([]byte("hello"))
+	other := NewKeyed(1e4, 1e-3, [16]byte{1})
+	other.Add([]byte("hello"))
+	if string(kf.B) == string(other.B) {
+		t.Fatal("Filters with different keys should set different bits")
+	}
+
+	data, err := kf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeKeyed(data, kf.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Test([]byte("hello")) {
+		t.Fatal("Should exist in decoded filter but got false")
+	}
+	if _, err := DecodeKeyed(data, [16]byte{1}); err != ErrWrongKey {
+		t.Fatalf("Wrong key error = %v, want %v", err, ErrWrongKey)
+	}
+}
@@ -0,0 +1,62 @@
+package bloom
+
+// Layered Bloom Filter
+//
+// A layered filter is a stack of L classic filters of the same geometry.
+// The n-th time an entry is added it goes into layer n-1, so the number of
+// consecutive layers from the bottom that contain an entry estimates how
+// many times it has been added, up to L.
+type LayeredFilter struct {
+	L []*ClassicFilter // layers
+}
+
+// NewLayered creates a layered Bloom Filter of l layers, each optimal for n entries and false
+// positive rate of p.
+func NewLayered(l, n int, p float64, h func([]byte) (uint64, uint64)) *LayeredFilter {
+	f := &LayeredFilter{L: make([]*ClassicFilter, l)}
+	for i := range f.L {
+		f.L[i] = newClassic(n, p, h)
+	}
+	return f
+}
+
+// Add adds an entry to the lowest layer it is not in yet and returns how many
+// times it has likely been added, including this time, up to L.
+func (f *LayeredFilter) Add(b []byte) int {
+	for i, l := range f.L {
+		if !l.Test(b) {
+			l.Add(b)
+			return i + 1
+		}
+	}
+	return len(f.L)
+}
+
+// Count returns how many times an entry has likely been added, up to L.
+func (f *LayeredFilter) Count(b []byte) int {
+	for i, l := range f.L {
+		if !l.Test(b) {
+			return i
+		}
+	}
+	return len(f.L)
+}
+
+// Test tests if an entry has been added at least once.
+func (f *LayeredFilter) Test(b []byte) bool { return f.L[0].Test(b) }
+
+// Size returns the size of the filter in bytes.
+func (f *LayeredFilter) Size() int {
+	size := 0
+	for _, l := range f.L {
+		size += l.Size()
+	}
+	return size
+}
+
+// Reset resets the filter to initial state.
+func (f *LayeredFilter) Reset() {
+	for _, l := range f.L {
+		l.Reset()
+	}
+}
@@ -0,0 +1,25 @@
+package bloom
+
+import "testing"
+
+func TestLayeredFilter_Add(t *testing.T) {
+	lf := NewLayered(3, 1e3, 1e-4, doubleFNV)
+	buf := []byte("testing")
+	if lf.Test(buf) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	for want := 1; want <= 5; want++ {
+		got := lf.Add(buf)
+		if want > 3 && got != 3 {
+			t.Fatalf("Count should cap at 3 but got %d", got)
+		} else if want <= 3 && got != want {
+			t.Fatalf("Add #%d returned %d", want, got)
+		}
+	}
+	if got := lf.Count(buf); got != 3 {
+		t.Fatalf("Count = %d, want 3", got)
+	}
+	if got := lf.Count([]byte("other")); got != 0 {
+		t.Fatalf("Count of a missing entry = %d, want 0", got)
+	}
+}
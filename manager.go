@@ -0,0 +1,493 @@
+package bloom
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrFilterExists is returned by Manager.Create for a name that is taken.
+var ErrFilterExists = errors.New("bloom: a filter of that name exists")
+
+// ErrInvalidName is returned by Manager.Create for an empty name.
+var ErrInvalidName = errors.New("bloom: filter name is empty")
+
+// CapacityPolicy says what a ManagedFilter does with a new entry once it holds as many as
+// its capacity.
+type CapacityPolicy int
+
+const (
+	// CapacityIgnore adds it, at a false positive rate growing beyond the one of the spec.
+	CapacityIgnore CapacityPolicy = iota
+	// CapacityReject drops it: Insert returns ErrFull, and Add and TestAndAdd ignore it.
+	CapacityReject
+	// CapacityReset empties the filter and adds it, starting over.
+	CapacityReset
+)
+
+// FilterSpec describes a filter of a Manager.
+type FilterSpec struct {
+	Capacity int            `json:"capacity"`
+	Rate     float64        `json:"rate"`
+	Hash     string         `json:"hash,omitempty"`   // name of a registered hash, "default" if empty
+	TTL      time.Duration  `json:"ttl,omitempty"`    // how long the filter is kept unused, forever if 0
+	Policy   CapacityPolicy `json:"policy,omitempty"` // what is done at capacity
+}
+
+// Manager owns named filters, such as one per tenant of a service: it creates them from a
+// FilterSpec, deletes them when asked or once unused for their TTL, and sums their
+// statistics. A manager opened with OpenManager persists its catalog of filters, and their
+// bits on Save. A Manager and its filters are safe for concurrent use.
+type Manager struct {
+	dir string
+	now func() time.Time
+
+	mu      sync.RWMutex
+	filters map[string]*ManagedFilter
+	expired uint64 // filters deleted for their TTL
+}
+
+// NewManager returns a manager without filters that persists nothing.
+func NewManager() *Manager {
+	return &Manager{now: time.Now, filters: make(map[string]*ManagedFilter)}
+}
+
+// catalogName is the file of the catalog in the directory of a manager.
+const catalogName = "catalog.json"
+
+// catalogEntry is a filter in the catalog.
+type catalogEntry struct {
+	Name     string     `json:"name"`
+	Spec     FilterSpec `json:"spec"`
+	Created  time.Time  `json:"created"`
+	LastUsed time.Time  `json:"last_used"`
+	Entries  int        `json:"entries"` // of the saved filter
+}
+
+// OpenManager returns a manager persisting its filters in dir, creating it if needed, with
+// the filters of the catalog there. Filters created or deleted are written to the catalog
+// right away, and their bits by Save, with SaveFile, which filters created since the last
+// Save come back without.
+func OpenManager(dir string) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	m := NewManager()
+	m.dir = dir
+	data, err := os.ReadFile(filepath.Join(dir, catalogName))
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var catalog []catalogEntry
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, err
+	}
+	for _, e := range catalog {
+		f, err := m.newFilter(e.Name, e.Spec)
+		if err != nil {
+			return nil, err
+		}
+		f.created = e.Created
+		f.lastUsed.Store(e.LastUsed.UnixNano())
+		path := m.path(e.Name)
+		cf, err := loadFile(path, f.f.H)
+		if err == nil && (cf.K != f.f.K || cf.BitCount() != f.f.BitCount()) {
+			err = ErrIncompatible
+		}
+		if os.IsNotExist(err) {
+			m.filters[e.Name] = f
+			continue
+		}
+		if err != nil {
+			emit(Event{Kind: EventLoad, Name: e.Name, Path: path, Err: err})
+			return nil, err
+		}
+		f.f, f.entries = cf, e.Entries
+		m.filters[e.Name] = f
+		emit(Event{Kind: EventLoad, Filter: f, Name: e.Name, Path: path})
+	}
+	return m, nil
+}
+
+// path returns the file of the bits of the filter of name, which is hex encoded to be a
+// valid file name on any platform.
+func (m *Manager) path(name string) string {
+	return filepath.Join(m.dir, hex.EncodeToString([]byte(name))+".bloom")
+}
+
+func (m *Manager) newFilter(name string, spec FilterSpec) (*ManagedFilter, error) {
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+	if spec.Hash == "" {
+		spec.Hash = "default"
+	}
+	h, ok := LookupHash(spec.Hash)
+	if !ok {
+		return nil, ErrUnregisteredHash
+	}
+	cf, err := NewChecked(spec.Capacity, spec.Rate, h)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	f := &ManagedFilter{name: name, spec: spec, m: m, f: cf, created: now}
+	f.lastUsed.Store(now.UnixNano())
+	return f, nil
+}
+
+// Create creates a filter named name as spec describes. It returns ErrFilterExists if there
+// is one of that name, ErrUnregisteredHash for a hash of spec that is not registered, the
+// errors of NewChecked for its capacity and rate, and the error of writing the catalog.
+func (m *Manager) Create(name string, spec FilterSpec) (*ManagedFilter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.filters[name]; ok && !f.expired() {
+		return nil, ErrFilterExists
+	}
+	f, err := m.newFilter(name, spec)
+	if err != nil {
+		return nil, err
+	}
+	if m.dir != "" {
+		// the bits of an expired filter of the name must not come back with this one
+		if err := os.Remove(m.path(name)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	old, replaced := m.filters[name]
+	m.filters[name] = f
+	if err := m.writeCatalog(); err != nil {
+		if replaced {
+			m.filters[name] = old
+		} else {
+			delete(m.filters, name)
+		}
+		return nil, err
+	}
+	if replaced {
+		m.expired++
+	}
+	return f, nil
+}
+
+// Get returns the filter named name, if there is one that has not expired, and marks it used.
+func (m *Manager) Get(name string) (*ManagedFilter, bool) {
+	m.mu.RLock()
+	f, ok := m.filters[name]
+	m.mu.RUnlock()
+	if !ok || f.expired() {
+		return nil, false
+	}
+	f.touch()
+	return f, true
+}
+
+// Delete deletes the filter named name, and its file, and reports whether there was one.
+func (m *Manager) Delete(name string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.filters[name]; !ok {
+		return false, nil
+	}
+	delete(m.filters, name)
+	return true, m.removeFiles(name)
+}
+
+func (m *Manager) removeFiles(names ...string) error {
+	if m.dir == "" {
+		return nil
+	}
+	if err := m.writeCatalog(); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := os.Remove(m.path(name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expire deletes the filters unused for longer than their TTL, and returns how many. Expired
+// filters are not returned by Get anyway, but hold their memory until Expire or a Create of
+// their name, so a service calls it periodically.
+func (m *Manager) Expire() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name, f := range m.filters {
+		if f.expired() {
+			names = append(names, name)
+			delete(m.filters, name)
+		}
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+	m.expired += uint64(len(names))
+	return len(names), m.removeFiles(names...)
+}
+
+// Names returns the names of the filters that have not expired, sorted.
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.filters))
+	for name, f := range m.filters {
+		if !f.expired() {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ManagerStats are the statistics of the filters of a Manager.
+type ManagerStats struct {
+	Filters   int    // filters that have not expired
+	Size      int    // bytes of their bit arrays
+	Entries   int    // entries they hold
+	Inserts   uint64 // calls adding entries
+	Lookups   uint64 // calls testing entries
+	Positives uint64 // tests that found the entry
+	Expired   uint64 // filters deleted for their TTL
+}
+
+// Stats returns the sums of the statistics of the filters that have not expired.
+func (m *Manager) Stats() ManagerStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st := ManagerStats{Expired: m.expired}
+	for _, f := range m.filters {
+		if f.expired() {
+			continue
+		}
+		fs := f.Stats()
+		st.Filters++
+		st.Size += fs.Size
+		st.Entries += fs.Entries
+		st.Inserts += fs.Inserts
+		st.Lookups += fs.Lookups
+		st.Positives += fs.Positives
+	}
+	return st
+}
+
+// Save writes the bits of every filter and the catalog, for a manager opened with
+// OpenManager. Each filter is written under its lock, so adds to it wait, but the other
+// filters are used as usual. It does nothing for a manager of NewManager.
+func (m *Manager) Save() error {
+	if m.dir == "" {
+		return nil
+	}
+	m.mu.RLock()
+	filters := make([]*ManagedFilter, 0, len(m.filters))
+	for _, f := range m.filters {
+		filters = append(filters, f)
+	}
+	m.mu.RUnlock()
+	for _, f := range filters {
+		f.mu.RLock()
+		path := m.path(f.name)
+		err := saveFile(path, f.f)
+		entries := f.entries
+		f.mu.RUnlock()
+		emit(Event{Kind: EventSave, Filter: f, Name: f.name, Path: path, Err: err})
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		f.saved = entries
+		f.mu.Unlock()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.writeCatalog()
+}
+
+// writeCatalog atomically replaces the catalog with the filters of m, with the number of
+// entries they held when last saved.
+func (m *Manager) writeCatalog() error {
+	if m.dir == "" {
+		return nil
+	}
+	catalog := make([]catalogEntry, 0, len(m.filters))
+	for name, f := range m.filters {
+		f.mu.RLock()
+		catalog = append(catalog, catalogEntry{
+			Name: name, Spec: f.spec, Created: f.created,
+			LastUsed: time.Unix(0, f.lastUsed.Load()), Entries: f.saved,
+		})
+		f.mu.RUnlock()
+	}
+	slices.SortFunc(catalog, func(a, b catalogEntry) int {
+		if a.Name < b.Name {
+			return -1
+		} else if a.Name > b.Name {
+			return 1
+		}
+		return 0
+	})
+	data, err := json.MarshalIndent(catalog, "", "\t")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.dir, catalogName)
+	tmp, err := os.CreateTemp(m.dir, catalogName+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ManagedFilter is a filter of a Manager: a classic filter counting its calls and the
+// entries it holds, which its CapacityPolicy applies to. It is safe for concurrent use.
+type ManagedFilter struct {
+	name    string
+	spec    FilterSpec
+	m       *Manager
+	created time.Time
+
+	mu      sync.RWMutex
+	f       *ClassicFilter
+	entries int // added since the last reset, not counting entries that tested positive
+	saved   int // entries when last saved
+
+	lastUsed                   atomic.Int64 // Unix nanoseconds
+	inserts, lookups, positive atomic.Uint64
+	resets, rejected           atomic.Uint64
+}
+
+// ManagedStats are the statistics of a ManagedFilter.
+type ManagedStats struct {
+	Size      int     // bytes of the bit array
+	Entries   int     // entries held, not counting those that tested positive when added
+	Capacity  int     // entries of the spec
+	FillRatio float64 // fraction of the bits set
+	Inserts   uint64  // calls adding entries
+	Lookups   uint64  // calls testing entries
+	Positives uint64  // tests that found the entry
+	Resets    uint64  // resets, by Reset or CapacityReset
+	Rejected  uint64  // entries dropped by CapacityReject
+}
+
+// Name returns the name of the filter.
+func (f *ManagedFilter) Name() string { return f.name }
+
+// Spec returns the spec the filter was created with.
+func (f *ManagedFilter) Spec() FilterSpec { return f.spec }
+
+func (f *ManagedFilter) touch() { f.lastUsed.Store(f.m.now().UnixNano()) }
+
+func (f *ManagedFilter) expired() bool {
+	return f.spec.TTL > 0 && f.m.now().Sub(time.Unix(0, f.lastUsed.Load())) > f.spec.TTL
+}
+
+// Insert adds an entry and returns ErrFull if the filter holds as many as its capacity and
+// its policy is CapacityReject.
+func (f *ManagedFilter) Insert(b []byte) error {
+	_, err := f.insert(b)
+	return err
+}
+
+func (f *ManagedFilter) insert(b []byte) (bool, error) {
+	f.touch()
+	f.inserts.Add(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f.Test(b) {
+		return true, nil
+	}
+	if f.entries >= f.spec.Capacity {
+		switch f.spec.Policy {
+		case CapacityReject:
+			f.rejected.Add(1)
+			return false, ErrFull
+		case CapacityReset:
+			f.f.Reset()
+			f.entries = 0
+			f.resets.Add(1)
+		}
+	}
+	f.f.Add(b)
+	f.entries++
+	if f.entries == f.spec.Capacity {
+		emit(Event{Kind: EventSaturate, Filter: f, Name: f.name})
+	}
+	return false, nil
+}
+
+// Add adds an entry like Insert, dropping it if Insert would return ErrFull.
+func (f *ManagedFilter) Add(b []byte) { f.insert(b) }
+
+// TestAndAdd adds an entry like Add and reports whether it was in the filter already.
+func (f *ManagedFilter) TestAndAdd(b []byte) bool {
+	found, _ := f.insert(b)
+	f.count(found)
+	return found
+}
+
+func (f *ManagedFilter) Test(b []byte) bool {
+	f.touch()
+	f.mu.RLock()
+	found := f.f.Test(b)
+	f.mu.RUnlock()
+	f.count(found)
+	return found
+}
+
+func (f *ManagedFilter) count(found bool) {
+	f.lookups.Add(1)
+	if found {
+		f.positive.Add(1)
+	}
+}
+
+func (f *ManagedFilter) Size() int { return f.f.Size() }
+
+func (f *ManagedFilter) Reset() {
+	f.touch()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.f.Reset()
+	f.entries = 0
+	f.resets.Add(1)
+}
+
+// Stats returns the statistics of the filter.
+func (f *ManagedFilter) Stats() ManagedStats {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return ManagedStats{
+		Size:      f.f.Size(),
+		Entries:   f.entries,
+		Capacity:  f.spec.Capacity,
+		FillRatio: f.f.FillRatio(),
+		Inserts:   f.inserts.Load(),
+		Lookups:   f.lookups.Load(),
+		Positives: f.positive.Load(),
+		Resets:    f.resets.Load(),
+		Rejected:  f.rejected.Load(),
+	}
+}
@@ -0,0 +1,213 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestManager_CreateGetDelete(t *testing.T) {
+	m := NewManager()
+	spec := FilterSpec{Capacity: 1000, Rate: 0.01}
+	f, err := m.Create("tenant-a", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create("tenant-a", spec); !errors.Is(err, ErrFilterExists) {
+		t.Errorf("Create of a taken name = %v, want ErrFilterExists", err)
+	}
+	if _, err := m.Create("", spec); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Create of an empty name = %v, want ErrInvalidName", err)
+	}
+	if _, err := m.Create("b", FilterSpec{Capacity: 1000, Rate: 0.01, Hash: "nope"}); !errors.Is(err, ErrUnregisteredHash) {
+		t.Errorf("Create with an unknown hash = %v, want ErrUnregisteredHash", err)
+	}
+	if _, err := m.Create("b", FilterSpec{Capacity: 1000, Rate: 2}); !errors.Is(err, ErrInvalidRate) {
+		t.Errorf("Create with rate 2 = %v, want ErrInvalidRate", err)
+	}
+	if f.Spec().Hash != "default" {
+		t.Errorf("Spec().Hash = %q, want default", f.Spec().Hash)
+	}
+
+	f.Add([]byte("x"))
+	g, ok := m.Get("tenant-a")
+	if !ok || g != f || !g.Test([]byte("x")) {
+		t.Fatal("Get should return the filter created")
+	}
+	if _, err := m.Create("tenant-b", spec); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Names(); !slices.Equal(got, []string{"tenant-a", "tenant-b"}) {
+		t.Errorf("Names() = %q", got)
+	}
+	if ok, err := m.Delete("tenant-a"); !ok || err != nil {
+		t.Fatalf("Delete = %v, %v", ok, err)
+	}
+	if ok, _ := m.Delete("tenant-a"); ok {
+		t.Error("Delete of a deleted filter should report false")
+	}
+	if _, ok := m.Get("tenant-a"); ok {
+		t.Error("Get should not return a deleted filter")
+	}
+}
+
+func TestManager_TTL(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	m := NewManager()
+	m.now = clock.Now
+	spec := FilterSpec{Capacity: 100, Rate: 0.01, TTL: time.Hour}
+	if _, err := m.Create("idle", spec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create("busy", spec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create("forever", FilterSpec{Capacity: 100, Rate: 0.01}); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		clock.Advance(40 * time.Minute)
+		f, ok := m.Get("busy")
+		if !ok {
+			t.Fatal("A filter used within its TTL should not expire")
+		}
+		f.Add([]byte("x"))
+	}
+	if _, ok := m.Get("idle"); ok {
+		t.Error("A filter unused for its TTL should expire")
+	}
+	if got := m.Names(); !slices.Equal(got, []string{"busy", "forever"}) {
+		t.Errorf("Names() = %q", got)
+	}
+	if got := m.Stats().Filters; got != 2 {
+		t.Errorf("Stats().Filters = %d, want 2", got)
+	}
+
+	// the name of an expired filter is free
+	if _, err := m.Create("idle", spec); err != nil {
+		t.Fatalf("Create of an expired name = %v", err)
+	}
+	clock.Advance(2 * time.Hour)
+	n, err := m.Expire()
+	if err != nil || n != 2 {
+		t.Fatalf("Expire() = %d, %v, want 2", n, err)
+	}
+	if got := m.Names(); !slices.Equal(got, []string{"forever"}) {
+		t.Errorf("Names() = %q", got)
+	}
+	if got := m.Stats().Expired; got != 3 {
+		t.Errorf("Stats().Expired = %d, want 3", got)
+	}
+}
+
+func TestManager_CapacityPolicy(t *testing.T) {
+	m := NewManager()
+	reject, _ := m.Create("reject", FilterSpec{Capacity: 10, Rate: 0.001, Policy: CapacityReject})
+	reset, _ := m.Create("reset", FilterSpec{Capacity: 10, Rate: 0.001, Policy: CapacityReset})
+	ignore, _ := m.Create("ignore", FilterSpec{Capacity: 10, Rate: 0.001})
+	for i := range 10 {
+		b := []byte(fmt.Sprint(i))
+		for _, f := range []*ManagedFilter{reject, reset, ignore} {
+			if err := f.Insert(b); err != nil {
+				t.Fatalf("%s: Insert below capacity = %v", f.Name(), err)
+			}
+		}
+	}
+	extra := []byte("extra")
+	if err := reject.Insert(extra); !errors.Is(err, ErrFull) {
+		t.Errorf("Insert at capacity = %v, want ErrFull", err)
+	}
+	if reject.Test(extra) {
+		t.Error("A rejected entry should not be added")
+	}
+	if err := reject.Insert([]byte("0")); err != nil {
+		t.Errorf("Insert of an entry held at capacity = %v, want nil", err)
+	}
+	if reset.TestAndAdd(extra) || reset.Test([]byte("0")) {
+		t.Error("CapacityReset should empty the filter at capacity")
+	}
+	if !reset.Test(extra) {
+		t.Error("CapacityReset should add the entry after the reset")
+	}
+	if err := ignore.Insert(extra); err != nil || !ignore.Test(extra) {
+		t.Errorf("CapacityIgnore should add beyond capacity, got %v", err)
+	}
+
+	if st := reject.Stats(); st.Entries != 10 || st.Rejected != 1 || st.Capacity != 10 {
+		t.Errorf("reject Stats() = %+v", st)
+	}
+	if st := reset.Stats(); st.Entries != 1 || st.Resets != 1 {
+		t.Errorf("reset Stats() = %+v", st)
+	}
+	if st := ignore.Stats(); st.Entries != 11 {
+		t.Errorf("ignore Stats() = %+v", st)
+	}
+}
+
+func TestManager_Stats(t *testing.T) {
+	m := NewManager()
+	a, _ := m.Create("a", FilterSpec{Capacity: 1000, Rate: 0.01})
+	b, _ := m.Create("b", FilterSpec{Capacity: 5000, Rate: 0.001})
+	a.Add([]byte("1"))
+	a.Add([]byte("2"))
+	b.Add([]byte("3"))
+	a.Test([]byte("1"))
+	b.Test([]byte("3"))
+	b.Test([]byte("4"))
+	st := m.Stats()
+	want := ManagerStats{Filters: 2, Size: a.Size() + b.Size(), Entries: 3, Inserts: 3, Lookups: 3}
+	want.Positives = st.Positives // 2, or 3 if "4" is a false positive
+	if st != want || st.Positives < 2 {
+		t.Errorf("Stats() = %+v, want %+v", st, want)
+	}
+}
+
+func TestManager_Persist(t *testing.T) {
+	dir := t.TempDir()
+	m, err := OpenManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := FilterSpec{Capacity: 1000, Rate: 0.01, Hash: "murmur3", TTL: time.Hour, Policy: CapacityReject}
+	a, err := m.Create("a/b", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Add([]byte("saved"))
+	if _, err := m.Create("gone", spec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	a.Add([]byte("unsaved"))
+	if _, err := m.Create("new", FilterSpec{Capacity: 10, Rate: 0.1}); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = OpenManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Names(); !slices.Equal(got, []string{"a/b", "new"}) {
+		t.Fatalf("Names() after reopening = %q", got)
+	}
+	a, _ = m.Get("a/b")
+	if a.Spec() != spec {
+		t.Errorf("Spec() = %+v, want %+v", a.Spec(), spec)
+	}
+	if !a.Test([]byte("saved")) {
+		t.Error("An entry added before Save should be restored")
+	}
+	if a.Test([]byte("unsaved")) {
+		t.Error("An entry added after Save should not be restored")
+	}
+	if got := a.Stats().Entries; got != 1 {
+		t.Errorf("Stats().Entries = %d, want 1", got)
+	}
+}
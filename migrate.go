@@ -0,0 +1,139 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"os"
+)
+
+// typedMagic starts the versioned encodings of filters other than classic filters, whose
+// binary encodings start with their own version. It is followed by typedVersion and a
+// type tag of the file format, then the encoding of the filter as it was before the
+// encodings were versioned.
+var typedMagic = [4]byte{'B', 'L', 'M', 'V'}
+
+// typedVersion is the version of the encodings that start with typedMagic.
+const typedVersion = 1
+
+// typedHeaderSize is the size of the magic number, version and type tag.
+const typedHeaderSize = len(typedMagic) + 2
+
+// appendTyped appends the header of a versioned encoding of a filter of type typ to b.
+func appendTyped(b []byte, typ byte) []byte {
+	b = append(b, typedMagic[:]...)
+	return append(b, typedVersion, typ)
+}
+
+// parseTyped returns the encoding of a filter of type typ following its header, or b
+// itself if it has no header, as written before the encodings were versioned. Those
+// encodings can start with anything, so one that starts with the magic number, once in
+// 2³², is taken to have a header.
+func parseTyped(b []byte, typ byte) ([]byte, error) {
+	if len(b) < len(typedMagic) || [4]byte(b) != typedMagic {
+		return b, nil
+	}
+	if len(b) < typedHeaderSize {
+		return nil, ErrInvalidEncoding
+	}
+	if b[len(typedMagic)] != typedVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	if b[len(typedMagic)+1] != typ {
+		return nil, ErrUnsupportedType
+	}
+	return b[typedHeaderSize:], nil
+}
+
+// legacyGob is how encoding/gob encoded a classic filter before it had GobEncode: its
+// exported fields, without the hash function, the number of bits taken from the bit array.
+type legacyGob struct {
+	B     []byte
+	K     int
+	Probe Probe
+	Seed  uint64
+}
+
+// LoadAny decodes a classic filter from data in any layout it has been saved in: the
+// file format of SaveFile, the binary encoding of MarshalBinary, WriteTo and their
+// compressed counterparts, the JSON of MarshalJSON, and gob streams, both of GobEncode
+// and of the exported fields of the filter, which encoding/gob wrote before the filter
+// had GobEncode. Layouts are told apart by their magic numbers and versions, and older
+// ones are upgraded, so saving the filter LoadAny returns writes the current layout. The
+// filter has the hash function named in data if there is one and it is registered, and h
+// otherwise. It returns ErrUnsupportedVersion for a file or JSON of a version it does not
+// know, and ErrInvalidEncoding for data in no layout it knows, which includes binary
+// encodings of other versions: they have no magic number to be told apart by. It returns
+// ErrDecrypt for an encrypted filter, which UnmarshalEncrypted decodes with its key.
+func LoadAny(data []byte, h func([]byte) (uint64, uint64)) (Filter, error) {
+	f, err := loadAny(data, h)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func loadAny(data []byte, h func([]byte) (uint64, uint64)) (*ClassicFilter, error) {
+	switch {
+	case len(data) == 0:
+		return nil, ErrInvalidEncoding
+	case len(data) >= len(fileMagic) && [4]byte(data) == fileMagic:
+		return readFile(bytes.NewReader(data), h)
+	case len(data) >= len(sealMagic) && [4]byte(data) == sealMagic:
+		return nil, ErrDecrypt
+	case data[0] == formatVersion:
+		f := &ClassicFilter{H: h}
+		if err := f.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case json.Valid(data):
+		f := &ClassicFilter{H: h}
+		if err := f.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return loadGob(data, h)
+}
+
+// gobBytes receives the GobEncode of a classic filter from a gob stream.
+type gobBytes []byte
+
+func (g *gobBytes) GobDecode(b []byte) error {
+	*g = append((*g)[:0], b...)
+	return nil
+}
+
+// loadGob decodes a gob stream of a classic filter, of GobEncode or of its exported fields.
+func loadGob(data []byte, h func([]byte) (uint64, uint64)) (*ClassicFilter, error) {
+	var encoded gobBytes
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&encoded); err == nil {
+		f := &ClassicFilter{}
+		if err := f.gobDecode(encoded, h); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	var g legacyGob
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&g); err != nil || g.K <= 0 || g.K > maxHashes || len(g.B) == 0 || !g.Probe.valid() {
+		return nil, ErrInvalidEncoding
+	}
+	return &ClassicFilter{B: g.B, K: g.K, H: h, Probe: g.Probe, Seed: g.Seed, m: 8 * uint64(len(g.B))}, nil
+}
+
+// LoadAnyFile reads a classic filter saved at path in any layout LoadAny decodes. It
+// emits an EventLoad.
+func LoadAnyFile(path string, h func([]byte) (uint64, uint64)) (Filter, error) {
+	data, err := os.ReadFile(path)
+	var f *ClassicFilter
+	if err == nil {
+		f, err = loadAny(data, h)
+	}
+	if err != nil {
+		emit(Event{Kind: EventLoad, Path: path, Err: err})
+		return nil, err
+	}
+	emit(Event{Kind: EventLoad, Filter: f, Path: path})
+	return f, nil
+}
@@ -0,0 +1,142 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// legacyClassic is a classic filter as it was declared before it had GobEncode.
+type legacyClassic struct {
+	B []byte
+	K int
+	H func([]byte) (uint64, uint64)
+}
+
+func TestLoadAny(t *testing.T) {
+	// a multiple of 8 bits, as filters had before their number of bits was exact
+	bf := NewWithBits(1<<14, 7, doubleFNV)
+	bf.Add([]byte("hello"))
+	RegisterHash("test-fnv", doubleFNV)
+
+	binary, _ := bf.MarshalBinary()
+	compressed, _ := bf.MarshalCompressed()
+	jsonData, _ := json.Marshal(bf)
+	var gobData, legacyData bytes.Buffer
+	if err := gob.NewEncoder(&gobData).Encode(bf); err != nil {
+		t.Fatal(err)
+	}
+	// encoding/gob skips the func field, as it did for the filter itself
+	if err := gob.NewEncoder(&legacyData).Encode(legacyClassic{B: bf.B, K: bf.K, H: bf.H}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "filter.bloom")
+	if err := SaveFile(path, bf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"binary", binary},
+		{"compressed", compressed},
+		{"json", jsonData},
+		{"gob", gobData.Bytes()},
+		{"legacy gob", legacyData.Bytes()},
+	} {
+		f, err := LoadAny(c.data, doubleFNV)
+		if err != nil {
+			t.Errorf("LoadAny of %s: %v", c.name, err)
+			continue
+		}
+		cf := f.(*ClassicFilter)
+		if !cf.Test([]byte("hello")) || cf.BitCount() != bf.BitCount() || cf.K != bf.K {
+			t.Errorf("LoadAny of %s = %d bits and %d hashes, want the filter", c.name, cf.BitCount(), cf.K)
+		}
+		upgraded, _ := cf.MarshalBinary()
+		if !bytes.Equal(upgraded, binary) {
+			t.Errorf("The filter loaded from %s should encode in the current layout", c.name)
+		}
+	}
+	f, err := LoadAnyFile(path, doubleFNV)
+	if err != nil || !f.Test([]byte("hello")) {
+		t.Fatalf("LoadAnyFile = %v, %v", f, err)
+	}
+
+	file, _ := os.ReadFile(path)
+	file[len(fileMagic)] = fileVersion + 1
+	for _, data := range [][]byte{file, []byte(`{"version":2}`)} {
+		if _, err := LoadAny(data, doubleFNV); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("LoadAny of a future version: %v, want %v", err, ErrUnsupportedVersion)
+		}
+	}
+	sealed, _ := MarshalEncrypted(bf, make([]byte, 16))
+	if _, err := LoadAny(sealed, doubleFNV); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("LoadAny of an encrypted filter: %v, want %v", err, ErrDecrypt)
+	}
+	for _, data := range [][]byte{nil, []byte("not a filter"), []byte(`{"version":1}`)} {
+		if _, err := LoadAny(data, doubleFNV); err == nil {
+			t.Errorf("LoadAny of %q should fail", data)
+		}
+	}
+}
+
+func TestLoadAny_UnregisteredGob(t *testing.T) {
+	bf := New(1e3, 1e-3, doubleFNV).(*ClassicFilter)
+	bf.Add([]byte("hello"))
+	bf.name = "migrate-unregistered"
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(bf); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadAny(buf.Bytes(), doubleFNV)
+	if err != nil || !f.Test([]byte("hello")) {
+		t.Fatalf("LoadAny should fall back to h for an unregistered hash but got %v", err)
+	}
+	if _, err := LoadAny(buf.Bytes(), nil); !errors.Is(err, ErrUnregisteredHash) {
+		t.Errorf("LoadAny without h = %v, want %v", err, ErrUnregisteredHash)
+	}
+}
+
+func TestParseTyped(t *testing.T) {
+	keys := [][]byte{[]byte("hello"), []byte("world")}
+	xf, _ := BuildXorFilter(keys, doubleFNV)
+	gcs, _ := BuildGCS(keys, 16, doubleFNV)
+	rf, _ := BuildRibbon(keys, 16, doubleFNV)
+	for _, c := range []struct {
+		name string
+		m    encoding.BinaryMarshaler
+		u    encoding.BinaryUnmarshaler
+	}{
+		{"xor", xf, &XorFilter{H: doubleFNV}},
+		{"gcs", gcs, &GCS{H: doubleFNV}},
+		{"ribbon", rf, &RibbonFilter{H: doubleFNV}},
+	} {
+		data, _ := c.m.MarshalBinary()
+		// the layout before the version header
+		if err := c.u.UnmarshalBinary(data[typedHeaderSize:]); err != nil {
+			t.Errorf("%s should decode its unversioned layout: %v", c.name, err)
+		}
+		data[len(typedMagic)] = 2
+		if err := c.u.UnmarshalBinary(data); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("%s of a future version: %v", c.name, err)
+		}
+		data[len(typedMagic)], data[len(typedMagic)+1] = typedVersion, typeClassic
+		if err := c.u.UnmarshalBinary(data); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("%s of another type: %v", c.name, err)
+		}
+	}
+
+	kf := NewKeyed(1e3, 1e-3, [16]byte{7})
+	kf.Add([]byte("hello"))
+	data, _ := kf.MarshalBinary()
+	if decoded, err := DecodeKeyed(data[typedHeaderSize:], kf.Key()); err != nil || !decoded.Test([]byte("hello")) {
+		t.Errorf("DecodeKeyed of the unversioned layout: %v", err)
+	}
+}
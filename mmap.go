@@ -0,0 +1,93 @@
+package bloom
+
+import (
+	"os"
+)
+
+// Memory-mapped Bloom Filter
+//
+// A memory-mapped filter is a classic filter whose bit array is a shared
+// memory mapping of a file in the format of SaveFile, so filters larger than
+// memory are paged in on demand and several processes can share one read-only
+// copy. Changes reach the file when the pages are written back by the
+// operating system, or explicitly on Flush, which also updates the checksum.
+type MmapFilter struct {
+	*ClassicFilter
+
+	file *os.File
+	data []byte // the whole mapping
+}
+
+// CreateMmap creates a file at path holding an empty classic filter that is optimal for n entries
+// and false positive rate of p, and maps it read-write.
+func CreateMmap(path string, n int, p float64, h func([]byte) (uint64, uint64)) (*MmapFilter, error) {
+	f := newClassicBits(n, p, make([]byte, BufferSize(n, p)), h)
+	if err := SaveFile(path, f); err != nil {
+		return nil, err
+	}
+	return OpenMmap(path, h, false)
+}
+
+// OpenMmap maps a filter file written by SaveFile or CreateMmap, read-only or read-write.
+// The checksum is not verified, since that would read the whole file.
+func OpenMmap(path string, h func([]byte) (uint64, uint64), readOnly bool) (*MmapFilter, error) {
+	flag := os.O_RDWR
+	if readOnly {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() < int64(fileHeaderSize) {
+		file.Close()
+		return nil, ErrInvalidEncoding
+	}
+	data, err := mmap(file, int(info.Size()), readOnly)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	hdr, _, err := parseFileHeader(data)
+	size := fileHeaderLen(hdr.Flags)
+	if err == nil && uint64(len(data)-size) != byteLen(hdr.M) {
+		err = ErrInvalidEncoding
+	}
+	if err != nil {
+		munmap(data)
+		file.Close()
+		return nil, err
+	}
+	return &MmapFilter{
+		ClassicFilter: &ClassicFilter{B: data[size:], K: int(hdr.K), H: h, Probe: probeOf(hdr), Seed: hdr.Seed, m: hdr.M, readOnly: readOnly},
+		file:          file,
+		data:          data,
+	}, nil
+}
+
+// Flush updates the checksum in the file and writes all changes to disk.
+func (f *MmapFilter) Flush() error {
+	if f.readOnly {
+		return nil
+	}
+	copy(f.data, fileHeader(f.ClassicFilter))
+	return msync(f.data)
+}
+
+// Close flushes a read-write filter and unmaps it. The filter must not be used afterwards.
+func (f *MmapFilter) Close() error {
+	err := f.Flush()
+	if uerr := munmap(f.data); err == nil {
+		err = uerr
+	}
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	f.ClassicFilter.B, f.data = nil, nil
+	return err
+}
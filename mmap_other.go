@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd) || tinygo
+
+package bloom
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapUnsupported = errors.New("bloom: memory-mapped filters are not supported on this platform")
+
+func mmap(file *os.File, size int, readOnly bool) ([]byte, error) { return nil, errMmapUnsupported }
+
+func munmap(data []byte) error { return errMmapUnsupported }
+
+func msync(data []byte) error { return errMmapUnsupported }
@@ -0,0 +1,70 @@
+//go:build (linux || darwin || freebsd) && !tinygo
+
+package bloom
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMmapFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.bloom")
+	mf, err := CreateMmap(path, 1e4, 1e-4, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf.Add([]byte("hello"))
+	if !mf.Test([]byte("hello")) {
+		t.Fatal("Should exist in filter but got false")
+	}
+
+	// a second read-only mapping sees the change right away
+	ro, err := OpenMmap(path, doubleFNV, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if !ro.Test([]byte("hello")) {
+		t.Fatal("Shared mapping should see the added entry")
+	}
+	mf.Add([]byte("world"))
+	if !ro.Test([]byte("world")) {
+		t.Fatal("Shared mapping should see entries added later")
+	}
+	if err := mf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// flushed files load with a valid checksum
+	loaded, err := LoadFile(path, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Test([]byte("hello")) || !loaded.Test([]byte("world")) {
+		t.Fatal("Should exist in loaded filter but got false")
+	}
+
+	defer func() {
+		if recover() != ErrReadOnly {
+			t.Fatal("Add to a read-only filter should panic with ErrReadOnly")
+		}
+	}()
+	ro.Add([]byte("nope"))
+}
+
+func TestMmapFilter_Seeded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.bloom")
+	bf := NewSeeded(1e4, 1e-4, 0x5eed, doubleFNV)
+	bf.Add([]byte("hello"))
+	if err := SaveFile(path, bf); err != nil {
+		t.Fatal(err)
+	}
+	mf, err := OpenMmap(path, doubleFNV, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mf.Close()
+	if mf.Seed != bf.Seed || !mf.Test([]byte("hello")) {
+		t.Fatal("Mapped filter should keep the seed")
+	}
+}
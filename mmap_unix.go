@@ -0,0 +1,27 @@
+//go:build (linux || darwin || freebsd) && !tinygo
+
+package bloom
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func mmap(file *os.File, size int, readOnly bool) ([]byte, error) {
+	prot := syscall.PROT_READ
+	if !readOnly {
+		prot |= syscall.PROT_WRITE
+	}
+	return syscall.Mmap(int(file.Fd()), 0, size, prot, syscall.MAP_SHARED)
+}
+
+func munmap(data []byte) error { return syscall.Munmap(data) }
+
+func msync(data []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
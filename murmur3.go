@@ -0,0 +1,141 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// MurmurHash3 x64 128-bit constants
+const (
+	murmurC1 uint64 = 0x87c37b91114253d5
+	murmurC2 uint64 = 0x4cf5ad432745937f
+)
+
+func murmurFmix(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+// murmur3Sum128 returns the 128-bit MurmurHash3 (x64 variant) of b with the given seed.
+// See https://github.com/aappleby/smhasher/blob/master/src/MurmurHash3.cpp
+func murmur3Sum128(b []byte, seed uint32) (uint64, uint64) {
+	full := len(b) &^ 15
+	return murmur3Tail(b[:full], b[full:], len(b), seed)
+}
+
+// murmur3Sum128Plus1 returns the MurmurHash3 of b followed by a single byte of value 1,
+// without copying b.
+func murmur3Sum128Plus1(b []byte, seed uint32) (uint64, uint64) {
+	full := len(b) &^ 15
+	var tail [16]byte
+	n := copy(tail[:], b[full:])
+	tail[n] = 1
+	if n == 15 {
+		// the extra byte completes a block
+		h1, h2 := murmur3Blocks(b[:full], seed)
+		h1, h2 = murmur3Block(h1, h2, tail[:])
+		return murmur3Final(h1, h2, nil, len(b)+1)
+	}
+	return murmur3Tail(b[:full], tail[:n+1], len(b)+1, seed)
+}
+
+func murmur3Tail(blocks, tail []byte, n int, seed uint32) (uint64, uint64) {
+	h1, h2 := murmur3Blocks(blocks, seed)
+	return murmur3Final(h1, h2, tail, n)
+}
+
+func murmur3Block(h1, h2 uint64, b []byte) (uint64, uint64) {
+	k1 := binary.LittleEndian.Uint64(b)
+	k2 := binary.LittleEndian.Uint64(b[8:])
+	k1 *= murmurC1
+	k1 = bits.RotateLeft64(k1, 31)
+	k1 *= murmurC2
+	h1 ^= k1
+	h1 = bits.RotateLeft64(h1, 27)
+	h1 += h2
+	h1 = h1*5 + 0x52dce729
+	k2 *= murmurC2
+	k2 = bits.RotateLeft64(k2, 33)
+	k2 *= murmurC1
+	h2 ^= k2
+	h2 = bits.RotateLeft64(h2, 31)
+	h2 += h1
+	h2 = h2*5 + 0x38495ab5
+	return h1, h2
+}
+
+func murmur3Blocks(b []byte, seed uint32) (uint64, uint64) {
+	h1, h2 := uint64(seed), uint64(seed)
+	for ; len(b) >= 16; b = b[16:] {
+		h1, h2 = murmur3Block(h1, h2, b)
+	}
+	return h1, h2
+}
+
+func murmur3Final(h1, h2 uint64, tail []byte, n int) (uint64, uint64) {
+	var k1, k2 uint64
+	for i := len(tail) - 1; i >= 8; i-- {
+		k2 = k2<<8 | uint64(tail[i])
+	}
+	for i := min(len(tail), 8) - 1; i >= 0; i-- {
+		k1 = k1<<8 | uint64(tail[i])
+	}
+	if len(tail) > 8 {
+		k2 *= murmurC2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= murmurC1
+		h2 ^= k2
+	}
+	if len(tail) > 0 {
+		k1 *= murmurC1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= murmurC2
+		h1 ^= k1
+	}
+	h1 ^= uint64(n)
+	h2 ^= uint64(n)
+	h1 += h2
+	h2 += h1
+	h1 = murmurFmix(h1)
+	h2 = murmurFmix(h2)
+	h1 += h2
+	h2 += h1
+	return h1, h2
+}
+
+// murmur3Sum32 returns the 32-bit MurmurHash3 (x86 variant) of b with the given seed.
+func murmur3Sum32(b []byte, seed uint32) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+	h := seed
+	full := len(b) &^ 3
+	for i := 0; i < full; i += 4 {
+		k := binary.LittleEndian.Uint32(b[i:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	var k uint32
+	for i := len(b) - 1; i >= full; i-- {
+		k = k<<8 | uint32(b[i])
+	}
+	if len(b) > full {
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(len(b))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,61 @@
+package bloom
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestMurmur3Sum128(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		h1, h2 uint64
+	}{
+		{"", 0, 0},
+		{"hello", 0xcbd8a7b341bd9b02, 0x5b1e906a48ae1d19},
+		{"The quick brown fox jumps over the lazy dog", 0xe34bbc7bbc071b6c, 0x7a433ca9c49a9347},
+	} {
+		if h1, h2 := murmur3Sum128([]byte(tc.in), 0); h1 != tc.h1 || h2 != tc.h2 {
+			t.Errorf("murmur3Sum128(%q) = %#x, %#x, want %#x, %#x", tc.in, h1, h2, tc.h1, tc.h2)
+		}
+	}
+}
+
+func TestMurmur3Sum128Plus1(t *testing.T) {
+	for n := 0; n < 40; n++ {
+		b := []byte(fmt.Sprintf("%040d", n))[:n]
+		h1, h2 := murmur3Sum128Plus1(b, 0)
+		w1, w2 := murmur3Sum128(append(append([]byte(nil), b...), 1), 0)
+		if h1 != w1 || h2 != w2 {
+			t.Fatalf("length %d: got %#x, %#x, want %#x, %#x", n, h1, h2, w1, w2)
+		}
+	}
+}
+
+// Test vectors of Bitcoin Core's hash_tests, of the seeds and lengths of BIP 37.
+func TestMurmur3Sum32(t *testing.T) {
+	for _, tc := range []struct {
+		want, seed uint32
+		in         string
+	}{
+		{0x00000000, 0x00000000, ""},
+		{0x6a396f08, 0xFBA4C795, ""},
+		{0x81f16f39, 0xffffffff, ""},
+		{0x514e28b7, 0x00000000, "00"},
+		{0xea3f0b17, 0xFBA4C795, "00"},
+		{0xfd6cf10d, 0x00000000, "ff"},
+		{0x16c6b7ab, 0x00000000, "0011"},
+		{0x8eb51c3d, 0x00000000, "001122"},
+		{0xb4471bf8, 0x00000000, "00112233"},
+		{0xe2301fa8, 0x00000000, "0011223344"},
+		{0xfc2e4a15, 0x00000000, "001122334455"},
+		{0xb074502c, 0x00000000, "00112233445566"},
+		{0x8034d2a0, 0x00000000, "0011223344556677"},
+		{0xb4698def, 0x00000000, "001122334455667788"},
+	} {
+		b, _ := hex.DecodeString(tc.in)
+		if got := murmur3Sum32(b, tc.seed); got != tc.want {
+			t.Errorf("murmur3Sum32(%s, %#x) = %#08x, want %#08x", tc.in, tc.seed, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package bloom
+
+import (
+	"os"
+	"unsafe"
+)
+
+// Option configures a filter created by NewWithOptions.
+type Option func(*options)
+
+type options struct {
+	h       func([]byte) (uint64, uint64)
+	name    string
+	seed    uint64
+	probe   Probe
+	safe    bool
+	blocked bool
+	pow2    bool
+	align   int // alignment of the bit array, or 0
+	huge    bool
+	ct      bool
+	thresh  *Thresholds
+}
+
+// bytes returns a zeroed bit array of size bytes, aligned as the options ask.
+func (o *options) bytes(size int) []byte {
+	if o.align == 0 {
+		return make([]byte, size)
+	}
+	b := alignedBytes(size, o.align)
+	if o.huge {
+		adviseHugePages(b)
+	}
+	return b
+}
+
+// words returns a zeroed bit array of n words, aligned as the options ask.
+func (o *options) words(n int) []uint64 {
+	if o.align == 0 {
+		return make([]uint64, n)
+	}
+	w := alignedWords(n, o.align)
+	if o.huge {
+		adviseHugePages(unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(w))), 8*n))
+	}
+	return w
+}
+
+// WithHasher hashes entries with h instead of DefaultHash.
+func WithHasher(h Hasher) Option {
+	return func(o *options) { o.h, o.name = hashFunc(h), h.Name() }
+}
+
+// WithHash hashes entries with the double hash h instead of DefaultHash.
+func WithHash(h func([]byte) (uint64, uint64)) Option {
+	return func(o *options) { o.h, o.name = h, "" }
+}
+
+// WithSeed mixes seed into the hashes, as in NewSeeded.
+func WithSeed(seed uint64) Option { return func(o *options) { o.seed = seed } }
+
+// WithProbeScheme derives the offsets of entries with p. Blocked filters have their own
+// scheme, and panic with any other than DoubleHashing.
+func WithProbeScheme(p Probe) Option { return func(o *options) { o.probe = p } }
+
+// WithConcurrencySafe wraps the filter in a SafeFilter.
+func WithConcurrencySafe() Option { return func(o *options) { o.safe = true } }
+
+// WithBlocked creates a BlockedFilter instead of a ClassicFilter.
+func WithBlocked() Option { return func(o *options) { o.blocked = true } }
+
+// WithPowerOfTwo rounds the number of bits up to a power of two, as in NewPowerOfTwo.
+// Blocked filters have their own sizing, and panic with it.
+func WithPowerOfTwo() Option { return func(o *options) { o.pow2 = true } }
+
+// WithPageAligned allocates the bit array aligned to a memory page.
+func WithPageAligned() Option {
+	return func(o *options) { o.align = max(o.align, os.Getpagesize()) }
+}
+
+// WithHugePages allocates the bit array aligned to a 2 MiB huge page and, on Linux, advises
+// the kernel to back it with transparent huge pages, which cuts the TLB misses of random
+// probes into filters of gigabytes. Elsewhere it only aligns the array.
+func WithHugePages() Option {
+	return func(o *options) { o.align, o.huge = max(o.align, hugePageSize), true }
+}
+
+// WithConstantTime makes Test take as long whether or not an entry is in the filter, as
+// SetConstantTime does. Blocked filters do not have it, and panic with it.
+func WithConstantTime() Option { return func(o *options) { o.ct = true } }
+
+// WithThresholds wraps the filter in a MonitoredFilter reporting the thresholds of t, before
+// any SafeFilter, whose lock Notify is then called with. Blocked filters do not have it, and
+// panic with it.
+func WithThresholds(t Thresholds) Option { return func(o *options) { o.thresh = &t } }
+
+// NewWithOptions creates a Bloom Filter that is optimal for n entries and false positive rate
+// of p, a *ClassicFilter unless opts ask for another variant.
+func NewWithOptions(n int, p float64, opts ...Option) Filter {
+	o := options{h: DefaultHash, name: "default"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var f Filter
+	if o.blocked {
+		if o.probe != DoubleHashing {
+			panic("bloom: blocked filters have their own probe scheme")
+		}
+		if o.pow2 {
+			panic("bloom: blocked filters have their own sizing")
+		}
+		if o.ct {
+			panic("bloom: blocked filters do not test in constant time")
+		}
+		if o.thresh != nil {
+			panic("bloom: blocked filters do not report thresholds")
+		}
+		h := o.h
+		if seed := o.seed; seed != 0 {
+			h = func(b []byte) (uint64, uint64) {
+				x, y := o.h(b)
+				return splitmix64(x ^ seed), splitmix64(y ^ seed)
+			}
+		}
+		words, k := blockedSize(n, p)
+		f = &BlockedFilter{B: o.words(words), K: k, H: h}
+	} else {
+		size := BufferSize(n, p)
+		if o.pow2 {
+			size = powerOfTwoSize(n, p)
+		}
+		cf := newClassicBits(n, p, o.bytes(size), o.h)
+		if o.pow2 {
+			cf.m = 8 * uint64(size)
+		}
+		cf.name, cf.Seed, cf.Probe, cf.constant = o.name, o.seed, o.probe, o.ct
+		f = cf
+		if o.thresh != nil {
+			f = Monitor(cf, *o.thresh)
+		}
+	}
+	if o.safe {
+		f = WrapSafe(f)
+	}
+	return f
+}
@@ -0,0 +1,115 @@
+package bloom
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"unsafe"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	bf := NewWithOptions(1e3, 1e-3).(*ClassicFilter)
+	if name, _ := bf.hashName(); name != "default" || bf.Seed != 0 || bf.Probe != DoubleHashing {
+		t.Fatal("Default options should make a classic filter of DefaultHash")
+	}
+
+	bf = NewWithOptions(1e3, 1e-3, WithHasher(NewHasher("fnv", doubleFNV)), WithSeed(3),
+		WithProbeScheme(EnhancedDoubleHashing)).(*ClassicFilter)
+	if name, _ := bf.hashName(); name != "fnv" || bf.Seed != 3 || bf.Probe != EnhancedDoubleHashing {
+		t.Fatal("Options should set the hasher, seed and probe scheme")
+	}
+	same := NewWithOptions(1e3, 1e-3, WithHasher(NewHasher("fnv", doubleFNV)), WithSeed(3),
+		WithProbeScheme(EnhancedDoubleHashing)).(*ClassicFilter)
+	if !bf.Equal(same) || bf.Equal(NewSeeded(1e3, 1e-3, 3, doubleFNV)) {
+		t.Fatal("Filters should be equal only with the same options")
+	}
+
+	safe := NewWithOptions(1e3, 1e-3, WithConcurrencySafe(), WithBlocked(), WithSeed(3))
+	if _, ok := safe.(*SafeFilter); !ok {
+		t.Fatalf("Concurrency safe filter should be a SafeFilter but got %T", safe)
+	}
+	blocked := NewWithOptions(1e3, 1e-3, WithBlocked(), WithSeed(3)).(*BlockedFilter)
+	unseeded := NewWithOptions(1e3, 1e-3, WithBlocked()).(*BlockedFilter)
+	for _, f := range []Filter{safe, blocked, unseeded} {
+		f.Add([]byte("hello"))
+		if !f.Test([]byte("hello")) {
+			t.Fatalf("%T: should exist in filter but got false", f)
+		}
+	}
+	if slices.Equal(blocked.B, unseeded.B) {
+		t.Fatal("Seed should change the bits of a blocked filter")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Blocked filter with a probe scheme should panic")
+		}
+	}()
+	NewWithOptions(1e3, 1e-3, WithBlocked(), WithProbeScheme(EnhancedDoubleHashing))
+}
+
+func TestNewWithOptions_ConstantTime(t *testing.T) {
+	bf := NewWithOptions(1e3, 1e-3, WithConstantTime()).(*ClassicFilter)
+	if !bf.ConstantTime() {
+		t.Fatal("WithConstantTime should make a constant-time filter")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Blocked filter in constant time should panic")
+		}
+	}()
+	NewWithOptions(1e3, 1e-3, WithBlocked(), WithConstantTime())
+}
+
+func TestNewWithOptions_Thresholds(t *testing.T) {
+	var got []Saturation
+	f := NewWithOptions(100, 1e-2, WithThresholds(Thresholds{FillRatio: 0.3, Notify: func(s Saturation) {
+		got = append(got, s)
+	}}), WithConcurrencySafe())
+	var inner Filter
+	f.(*SafeFilter).Do(func(f Filter) { inner = f })
+	if _, ok := inner.(*MonitoredFilter); !ok {
+		t.Fatalf("WithThresholds should monitor the filter but got %T", inner)
+	}
+	for i := range 100 {
+		f.Add([]byte(fmt.Sprint(i)))
+	}
+	if len(got) != 1 || got[0].Metric != "fill_ratio" {
+		t.Fatalf("Thresholds = %+v", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Blocked filter with thresholds should panic")
+		}
+	}()
+	NewWithOptions(1e3, 1e-3, WithBlocked(), WithThresholds(Thresholds{FillRatio: 0.5}))
+}
+
+func TestNewWithOptions_Aligned(t *testing.T) {
+	page := NewWithOptions(1e5, 1e-3, WithPageAligned()).(*ClassicFilter)
+	huge := NewWithOptions(1e6, 1e-3, WithHugePages(), WithPowerOfTwo()).(*ClassicFilter)
+	blocked := NewWithOptions(1e5, 1e-3, WithHugePages(), WithBlocked()).(*BlockedFilter)
+	for _, c := range []struct {
+		name  string
+		p     unsafe.Pointer
+		align int
+	}{
+		{"page aligned", unsafe.Pointer(&page.B[0]), os.Getpagesize()},
+		{"huge page", unsafe.Pointer(&huge.B[0]), hugePageSize},
+		{"blocked huge page", unsafe.Pointer(&blocked.B[0]), hugePageSize},
+	} {
+		if uintptr(c.p)%uintptr(c.align) != 0 {
+			t.Fatalf("Bit array of %s filter should be aligned to %d bytes", c.name, c.align)
+		}
+	}
+	if page.Size() != BufferSize(1e5, 1e-3) || huge.Size() != powerOfTwoSize(1e6, 1e-3) {
+		t.Fatal("Aligned filters should keep their size")
+	}
+	for _, f := range []Filter{page, huge, blocked} {
+		f.Add([]byte("hello"))
+		if !f.Test([]byte("hello")) || f.Test([]byte("world")) {
+			t.Fatalf("%T: aligned filter should work like any other", f)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+module github.com/OperatorFoundation/go-bloom/otel
+
+go 1.23
+
+require (
+	github.com/OperatorFoundation/go-bloom v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.31.0
+	go.opentelemetry.io/otel/sdk v1.31.0
+	go.opentelemetry.io/otel/trace v1.31.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/otel/metric v1.31.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+)
+
+replace github.com/OperatorFoundation/go-bloom => ../
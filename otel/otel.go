@@ -0,0 +1,333 @@
+// Package otel traces the slow calls of filters of github.com/OperatorFoundation/go-bloom
+// with OpenTelemetry, so bulk loads, serialization, rotations and the round trips of
+// remote filters show up in the traces of the services using them.
+package otel
+
+import (
+	"bufio"
+	"context"
+	"encoding"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans.
+const tracerName = "github.com/OperatorFoundation/go-bloom/otel"
+
+// Attributes of the spans.
+const (
+	KeysKey      = attribute.Key("bloom.keys")      // entries of a bulk operation
+	PositivesKey = attribute.Key("bloom.positives") // entries a bulk test found
+	BytesKey     = attribute.Key("bloom.bytes")     // bytes encoded or decoded
+	SizeKey      = attribute.Key("bloom.size")      // size of the filter in bytes
+)
+
+// Option configures a TracedFilter.
+type Option func(*TracedFilter)
+
+// WithTracerProvider creates the spans with tp instead of the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *TracedFilter) { t.tracer = tp.Tracer(tracerName) }
+}
+
+// WithAttributes adds attrs to every span, such as the name of the filter.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(t *TracedFilter) { t.attrs = append(t.attrs, attrs...) }
+}
+
+// remote is a filter served by another process, whose calls are round trips that can fail:
+// a bloom.RedisFilter, a bloom.BloomdFilter or a grpc.Client.
+type remote interface {
+	Insert([]byte) error
+	Lookup([]byte) (bool, error)
+	InsertAndLookup([]byte) (bool, error)
+	InsertMany([][]byte) error
+	LookupMany([][]byte) ([]bool, error)
+	Err() error
+}
+
+// TracedFilter is a Filter recording a span for each call to the filter it wraps that may
+// be slow:
+//
+//	bloom.AddMany, bloom.TestMany      bulk operations, with the number of keys
+//	bloom.AddFromReader                loads of entries from a reader
+//	bloom.WriteTo, bloom.ReadFrom,     serialization, with the number of bytes
+//	bloom.MarshalBinary, bloom.UnmarshalBinary
+//	bloom.Reset                        resets, which clear the whole filter
+//	bloom.Rotate                       rotations, if the filter is a RotatingFilter
+//	bloom.Add, bloom.Test,             calls of a remote filter, such as a RedisFilter,
+//	bloom.TestAndAdd                   with their error; of other filters, not traced
+//
+// The spans are children of the span of the context given to WithContext, and roots
+// otherwise. Errors are recorded on the spans, and the errors of remote filters, which
+// Add and Test have no result for, are kept for Err like the remote filter does.
+type TracedFilter struct {
+	f      bloom.Filter
+	ctx    context.Context
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+	errs   *firstError
+}
+
+// Wrap returns f recording spans of its slow calls with the global tracer provider, or the
+// one of the options. The rotations of a RotatingFilter are traced by chaining its
+// OnRotate, which must be set before, as a span at the time of the rotation.
+func Wrap(f bloom.Filter, opts ...Option) *TracedFilter {
+	t := &TracedFilter{f: f, ctx: context.Background(), errs: new(firstError)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.tracer == nil {
+		t.tracer = otel.GetTracerProvider().Tracer(tracerName)
+	}
+	if r, ok := f.(*bloom.RotatingFilter); ok {
+		onRotate := r.OnRotate
+		r.OnRotate = func(at time.Time) {
+			_, span := t.tracer.Start(t.ctx, "bloom.Rotate", trace.WithTimestamp(at), trace.WithAttributes(t.attrs...))
+			span.End(trace.WithTimestamp(at))
+			if onRotate != nil {
+				onRotate(at)
+			}
+		}
+	}
+	return t
+}
+
+// WithContext returns a copy of t whose spans are children of the span of ctx. The copy
+// shares the filter and the kept error of t.
+func (t *TracedFilter) WithContext(ctx context.Context) *TracedFilter {
+	c := *t
+	c.ctx = ctx
+	return &c
+}
+
+// Unwrap returns the wrapped filter.
+func (t *TracedFilter) Unwrap() bloom.Filter { return t.f }
+
+// start starts a span of the call name.
+func (t *TracedFilter) start(name string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := t.tracer.Start(t.ctx, name, trace.WithAttributes(t.attrs...), trace.WithAttributes(attrs...))
+	return span
+}
+
+// end ends span, recording err if it is not nil.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *TracedFilter) Add(b []byte) {
+	r, ok := t.f.(remote)
+	if !ok {
+		t.f.Add(b)
+		return
+	}
+	span := t.start("bloom.Add")
+	err := r.Insert(b)
+	t.errs.record(err)
+	end(span, err)
+}
+
+func (t *TracedFilter) Test(b []byte) bool {
+	r, ok := t.f.(remote)
+	if !ok {
+		return t.f.Test(b)
+	}
+	span := t.start("bloom.Test")
+	found, err := r.Lookup(b)
+	t.errs.record(err)
+	end(span, err)
+	return found
+}
+
+// TestAndAdd adds an entry and reports whether it was already in the filter, with one call
+// if the wrapped filter is a bloom.TestAndAdder.
+func (t *TracedFilter) TestAndAdd(b []byte) bool {
+	r, ok := t.f.(remote)
+	if !ok {
+		return bloom.TestAndAdd(t.f, b)
+	}
+	span := t.start("bloom.TestAndAdd")
+	found, err := r.InsertAndLookup(b)
+	t.errs.record(err)
+	end(span, err)
+	return found
+}
+
+func (t *TracedFilter) Size() int { return t.f.Size() }
+
+func (t *TracedFilter) Reset() {
+	span := t.start("bloom.Reset", SizeKey.Int(t.f.Size()))
+	t.f.Reset()
+	if r, ok := t.f.(remote); ok {
+		err := r.Err()
+		t.errs.record(err)
+		end(span, err)
+		return
+	}
+	span.End()
+}
+
+// AddMany adds keys, in one call if the wrapped filter has AddMany.
+func (t *TracedFilter) AddMany(keys [][]byte) {
+	span := t.start("bloom.AddMany", KeysKey.Int(len(keys)))
+	switch f := t.f.(type) {
+	case remote:
+		err := f.InsertMany(keys)
+		t.errs.record(err)
+		end(span, err)
+		return
+	case interface{ AddMany([][]byte) }:
+		f.AddMany(keys)
+	default:
+		for _, b := range keys {
+			f.Add(b)
+		}
+	}
+	span.End()
+}
+
+// TestMany tests keys and reports which may be in the filter, in one call if the wrapped
+// filter has TestMany.
+func (t *TracedFilter) TestMany(keys [][]byte) []bool {
+	span := t.start("bloom.TestMany", KeysKey.Int(len(keys)))
+	var found []bool
+	var err error
+	switch f := t.f.(type) {
+	case remote:
+		found, err = f.LookupMany(keys)
+		t.errs.record(err)
+		if found == nil {
+			found = make([]bool, len(keys))
+		}
+	case interface{ TestMany([][]byte) []bool }:
+		found = f.TestMany(keys)
+	default:
+		found = make([]bool, len(keys))
+		for i, b := range keys {
+			found[i] = f.Test(b)
+		}
+	}
+	positives := 0
+	for _, ok := range found {
+		if ok {
+			positives++
+		}
+	}
+	span.SetAttributes(PositivesKey.Int(positives))
+	end(span, err)
+	return found
+}
+
+// AddFromReader adds the entries of r, split by split, like bloom.AddFromReader, and
+// returns how many it added.
+func (t *TracedFilter) AddFromReader(r io.Reader, split bufio.SplitFunc) (int, error) {
+	span := t.start("bloom.AddFromReader")
+	n, err := bloom.AddFromReader(t.f, r, split)
+	if rf, ok := t.f.(remote); ok && err == nil {
+		err = rf.Err()
+		t.errs.record(err)
+	}
+	span.SetAttributes(KeysKey.Int(n))
+	end(span, err)
+	return n, err
+}
+
+// WriteTo writes the wrapped filter to w, and returns errors.ErrUnsupported if it is not
+// an io.WriterTo.
+func (t *TracedFilter) WriteTo(w io.Writer) (int64, error) {
+	span := t.start("bloom.WriteTo")
+	var n int64
+	err := errors.ErrUnsupported
+	if wt, ok := t.f.(io.WriterTo); ok {
+		n, err = wt.WriteTo(w)
+	}
+	span.SetAttributes(BytesKey.Int64(n))
+	end(span, err)
+	return n, err
+}
+
+// ReadFrom reads the wrapped filter from r, and returns errors.ErrUnsupported if it is not
+// an io.ReaderFrom.
+func (t *TracedFilter) ReadFrom(r io.Reader) (int64, error) {
+	span := t.start("bloom.ReadFrom")
+	var n int64
+	err := errors.ErrUnsupported
+	if rf, ok := t.f.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	}
+	span.SetAttributes(BytesKey.Int64(n))
+	end(span, err)
+	return n, err
+}
+
+// MarshalBinary encodes the wrapped filter, and returns errors.ErrUnsupported if it is not
+// an encoding.BinaryMarshaler.
+func (t *TracedFilter) MarshalBinary() ([]byte, error) {
+	span := t.start("bloom.MarshalBinary")
+	var data []byte
+	err := errors.ErrUnsupported
+	if m, ok := t.f.(encoding.BinaryMarshaler); ok {
+		data, err = m.MarshalBinary()
+	}
+	span.SetAttributes(BytesKey.Int(len(data)))
+	end(span, err)
+	return data, err
+}
+
+// UnmarshalBinary decodes the wrapped filter, and returns errors.ErrUnsupported if it is
+// not an encoding.BinaryUnmarshaler.
+func (t *TracedFilter) UnmarshalBinary(data []byte) error {
+	span := t.start("bloom.UnmarshalBinary", BytesKey.Int(len(data)))
+	err := errors.ErrUnsupported
+	if u, ok := t.f.(encoding.BinaryUnmarshaler); ok {
+		err = u.UnmarshalBinary(data)
+	}
+	end(span, err)
+	return err
+}
+
+// Err returns the first error of a remote filter since the last call, kept by t or by the
+// filter, and clears it. It is nil for other filters.
+func (t *TracedFilter) Err() error {
+	if err := t.errs.take(); err != nil {
+		return err
+	}
+	if r, ok := t.f.(remote); ok {
+		return r.Err()
+	}
+	return nil
+}
+
+// firstError keeps the first error of the calls without an error result until it is taken.
+type firstError struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (e *firstError) record(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *firstError) take() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := e.err
+	e.err = nil
+	return err
+}
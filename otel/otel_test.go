@@ -0,0 +1,200 @@
+package otel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newRecorder() (*tracetest.SpanRecorder, Option) {
+	rec := tracetest.NewSpanRecorder()
+	return rec, WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+}
+
+func names(rec *tracetest.SpanRecorder) []string {
+	var names []string
+	for _, s := range rec.Ended() {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+func attr(s sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracedFilter(t *testing.T) {
+	rec, tp := newRecorder()
+	f := Wrap(bloom.NewDefault(1e3, 1e-3), tp, WithAttributes(attribute.String("bloom.filter", "urls")))
+	f.Add([]byte("hello"))
+	if !f.Test([]byte("hello")) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if len(rec.Ended()) != 0 {
+		t.Fatalf("Calls of a local filter should not be traced but got %q", names(rec))
+	}
+
+	f.AddMany([][]byte{[]byte("a"), []byte("b")})
+	if got := f.TestMany([][]byte{[]byte("a"), []byte("c"), []byte("hello")}); !slices.Equal(got, []bool{true, false, true}) {
+		t.Fatalf("TestMany() = %v", got)
+	}
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	g := Wrap(&bloom.ClassicFilter{H: bloom.DefaultHash}, tp)
+	if _, err := g.ReadFrom(&buf); err != nil || !g.Test([]byte("b")) {
+		t.Fatal("ReadFrom should read the filter written, but got", err)
+	}
+	n, err := f.AddFromReader(strings.NewReader("x\ny\nz\n"), bufio.ScanLines)
+	if n != 3 || err != nil {
+		t.Fatal("AddFromReader() =", n, err)
+	}
+	f.Reset()
+
+	want := []string{"bloom.AddMany", "bloom.TestMany", "bloom.WriteTo", "bloom.ReadFrom", "bloom.AddFromReader", "bloom.Reset"}
+	if got := names(rec); !slices.Equal(got, want) {
+		t.Fatalf("Spans = %q, want %q", got, want)
+	}
+	spans := rec.Ended()
+	if got := attr(spans[0], "bloom.filter").AsString(); got != "urls" {
+		t.Errorf("Spans should have the attributes of the options but got %q", got)
+	}
+	if got := attr(spans[1], KeysKey).AsInt64(); got != 3 {
+		t.Errorf("%s = %d, want 3", KeysKey, got)
+	}
+	if got := attr(spans[1], PositivesKey).AsInt64(); got != 2 {
+		t.Errorf("%s = %d, want 2", PositivesKey, got)
+	}
+	if got := attr(spans[2], BytesKey).AsInt64(); got != int64(buf.Cap()-buf.Available()) {
+		t.Errorf("%s of WriteTo = %d, want the bytes written", BytesKey, got)
+	}
+	if got := attr(spans[4], KeysKey).AsInt64(); got != 3 {
+		t.Errorf("%s of AddFromReader = %d, want 3", KeysKey, got)
+	}
+}
+
+func TestTracedFilter_Context(t *testing.T) {
+	rec, tp := newRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "rebuild")
+	f := Wrap(bloom.NewDefault(1e3, 1e-3), tp)
+	f.WithContext(ctx).AddMany([][]byte{[]byte("a")})
+	f.AddMany([][]byte{[]byte("b")})
+	parent.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Spans = %q", names(rec))
+	}
+	if spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("The span of a call with a context should be a child of the span of the context")
+	}
+	if spans[1].Parent().IsValid() {
+		t.Error("The span of a call without a context should be a root")
+	}
+}
+
+func TestTracedFilter_Unsupported(t *testing.T) {
+	rec, tp := newRecorder()
+	f := Wrap(bloom.NewSafe(1e3, 1e-3, bloom.DefaultHash), tp)
+	if _, err := f.MarshalBinary(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("MarshalBinary of a filter without it = %v, want ErrUnsupported", err)
+	}
+	if s := rec.Ended()[0]; s.Status().Code != codes.Error || len(s.Events()) != 1 {
+		t.Error("The error should be recorded on the span")
+	}
+}
+
+func TestTracedFilter_Rotate(t *testing.T) {
+	rec, tp := newRecorder()
+	r := bloom.NewRotating(1e3, 1e-2, time.Hour, bloom.XXHash)
+	var notified int
+	r.OnRotate = func(time.Time) { notified++ }
+	Wrap(r, tp)
+	r.Rotate()
+	if got := names(rec); !slices.Equal(got, []string{"bloom.Rotate"}) {
+		t.Fatalf("Spans = %q", got)
+	}
+	if notified != 1 {
+		t.Fatal("OnRotate set before Wrap should still be called")
+	}
+}
+
+// fakeRemote is a remote filter failing its calls with err.
+type fakeRemote struct {
+	*bloom.ClassicFilter
+	err, kept error
+}
+
+func (f *fakeRemote) Insert(b []byte) error { f.ClassicFilter.Add(b); return f.err }
+
+func (f *fakeRemote) Lookup(b []byte) (bool, error) { return f.ClassicFilter.Test(b), f.err }
+
+func (f *fakeRemote) InsertAndLookup(b []byte) (bool, error) {
+	return f.ClassicFilter.TestAndAdd(b), f.err
+}
+
+func (f *fakeRemote) InsertMany(keys [][]byte) error {
+	f.ClassicFilter.AddMany(keys)
+	return f.err
+}
+
+func (f *fakeRemote) LookupMany(keys [][]byte) ([]bool, error) {
+	return f.ClassicFilter.TestMany(keys), f.err
+}
+
+func (f *fakeRemote) Reset() { f.ClassicFilter.Reset(); f.kept = f.err }
+
+func (f *fakeRemote) Err() error {
+	err := f.kept
+	f.kept = nil
+	return err
+}
+
+func TestTracedFilter_Remote(t *testing.T) {
+	rec, tp := newRecorder()
+	r := &fakeRemote{ClassicFilter: bloom.NewDefault(1e3, 1e-3).(*bloom.ClassicFilter)}
+	f := Wrap(r, tp)
+	f.Add([]byte("a"))
+	f.Test([]byte("a"))
+	f.TestAndAdd([]byte("b"))
+	if got := names(rec); !slices.Equal(got, []string{"bloom.Add", "bloom.Test", "bloom.TestAndAdd"}) {
+		t.Fatalf("Calls of a remote filter should be traced but got %q", got)
+	}
+	if f.Err() != nil {
+		t.Fatal("Err should be nil without errors")
+	}
+
+	r.err = errors.New("connection reset")
+	f.Add([]byte("c"))
+	f.Reset()
+	if err := f.Err(); err != r.err {
+		t.Fatalf("Err() = %v, want the error of the remote filter", err)
+	}
+	spans := rec.Ended()
+	for _, s := range spans[3:] {
+		if s.Status().Code != codes.Error {
+			t.Errorf("%s should record the error of the remote filter", s.Name())
+		}
+	}
+	if f.Err() != nil {
+		t.Fatal("Err should clear the error")
+	}
+}
@@ -0,0 +1,26 @@
+package bloom
+
+// getBits returns the i-th value of w bits packed into t, w <= 64.
+func getBits(t []uint64, i uint64, w uint) uint64 {
+	bit := i * uint64(w)
+	word, off := bit/64, bit%64
+	v := t[word] >> off
+	if off+uint64(w) > 64 {
+		v |= t[word+1] << (64 - off)
+	}
+	return v & (1<<w - 1)
+}
+
+// setBits sets the i-th value of w bits packed into t, w <= 64.
+func setBits(t []uint64, i uint64, w uint, v uint64) {
+	bit := i * uint64(w)
+	word, off := bit/64, bit%64
+	mask := uint64(1)<<w - 1
+	t[word] = t[word]&^(mask<<off) | v<<off
+	if off+uint64(w) > 64 {
+		t[word+1] = t[word+1]&^(mask>>(64-off)) | v>>(64-off)
+	}
+}
+
+// packedWords returns the number of words needed for n values of w bits.
+func packedWords(n uint64, w uint) int { return int((n*uint64(w) + 63) / 64) }
@@ -0,0 +1,56 @@
+package bloom
+
+// Partitioned Bloom Filter
+//
+// A partitioned filter divides its bits into K disjoint slices of S bits and
+// probe i of an entry only sets a bit in slice i, so every entry sets exactly
+// K bits. It has about the same false positive rate as a classic filter of
+// the same size, but fill ratio and union/intersection estimates are easier
+// to reason about.
+type PartitionedFilter struct {
+	B []byte // K slices of S bits
+	S uint64 // number of bits in a slice
+	K int
+	H func([]byte) (uint64, uint64)
+}
+
+// NewPartitioned creates a partitioned Bloom Filter that is optimal for n entries and false positive rate of p.
+// H is a double hash that takes an entry and returns two different hashes.
+func NewPartitioned(n int, p float64, h func([]byte) (uint64, uint64)) *PartitionedFilter {
+	m, k := optimal(n, p)
+	s := uint64(m / float64(int(k)))
+	return &PartitionedFilter{B: make([]byte, elements(float64(s)*k/8, 1)), S: s, K: int(k), H: h}
+}
+
+// getOffset returns the offset of the i-th probe in slice i.
+func (f *PartitionedFilter) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return uint64(i)*f.S + (x+uint64(i)*y)%f.S
+}
+
+func (f *PartitionedFilter) Add(b []byte) {
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		f.B[offset/8] |= 1 << (offset % 8)
+	}
+}
+
+func (f *PartitionedFilter) Test(b []byte) bool {
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		if f.B[offset/8]&(1<<(offset%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *PartitionedFilter) Size() int { return len(f.B) }
+
+func (f *PartitionedFilter) Reset() {
+	for i := range f.B {
+		f.B[i] = 0
+	}
+}
@@ -0,0 +1,53 @@
+package bloom
+
+import (
+	"fmt"
+	"math/bits"
+	"testing"
+)
+
+func TestPartitionedFilter_Test(t *testing.T) {
+	pf := NewPartitioned(1e6, 1e-4, doubleFNV)
+	buf := []byte("testing")
+	pf.Add(buf)
+	if !pf.Test(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if pf.Test([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
+
+func TestPartitionedFilter_Slices(t *testing.T) {
+	pf := NewPartitioned(1e3, 1e-3, doubleFNV)
+	pf.Add([]byte("testing"))
+	set := 0
+	for _, b := range pf.B {
+		set += bits.OnesCount8(b)
+	}
+	if set != pf.K {
+		t.Fatalf("A single entry should set exactly K = %d bits but set %d", pf.K, set)
+	}
+}
+
+func TestPartitionedFilter_FalsePositive(t *testing.T) {
+	const (
+		n         = 1e5
+		expectFPR = 1e-3
+	)
+	pf := NewPartitioned(n, expectFPR, doubleSHA)
+	for i := 0; i < n; i++ {
+		pf.Add([]byte(fmt.Sprint(i)))
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if pf.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	fpr := float64(fp) / n
+	t.Logf("Samples = %d, FP = %d, FPR = %.4f%%", int(n), fp, fpr*100)
+	if fpr > 2*expectFPR {
+		t.Fatalf("FPR %.4f%% is well above the target %.4f%%", fpr*100, expectFPR*100)
+	}
+}
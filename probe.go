@@ -0,0 +1,146 @@
+package bloom
+
+import "strconv"
+
+// Probe is a scheme for deriving the K bit offsets of an entry from its two hashes x and y.
+//
+// Offsets are computed with 64-bit integer arithmetic wrapping modulo 2^64, and the hashes
+// of this package read entries as little-endian words, so an entry has the same offsets in
+// a filter of the same hash, seed, scheme and geometry on 32-bit and 64-bit, little-endian
+// and big-endian platforms alike. They are also fixed across releases: a change to how
+// offsets are derived comes as a new Probe, recorded in encodings, never as a change to an
+// existing one.
+type Probe byte
+
+const (
+	// DoubleHashing probes x + i*y.
+	DoubleHashing Probe = iota
+	// EnhancedDoubleHashing probes x + i*y + (i³-i)/6, which keeps the probes of an entry
+	// apart even when y is a multiple of a large factor of the number of bits, making the
+	// false positive rate closer to theory at high K.
+	// See Dillinger and Manolios, "Bloom Filters in Probabilistic Verification" (2004).
+	EnhancedDoubleHashing
+	// ReseededDoubleHashing probes x' + i*y', where x' and y' are reseeded from x and y with
+	// SplitMix64 and y' is odd, so the probes are as good as those of two independent hashes
+	// even if the two hashes of the hash function are correlated.
+	// See Kirsch and Mitzenmacher, "Less Hashing, Same Performance: Building a Better Bloom Filter" (2006).
+	ReseededDoubleHashing
+	// IndependentHashing derives every probe as a separate hash of x and y, as if the filter
+	// had K independent hash functions. It is the slowest scheme, and the baseline the
+	// others approximate.
+	IndependentHashing
+)
+
+// flagEnhanced and flagProbeHigh hold the low and high bit of the probe scheme of an
+// encoded filter, so encodings of DoubleHashing and EnhancedDoubleHashing filters are
+// unchanged by the other schemes.
+const (
+	flagEnhanced  = 4
+	flagProbeHigh = 16
+
+	flagProbe = flagEnhanced | flagProbeHigh
+)
+
+// probe returns the i-th probe of an entry with hashes x and y, before reducing it to a bit offset.
+// It defines the schemes; the probe loops compute the same probes in order with seq.
+func (p Probe) probe(x, y uint64, i int) uint64 {
+	u := uint64(i)
+	switch p {
+	case EnhancedDoubleHashing:
+		return x + u*y + (u*u*u-u)/6
+	case ReseededDoubleHashing:
+		x = splitmix64(x)
+		return x + u*(splitmix64(y^x)|1)
+	case IndependentHashing:
+		return splitmix64(x ^ splitmix64(y+u))
+	}
+	return x + u*y
+}
+
+// probeSeq computes the probes of an entry in order, adding to the previous probe instead
+// of multiplying, with the same wraparound as probe. It has at most four fields, so the
+// compiler keeps it in registers.
+type probeSeq struct {
+	x, y uint64 // next value and step
+	d, e uint64 // growth of the step and of the growth
+}
+
+// seq returns the probes of an entry with hashes x and y, which are finish(x, s.next()).
+// The step of EnhancedDoubleHashing grows by i at probe i, which sums to the (i³-i)/6 of
+// probe, and the values of IndependentHashing are y, y+1, y+2, ... for finish to hash.
+func (p Probe) seq(x, y uint64) probeSeq {
+	switch p {
+	case EnhancedDoubleHashing:
+		return probeSeq{x: x, y: y, d: 1, e: 1}
+	case ReseededDoubleHashing:
+		x = splitmix64(x)
+		y = splitmix64(y^x) | 1
+	case IndependentHashing:
+		return probeSeq{x: y, y: 1}
+	}
+	return probeSeq{x: x, y: y}
+}
+
+// next returns the next value of the sequence.
+func (s *probeSeq) next() uint64 {
+	v := s.x
+	s.x, s.y, s.d = s.x+s.y, s.y+s.d, s.d+s.e
+	return v
+}
+
+// finish returns the probe of an entry with first hash x for the next value v of its
+// sequence. It is v except for IndependentHashing, and is kept out of next so that both
+// are inlined in the probe loops.
+func (p Probe) finish(x, v uint64) uint64 {
+	if p == IndependentHashing {
+		return independentProbe(x, v)
+	}
+	return v
+}
+
+// independentProbe hashes the value v of an IndependentHashing sequence. It is not inlined,
+// so the loops of the other schemes do not hold its constants.
+//
+//go:noinline
+func independentProbe(x, v uint64) uint64 { return splitmix64(x ^ splitmix64(v)) }
+
+// valid reports whether p is a known probe scheme.
+func (p Probe) valid() bool { return p <= IndependentHashing }
+
+// flags returns the header flags of the probe scheme.
+func (p Probe) flags() byte {
+	var flags byte
+	if p&1 != 0 {
+		flags |= flagEnhanced
+	}
+	if p&2 != 0 {
+		flags |= flagProbeHigh
+	}
+	return flags
+}
+
+// probeOf returns the probe scheme of a header.
+func probeOf(h header) Probe {
+	var p Probe
+	if h.Flags&flagEnhanced != 0 {
+		p |= 1
+	}
+	if h.Flags&flagProbeHigh != 0 {
+		p |= 2
+	}
+	return p
+}
+
+func (p Probe) String() string {
+	switch p {
+	case DoubleHashing:
+		return "double"
+	case EnhancedDoubleHashing:
+		return "enhanced"
+	case ReseededDoubleHashing:
+		return "reseeded"
+	case IndependentHashing:
+		return "independent"
+	}
+	return "Probe(" + strconv.Itoa(int(p)) + ")"
+}
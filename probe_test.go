@@ -0,0 +1,196 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"math"
+	"strconv"
+	"testing"
+)
+
+// fpRate returns the false positive rate of a filter of n entries over 1e5 absent entries.
+func fpRate(f Filter, n int) float64 {
+	for i := 0; i < n; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	fp := 0
+	for i := 0; i < 1e5; i++ {
+		if f.Test([]byte("absent" + strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	return float64(fp) / 1e5
+}
+
+func TestEnhancedDoubleHashing(t *testing.T) {
+	// a hash whose second half is a multiple of a quarter of the number of bits,
+	// so classic probes repeat after at most four bits
+	classic := newClassic(2e4, 1e-4, nil)
+	enhanced := newClassic(2e4, 1e-4, nil)
+	m := classic.BitCount()
+	weak := func(b []byte) (uint64, uint64) {
+		x, y := doubleSHA(b)
+		return x, m / 4 * (1 + y%3)
+	}
+	classic.H, enhanced.H = weak, weak
+	enhanced.Probe = EnhancedDoubleHashing
+	cr, er := fpRate(classic, 2e4), fpRate(enhanced, 2e4)
+	t.Logf("K = %d, classic %.2e, enhanced %.2e", classic.K, cr, er)
+	if er*5 > cr {
+		t.Fatalf("Enhanced double hashing rate %.2e should be far below classic %.2e", er, cr)
+	}
+
+	// with a good hash both schemes match theory
+	classic = newClassic(2e4, 1e-4, doubleSHA)
+	enhanced = newClassic(2e4, 1e-4, doubleSHA)
+	enhanced.Probe = EnhancedDoubleHashing
+	if cr, er := fpRate(classic, 2e4), fpRate(enhanced, 2e4); cr > 3e-4 || er > 3e-4 {
+		t.Fatalf("Rates %.2e and %.2e should be close to 1e-4", cr, er)
+	}
+}
+
+func TestEnhancedDoubleHashing_Encoding(t *testing.T) {
+	bf := newClassic(1e3, 1e-3, doubleSHA)
+	bf.Probe = EnhancedDoubleHashing
+	bf.Add([]byte("hello"))
+	data, _ := bf.MarshalBinary()
+	decoded := &ClassicFilter{H: doubleSHA}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	j, _ := json.Marshal(bf)
+	fromJSON := &ClassicFilter{H: doubleSHA}
+	if err := json.Unmarshal(j, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := ToProto(bf)
+	fromProto, err := FromProto(p, doubleSHA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*ClassicFilter{decoded, fromJSON, fromProto.(*ClassicFilter)} {
+		if f.Probe != EnhancedDoubleHashing || !bytes.Equal(f.B, bf.B) || !f.Test([]byte("hello")) {
+			t.Fatal("Decoded filter should keep the probe scheme")
+		}
+	}
+}
+
+func TestProbeSchemes(t *testing.T) {
+	m := 8 * uint64(len(newClassic(2e4, 1e-4, nil).B))
+	weak := func(b []byte) (uint64, uint64) {
+		x, y := doubleSHA(b)
+		return x, m / 4 * (1 + y%3)
+	}
+	for _, p := range []Probe{ReseededDoubleHashing, IndependentHashing} {
+		for _, h := range []func([]byte) (uint64, uint64){doubleSHA, weak} {
+			bf := newClassic(2e4, 1e-4, h)
+			bf.Probe = p
+			if r := fpRate(bf, 2e4); r > 3e-4 {
+				t.Fatalf("%v rate %.2e should be close to 1e-4", p, r)
+			}
+		}
+	}
+}
+
+func TestProbeSchemes_Encoding(t *testing.T) {
+	for p := DoubleHashing; p.valid(); p++ {
+		bf := newClassic(1e3, 1e-3, doubleSHA)
+		bf.Probe = p
+		bf.Add([]byte("hello"))
+		data, _ := bf.MarshalBinary()
+		decoded := &ClassicFilter{H: doubleSHA}
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		j, _ := json.Marshal(bf)
+		fromJSON := &ClassicFilter{H: doubleSHA}
+		if err := json.Unmarshal(j, fromJSON); err != nil {
+			t.Fatal(err)
+		}
+		pb, _ := ToProto(bf)
+		fromProto, err := FromProto(pb, doubleSHA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []*ClassicFilter{decoded, fromJSON, fromProto.(*ClassicFilter)} {
+			if f.Probe != p || !bytes.Equal(f.B, bf.B) || !f.Test([]byte("hello")) {
+				t.Fatalf("Decoded filter should keep the %v probe scheme", p)
+			}
+		}
+	}
+}
+
+func TestProbeSeq(t *testing.T) {
+	hashes := [][2]uint64{{0, 1}, {1, math.MaxUint64}, {0x9e3779b97f4a7c15, 0x632be59bd9b4e019}, {math.MaxUint64, 3}}
+	for p := DoubleHashing; p.valid(); p++ {
+		for _, h := range hashes {
+			x, y := h[0], h[1]
+			probes := p.seq(x, y)
+			for i := 0; i < 100; i++ {
+				if got, want := p.finish(x, probes.next()), p.probe(x, y, i); got != want {
+					t.Fatalf("%v probe %d of %#x, %#x should be %#x but is %#x", p, i, x, y, want, got)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkTest_Full(b *testing.B) {
+	for p := DoubleHashing; p.valid(); p++ {
+		bf := NewWithOptions(1e5, 1e-4, WithHash(XXHash), WithProbeScheme(p))
+		keys := make([][]byte, 1e5)
+		for i := range keys {
+			keys[i] = binary.AppendUvarint(nil, uint64(i))
+			bf.Add(keys[i])
+		}
+		b.Run(p.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bf.Test(keys[i%len(keys)])
+			}
+		})
+	}
+}
+
+// offsetsGolden holds the CRC-32 of the bit arrays of 1001-bit filters of the entries 0 to
+// 99, unseeded and with seed 42, for each built-in hash and probe scheme. Offsets must not
+// change between platforms or releases, as filters are shipped encoded; run the test with
+// GOARCH=386 and, under emulation, a big-endian GOARCH such as s390x to check the former.
+var offsetsGolden = map[string][4]uint32{
+	"default":        {0x34122469, 0xcc835e17, 0x6d553567, 0x5a340e8b},
+	"murmur3":        {0xebac2f94, 0x7c4115c4, 0x83ee0ea9, 0x66c3267b},
+	"murmur3-seeded": {0x93817b14, 0xaf2c9f37, 0xaa248815, 0x440a5166},
+	"xxhash":         {0xd0fae51e, 0x49fb666c, 0x8852c389, 0x44c333df},
+	"xxhash-seeded":  {0x15199c7e, 0x66d8ff81, 0x4bc83c22, 0x51387b1d},
+	"siphash":        {0x0013ab9d, 0xad371e0f, 0x7c67c3dc, 0x2380fa1f},
+}
+
+func TestOffsetsGolden(t *testing.T) {
+	hashes := map[string]func([]byte) (uint64, uint64){
+		"default":        DefaultHash,
+		"murmur3":        Murmur3Hash,
+		"murmur3-seeded": Murmur3Seeded(7),
+		"xxhash":         XXHash,
+		"xxhash-seeded":  XXHashSeeded(7, 11),
+		"siphash":        SipHash([16]byte{0: 1, 15: 2}),
+	}
+	for name, h := range hashes {
+		var got [4]uint32
+		for p := DoubleHashing; p.valid(); p++ {
+			crc := crc32.NewIEEE()
+			for _, seed := range []uint64{0, 42} {
+				bf := NewWithBits(1001, 7, h)
+				bf.Probe, bf.Seed = p, seed
+				for i := 0; i < 100; i++ {
+					bf.Add([]byte(strconv.Itoa(i)))
+				}
+				crc.Write(bf.B)
+			}
+			got[p] = crc.Sum32()
+		}
+		if got != offsetsGolden[name] {
+			t.Errorf("Offsets of %s hash changed: got %#08x, want %#08x", name, got, offsetsGolden[name])
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package prometheus exports metrics of filters of github.com/OperatorFoundation/go-bloom
+// to Prometheus, so operators get dashboards of their filters without wrapping every call.
+package prometheus
+
+import (
+	"time"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Opts names the metrics of an InstrumentedFilter.
+type Opts struct {
+	Namespace   string            // prefix of the metric names, "bloom" if empty
+	Subsystem   string            // middle part of the metric names, if not empty
+	ConstLabels prometheus.Labels // labels of every metric, such as the name of the filter
+	Buckets     []float64         // buckets of the latency histogram, 100ns to 26ms if nil
+}
+
+// InstrumentedFilter is a Filter that counts and times the calls to the filter it wraps.
+// It exports, with the Namespace of its Opts as prefix:
+//
+//	bloom_adds_total                     entries added, by Add or TestAndAdd
+//	bloom_tests_total                    entries tested, by Test or TestAndAdd
+//	bloom_test_positives_total           tests that found the entry, so the positive rate is
+//	                                     rate(bloom_test_positives_total) / rate(bloom_tests_total)
+//	bloom_operation_duration_seconds     latency histogram of the calls, by op: add, test,
+//	                                     test_and_add or reset
+//	bloom_fill_ratio                     fraction of bits set, if the filter has FillRatio
+//	bloom_rotations_total                rotations, if the filter is a RotatingFilter
+//
+// The fill ratio is read when metrics are collected, concurrently with the calls, so it is
+// exported only for a ClassicFilter wrapped in a bloom.SafeFilter, which is read under its
+// lock, or for a filter whose FillRatio is safe for concurrent use.
+type InstrumentedFilter struct {
+	f bloom.Filter
+
+	adds, tests, positives prometheus.Counter
+	add, test, testAndAdd  prometheus.Observer
+	reset                  prometheus.Observer
+}
+
+// Wrap returns f counting and timing its calls with metrics registered on reg. Metrics of
+// several filters on one registry must differ by their Opts, such as a filter label in
+// ConstLabels; registering the same metric twice returns the error of reg. The
+// rotations of a RotatingFilter are counted by chaining its OnRotate, which must be set
+// before.
+func Wrap(f bloom.Filter, reg prometheus.Registerer, opts Opts) (*InstrumentedFilter, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = "bloom"
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = prometheus.ExponentialBuckets(1e-7, 4, 10)
+	}
+	counter := func(name, help string) prometheus.Counter {
+		return prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: opts.Namespace, Subsystem: opts.Subsystem, Name: name, Help: help, ConstLabels: opts.ConstLabels,
+		})
+	}
+	i := &InstrumentedFilter{
+		f:         f,
+		adds:      counter("adds_total", "Number of entries added to the filter."),
+		tests:     counter("tests_total", "Number of entries tested against the filter."),
+		positives: counter("test_positives_total", "Number of tests that found the entry in the filter."),
+	}
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace, Subsystem: opts.Subsystem, Name: "operation_duration_seconds",
+		Help: "Latency of the calls to the filter.", ConstLabels: opts.ConstLabels, Buckets: opts.Buckets,
+	}, []string{"op"})
+	i.add, i.test = latency.WithLabelValues("add"), latency.WithLabelValues("test")
+	i.testAndAdd, i.reset = latency.WithLabelValues("test_and_add"), latency.WithLabelValues("reset")
+	collectors := []prometheus.Collector{i.adds, i.tests, i.positives, latency}
+
+	if fill := fillRatio(f); fill != nil {
+		collectors = append(collectors, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: opts.Namespace, Subsystem: opts.Subsystem, Name: "fill_ratio",
+			Help: "Fraction of the bits of the filter that are set.", ConstLabels: opts.ConstLabels,
+		}, fill))
+	}
+	var rotations prometheus.Counter
+	if _, ok := f.(*bloom.RotatingFilter); ok {
+		rotations = counter("rotations_total", "Number of rotations of the filter.")
+		collectors = append(collectors, rotations)
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			for _, registered := range collectors {
+				if registered == c {
+					break
+				}
+				reg.Unregister(registered)
+			}
+			return nil, err
+		}
+	}
+	if r, ok := f.(*bloom.RotatingFilter); ok {
+		onRotate := r.OnRotate
+		r.OnRotate = func(at time.Time) {
+			rotations.Inc()
+			if onRotate != nil {
+				onRotate(at)
+			}
+		}
+	}
+	return i, nil
+}
+
+// fillRatio returns a function reading the fill ratio of f safely while it is in use, or
+// nil if there is none.
+func fillRatio(f bloom.Filter) func() float64 {
+	if s, ok := f.(*bloom.SafeFilter); ok {
+		var fill bool
+		s.Do(func(f bloom.Filter) { _, fill = f.(interface{ FillRatio() float64 }) })
+		if !fill {
+			return nil
+		}
+		return func() (ratio float64) {
+			s.Do(func(f bloom.Filter) { ratio = f.(interface{ FillRatio() float64 }).FillRatio() })
+			return ratio
+		}
+	}
+	if _, ok := f.(*bloom.ClassicFilter); ok {
+		return nil // read without synchronization
+	}
+	if f, ok := f.(interface{ FillRatio() float64 }); ok {
+		return f.FillRatio
+	}
+	return nil
+}
+
+// Unwrap returns the wrapped filter.
+func (i *InstrumentedFilter) Unwrap() bloom.Filter { return i.f }
+
+func (i *InstrumentedFilter) Add(b []byte) {
+	start := time.Now()
+	i.f.Add(b)
+	i.add.Observe(time.Since(start).Seconds())
+	i.adds.Inc()
+}
+
+func (i *InstrumentedFilter) Test(b []byte) bool {
+	start := time.Now()
+	found := i.f.Test(b)
+	i.test.Observe(time.Since(start).Seconds())
+	i.count(found)
+	return found
+}
+
+// TestAndAdd adds an entry and reports whether it was already in the filter, with one call
+// if the wrapped filter is a bloom.TestAndAdder. It counts as an add and a test.
+func (i *InstrumentedFilter) TestAndAdd(b []byte) bool {
+	start := time.Now()
+	found := bloom.TestAndAdd(i.f, b)
+	i.testAndAdd.Observe(time.Since(start).Seconds())
+	i.adds.Inc()
+	i.count(found)
+	return found
+}
+
+func (i *InstrumentedFilter) Size() int { return i.f.Size() }
+
+func (i *InstrumentedFilter) Reset() {
+	start := time.Now()
+	i.f.Reset()
+	i.reset.Observe(time.Since(start).Seconds())
+}
+
+func (i *InstrumentedFilter) count(found bool) {
+	i.tests.Inc()
+	if found {
+		i.positives.Inc()
+	}
+}
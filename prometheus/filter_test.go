@@ -0,0 +1,68 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentedFilter(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	f, err := Wrap(bloom.NewSafe(1e3, 1e-2, bloom.XXHash), reg, Opts{ConstLabels: prometheus.Labels{"filter": "sessions"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Add([]byte("hello"))
+	f.Test([]byte("hello"))
+	f.Test([]byte("world"))
+	f.TestAndAdd([]byte("world"))
+
+	for _, c := range []struct {
+		counter prometheus.Counter
+		want    float64
+	}{{f.adds, 2}, {f.tests, 3}, {f.positives, 1}} {
+		if got := testutil.ToFloat64(c.counter); got != c.want {
+			t.Errorf("%v should be %v but got %v", c.counter.Desc(), c.want, got)
+		}
+	}
+	if n, err := testutil.GatherAndCount(reg, "bloom_operation_duration_seconds"); err != nil || n != 4 {
+		t.Fatal("The latency of each op should be exported but got", n, err)
+	}
+	if n, err := testutil.GatherAndCount(reg, "bloom_fill_ratio"); err != nil || n != 1 {
+		t.Fatal("The fill ratio of a safe classic filter should be exported but got", n, err)
+	}
+	if n, _ := testutil.GatherAndCount(reg, "bloom_rotations_total"); n != 0 {
+		t.Fatal("Rotations should be exported only for rotating filters")
+	}
+
+	// a second filter on the registry needs other labels
+	if _, err := Wrap(bloom.NewSafe(1e3, 1e-2, bloom.XXHash), reg, Opts{ConstLabels: prometheus.Labels{"filter": "sessions"}}); err == nil {
+		t.Fatal("Registering the same metrics twice should fail")
+	}
+	if _, err := Wrap(bloom.NewSafe(1e3, 1e-2, bloom.XXHash), reg, Opts{ConstLabels: prometheus.Labels{"filter": "users"}}); err != nil {
+		t.Fatal("A failed Wrap should unregister its metrics, but got", err)
+	}
+}
+
+func TestInstrumentedFilter_Rotations(t *testing.T) {
+	r := bloom.NewRotating(1e3, 1e-2, time.Hour, bloom.XXHash)
+	var notified int
+	r.OnRotate = func(time.Time) { notified++ }
+	reg := prometheus.NewRegistry()
+	if _, err := Wrap(r, reg, Opts{Namespace: "dedup"}); err != nil {
+		t.Fatal(err)
+	}
+	r.Rotate()
+	r.Rotate()
+	if notified != 2 {
+		t.Fatal("OnRotate should still be called")
+	}
+	want := "# HELP dedup_rotations_total Number of rotations of the filter.\n# TYPE dedup_rotations_total counter\ndedup_rotations_total 2\n"
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "dedup_rotations_total"); err != nil {
+		t.Fatal(err)
+	}
+}
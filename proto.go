@@ -0,0 +1,184 @@
+package bloom
+
+import (
+	"encoding/binary"
+)
+
+// values of the FilterType enum of bloom.proto
+const (
+	protoClassic    = 1
+	protoXor        = 2
+	protoSplitBlock = 3
+)
+
+// protocol buffer wire types
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+// protoFilter is the Filter message of bloom.proto.
+type protoFilter struct {
+	Type  uint64
+	K     uint64
+	M     uint64
+	Seed  uint64
+	Bits  []byte
+	Hash  string
+	Probe uint64
+}
+
+// ToProto encodes a ClassicFilter, XorFilter or SplitBlockFilter as the Filter message of
+// bloom.proto. The name of the hash function is included if it is known.
+func ToProto(f any) ([]byte, error) {
+	var p protoFilter
+	switch f := f.(type) {
+	case *ClassicFilter:
+		p = protoFilter{Type: protoClassic, K: uint64(f.K), M: f.BitCount(), Seed: f.Seed, Bits: f.B, Probe: uint64(f.Probe)}
+		p.Hash, _ = f.hashName()
+	case *XorFilter:
+		p = protoFilter{Type: protoXor, Seed: f.Seed, Bits: f.F}
+		p.Hash, _ = HashName(f.H)
+	case *SplitBlockFilter:
+		p = protoFilter{Type: protoSplitBlock, Bits: f.B}
+	default:
+		return nil, ErrUnsupportedType
+	}
+	if p.Type != protoClassic {
+		p.M = 8 * uint64(len(p.Bits))
+	}
+	b := appendVarintField(nil, 1, p.Type)
+	b = appendVarintField(b, 2, p.K)
+	b = appendVarintField(b, 3, p.M)
+	b = appendVarintField(b, 4, p.Seed)
+	b = appendBytesField(b, 5, p.Bits)
+	b = appendBytesField(b, 6, []byte(p.Hash))
+	return appendVarintField(b, 7, p.Probe), nil
+}
+
+// FromProto decodes a Filter message of bloom.proto into a *ClassicFilter, *XorFilter or
+// *SplitBlockFilter. A named hash function is looked up in the registry; otherwise h is
+// used, and it must be the hash function the filter was built with. Split block filters
+// need no hash function; for the others it returns ErrNilHash if h is nil and the message
+// names none.
+func FromProto(b []byte, h func([]byte) (uint64, uint64)) (any, error) {
+	p, err := parseProto(b)
+	if err != nil {
+		return nil, err
+	}
+	if p.Type == protoClassic && byteLen(p.M) != uint64(len(p.Bits)) || p.Type != protoClassic && p.M != 8*uint64(len(p.Bits)) {
+		return nil, ErrInvalidEncoding
+	}
+	if p.Hash != "" {
+		if named, ok := LookupHash(p.Hash); ok {
+			h = named
+		} else if h == nil {
+			return nil, ErrUnregisteredHash
+		}
+	}
+	bits := append([]byte(nil), p.Bits...)
+	switch p.Type {
+	case protoClassic:
+		if p.K == 0 || p.K > maxHashes || len(bits) == 0 || p.Probe > uint64(IndependentHashing) {
+			return nil, ErrInvalidEncoding
+		}
+		if h == nil {
+			return nil, ErrNilHash
+		}
+		return &ClassicFilter{B: bits, K: int(p.K), H: h, Probe: Probe(p.Probe), Seed: p.Seed, name: p.Hash, m: p.M}, nil
+	case protoXor:
+		if len(bits) == 0 || len(bits)%3 != 0 {
+			return nil, ErrInvalidEncoding
+		}
+		if h == nil {
+			return nil, ErrNilHash
+		}
+		return &XorFilter{F: bits, Seed: p.Seed, H: h}, nil
+	case protoSplitBlock:
+		f, err := NewSplitBlockFromBytes(bits)
+		if err != nil {
+			return nil, ErrInvalidEncoding
+		}
+		return f, nil
+	}
+	return nil, ErrUnsupportedType
+}
+
+// appendVarintField appends a varint field, omitting it if it is zero as proto3 does.
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendBytesField appends a length-delimited field, omitting it if it is empty.
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// parseProto parses a Filter message, skipping unknown fields.
+func parseProto(b []byte) (protoFilter, error) {
+	var p protoFilter
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return p, ErrInvalidEncoding
+		}
+		b = b[n:]
+		field, wire := tag>>3, tag&7
+		var v uint64
+		var data []byte
+		switch wire {
+		case wireVarint:
+			v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return p, ErrInvalidEncoding
+			}
+			b = b[n:]
+		case wireI64, wireI32:
+			size := 8
+			if wire == wireI32 {
+				size = 4
+			}
+			if len(b) < size {
+				return p, ErrInvalidEncoding
+			}
+			b = b[size:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return p, ErrInvalidEncoding
+			}
+			data, b = b[n:n+int(length)], b[n+int(length):]
+		default:
+			return p, ErrInvalidEncoding
+		}
+		// fields with another wire type than their own are unknown fields
+		switch {
+		case field == 1 && wire == wireVarint:
+			p.Type = v
+		case field == 2 && wire == wireVarint:
+			p.K = v
+		case field == 3 && wire == wireVarint:
+			p.M = v
+		case field == 4 && wire == wireVarint:
+			p.Seed = v
+		case field == 5 && wire == wireBytes:
+			p.Bits = data
+		case field == 6 && wire == wireBytes:
+			p.Hash = string(data)
+		case field == 7 && wire == wireVarint:
+			p.Probe = v
+		}
+	}
+	return p, nil
+}
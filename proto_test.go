@@ -0,0 +1,87 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestToProto(t *testing.T) {
+	bf := &ClassicFilter{B: []byte{0x01, 0x80}, K: 3, H: func([]byte) (uint64, uint64) { return 0, 0 }}
+	b, err := ToProto(bf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// type 1, k 3, m 16, bits 0180
+	if want := "080110031810" + "2a020180"; hex.EncodeToString(b) != want {
+		t.Fatalf("ToProto = %x, want %s", b, want)
+	}
+	if _, err := ToProto(NewCounting(10, 0.1, doubleFNV)); err != ErrUnsupportedType {
+		t.Fatalf("Unsupported type error = %v, want %v", err, ErrUnsupportedType)
+	}
+}
+
+func TestFromProto(t *testing.T) {
+	RegisterHash("sha256", doubleSHA)
+	bf := newClassic(1000, 0.01, doubleSHA)
+	bf.Add([]byte("hello"))
+	xf, _ := BuildXorFilter([][]byte{[]byte("hello")}, doubleSHA)
+	sf := NewSplitBlock(1000, 0.01)
+	sf.Add([]byte("hello"))
+
+	for _, f := range []any{bf, xf, sf} {
+		b, err := ToProto(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// unknown fields of every wire type are skipped
+		b = append(b, 0x78, 0x01, 0x41, 1, 2, 3, 4, 5, 6, 7, 8, 0x4a, 0x01, 0xff, 0x55, 1, 2, 3, 4)
+		g, err := FromProto(b, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !g.(interface{ Test([]byte) bool }).Test([]byte("hello")) {
+			t.Fatalf("%T: Should exist in decoded filter but got false", g)
+		}
+	}
+
+	b, _ := ToProto(bf)
+	for _, bad := range [][]byte{b[:len(b)-1], append(b, 0x07)} {
+		if _, err := FromProto(bad, doubleSHA); err != ErrInvalidEncoding {
+			t.Fatalf("Malformed message error = %v, want %v", err, ErrInvalidEncoding)
+		}
+	}
+	g, _ := FromProto(b, nil)
+	g.(*ClassicFilter).B[0] ^= 0xff
+	if !bytes.Equal(g.(*ClassicFilter).B[1:], bf.B[1:]) || g.(*ClassicFilter).B[0] == bf.B[0] {
+		t.Fatal("Decoded filter should not share memory with the message")
+	}
+}
+
+func TestFromProto_NilHash(t *testing.T) {
+	unnamed := func(b []byte) (uint64, uint64) { return doubleSHA(b) }
+	bf := newClassic(1000, 0.01, unnamed)
+	xf, _ := BuildXorFilter([][]byte{[]byte("hello")}, unnamed)
+	for _, f := range []any{bf, xf} {
+		b, err := ToProto(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := FromProto(b, nil); err != ErrNilHash {
+			t.Fatalf("%T: error without a hash function = %v, want %v", f, err, ErrNilHash)
+		}
+		if _, err := FromProto(b, unnamed); err != nil {
+			t.Fatalf("%T: %v", f, err)
+		}
+	}
+}
+
+func TestFromProto_EmptyXor(t *testing.T) {
+	b, err := ToProto(&XorFilter{Seed: 1, H: doubleSHA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromProto(b, doubleSHA); err != ErrInvalidEncoding {
+		t.Fatalf("Xor filter of no fingerprints error = %v, want %v", err, ErrInvalidEncoding)
+	}
+}
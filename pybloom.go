@@ -0,0 +1,412 @@
+package bloom
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"hash"
+	"io"
+	"math"
+)
+
+// pybloom compatible Bloom Filters
+//
+// A PyBloomFilter is a Bloom Filter that is bit for bit compatible with the
+// BloomFilter of the Python packages pybloom and pybloom-live: it is sized
+// like it, hashes entries with the same salted MD5 or SHA digests into one
+// slice of the bit array per hash, and reads and writes the files of its
+// tofile and fromfile, so filters built by Python jobs can be loaded by Go
+// services as they are. A PyScalableBloomFilter is likewise compatible with
+// ScalableBloomFilter. Python 3 hashes the UTF-8 of str keys, which are the
+// entries to add and test here; keys of other types are hashed as the UTF-8 of
+// their str(), b'…' for bytes.
+type PyBloomFilter struct {
+	B            []byte // the bit array, bit i at bit i%8 of B[i/8] like a little-endian bitarray
+	ErrorRate    float64
+	Slices       int // the number of hashes, each setting a bit of its slice of BitsPerSlice bits
+	BitsPerSlice uint64
+	Capacity     int
+	Count        int // entries added, not counting those that were in the filter already
+
+	newHash func() hash.Hash
+	salts   [][]byte // digests of the indexes of the salts, which data are appended to
+	chunk   int      // bytes of a digest per hash
+}
+
+// pyBloomHeaderSize is the size of the header of a file of pybloom: error rate, number of
+// slices, bits per slice, capacity and count.
+const pyBloomHeaderSize = 8 + 4*8
+
+// NewPyBloom creates an empty filter for capacity entries and a false positive rate of
+// errorRate, like BloomFilter(capacity, error_rate) of pybloom.
+func NewPyBloom(capacity int, errorRate float64) *PyBloomFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if !(errorRate > 0 && errorRate < 1) {
+		errorRate = 0.001
+	}
+	slices, bits := pyBloomGeometry(capacity, errorRate)
+	f := &PyBloomFilter{ErrorRate: errorRate, Slices: slices, BitsPerSlice: bits, Capacity: capacity}
+	f.B = make([]byte, f.byteLen())
+	f.setup()
+	return f
+}
+
+// pyBloomGeometry returns the number of slices and bits per slice pybloom gives a filter of
+// capacity entries and a false positive rate of errorRate.
+func pyBloomGeometry(capacity int, errorRate float64) (slices int, bits uint64) {
+	slices = int(math.Ceil(math.Log(1/errorRate) / math.Ln2)) // math.log(1/error_rate, 2)
+	bits = uint64(math.Ceil(float64(capacity) * math.Abs(math.Log(errorRate)) / (float64(slices) * math.Ln2 * math.Ln2)))
+	return slices, bits
+}
+
+func (f *PyBloomFilter) byteLen() uint64 { return (uint64(f.Slices)*f.BitsPerSlice + 7) / 8 }
+
+// setup picks the digest and salts of make_hashfuncs of pybloom: the smallest digest holding
+// a chunk per slice, of 2, 4 or 8 bytes as a slice has fewer than 2¹⁵, 2³¹ or more bits.
+func (f *PyBloomFilter) setup() {
+	switch {
+	case f.BitsPerSlice >= 1<<31:
+		f.chunk = 8
+	case f.BitsPerSlice >= 1<<15:
+		f.chunk = 4
+	default:
+		f.chunk = 2
+	}
+	var size int
+	switch bits := 8 * f.Slices * f.chunk; {
+	case bits > 384:
+		f.newHash, size = sha512.New, sha512.Size
+	case bits > 256:
+		f.newHash, size = sha512.New384, sha512.Size384
+	case bits > 160:
+		f.newHash, size = sha256.New, sha256.Size
+	case bits > 128:
+		f.newHash, size = sha1.New, sha1.Size
+	default:
+		f.newHash, size = md5.New, md5.Size
+	}
+	per := size / f.chunk
+	f.salts = make([][]byte, (f.Slices+per-1)/per)
+	for i := range f.salts {
+		h := f.newHash()
+		h.Write(binary.LittleEndian.AppendUint32(nil, uint32(i)))
+		f.salts[i] = h.Sum(nil)
+	}
+}
+
+// probe calls fn with the index of the bit of each slice of an entry, until fn returns false.
+func (f *PyBloomFilter) probe(b []byte, fn func(uint64) bool) {
+	h := f.newHash()
+	var digest []byte
+	var offset uint64
+	for i, slice := 0, 0; slice < f.Slices; i++ {
+		h.Reset()
+		h.Write(f.salts[i])
+		h.Write(b)
+		digest = h.Sum(digest[:0])
+		for c := 0; c+f.chunk <= len(digest) && slice < f.Slices; c, slice = c+f.chunk, slice+1 {
+			var v uint64
+			switch f.chunk {
+			case 2:
+				v = uint64(binary.LittleEndian.Uint16(digest[c:]))
+			case 4:
+				v = uint64(binary.LittleEndian.Uint32(digest[c:]))
+			default:
+				v = binary.LittleEndian.Uint64(digest[c:])
+			}
+			if !fn(offset + v%f.BitsPerSlice) {
+				return
+			}
+			offset += f.BitsPerSlice
+		}
+	}
+}
+
+// Add adds an entry. Unlike pybloom, which raises IndexError, it keeps adding entries to a
+// filter holding more than its capacity, at a growing false positive rate.
+func (f *PyBloomFilter) Add(b []byte) { f.TestAndAdd(b) }
+
+func (f *PyBloomFilter) Test(b []byte) bool {
+	found := true
+	f.probe(b, func(i uint64) bool {
+		found = f.B[i/8]&(1<<(i%8)) != 0
+		return found
+	})
+	return found
+}
+
+// TestAndAdd adds an entry and reports whether it was in the filter already, counting it
+// if it was not, like add of pybloom.
+func (f *PyBloomFilter) TestAndAdd(b []byte) bool {
+	found := true
+	f.probe(b, func(i uint64) bool {
+		found = found && f.B[i/8]&(1<<(i%8)) != 0
+		f.B[i/8] |= 1 << (i % 8)
+		return true
+	})
+	if !found {
+		f.Count++
+	}
+	return found
+}
+
+func (f *PyBloomFilter) Size() int { return len(f.B) }
+
+func (f *PyBloomFilter) Reset() {
+	clear(f.B)
+	f.Count = 0
+}
+
+// WriteTo writes the filter in the file format of tofile of pybloom: the error rate as a
+// little-endian float64, the number of slices, bits per slice, capacity and count as
+// little-endian uint64s, and the bit array.
+func (f *PyBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	b := make([]byte, 0, pyBloomHeaderSize+len(f.B))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f.ErrorRate))
+	b = binary.LittleEndian.AppendUint64(b, uint64(f.Slices))
+	b = binary.LittleEndian.AppendUint64(b, f.BitsPerSlice)
+	b = binary.LittleEndian.AppendUint64(b, uint64(f.Capacity))
+	b = binary.LittleEndian.AppendUint64(b, uint64(f.Count))
+	n, err := w.Write(append(b, f.B...))
+	return int64(n), err
+}
+
+// ReadFrom reads a filter in the file format of pybloom.
+func (f *PyBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	var hdr [pyBloomHeaderSize]byte
+	n, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return int64(n), noEOF(err)
+	}
+	g := PyBloomFilter{
+		ErrorRate:    math.Float64frombits(binary.LittleEndian.Uint64(hdr[:])),
+		BitsPerSlice: binary.LittleEndian.Uint64(hdr[16:]),
+	}
+	slices, capacity, count := binary.LittleEndian.Uint64(hdr[8:]), binary.LittleEndian.Uint64(hdr[24:]), binary.LittleEndian.Uint64(hdr[32:])
+	if slices == 0 || slices > 1<<10 || g.BitsPerSlice == 0 || g.BitsPerSlice > 1<<48 || capacity > math.MaxInt32 || count > math.MaxInt32 {
+		return int64(n), ErrInvalidEncoding
+	}
+	g.Slices, g.Capacity, g.Count = int(slices), int(capacity), int(count)
+	read := int64(n)
+	for size := g.byteLen(); uint64(len(g.B)) < size; {
+		chunk := min(size-uint64(len(g.B)), readChunk)
+		g.B = append(g.B, make([]byte, chunk)...)
+		c, err := io.ReadFull(r, g.B[uint64(len(g.B))-chunk:])
+		read += int64(c)
+		if err != nil {
+			return read, noEOF(err)
+		}
+	}
+	g.setup()
+	*f = g
+	return read, nil
+}
+
+// MarshalBinary encodes the filter in the file format of pybloom.
+func (f *PyBloomFilter) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := f.WriteTo(&buf)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary decodes a filter in the file format of pybloom.
+func (f *PyBloomFilter) UnmarshalBinary(b []byte) error {
+	r := bytes.NewReader(b)
+	if _, err := f.ReadFrom(r); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return ErrInvalidEncoding
+	}
+	return nil
+}
+
+// PyScalableBloomFilter is compatible with ScalableBloomFilter of pybloom: a series of
+// PyBloomFilters, each Scale times the capacity of the one before and Ratio times its error
+// rate, the last of which entries are added to.
+type PyScalableBloomFilter struct {
+	Scale           int
+	Ratio           float64
+	InitialCapacity int
+	ErrorRate       float64
+	Filters         []*PyBloomFilter
+}
+
+// pybloom's growth modes, the Scale of a ScalableBloomFilter
+const (
+	PySmallSetGrowth = 2
+	PyLargeSetGrowth = 4
+)
+
+// pyMaxScale and pyMaxFirstBits bound the growth and the first filter, of 1 GiB, of a
+// decoded scalable filter, so that a crafted file cannot make its first adds allocate
+// without bound. pybloom grows by 2 or 4, and its filter of a million entries at a rate of
+// one in a billion has 43 million bits.
+const (
+	pyMaxScale     = 64
+	pyMaxFirstBits = 1 << 33
+)
+
+// pyScalableHeaderSize is the size of the header of a file of a scalable filter of pybloom:
+// scale, ratio, initial capacity, error rate and number of filters.
+const pyScalableHeaderSize = 4 + 8 + 8 + 8 + 4
+
+// NewPyScalableBloom creates an empty filter like ScalableBloomFilter(initialCapacity,
+// errorRate, scale) of pybloom, with a ratio of 0.9.
+func NewPyScalableBloom(initialCapacity int, errorRate float64, scale int) *PyScalableBloomFilter {
+	if scale < 1 {
+		scale = PySmallSetGrowth
+	}
+	return &PyScalableBloomFilter{Scale: scale, Ratio: 0.9, InitialCapacity: initialCapacity, ErrorRate: errorRate}
+}
+
+func (f *PyScalableBloomFilter) Add(b []byte) { f.TestAndAdd(b) }
+
+func (f *PyScalableBloomFilter) Test(b []byte) bool {
+	for i := len(f.Filters) - 1; i >= 0; i-- {
+		if f.Filters[i].Test(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAndAdd adds an entry that is in none of the filters to the last one, after adding a
+// filter if it is full, and reports whether it was in one, like add of pybloom.
+func (f *PyScalableBloomFilter) TestAndAdd(b []byte) bool {
+	if f.Test(b) {
+		return true
+	}
+	if len(f.Filters) == 0 {
+		f.Filters = append(f.Filters, NewPyBloom(f.InitialCapacity, f.ErrorRate*(1-f.Ratio)))
+	} else if last := f.Filters[len(f.Filters)-1]; last.Count >= last.Capacity {
+		f.Filters = append(f.Filters, NewPyBloom(last.Capacity*f.Scale, last.ErrorRate*f.Ratio))
+	}
+	last := f.Filters[len(f.Filters)-1]
+	last.probe(b, func(i uint64) bool {
+		last.B[i/8] |= 1 << (i % 8)
+		return true
+	})
+	last.Count++
+	return false
+}
+
+func (f *PyScalableBloomFilter) Size() int {
+	n := 0
+	for _, g := range f.Filters {
+		n += g.Size()
+	}
+	return n
+}
+
+func (f *PyScalableBloomFilter) Reset() { f.Filters = nil }
+
+// Count returns the number of entries added.
+func (f *PyScalableBloomFilter) Count() int {
+	n := 0
+	for _, g := range f.Filters {
+		n += g.Count
+	}
+	return n
+}
+
+// WriteTo writes the filter in the file format of tofile of pybloom: the scale as a
+// little-endian int32, the ratio as a float64, the initial capacity as a uint64, the error
+// rate as a float64, the number of filters as an int32, the size of the file of each filter
+// as a uint64, and the files of the filters.
+func (f *PyScalableBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(f.Scale))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f.Ratio))
+	b = binary.LittleEndian.AppendUint64(b, uint64(f.InitialCapacity))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f.ErrorRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(f.Filters)))
+	for _, g := range f.Filters {
+		b = binary.LittleEndian.AppendUint64(b, uint64(pyBloomHeaderSize+len(g.B)))
+	}
+	n, err := w.Write(b)
+	written := int64(n)
+	for _, g := range f.Filters {
+		if err != nil {
+			break
+		}
+		var m int64
+		m, err = g.WriteTo(w)
+		written += m
+	}
+	return written, err
+}
+
+// ReadFrom reads a filter in the file format of a scalable filter of pybloom.
+func (f *PyScalableBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	var hdr [pyScalableHeaderSize]byte
+	n, err := io.ReadFull(r, hdr[:])
+	read := int64(n)
+	if err != nil {
+		return read, noEOF(err)
+	}
+	g := PyScalableBloomFilter{
+		Scale:           int(int32(binary.LittleEndian.Uint32(hdr[:]))),
+		Ratio:           math.Float64frombits(binary.LittleEndian.Uint64(hdr[4:])),
+		InitialCapacity: int(binary.LittleEndian.Uint64(hdr[12:]) & math.MaxInt32),
+		ErrorRate:       math.Float64frombits(binary.LittleEndian.Uint64(hdr[20:])),
+	}
+	count := int32(binary.LittleEndian.Uint32(hdr[28:]))
+	if count < 0 || count > 64 || !g.valid() {
+		return read, ErrInvalidEncoding
+	}
+	sizes := make([]byte, 8*int(count))
+	n, err = io.ReadFull(r, sizes)
+	read += int64(n)
+	if err != nil {
+		return read, noEOF(err)
+	}
+	for i := range int(count) {
+		var filter PyBloomFilter
+		m, err := filter.ReadFrom(r)
+		read += m
+		if err != nil {
+			return read, err
+		}
+		if uint64(m) != binary.LittleEndian.Uint64(sizes[8*i:]) {
+			return read, ErrInvalidEncoding
+		}
+		g.Filters = append(g.Filters, &filter)
+	}
+	*f = g
+	return read, nil
+}
+
+// valid reports whether the parameters of a decoded filter are ones pybloom makes filters
+// with, and its first filter is of at most pyMaxFirstBits.
+func (f *PyScalableBloomFilter) valid() bool {
+	if f.Scale < 1 || f.Scale > pyMaxScale || !(f.Ratio > 0 && f.Ratio < 1) ||
+		!(f.ErrorRate > 0 && f.ErrorRate < 1) || f.InitialCapacity < 1 {
+		return false
+	}
+	slices, bits := pyBloomGeometry(f.InitialCapacity, f.ErrorRate*(1-f.Ratio))
+	return float64(slices)*float64(bits) <= pyMaxFirstBits
+}
+
+// MarshalBinary encodes the filter in the file format of a scalable filter of pybloom.
+func (f *PyScalableBloomFilter) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := f.WriteTo(&buf)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary decodes a filter in the file format of a scalable filter of pybloom.
+func (f *PyScalableBloomFilter) UnmarshalBinary(b []byte) error {
+	r := bytes.NewReader(b)
+	if _, err := f.ReadFrom(r); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return ErrInvalidEncoding
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package bloom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"testing"
+)
+
+// Files of pybloom-live's tofile, computed with its make_hashfuncs and hashlib: of
+// BloomFilter(100, 0.01) after adding "hello", "world" and "hello", and of
+// ScalableBloomFilter(10, 0.01, SMALL_SET_GROWTH) after adding the numbers below 50, which
+// fill three filters. The file of BloomFilter(100000, 0.001), which hashes 4 bytes of SHA-256
+// per slice, is given by its SHA-256 after adding the numbers below 1000.
+const (
+	pyBloomFile     = "7b14ae47e17a843f0700000000000000890000000000000064000000000000000200000000000000001000000000000008000000000000000000000000800008000000000000000000000000000020000000002000000000000000000000000000000000000008000800000000000010020000000000000000000000000000000800000000004000000000000000000000010000000000000000001000000000"
+	pyBloomLargeSum = "889d88335563d16b06ae47414f1d4e6735d68fad184af94488468f8399f37403"
+	pyScalableFile  = "02000000cdccccccccccec3f0a000000000000007b14ae47e17a843f030000003b000000000000004e000000000000007300000000000000fba9f1d24d62503f0a000000000000000f000000000000000a000000000000000a00000000000000d728848b4613c62b65234fe8604d949d31321791cb7f48bf7d4d3f0b000000000000001b0000000000000014000000000000001400000000000000dfba6d61227ef55abe39e8c9a0ce7396e94ab1460680e13f33fda899fc89a7d0d8a2a1d74800cfd03fc1c58a4a3f0b00000000000000360000000000000028000000000000001400000000000000100180115d60110032b83e1003340ab2044f001c4080472a8141260e60ba408116903004224218aa0d730149d02a635a8ac281869482c4e08c041160182998060085020a8020d1881a5801"
+)
+
+func TestPyBloomFilter_Compatible(t *testing.T) {
+	f := NewPyBloom(100, 0.01)
+	f.Add([]byte("hello"))
+	f.Add([]byte("world"))
+	if !f.TestAndAdd([]byte("hello")) || f.Count != 2 {
+		t.Fatal("An entry added again should be found and not counted")
+	}
+	data, _ := f.MarshalBinary()
+	if got := hex.EncodeToString(data); got != pyBloomFile {
+		t.Fatalf("MarshalBinary = %s, want %s", got, pyBloomFile)
+	}
+
+	large := NewPyBloom(100000, 0.001)
+	for i := 0; i < 1000; i++ {
+		large.Add([]byte(strconv.Itoa(i)))
+	}
+	data, _ = large.MarshalBinary()
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != pyBloomLargeSum {
+		t.Fatalf("The file of a large filter should have SHA-256 %s, but got %x", pyBloomLargeSum, sum)
+	}
+}
+
+func TestPyBloomFilter_Import(t *testing.T) {
+	data, _ := hex.DecodeString(pyBloomFile)
+	var f PyBloomFilter
+	if err := f.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if f.Slices != 7 || f.BitsPerSlice != 137 || f.Capacity != 100 || f.Count != 2 || f.ErrorRate != 0.01 {
+		t.Fatalf("Decoded %d slices of %d bits, capacity %d, count %d and error rate %v", f.Slices, f.BitsPerSlice, f.Capacity, f.Count, f.ErrorRate)
+	}
+	if !f.Test([]byte("hello")) || !f.Test([]byte("world")) || f.Test([]byte("python")) {
+		t.Fatal("The imported filter should hold its entries only")
+	}
+	for _, bad := range [][]byte{data[:len(data)-1], append(data, 0), data[:pyBloomHeaderSize-1], make([]byte, pyBloomHeaderSize)} {
+		if err := f.UnmarshalBinary(bad); err == nil {
+			t.Fatalf("%x should fail to decode", bad)
+		}
+	}
+}
+
+func TestPyScalableBloomFilter(t *testing.T) {
+	f := NewPyScalableBloom(10, 0.01, PySmallSetGrowth)
+	for i := 0; i < 50; i++ {
+		if f.TestAndAdd([]byte(strconv.Itoa(i))) {
+			t.Fatal("A new entry should not be found, but", i, "was")
+		}
+	}
+	if len(f.Filters) != 3 || f.Count() != 50 {
+		t.Fatal("50 entries should fill 10 + 20 and part of 40, but got", len(f.Filters), "filters of", f.Count())
+	}
+	data, _ := f.MarshalBinary()
+	if got := hex.EncodeToString(data); got != pyScalableFile {
+		t.Fatalf("MarshalBinary = %s, want %s", got, pyScalableFile)
+	}
+
+	var g PyScalableBloomFilter
+	raw, _ := hex.DecodeString(pyScalableFile)
+	if err := g.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if !g.Test([]byte(strconv.Itoa(i))) {
+			t.Fatal("Should exist in imported filter but got false for", i)
+		}
+	}
+	if g.Scale != 2 || g.Ratio != 0.9 || g.InitialCapacity != 10 || g.Size() != f.Size() {
+		t.Fatal("The imported filter should have the parameters of the file")
+	}
+	if err := g.UnmarshalBinary(raw[:len(raw)-1]); err == nil {
+		t.Fatal("Truncated data should fail to decode")
+	}
+	g.Reset()
+	if g.Test([]byte("1")) || g.Count() != 0 {
+		t.Fatal("Reset should drop the filters")
+	}
+}
+
+func TestPyScalableBloomFilter_InvalidParameters(t *testing.T) {
+	for _, f := range []*PyScalableBloomFilter{
+		{Scale: 0, Ratio: 0.9, InitialCapacity: 10, ErrorRate: 0.01},
+		{Scale: 1 << 20, Ratio: 0.9, InitialCapacity: 10, ErrorRate: 0.01},
+		{Scale: 2, Ratio: 1, InitialCapacity: 10, ErrorRate: 0.01},
+		{Scale: 2, Ratio: math.NaN(), InitialCapacity: 10, ErrorRate: 0.01},
+		{Scale: 2, Ratio: 0.9, InitialCapacity: 10, ErrorRate: 0},
+		{Scale: 2, Ratio: 0.9, InitialCapacity: 0, ErrorRate: 0.01},
+		{Scale: 2, Ratio: 0.9, InitialCapacity: math.MaxInt32, ErrorRate: 1e-300},
+	} {
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := new(PyScalableBloomFilter).UnmarshalBinary(data); err != ErrInvalidEncoding {
+			t.Fatalf("Decoding %+v = %v, want %v", *f, err, ErrInvalidEncoding)
+		}
+	}
+}
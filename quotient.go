@@ -0,0 +1,278 @@
+package bloom
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrIncompatible is returned when two filters cannot be combined.
+var ErrIncompatible = errors.New("bloom: incompatible filters")
+
+// quotientMaxLoad is the load factor at which a quotient filter grows.
+const quotientMaxLoad = 0.75
+
+// Quotient Filter
+//
+// A quotient filter stores a p = Q+R bit fingerprint of each entry in a
+// compact open addressing table of 2^Q slots: the top Q bits select the
+// canonical slot and the low R bits are stored in it, together with three
+// metadata bits. Entries of the same quotient are kept in sorted runs of
+// consecutive slots, which makes lookups cache friendly. Since the full
+// fingerprint can be recovered from the table, a quotient filter can be
+// resized in place and merged with another one without the original entries.
+// The false positive rate is about LoadFactor/2^R.
+// See Bender et al., "Don't Thrash: How to Cache Your Hash on Flash" (2012).
+type QuotientFilter struct {
+	T []uint64 // packed slots of R+3 bits each
+	Q uint     // quotient bits, the table has 2^Q slots
+	R uint     // remainder bits
+	H func([]byte) (uint64, uint64)
+
+	count int
+}
+
+// NewQuotient creates a quotient filter that holds n entries with a false positive rate of p
+// before it has to grow.
+func NewQuotient(n int, p float64, h func([]byte) (uint64, uint64)) *QuotientFilter {
+	// at least one bit each, for no entries and rates of one half or more
+	q := uint(max(math.Ceil(math.Log2(float64(max(n, 1))/quotientMaxLoad)), 1))
+	r := uint(max(math.Ceil(-math.Log2(checkRate(p))), 1))
+	if q+r > 64 || r > 61 {
+		panic("bloom: quotient filter fingerprint is wider than 64 bits")
+	}
+	return newQuotient(q, r, h)
+}
+
+func newQuotient(q, r uint, h func([]byte) (uint64, uint64)) *QuotientFilter {
+	return &QuotientFilter{T: make([]uint64, packedWords(1<<q, r+3)), Q: q, R: r, H: h}
+}
+
+// slot metadata bits
+const (
+	qfOccupied     = 1 << 0 // some entry has this slot as its canonical slot
+	qfContinuation = 1 << 1 // the entry continues the run of the previous slot
+	qfShifted      = 1 << 2 // the entry is not in its canonical slot
+)
+
+func (f *QuotientFilter) size() uint64         { return 1 << f.Q }
+func (f *QuotientFilter) incr(i uint64) uint64 { return (i + 1) & (f.size() - 1) }
+func (f *QuotientFilter) decr(i uint64) uint64 { return (i - 1) & (f.size() - 1) }
+
+func (f *QuotientFilter) get(i uint64) uint64 { return getBits(f.T, i, f.R+3) }
+func (f *QuotientFilter) set(i, v uint64)     { setBits(f.T, i, f.R+3, v) }
+
+func (f *QuotientFilter) isEmpty(i uint64) bool { return f.get(i)&7 == 0 }
+
+// fingerprint returns the Q+R bit fingerprint of an entry.
+func (f *QuotientFilter) fingerprint(b []byte) uint64 {
+	x, _ := f.H(b)
+	return x & (1<<(f.Q+f.R) - 1)
+}
+
+func (f *QuotientFilter) split(fp uint64) (fq, fr uint64) {
+	return fp >> f.R, fp & (1<<f.R - 1)
+}
+
+// runStart returns the slot of the first entry of the run of quotient fq.
+func (f *QuotientFilter) runStart(fq uint64) uint64 {
+	b := fq
+	for f.get(b)&qfShifted != 0 {
+		b = f.decr(b)
+	}
+	s := b
+	for b != fq {
+		for s = f.incr(s); f.get(s)&qfContinuation != 0; s = f.incr(s) {
+		}
+		for b = f.incr(b); f.get(b)&qfOccupied == 0; b = f.incr(b) {
+		}
+	}
+	return s
+}
+
+// shiftInto stores entry at slot s and shifts the following entries right up to the next empty slot.
+// The occupied bit belongs to the slot rather than the entry and stays in place.
+func (f *QuotientFilter) shiftInto(s, entry uint64) {
+	for {
+		prev := f.get(s)
+		empty := prev&7 == 0
+		if !empty {
+			prev |= qfShifted
+			if prev&qfOccupied != 0 {
+				entry |= qfOccupied
+				prev &^= qfOccupied
+			}
+		}
+		f.set(s, entry)
+		if empty {
+			return
+		}
+		entry = prev
+		s = f.incr(s)
+	}
+}
+
+// insert adds a fingerprint to the table and reports whether it was not there yet.
+func (f *QuotientFilter) insert(fp uint64) bool {
+	fq, fr := f.split(fp)
+	canonical := f.get(fq)
+	entry := fr << 3
+	if canonical&7 == 0 {
+		f.set(fq, entry|qfOccupied)
+		f.count++
+		return true
+	}
+	if canonical&qfOccupied == 0 {
+		f.set(fq, canonical|qfOccupied)
+	}
+	start := f.runStart(fq)
+	s := start
+	if canonical&qfOccupied != 0 {
+		// the run exists, find the sorted position of the remainder in it
+		for {
+			rem := f.get(s) >> 3
+			if rem == fr {
+				return false
+			} else if rem > fr {
+				break
+			}
+			if s = f.incr(s); f.get(s)&qfContinuation == 0 {
+				break
+			}
+		}
+		if s == start {
+			// the old head of the run becomes its continuation
+			f.set(start, f.get(start)|qfContinuation)
+		} else {
+			entry |= qfContinuation
+		}
+	}
+	if s != fq {
+		entry |= qfShifted
+	}
+	f.shiftInto(s, entry)
+	f.count++
+	return true
+}
+
+// each calls fn with every fingerprint stored in the table.
+func (f *QuotientFilter) each(fn func(fp uint64)) {
+	if f.count == 0 {
+		return
+	}
+	// start right after an empty slot, which always begins a cluster
+	start := uint64(0)
+	for !f.isEmpty(start) {
+		start = f.incr(start)
+	}
+	i := start
+	var fq uint64
+	for {
+		i = f.incr(i)
+		if i == start {
+			return
+		}
+		v := f.get(i)
+		if v&7 == 0 {
+			continue
+		}
+		if v&qfShifted == 0 {
+			fq = i // start of a cluster
+		} else if v&qfContinuation == 0 {
+			for fq = f.incr(fq); f.get(fq)&qfOccupied == 0; fq = f.incr(fq) {
+			}
+		}
+		fn(fq<<f.R | v>>3)
+	}
+}
+
+// Grow doubles the number of slots by moving one bit from the remainder to the
+// quotient of every fingerprint, which doubles the false positive rate at
+// the same number of entries. It returns ErrFull if the remainder is down to a single bit.
+func (f *QuotientFilter) Grow() error {
+	if f.R <= 1 {
+		return ErrFull
+	}
+	g := newQuotient(f.Q+1, f.R-1, f.H)
+	f.each(func(fp uint64) { g.insert(fp) })
+	*f = *g
+	return nil
+}
+
+func (f *QuotientFilter) reserve(n int) error {
+	for float64(f.count+n) > quotientMaxLoad*float64(f.size()) {
+		if err := f.Grow(); err != nil {
+			if f.count+n < int(f.size()) {
+				return nil // keep at least one empty slot
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert adds an entry to the filter, growing it when it is more than 3/4 full.
+// It returns ErrFull if the filter can neither grow nor hold another entry.
+func (f *QuotientFilter) Insert(b []byte) error {
+	if err := f.reserve(1); err != nil {
+		return err
+	}
+	f.insert(f.fingerprint(b))
+	return nil
+}
+
+// Add adds an entry to the filter. Use Insert to find out whether the entry fit.
+func (f *QuotientFilter) Add(b []byte) { _ = f.Insert(b) }
+
+func (f *QuotientFilter) Test(b []byte) bool {
+	fq, fr := f.split(f.fingerprint(b))
+	if f.get(fq)&qfOccupied == 0 {
+		return false
+	}
+	s := f.runStart(fq)
+	for {
+		rem := f.get(s) >> 3
+		if rem == fr {
+			return true
+		} else if rem > fr {
+			return false
+		}
+		if s = f.incr(s); f.get(s)&qfContinuation == 0 {
+			return false
+		}
+	}
+}
+
+// Merge adds all entries of other to f. Both filters must have the same fingerprint size
+// Q+R, or else ErrIncompatibleSize is returned, and the same hash function, or else
+// ErrIncompatibleHash is returned, and f grows as needed.
+func (f *QuotientFilter) Merge(other *QuotientFilter) error {
+	if f.Q+f.R != other.Q+other.R {
+		emit(Event{Kind: EventMergeFail, Filter: f, Err: ErrIncompatibleSize})
+		return ErrIncompatibleSize
+	}
+	if funcID(f.H) != funcID(other.H) {
+		emit(Event{Kind: EventMergeFail, Filter: f, Err: ErrIncompatibleHash})
+		return ErrIncompatibleHash
+	}
+	if err := f.reserve(other.count); err != nil {
+		return err
+	}
+	// other may have a different split of the same fingerprint
+	other.each(func(fp uint64) { f.insert(fp) })
+	return nil
+}
+
+// Count returns the number of distinct fingerprints in the filter.
+func (f *QuotientFilter) Count() int { return f.count }
+
+// LoadFactor returns the fraction of occupied slots.
+func (f *QuotientFilter) LoadFactor() float64 { return float64(f.count) / float64(f.size()) }
+
+func (f *QuotientFilter) Size() int { return 8 * len(f.T) }
+
+func (f *QuotientFilter) Reset() {
+	for i := range f.T {
+		f.T[i] = 0
+	}
+	f.count = 0
+}
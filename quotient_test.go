@@ -0,0 +1,88 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestQuotientFilter_Test(t *testing.T) {
+	qf := NewQuotient(1e4, 1e-4, doubleFNV)
+	buf := []byte("testing")
+	qf.Add(buf)
+	if !qf.Test(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if qf.Test([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
+
+func TestQuotientFilter_Grow(t *testing.T) {
+	const n = 1e5
+	qf := NewQuotient(1e3, 1e-6, doubleSHA)
+	q := qf.Q
+	for i := 0; i < n; i++ {
+		qf.Add([]byte(fmt.Sprint(i)))
+	}
+	if qf.Q <= q {
+		t.Fatal("Filter should have grown")
+	}
+	if lf := qf.LoadFactor(); lf > quotientMaxLoad {
+		t.Fatalf("Load factor %.2f is above the maximum", lf)
+	}
+	for i := 0; i < n; i++ {
+		if !qf.Test([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if qf.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	t.Logf("Q = %d, R = %d, load = %.2f, FP = %d", qf.Q, qf.R, qf.LoadFactor(), fp)
+}
+
+func TestQuotientFilter_Fingerprints(t *testing.T) {
+	qf := newQuotient(6, 4, doubleFNV)
+	var want []uint64
+	for i := 0; i < 40; i++ {
+		fp := qf.fingerprint([]byte(fmt.Sprint(i)))
+		if qf.insert(fp) {
+			want = append(want, fp)
+		}
+	}
+	var got []uint64
+	qf.each(func(fp uint64) { got = append(got, fp) })
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Fingerprints = %v, want %v", got, want)
+	}
+}
+
+func TestQuotientFilter_Merge(t *testing.T) {
+	a := NewQuotient(1e3, 1e-4, doubleSHA)
+	b := newQuotient(a.Q+3, a.R-3, doubleSHA) // same fingerprint size, different split
+	for i := 0; i < 1e3; i++ {
+		a.Add([]byte(fmt.Sprint("a", i)))
+		b.Add([]byte(fmt.Sprint("b", i)))
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1e3; i++ {
+		if !a.Test([]byte(fmt.Sprint("a", i))) || !a.Test([]byte(fmt.Sprint("b", i))) {
+			t.Fatalf("%d should exist in merged filter but got false", i)
+		}
+	}
+	if err := a.Merge(NewQuotient(1e3, 1e-2, doubleSHA)); !errors.Is(err, ErrIncompatibleSize) {
+		t.Fatalf("Merge of a different fingerprint size should fail but got %v", err)
+	}
+	if err := a.Merge(NewQuotient(1e3, 1e-4, doubleFNV)); err != ErrIncompatibleHash {
+		t.Fatalf("Merge of a different hash should fail but got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package bloom
+
+// raceEnabled is whether tests run with the race detector, under which sync.Pool drops
+// some buffers and so allocates.
+const raceEnabled = true
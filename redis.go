@@ -0,0 +1,278 @@
+package bloom
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// ErrRedisReply is returned by a RedisFilter when Redis sends a reply it does not expect.
+var ErrRedisReply = errors.New("bloom: unexpected reply from redis")
+
+// Redis Bloom Filter
+//
+// A Redis filter is a classic filter whose bit array is a string value in Redis, set and
+// tested with BITFIELD, so several processes sharing one Redis share one filter. Offsets
+// are computed locally from the hash, as in a ClassicFilter of the same geometry, and an
+// entry takes one round trip; AddMany, TestMany and their error-returning counterparts
+// pipeline a batch of entries in one. The value holds the bytes of the bit array of that
+// ClassicFilter, with bit offset i at the SETBIT offset that puts it in the same byte and bit.
+//
+// The filter speaks RESP2 over a connection the caller sets up, authenticates and closes,
+// and serializes the commands of its goroutines. Add, Test and the other methods of Filter
+// have no error result, so they record the first error for Err, and Test reports false
+// when it fails; Insert, Lookup and their batch forms return it.
+type RedisFilter struct {
+	K int
+	H func([]byte) (uint64, uint64)
+
+	key  string
+	m    uint64 // number of bits
+	mu   sync.Mutex
+	rw   *bufio.ReadWriter
+	errs firstError
+}
+
+// NewRedis creates a filter that is optimal for n entries and false positive rate of p in
+// the Redis key key, reached over conn. Every process sharing the key must create its
+// filter with the same n, p and hash. It panics with ErrTooLarge if the filter needs more
+// than the 2^32 bits a Redis string holds.
+func NewRedis(conn io.ReadWriter, key string, n int, p float64, h func([]byte) (uint64, uint64)) *RedisFilter {
+	m, k := optimal(n, p)
+	if m > 1<<32 {
+		panic(ErrTooLarge)
+	}
+	return &RedisFilter{
+		K:   max(int(k), 1),
+		H:   h,
+		key: key,
+		m:   uint64(m),
+		rw:  bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)),
+	}
+}
+
+// Key returns the Redis key of the bit array.
+func (f *RedisFilter) Key() string { return f.key }
+
+// BitCount returns the number of bits of the filter.
+func (f *RedisFilter) BitCount() uint64 { return f.m }
+
+// Insert adds an entry to the filter.
+func (f *RedisFilter) Insert(b []byte) error { return f.InsertMany([][]byte{b}) }
+
+// Lookup tests if an entry is in the filter.
+func (f *RedisFilter) Lookup(b []byte) (bool, error) {
+	found, err := f.LookupMany([][]byte{b})
+	if err != nil {
+		return false, err
+	}
+	return found[0], nil
+}
+
+// InsertMany adds entries to the filter with one round trip.
+func (f *RedisFilter) InsertMany(keys [][]byte) error {
+	_, err := f.bitfields(keys, true)
+	return err
+}
+
+// LookupMany tests if entries are in the filter with one round trip.
+func (f *RedisFilter) LookupMany(keys [][]byte) ([]bool, error) { return f.bitfields(keys, false) }
+
+// InsertAndLookup adds an entry to the filter and reports whether it was already in it,
+// with one command, as TestAndAdd does for a ClassicFilter.
+func (f *RedisFilter) InsertAndLookup(b []byte) (bool, error) {
+	found, err := f.bitfields([][]byte{b}, true)
+	if err != nil {
+		return false, err
+	}
+	return found[0], nil
+}
+
+func (f *RedisFilter) Add(b []byte) { f.errs.record(f.Insert(b)) }
+
+func (f *RedisFilter) Test(b []byte) bool {
+	found, err := f.Lookup(b)
+	f.errs.record(err)
+	return found
+}
+
+// TestAndAdd is InsertAndLookup recording its error for Err.
+func (f *RedisFilter) TestAndAdd(b []byte) bool {
+	found, err := f.InsertAndLookup(b)
+	f.errs.record(err)
+	return found
+}
+
+// AddMany is InsertMany recording its error for Err.
+func (f *RedisFilter) AddMany(keys [][]byte) { f.errs.record(f.InsertMany(keys)) }
+
+// TestMany is LookupMany recording its error for Err. An entry that could not be tested is
+// reported absent.
+func (f *RedisFilter) TestMany(keys [][]byte) []bool {
+	found, err := f.LookupMany(keys)
+	f.errs.record(err)
+	if found == nil {
+		found = make([]bool, len(keys))
+	}
+	return found
+}
+
+func (f *RedisFilter) Size() int { return int(byteLen(f.m)) }
+
+// Reset deletes the key, clearing the filter for every process sharing it.
+func (f *RedisFilter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err := f.do([][]string{{"DEL", f.key}})
+	f.errs.record(err)
+}
+
+// Err returns the first error of the methods without an error result since it was last
+// called, and clears it.
+func (f *RedisFilter) Err() error { return f.errs.take() }
+
+// Classic fetches the bit array and returns it as a ClassicFilter of the same entries.
+func (f *RedisFilter) Classic() (*ClassicFilter, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	replies, err := f.do([][]string{{"GET", f.key}})
+	if err != nil {
+		return nil, err
+	}
+	value, ok := replies[0].([]byte)
+	if !ok && replies[0] != nil || uint64(len(value)) > byteLen(f.m) {
+		return nil, ErrRedisReply
+	}
+	c := &ClassicFilter{B: make([]byte, byteLen(f.m)), K: f.K, H: f.H, m: f.m}
+	copy(c.B, value) // Redis leaves out the zero bytes past the last bit set
+	return c, nil
+}
+
+// bitfields sends one BITFIELD command per key, setting its bits if set or getting them
+// otherwise, and reports whether all bits of each key were set before the command.
+func (f *RedisFilter) bitfields(keys [][]byte, set bool) ([]bool, error) {
+	cmds := make([][]string, len(keys))
+	for i, b := range keys {
+		x, y := f.H(b)
+		probes := DoubleHashing.seq(x, nonzero(x, y))
+		cmd := make([]string, 2, 2+4*f.K)
+		cmd[0], cmd[1] = "BITFIELD", f.key
+		for j := 0; j < f.K; j++ {
+			offset := strconv.FormatUint(redisBit(reduceOffset(probes.next(), f.m)), 10)
+			if set {
+				cmd = append(cmd, "SET", "u1", offset, "1")
+			} else {
+				cmd = append(cmd, "GET", "u1", offset)
+			}
+		}
+		cmds[i] = cmd
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	replies, err := f.do(cmds)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(keys))
+	for i, r := range replies {
+		values, ok := r.([]any)
+		if !ok || len(values) != f.K {
+			return nil, ErrRedisReply
+		}
+		found[i] = true
+		for _, v := range values {
+			found[i] = found[i] && v == int64(1)
+		}
+	}
+	return found, nil
+}
+
+// redisBit returns the SETBIT offset of bit offset of a classic filter, which is bit
+// offset%8 of byte offset/8 counting from the least significant bit, while SETBIT counts
+// from the most significant.
+func redisBit(offset uint64) uint64 { return offset&^7 + 7 - offset&7 }
+
+// do writes cmds in one pipeline and reads their replies. It returns the first error reply
+// after reading all of them, so the connection stays in step. f.mu must be held.
+func (f *RedisFilter) do(cmds [][]string) ([]any, error) {
+	for _, cmd := range cmds {
+		fmt.Fprintf(f.rw, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(f.rw, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := f.rw.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]any, len(cmds))
+	var replyErr error
+	for i := range replies {
+		r, err := readRESP(f.rw.Reader)
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := r.(redisError); ok && replyErr == nil {
+			replyErr = e
+		}
+		replies[i] = r
+	}
+	return replies, replyErr
+}
+
+// redisError is an error reply of Redis.
+type redisError string
+
+func (e redisError) Error() string { return "bloom: redis: " + string(e) }
+
+// readRESP reads a RESP2 reply: a string for a simple string, a redisError, an int64, a
+// []byte or nil for a bulk string, or an []any or nil for an array.
+func readRESP(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, ErrRedisReply
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return redisError(line), nil
+	}
+	n, err := strconv.ParseInt(line, 10, 64)
+	if err != nil {
+		return nil, ErrRedisReply
+	}
+	switch kind {
+	case ':':
+		return n, nil
+	case '$':
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, noEOF(err)
+		}
+		return b[:n], nil
+	case '*':
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]any, 0, min(n, 1024))
+		for ; n > 0; n-- {
+			v, err := readRESP(r)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	return nil, ErrRedisReply
+}
@@ -0,0 +1,149 @@
+package bloom
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeRedis serves the commands a RedisFilter sends on conn from an in-memory keyspace,
+// with bits numbered as SETBIT numbers them. BITFIELD of the key "wrongtype" fails.
+// Replies are queued and written by another goroutine, as Redis buffers them, so a
+// client can write a whole pipeline before reading.
+func fakeRedis(conn net.Conn) {
+	defer conn.Close()
+	queue := make(chan []byte, 1<<12)
+	defer close(queue)
+	go func() {
+		for reply := range queue {
+			conn.Write(reply)
+		}
+	}()
+	r := bufio.NewReader(conn)
+	values := make(map[string][]byte)
+	for {
+		w := new(bytes.Buffer)
+		cmd, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range cmd.([]any) {
+			args = append(args, string(arg.([]byte)))
+		}
+		switch v := values[args[1]]; {
+		case args[0] == "GET" && v == nil:
+			w.WriteString("$-1\r\n")
+		case args[0] == "GET":
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+		case args[0] == "DEL":
+			delete(values, args[1])
+			w.WriteString(":1\r\n")
+		case args[0] == "BITFIELD" && args[1] == "wrongtype":
+			w.WriteString("-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+		case args[0] == "BITFIELD":
+			var replies []string
+			for ops := args[2:]; len(ops) > 0; {
+				bit, _ := strconv.ParseUint(ops[2], 10, 64)
+				for uint64(len(v)) <= bit/8 {
+					v = append(v, 0)
+				}
+				mask := byte(0x80) >> (bit % 8)
+				replies = append(replies, strconv.Itoa(min(int(v[bit/8]&mask), 1)))
+				if ops[0] == "SET" {
+					v[bit/8] |= mask
+					ops = ops[4:]
+				} else {
+					ops = ops[3:]
+				}
+			}
+			values[args[1]] = v
+			fmt.Fprintf(w, "*%d\r\n:%s\r\n", len(replies), strings.Join(replies, "\r\n:"))
+		}
+		queue <- w.Bytes()
+	}
+}
+
+// newFakeRedis returns a filter of key served by fakeRedis.
+func newFakeRedis(t *testing.T, key string) *RedisFilter {
+	client, server := net.Pipe()
+	go fakeRedis(server)
+	t.Cleanup(func() { client.Close() })
+	return NewRedis(client, key, 1e3, 1e-2, doubleFNV)
+}
+
+func TestRedisFilter(t *testing.T) {
+	f := newFakeRedis(t, "filter")
+	f.Add([]byte("hello"))
+	if !f.Test([]byte("hello")) || f.Test([]byte("world")) {
+		t.Fatal("Only the added entry should be in the filter")
+	}
+	if f.TestAndAdd([]byte("world")) || !f.TestAndAdd([]byte("world")) {
+		t.Fatal("TestAndAdd should report an entry present only once added")
+	}
+
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	f.AddMany(keys)
+	for i, found := range f.TestMany(keys) {
+		if !found {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+	if err := f.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the value holds the bits of a classic filter of the same entries
+	want := NewWithBits(f.BitCount(), f.K, doubleFNV)
+	want.Add([]byte("hello"))
+	want.Add([]byte("world"))
+	want.AddMany(keys)
+	c, err := f.Classic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.B, want.B) || !c.Test([]byte("hello")) {
+		t.Fatal("Fetched filter should have the bits of a classic filter of the same entries")
+	}
+
+	f.Reset()
+	if f.Test([]byte("hello")) || f.Err() != nil {
+		t.Fatal("Reset filter should be empty")
+	}
+}
+
+func TestRedisFilter_Errors(t *testing.T) {
+	f := newFakeRedis(t, "wrongtype")
+	if err := f.Insert([]byte("hello")); err == nil || !strings.Contains(err.Error(), "WRONGTYPE") {
+		t.Fatalf("Insert should return the error reply but got %v", err)
+	}
+	f.Add([]byte("hello"))
+	f.Add([]byte("world"))
+	if f.Err() == nil || f.Err() != nil {
+		t.Fatal("Err should return the error of Add once")
+	}
+	// the connection stays usable after an error reply
+	if _, err := f.Classic(); err != nil {
+		t.Fatal(err)
+	}
+
+	f = newFakeRedis(t, "closed")
+	f.rw.Writer.Reset(failingWriter{})
+	if _, err := f.Lookup([]byte("hello")); err == nil {
+		t.Fatal("Lookup over a broken connection should fail")
+	}
+	if found := f.TestMany([][]byte{[]byte("hello")}); len(found) != 1 || found[0] || f.Err() == nil {
+		t.Fatal("TestMany over a broken connection should report entries absent and record the error")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, net.ErrClosed }
@@ -0,0 +1,145 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// RedisBloom options of a filter chain, from RedisBloom's bloom.h.
+const (
+	redisOptNoRound = 1
+	redisOptForce64 = 4
+)
+
+// redisChainHeaderSize is the size of the packed dumpedChainHeader of RedisBloom:
+// the number of entries, the number of filters, options and growth.
+const redisChainHeaderSize = 8 + 4 + 4 + 4
+
+// redisLinkHeaderSize is the size of the packed dumpedChainLink of RedisBloom:
+// bytes, bits, entries, error rate, bits per entry, hashes, capacity and n2.
+const redisLinkHeaderSize = 8 + 8 + 8 + 8 + 8 + 4 + 8 + 1
+
+// RedisMaxChunk is the largest chunk RedisBloom returns from BF.SCANDUMP.
+const RedisMaxChunk = 10 << 20
+
+// RedisChunk is one reply of BF.SCANDUMP and the arguments of the matching BF.LOADCHUNK.
+type RedisChunk struct {
+	Iter int64
+	Data []byte
+}
+
+// RedisHash is the double hash RedisBloom uses for 64-bit filters.
+// A ClassicFilter that is exchanged with Redis must use it,
+// or its entries will not be found on the other side.
+func RedisHash(b []byte) (uint64, uint64) {
+	x := murmur64A(b, 0xc6a4a7935bd1e995)
+	return x, murmur64A(b, x)
+}
+
+// murmur64A is Austin Appleby's MurmurHash64A.
+func murmur64A(b []byte, seed uint64) uint64 {
+	const m, r = 0xc6a4a7935bd1e995, 47
+	h := seed ^ uint64(len(b))*m
+	for ; len(b) >= 8; b = b[8:] {
+		k := binary.LittleEndian.Uint64(b)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	if len(b) > 0 {
+		for i := len(b) - 1; i >= 0; i-- {
+			h ^= uint64(b[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// DumpRedis splits f into the chunks BF.SCANDUMP would return for it, so it can
+// be loaded into Redis by passing each chunk to BF.LOADCHUNK in order.
+// Chunks hold at most chunkSize bytes of the bit array, or RedisMaxChunk if chunkSize is not positive.
+// The chunks share memory with f.B. The filter is dumped as a 64-bit, unrounded,
+// scaling filter whose capacity and count are estimated from its size and bits.
+func DumpRedis(f *ClassicFilter, chunkSize int) []RedisChunk {
+	if chunkSize <= 0 {
+		chunkSize = RedisMaxChunk
+	}
+	m := float64(f.BitCount())
+	k := float64(f.K)
+	count := -m / k * math.Log1p(-float64(f.setBits())/m)
+	bpe := k / math.Ln2
+	h := binary.LittleEndian.AppendUint64(nil, uint64(count))
+	h = binary.LittleEndian.AppendUint32(h, 1) // filters
+	h = binary.LittleEndian.AppendUint32(h, redisOptNoRound|redisOptForce64)
+	h = binary.LittleEndian.AppendUint32(h, 2) // growth
+	h = binary.LittleEndian.AppendUint64(h, uint64(len(f.B)))
+	h = binary.LittleEndian.AppendUint64(h, f.BitCount())
+	h = binary.LittleEndian.AppendUint64(h, uint64(count))
+	h = binary.LittleEndian.AppendUint64(h, math.Float64bits(math.Pow(0.5, k)))
+	h = binary.LittleEndian.AppendUint64(h, math.Float64bits(bpe))
+	h = binary.LittleEndian.AppendUint32(h, uint32(f.K))
+	h = binary.LittleEndian.AppendUint64(h, uint64(m/bpe))
+	h = append(h, 0) // n2
+	chunks := []RedisChunk{{Iter: 1, Data: h}}
+	for off := 0; off < len(f.B); off += chunkSize {
+		data := f.B[off:min(off+chunkSize, len(f.B))]
+		chunks = append(chunks, RedisChunk{Iter: int64(off+len(data)) + 1, Data: data})
+	}
+	return chunks
+}
+
+// LoadRedis builds a ClassicFilter from the chunks of BF.SCANDUMP, using RedisHash.
+// Only chains of a single 64-bit filter, which is what BF.RESERVE creates until
+// the filter scales, can be loaded; others return ErrUnsupportedType.
+// The final empty reply of BF.SCANDUMP may be included.
+func LoadRedis(chunks []RedisChunk) (*ClassicFilter, error) {
+	if len(chunks) == 0 || chunks[0].Iter != 1 {
+		return nil, ErrInvalidEncoding
+	}
+	h := chunks[0].Data
+	if len(h) < redisChainHeaderSize {
+		return nil, ErrInvalidEncoding
+	}
+	filters := binary.LittleEndian.Uint32(h[8:])
+	options := binary.LittleEndian.Uint32(h[12:])
+	if uint64(len(h)) != redisChainHeaderSize+uint64(filters)*redisLinkHeaderSize {
+		return nil, ErrInvalidEncoding
+	}
+	if filters != 1 || options&redisOptForce64 == 0 {
+		return nil, ErrUnsupportedType
+	}
+	link := h[redisChainHeaderSize:]
+	size := binary.LittleEndian.Uint64(link)
+	m := binary.LittleEndian.Uint64(link[8:])
+	k := binary.LittleEndian.Uint32(link[40:])
+	n2 := link[52]
+	if k == 0 || k > maxHashes || m == 0 || m > 8*size || n2 >= 64 || n2 > 0 && m != 1<<n2 {
+		return nil, ErrUnsupportedType
+	}
+	var loaded uint64
+	for _, c := range chunks[1:] {
+		if c.Iter == 0 && len(c.Data) == 0 {
+			continue
+		}
+		if c.Iter < 1+int64(len(c.Data)) || uint64(c.Iter) > size+1 {
+			return nil, ErrInvalidEncoding
+		}
+		loaded += uint64(len(c.Data))
+	}
+	if loaded != size {
+		return nil, ErrInvalidEncoding
+	}
+	f := &ClassicFilter{B: make([]byte, size), K: int(k), H: RedisHash, m: m}
+	for _, c := range chunks[1:] {
+		if c.Iter == 0 {
+			continue
+		}
+		copy(f.B[c.Iter-1-int64(len(c.Data)):], c.Data)
+	}
+	return f, nil
+}
@@ -0,0 +1,64 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestRedisHash(t *testing.T) {
+	// the tail bytes are mixed in little-endian order
+	x := murmur64A([]byte{1, 2, 3}, 0)
+	if x == murmur64A([]byte{3, 2, 1}, 0) {
+		t.Fatal("Tail bytes should not commute")
+	}
+	a, b := RedisHash([]byte("hello"))
+	if b != murmur64A([]byte("hello"), a) {
+		t.Fatal("Second hash should be seeded with the first")
+	}
+}
+
+func TestRedis_RoundTrip(t *testing.T) {
+	f := newClassic(10000, 0.01, RedisHash)
+	for i := 0; i < 5000; i++ {
+		f.Add([]byte{byte(i), byte(i >> 8)})
+	}
+	chunks := DumpRedis(f, 1000)
+	if len(chunks) != 1+(len(f.B)+999)/1000 {
+		t.Fatalf("Dump has %d chunks", len(chunks))
+	}
+	if len(chunks[0].Data) != redisChainHeaderSize+redisLinkHeaderSize {
+		t.Fatalf("Header has %d bytes", len(chunks[0].Data))
+	}
+	last := chunks[len(chunks)-1]
+	if last.Iter != int64(len(f.B))+1 {
+		t.Fatalf("Last iterator = %d, want %d", last.Iter, len(f.B)+1)
+	}
+	if n := binary.LittleEndian.Uint64(chunks[0].Data); n < 4800 || n > 5200 {
+		t.Fatalf("Estimated count = %d, want about 5000", n)
+	}
+
+	// load out of order, with the terminating reply
+	shuffled := append([]RedisChunk{chunks[0]}, chunks[1:]...)
+	shuffled[1], shuffled[2] = shuffled[2], shuffled[1]
+	g, err := LoadRedis(append(shuffled, RedisChunk{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.K != f.K || !bytes.Equal(g.B, f.B) {
+		t.Fatal("Loaded filter differs from dumped filter")
+	}
+	if !g.Test([]byte{1, 0}) {
+		t.Fatal("Should exist in loaded filter but got false")
+	}
+
+	if _, err := LoadRedis(chunks[:len(chunks)-1]); err != ErrInvalidEncoding {
+		t.Fatalf("Missing chunk error = %v, want %v", err, ErrInvalidEncoding)
+	}
+	chained := append([]byte(nil), chunks[0].Data...)
+	binary.LittleEndian.PutUint32(chained[8:], 2)
+	chained = append(chained, chunks[0].Data[redisChainHeaderSize:]...)
+	if _, err := LoadRedis([]RedisChunk{{Iter: 1, Data: chained}}); err != ErrUnsupportedType {
+		t.Fatalf("Chain error = %v, want %v", err, ErrUnsupportedType)
+	}
+}
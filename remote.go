@@ -0,0 +1,31 @@
+package bloom
+
+import "sync"
+
+// firstError keeps the first error of the methods of a remote filter that have no error
+// result, such as Add and Test, until it is taken.
+type firstError struct {
+	mu  sync.Mutex
+	err error
+}
+
+// record keeps err unless an earlier error is kept.
+func (e *firstError) record(err error) {
+	if err == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// take returns the kept error and clears it.
+func (e *firstError) take() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := e.err
+	e.err = nil
+	return err
+}
@@ -0,0 +1,313 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+)
+
+// ErrCorruptSnapshot is returned by Open for a snapshot that is not one written by a
+// Filter, or whose checksum does not match.
+var ErrCorruptSnapshot = errors.New("replay: corrupt snapshot")
+
+// ErrConfigMismatch is returned by Open for a snapshot written by a filter with another
+// window, number of buckets or size of their filters.
+var ErrConfigMismatch = errors.New("replay: snapshot was written with another configuration")
+
+// files of a directory of Open
+const (
+	snapshotName = "snapshot"
+	logName      = "log"
+)
+
+var snapshotMagic = [4]byte{'B', 'L', 'M', 'R'}
+
+const snapshotVersion = 1
+
+// maxNonce bounds the length of a nonce in the log, beyond which a record is taken as torn.
+const maxNonce = 1 << 16
+
+// Open returns a filter configured by cfg like New, which persists the nonces it remembers
+// in dir, creating it if needed, so that a server restarted after a crash still rejects
+// the nonces it accepted before instead of reopening a replay window.
+//
+// The directory holds a snapshot of the filters and a log of the nonces accepted since,
+// each appended and synced, as SyncInterval says, before CheckAndRemember accepts it. Once
+// the log is larger than the filters, CheckAndRemember writes a new snapshot and empties
+// the log. Open loads the snapshot and adds the nonces of the log whose timestamps are
+// still in the window, up to a record torn by the crash, which is dropped.
+//
+// Hash must be the same as when the directory was written, and so must the key of a
+// keyed hash, or the persisted nonces are not recognized.
+func Open(dir string, cfg Config) (*Filter, error) {
+	f, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := f.loadSnapshot(filepath.Join(dir, snapshotName)); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(filepath.Join(dir, logName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	size, err := f.replayLog(log)
+	if err == nil {
+		err = log.Truncate(size)
+	}
+	if err == nil {
+		_, err = log.Seek(size, io.SeekStart)
+	}
+	if err == nil {
+		err = log.Sync()
+	}
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	syncDir(dir)
+	f.dir, f.log, f.logSize = dir, log, size
+	f.syncInterval, f.synced = cfg.SyncInterval, f.now()
+	return f, nil
+}
+
+// Checkpoint writes a snapshot of a filter opened with Open and empties its log, which
+// CheckAndRemember does once the log is larger than the filters. It does nothing for a
+// filter returned by New.
+func (f *Filter) Checkpoint() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.log == nil {
+		return nil
+	}
+	return f.checkpoint()
+}
+
+// Close syncs and closes the log of a filter opened with Open, after which
+// CheckAndRemember returns the error of writing to a closed file. It does nothing for a
+// filter returned by New.
+func (f *Filter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.log == nil {
+		return nil
+	}
+	err := f.log.Sync()
+	if cerr := f.log.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// logNonce writes a record of nonce to the log: the uvarint length of nonce, timestamp in
+// nanoseconds as a little-endian int64, nonce, and a CRC-32 checksum of all before.
+func (f *Filter) logNonce(nonce []byte, timestamp, now time.Time) error {
+	rec := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+8+len(nonce)+4), uint64(len(nonce)))
+	rec = binary.LittleEndian.AppendUint64(rec, uint64(timestamp.UnixNano()))
+	rec = append(rec, nonce...)
+	rec = binary.LittleEndian.AppendUint32(rec, crc32.ChecksumIEEE(rec))
+	if _, err := f.log.Write(rec); err != nil {
+		// drop what was written, so the records after are not taken for torn ones
+		if f.log.Truncate(f.logSize) == nil {
+			f.log.Seek(f.logSize, io.SeekStart)
+		}
+		return err
+	}
+	f.logSize += int64(len(rec))
+	if now.Sub(f.synced) >= f.syncInterval {
+		if err := f.log.Sync(); err != nil {
+			return err
+		}
+		f.synced = now
+	}
+	if f.logSize > int64(f.Size()) {
+		return f.checkpoint()
+	}
+	return nil
+}
+
+// replayLog remembers the nonces of the log that are in the window, and returns the size
+// of its records up to the first torn one.
+func (f *Filter) replayLog(log *os.File) (int64, error) {
+	r := bufio.NewReader(log)
+	now := f.now()
+	var size int64
+	for {
+		rec, err := readRecord(r)
+		if err == io.EOF || err == errTornRecord {
+			return size, nil
+		} else if err != nil {
+			return 0, err
+		}
+		size += int64(len(rec))
+		n, l := binary.Uvarint(rec)
+		ts := time.Unix(0, int64(binary.LittleEndian.Uint64(rec[l:])))
+		if d := now.Sub(ts); d <= f.window && d >= -f.window {
+			f.remember(rec[l+8:l+8+int(n)], f.epoch(ts))
+		}
+	}
+}
+
+var errTornRecord = errors.New("replay: torn record")
+
+// readRecord returns the next record of r, io.EOF at the end of r, or errTornRecord for a
+// record that is cut short or whose checksum does not match.
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	var rec []byte
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF && len(rec) == 0 {
+			return nil, io.EOF
+		} else if err == io.EOF {
+			return nil, errTornRecord
+		} else if err != nil {
+			return nil, err
+		}
+		if rec = append(rec, c); c < 0x80 {
+			break
+		} else if len(rec) == binary.MaxVarintLen64 {
+			return nil, errTornRecord
+		}
+	}
+	n, l := binary.Uvarint(rec)
+	if l <= 0 || n > maxNonce {
+		return nil, errTornRecord
+	}
+	rec = append(rec, make([]byte, 8+int(n)+4)...)
+	if _, err := io.ReadFull(r, rec[l:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, errTornRecord
+	} else if err != nil {
+		return nil, err
+	}
+	body, sum := rec[:len(rec)-4], rec[len(rec)-4:]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(sum) {
+		return nil, errTornRecord
+	}
+	return rec, nil
+}
+
+// checkpoint writes a snapshot of the filters and empties the log.
+func (f *Filter) checkpoint() error {
+	if err := f.writeSnapshot(filepath.Join(f.dir, snapshotName)); err != nil {
+		return err
+	}
+	// a crash before the log is emptied adds its nonces to the snapshot again, which the
+	// snapshot already has
+	if err := f.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	f.logSize = 0
+	f.synced = f.now()
+	return f.log.Sync()
+}
+
+// writeSnapshot atomically replaces the snapshot at path with the magic number, the
+// version, the width of a bucket in nanoseconds, the number of buckets, the epoch and
+// length-prefixed binary encoding of each, and a CRC-32 checksum of all before.
+func (f *Filter) writeSnapshot(path string) error {
+	b := append(snapshotMagic[:len(snapshotMagic):len(snapshotMagic)], snapshotVersion)
+	b = binary.LittleEndian.AppendUint64(b, uint64(f.width))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(f.buckets)))
+	for _, bucket := range f.buckets {
+		enc, err := bucket.f.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		b = binary.LittleEndian.AppendUint64(b, uint64(bucket.epoch))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(enc)))
+		b = append(b, enc...)
+	}
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// loadSnapshot replaces the filters with those of the snapshot at path, if there is one.
+func (f *Filter) loadSnapshot(path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	const headerLen = len(snapshotMagic) + 1 + 8 + 4
+	if len(b) < headerLen+4 || !bytes.Equal(b[:4], snapshotMagic[:]) || b[4] != snapshotVersion {
+		return ErrCorruptSnapshot
+	}
+	body, sum := b[:len(b)-4], b[len(b)-4:]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(sum) {
+		return ErrCorruptSnapshot
+	}
+	width, n := time.Duration(binary.LittleEndian.Uint64(body[5:])), binary.LittleEndian.Uint32(body[13:])
+	if width != f.width || int(n) != len(f.buckets) {
+		return ErrConfigMismatch
+	}
+	body = body[headerLen:]
+	for i := range f.buckets {
+		if len(body) < 12 {
+			return ErrCorruptSnapshot
+		}
+		epoch, l := int64(binary.LittleEndian.Uint64(body)), binary.LittleEndian.Uint32(body[8:])
+		if uint64(len(body)-12) < uint64(l) {
+			return ErrCorruptSnapshot
+		}
+		b := &f.buckets[i]
+		g := bloom.ClassicFilter{H: b.f.H}
+		if err := g.UnmarshalBinary(body[12 : 12+l]); err != nil {
+			return ErrCorruptSnapshot
+		}
+		if g.BitCount() != b.f.BitCount() || g.K != b.f.K {
+			return ErrConfigMismatch
+		}
+		copy(b.f.B, g.B)
+		b.epoch = epoch
+		body = body[12+l:]
+	}
+	if len(body) != 0 {
+		return ErrCorruptSnapshot
+	}
+	return nil
+}
+
+// syncDir syncs the directory at path, so that files created or renamed in it survive a
+// loss of power. Directories cannot be synced on every platform, so errors are ignored.
+func syncDir(path string) {
+	if d, err := os.Open(path); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
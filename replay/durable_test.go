@@ -0,0 +1,163 @@
+package replay
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpen_Crash(t *testing.T) {
+	dir := t.TempDir()
+	c := &clock{time.Unix(1700000000, 0)}
+	cfg := Config{Window: time.Minute, Capacity: 1e3, Now: c.now}
+	f, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for i := 0; i < 100; i++ {
+		if err := f.CheckAndRemember([]byte(fmt.Sprint(i)), c.t.Add(time.Duration(i-50)*time.Second/2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the process dies without closing f, halfway through writing a record
+	log, err := os.OpenFile(filepath.Join(dir, logName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Write([]byte{5, 1, 2, 3})
+	log.Close()
+
+	c.t = c.t.Add(10 * time.Second)
+	g, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := g.CheckAndRemember([]byte(fmt.Sprint(i)), c.t.Add(time.Duration(i-50)*time.Second/2)); err != ErrReplay {
+			t.Fatal("A nonce accepted before the crash should be rejected, but got", err)
+		}
+	}
+	if err := g.CheckAndRemember([]byte("after"), c.t); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.CheckAndRemember([]byte("closed"), c.t); err == nil {
+		t.Fatal("A closed filter should not accept nonces")
+	}
+
+	h, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.CheckAndRemember([]byte("after"), c.t); err != ErrReplay {
+		t.Fatal("The torn record should be dropped, not hide the records after, but got", err)
+	}
+
+	// nonces of the log whose timestamps left the window are not remembered
+	c.t = c.t.Add(2 * time.Minute)
+	i, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+	for _, b := range i.buckets {
+		if b.f.FillRatio() != 0 {
+			t.Fatal("Expired nonces should not be added back")
+		}
+	}
+}
+
+func TestOpen_Checkpoint(t *testing.T) {
+	dir := t.TempDir()
+	c := &clock{time.Unix(1700000000, 0)}
+	cfg := Config{Window: time.Minute, Buckets: 2, Capacity: 100, FalsePositiveRate: 1e-3, Now: c.now}
+	f, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for i := 0; i < 50; i++ {
+		if err := f.CheckAndRemember([]byte(fmt.Sprint("nonce", i)), c.t); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotName)); err != nil {
+		t.Fatal("A log larger than the filters should be checkpointed, but got", err)
+	}
+	if f.logSize > int64(f.Size()) {
+		t.Fatal("The log should be emptied by a checkpoint, but has", f.logSize, "bytes")
+	}
+
+	g, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := g.CheckAndRemember([]byte(fmt.Sprint("nonce", i)), c.t); err != ErrReplay {
+			t.Fatal("A nonce of the snapshot or the log should be rejected, but got", err)
+		}
+	}
+	g.Reset()
+	g.Close()
+	h, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.CheckAndRemember([]byte("nonce0"), c.t); err != nil {
+		t.Fatal("Reset should empty the snapshot and log, but got", err)
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Window: time.Minute, Capacity: 1e3}
+	f, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for _, other := range []Config{
+		{Window: time.Hour, Capacity: 1e3},
+		{Window: time.Minute, Buckets: 4, Capacity: 1e3},
+		{Window: time.Minute, Capacity: 1e4},
+	} {
+		if _, err := Open(dir, other); err != ErrConfigMismatch {
+			t.Errorf("%+v should not open the snapshot of %+v, but got %v", other, cfg, err)
+		}
+	}
+
+	path := filepath.Join(dir, snapshotName)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)/2] ^= 1
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir, cfg); err != ErrCorruptSnapshot {
+		t.Fatal("A corrupt snapshot should be reported, but got", err)
+	}
+}
+
+func TestNew_NotPersisted(t *testing.T) {
+	f, err := New(Config{Window: time.Minute, Capacity: 1e3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Checkpoint() != nil || f.Close() != nil {
+		t.Fatal("Checkpoint and Close should do nothing for a filter of New")
+	}
+}
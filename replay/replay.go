@@ -0,0 +1,211 @@
+// Package replay rejects replayed handshake material, such as the nonces of the handshakes
+// of Pluggable Transports, with Bloom filters of github.com/OperatorFoundation/go-bloom
+// that forget nonces once their timestamps leave the window a server accepts.
+package replay
+
+import (
+	"errors"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	bloom "github.com/OperatorFoundation/go-bloom"
+)
+
+// ErrReplay is returned by CheckAndRemember for a nonce it has seen before.
+var ErrReplay = errors.New("replay: nonce was seen before")
+
+// ErrStale is returned by CheckAndRemember for a timestamp too far from the current time
+// for its nonce to be checked, which a server must reject as it would a replay.
+var ErrStale = errors.New("replay: timestamp is outside the window")
+
+// ErrInvalidConfig is returned by New for a Config without a window or capacity, with a
+// false positive rate that is not a probability, or with a memory budget too small for a
+// filter per bucket.
+var ErrInvalidConfig = errors.New("replay: invalid configuration")
+
+// Config configures a Filter.
+type Config struct {
+	// Window is how far a timestamp may be from the current time, in the past or in the
+	// future for clients with skewed clocks. Nonces are remembered until their timestamps
+	// are older than that.
+	Window time.Duration
+
+	// Buckets is the number of time buckets each Window is split into, 8 if 0. Memory is
+	// freed a bucket at a time, so more buckets hold fewer expired nonces.
+	Buckets int
+
+	// Capacity is the number of nonces expected per Window.
+	Capacity int
+
+	// MemoryBudget is the number of bytes of all filters. If it is 0, the filters are sized
+	// for a false positive rate of FalsePositiveRate instead.
+	MemoryBudget int
+
+	// FalsePositiveRate is the probability that a new nonce is taken for a replay, 1e-6 if
+	// 0. It is ignored if MemoryBudget is set.
+	FalsePositiveRate float64
+
+	// Hash is the double hash of nonces, bloom.DefaultHash if nil. Nonces chosen by clients
+	// are attacker-controlled, so a keyed hash, such as one returned by bloom.SipHash, keeps them
+	// from crafting nonces that collide.
+	Hash func([]byte) (uint64, uint64)
+
+	// Now is the clock, time.Now if nil.
+	Now func() time.Time
+
+	// SyncInterval is how often a filter opened with Open syncs its log to disk. If it is
+	// 0, nonces are synced before CheckAndRemember accepts them; otherwise the log is synced
+	// by the first call accepting a nonce SyncInterval after the last sync, and losing power
+	// before may forget the nonces accepted since, which a crash of the process does not.
+	SyncInterval time.Duration
+}
+
+// Filter remembers the nonces of a time window, keyed by the epoch of their timestamps: the
+// time bucket a timestamp falls in. A nonce is added to the filter of its bucket and
+// checked against every bucket of the window, and the filter of a bucket is cleared for
+// reuse once the bucket leaves the window, so memory stays within the budget however long
+// the filter runs. A Filter is safe for concurrent use.
+type Filter struct {
+	window time.Duration
+	width  time.Duration // of a bucket
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets []bucket // bucket of epoch e at e mod len(buckets)
+
+	// persistence of a filter opened with Open
+	dir          string
+	log          *os.File
+	logSize      int64
+	syncInterval time.Duration
+	synced       time.Time
+}
+
+type bucket struct {
+	epoch int64
+	f     *bloom.ClassicFilter
+}
+
+// New returns a filter configured by cfg. Timestamps within Window of the current time,
+// past or future, span 2·Buckets+1 buckets, and filters are kept for one more, being cleared
+// while the window moves on; the memory budget or false positive rate is shared among them.
+func New(cfg Config) (*Filter, error) {
+	if cfg.Window <= 0 || cfg.Capacity <= 0 || cfg.MemoryBudget < 0 || cfg.Buckets < 0 ||
+		cfg.FalsePositiveRate < 0 || cfg.FalsePositiveRate >= 1 {
+		return nil, ErrInvalidConfig
+	}
+	if cfg.Buckets == 0 {
+		cfg.Buckets = 8
+	}
+	if cfg.FalsePositiveRate == 0 {
+		cfg.FalsePositiveRate = 1e-6
+	}
+	if cfg.Hash == nil {
+		cfg.Hash = bloom.DefaultHash
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	width := max(cfg.Window/time.Duration(cfg.Buckets), 1)
+	spanned := int((cfg.Window + width - 1) / width) // Buckets, unless Window is too short
+	f := &Filter{
+		window:  cfg.Window,
+		width:   width,
+		now:     cfg.Now,
+		buckets: make([]bucket, 2*spanned+2),
+	}
+	// a nonce is tested against every bucket of the window, so their rates add up
+	n := (cfg.Capacity + cfg.Buckets - 1) / cfg.Buckets
+	tested := float64(len(f.buckets) - 1)
+	for i := range f.buckets {
+		var err error
+		f.buckets[i].epoch = math.MinInt64
+		if cfg.MemoryBudget == 0 {
+			f.buckets[i].f, err = bloom.NewChecked(n, cfg.FalsePositiveRate/tested, cfg.Hash)
+		} else {
+			bits := uint64(cfg.MemoryBudget / len(f.buckets) * 8)
+			if bits == 0 {
+				return nil, ErrInvalidConfig
+			}
+			k := max(int(math.Round(float64(bits)/float64(n)*math.Ln2)), 1)
+			f.buckets[i].f = bloom.NewWithBits(bits, k, cfg.Hash)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// CheckAndRemember remembers nonce and returns nil if it has not been seen with a timestamp
+// in the window, or ErrReplay if it has. It returns ErrStale without remembering nonce if
+// timestamp is more than Window away from the current time. Concurrent callers presenting
+// the same nonce see it as new exactly once. A filter opened with Open returns the error of
+// writing nonce to its log, which must be taken as a rejection too.
+func (f *Filter) CheckAndRemember(nonce []byte, timestamp time.Time) error {
+	now := f.now()
+	if d := now.Sub(timestamp); d > f.window || d < -f.window {
+		return ErrStale
+	}
+	oldest, epoch := f.epoch(now.Add(-f.window)), f.epoch(timestamp)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.buckets {
+		if b := &f.buckets[i]; b.epoch >= oldest && b.f.Test(nonce) {
+			return ErrReplay
+		}
+	}
+	f.remember(nonce, epoch)
+	if f.log != nil {
+		return f.logNonce(nonce, timestamp, now)
+	}
+	return nil
+}
+
+// remember adds nonce to the bucket of epoch.
+func (f *Filter) remember(nonce []byte, epoch int64) {
+	b := &f.buckets[f.slot(epoch)]
+	if b.epoch != epoch {
+		// the bucket held an epoch that has left the window
+		b.f.Reset()
+		b.epoch = epoch
+	}
+	b.f.Add(nonce)
+}
+
+// Size returns the number of bytes of the filters.
+func (f *Filter) Size() int { return len(f.buckets) * f.buckets[0].f.Size() }
+
+// Reset forgets every nonce, which lets nonces seen before be replayed. A filter opened with
+// Open also empties its snapshot and log; if that fails, the nonces are remembered again
+// when the filter is next opened.
+func (f *Filter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.buckets {
+		f.buckets[i].f.Reset()
+		f.buckets[i].epoch = math.MinInt64
+	}
+	if f.log != nil {
+		f.checkpoint()
+	}
+}
+
+// epoch returns the number of the bucket of t.
+func (f *Filter) epoch(t time.Time) int64 {
+	ns := t.UnixNano()
+	e := ns / int64(f.width)
+	if ns < 0 && ns%int64(f.width) != 0 {
+		e-- // round toward the past
+	}
+	return e
+}
+
+// slot returns the index of the bucket of epoch.
+func (f *Filter) slot(epoch int64) int {
+	n := int64(len(f.buckets))
+	return int((epoch%n + n) % n)
+}
@@ -0,0 +1,131 @@
+package replay
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestCheckAndRemember(t *testing.T) {
+	c := &clock{time.Unix(1700000000, 0)}
+	f, err := New(Config{Window: time.Minute, Buckets: 4, Capacity: 1e3, Now: c.now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("handshake nonce")
+	if err := f.CheckAndRemember(nonce, c.t); err != nil {
+		t.Fatal("A new nonce should be accepted, but got", err)
+	}
+	if err := f.CheckAndRemember(nonce, c.t); err != ErrReplay {
+		t.Fatal("A replayed nonce should be rejected, but got", err)
+	}
+	if err := f.CheckAndRemember(nonce, c.t.Add(-30*time.Second)); err != ErrReplay {
+		t.Fatal("A nonce should be rejected with any timestamp in the window, but got", err)
+	}
+	if err := f.CheckAndRemember([]byte("old"), c.t.Add(-time.Minute-time.Second)); err != ErrStale {
+		t.Fatal("A timestamp before the window should be stale, but got", err)
+	}
+	if err := f.CheckAndRemember([]byte("skewed"), c.t.Add(time.Minute+time.Second)); err != ErrStale {
+		t.Fatal("A timestamp after the window should be stale, but got", err)
+	}
+	if err := f.CheckAndRemember([]byte("skewed"), c.t.Add(50*time.Second)); err != nil {
+		t.Fatal("A timestamp of a client clock ahead within the window should be accepted, but got", err)
+	}
+
+	// the nonce is remembered for as long as its timestamp is accepted
+	for d := time.Duration(0); d <= time.Minute; d += time.Second {
+		c.t = time.Unix(1700000000, 0).Add(d)
+		if err := f.CheckAndRemember(nonce, time.Unix(1700000000, 0)); err != ErrReplay {
+			t.Fatal("A nonce should be remembered within the window, but got", err, "after", d)
+		}
+	}
+	c.t = c.t.Add(15 * time.Second) // and at most a bucket longer
+	if err := f.CheckAndRemember(nonce, c.t); err != nil {
+		t.Fatal("A nonce should be forgotten once its timestamp left the window, but got", err)
+	}
+}
+
+func TestCheckAndRemember_Rotation(t *testing.T) {
+	c := &clock{time.Unix(1700000000, 0)}
+	f, err := New(Config{Window: time.Minute, Buckets: 6, Capacity: 600, MemoryBudget: 4 << 10, Now: c.now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Size() > 4<<10 {
+		t.Fatal("The filters should fit the memory budget, but take", f.Size())
+	}
+	// ten nonces a second for an hour, each replayed ten seconds later
+	var falsePositives int
+	for s := 0; s < 3600; s++ {
+		c.t = time.Unix(1700000000+int64(s), 0)
+		for i := 0; i < 10; i++ {
+			if err := f.CheckAndRemember([]byte(fmt.Sprint(s, i)), c.t); err == ErrReplay {
+				falsePositives++
+			}
+			if s >= 10 {
+				if err := f.CheckAndRemember([]byte(fmt.Sprint(s-10, i)), c.t.Add(-10*time.Second)); err != ErrReplay {
+					t.Fatal("A replay should be detected, but got", err)
+				}
+			}
+		}
+	}
+	if falsePositives > 36 {
+		t.Error("The false positive rate should stay low as buckets are reused, but got", falsePositives, "of 36000")
+	}
+}
+
+func TestCheckAndRemember_Concurrent(t *testing.T) {
+	f, err := New(Config{Window: time.Minute, Capacity: 1e3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	now := time.Now()
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if f.CheckAndRemember([]byte("nonce"), now) == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted.Load() != 1 {
+		t.Fatal("A nonce should be accepted exactly once, but was accepted", accepted.Load(), "times")
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{Capacity: 1e3},
+		{Window: time.Minute},
+		{Window: time.Minute, Capacity: 1e3, MemoryBudget: 10},
+		{Window: time.Minute, Capacity: 1e3, Buckets: -1},
+		{Window: time.Minute, Capacity: 1e3, FalsePositiveRate: 1},
+	} {
+		if _, err := New(cfg); err != ErrInvalidConfig {
+			t.Errorf("%+v should be invalid, but got %v", cfg, err)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	f, err := New(Config{Window: time.Minute, Capacity: 1e3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	f.CheckAndRemember([]byte("nonce"), now)
+	f.Reset()
+	if err := f.CheckAndRemember([]byte("nonce"), now); err != nil {
+		t.Fatal("Reset should forget the nonces, but got", err)
+	}
+}
@@ -0,0 +1,207 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// replicaVersion is the version of the binary encoding of replica states.
+const replicaVersion = 1
+
+// VersionVector counts the changes each replica of a filter has made, by replica ID.
+type VersionVector map[string]uint64
+
+// Dominates reports whether v has seen every change w has, so a state tagged w adds
+// nothing to one tagged v.
+func (v VersionVector) Dominates(w VersionVector) bool {
+	for id, n := range w {
+		if v[id] < n {
+			return false
+		}
+	}
+	return true
+}
+
+// merge raises the counts of v to those of w.
+func (v VersionVector) merge(w VersionVector) {
+	for id, n := range w {
+		v[id] = max(v[id], n)
+	}
+}
+
+// ReplicaState is the state of a replica exchanged between replicas: its filter, tagged
+// with the version vector of the changes it holds.
+type ReplicaState struct {
+	Clock  VersionVector
+	Filter *ClassicFilter
+}
+
+// MarshalBinary encodes the version vector followed by the binary encoding of the filter.
+// The hash function is not encoded.
+func (s ReplicaState) MarshalBinary() ([]byte, error) {
+	b := []byte{replicaVersion}
+	b = binary.AppendUvarint(b, uint64(len(s.Clock)))
+	for _, id := range slices.Sorted(maps.Keys(s.Clock)) {
+		b = binary.AppendUvarint(b, uint64(len(id)))
+		b = append(b, id...)
+		b = binary.AppendUvarint(b, s.Clock[id])
+	}
+	filter, err := s.Filter.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(b, filter...), nil
+}
+
+// UnmarshalBinary decodes a state encoded by MarshalBinary. The filter has no hash
+// function; ApplyRemote takes it to have that of the replica.
+func (s *ReplicaState) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return ErrInvalidEncoding
+	}
+	if b[0] != replicaVersion {
+		return ErrUnsupportedVersion
+	}
+	b = b[1:]
+	count, size := binary.Uvarint(b)
+	if size <= 0 || count > uint64(len(b)) {
+		return ErrInvalidEncoding
+	}
+	b = b[size:]
+	clock := make(VersionVector, count)
+	for ; count > 0; count-- {
+		n, size := binary.Uvarint(b)
+		if size <= 0 || uint64(len(b)-size) < n {
+			return ErrInvalidEncoding
+		}
+		id := string(b[size : size+int(n)])
+		b = b[size+int(n):]
+		if clock[id], size = binary.Uvarint(b); size <= 0 {
+			return ErrInvalidEncoding
+		}
+		b = b[size:]
+	}
+	f := new(ClassicFilter)
+	if err := f.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	s.Clock, s.Filter = clock, f
+	return nil
+}
+
+// Replica is a classic filter replicated between nodes that add entries independently,
+// such as edge nodes gossiping the filter of the requests they have seen. Filters form a
+// join-semilattice under OR of their bits, so replicas that apply each other's states in
+// any order, any number of times, converge to the filter of all entries added to any of
+// them, without a coordinator.
+//
+// Each replica counts the adds that change its bits under its ID in a version vector, which tags its
+// state, so a replica can tell whether the state of another holds anything it has not
+// seen: StateSince returns nothing for a peer whose clock dominates it, and ApplyRemote
+// skips a state it dominates. Entries cannot be removed from the replicated filter.
+//
+// A Replica is safe for concurrent use.
+type Replica struct {
+	id    string
+	mu    sync.RWMutex
+	f     *ClassicFilter
+	clock VersionVector
+	key   string // the key of the changes of the replica in clock
+	reset int    // number of resets
+}
+
+// NewReplica returns the replica id of a filter, starting from the entries of f. IDs must
+// be unique among the replicas, and f must not be used directly afterwards. Replicas must
+// start from compatible filters, as for Union.
+func NewReplica(id string, f *ClassicFilter) *Replica {
+	return &Replica{id: id, f: f, clock: make(VersionVector), key: id}
+}
+
+// ID returns the ID of the replica.
+func (r *Replica) ID() string { return r.id }
+
+func (r *Replica) Add(b []byte) { r.TestAndAdd(b) }
+
+func (r *Replica) Test(b []byte) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.f.Test(b)
+}
+
+// TestAndAdd adds an entry and reports whether it was in the filter, counting a change of
+// the replica if it was not.
+func (r *Replica) TestAndAdd(b []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	present := r.f.TestAndAdd(b)
+	if !present {
+		r.clock[r.key]++
+	}
+	return present
+}
+
+func (r *Replica) Size() int { return r.f.Size() }
+
+// Reset clears the filter of this replica only, and its version vector, so the next state
+// of any peer brings back the entries it holds: a replicated filter is only reset by
+// resetting every replica before they exchange states again. The changes the replica
+// makes afterwards are counted under a new key, its ID followed by "#" and the number of
+// resets, so peers do not take them for the changes they have seen before.
+func (r *Replica) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.f.Reset()
+	r.reset++
+	r.clock, r.key = make(VersionVector), r.id+"#"+strconv.Itoa(r.reset)
+}
+
+// Clock returns a copy of the version vector of the replica.
+func (r *Replica) Clock() VersionVector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return maps.Clone(r.clock)
+}
+
+// State returns a copy of the state of the replica, to send to others.
+func (r *Replica) State() ReplicaState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return ReplicaState{Clock: maps.Clone(r.clock), Filter: r.f.Clone()}
+}
+
+// StateSince returns the state of the replica for a peer whose version vector is clock, or
+// false if the peer has seen every change the replica holds.
+func (r *Replica) StateSince(clock VersionVector) (ReplicaState, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if clock.Dominates(r.clock) {
+		return ReplicaState{}, false
+	}
+	return ReplicaState{Clock: maps.Clone(r.clock), Filter: r.f.Clone()}, true
+}
+
+// ApplyRemote merges the state of another replica into this one and reports whether it
+// held changes this replica had not seen. A state whose filter has no hash function, as
+// decoded by UnmarshalBinary, is taken to have the hash of the replica; its geometry,
+// probe scheme and seed must still match, or an IncompatibleError is returned.
+func (r *Replica) ApplyRemote(s ReplicaState) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.clock.Dominates(s.Clock) {
+		return false, nil
+	}
+	remote := s.Filter
+	if remote.H == nil {
+		c := *remote
+		c.H, c.name = r.f.H, r.f.name
+		remote = &c
+	}
+	if err := r.f.Merge(remote); err != nil {
+		return false, err
+	}
+	r.clock.merge(s.Clock)
+	return true, nil
+}
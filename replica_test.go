@@ -0,0 +1,111 @@
+package bloom
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestReplica_Converge(t *testing.T) {
+	replicas := make([]*Replica, 3)
+	for i := range replicas {
+		replicas[i] = NewReplica(strconv.Itoa(i), NewWithBits(8192, 5, doubleFNV))
+	}
+	for i, r := range replicas {
+		for j := 0; j < 100; j++ {
+			r.Add([]byte(strconv.Itoa(i*1000 + j)))
+		}
+	}
+
+	// gossip in a ring, through the exchange format, until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for i, r := range replicas {
+			peer := replicas[(i+1)%len(replicas)]
+			s, ok := r.StateSince(peer.Clock())
+			if !ok {
+				continue
+			}
+			b, err := s.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded ReplicaState
+			if err := decoded.UnmarshalBinary(b); err != nil {
+				t.Fatal(err)
+			}
+			applied, err := peer.ApplyRemote(decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changed = changed || applied
+		}
+	}
+
+	want := replicas[0].State()
+	for _, r := range replicas[1:] {
+		if s := r.State(); !s.Filter.Equal(want.Filter) || !s.Clock.Dominates(want.Clock) || !want.Clock.Dominates(s.Clock) {
+			t.Fatal("Replicas should converge to the same filter and clock")
+		}
+	}
+	for i := range replicas {
+		for j := 0; j < 100; j++ {
+			if !want.Filter.Test([]byte(strconv.Itoa(i*1000 + j))) {
+				t.Fatalf("Entry %d of replica %d should be in the converged filter", j, i)
+			}
+		}
+	}
+	if _, ok := replicas[0].StateSince(replicas[1].Clock()); ok {
+		t.Fatal("A converged replica should have nothing to send")
+	}
+}
+
+func TestReplica_ApplyRemote(t *testing.T) {
+	a := NewReplica("a", NewWithBits(1024, 3, doubleFNV))
+	b := NewReplica("b", NewWithBits(1024, 3, doubleFNV))
+	a.Add([]byte("hello"))
+	a.Add([]byte("hello"))
+	if a.Clock()["a"] != 1 {
+		t.Fatal("Only adds that change the filter should be counted")
+	}
+	if applied, err := b.ApplyRemote(a.State()); !applied || err != nil || !b.Test([]byte("hello")) {
+		t.Fatal("A state with unseen changes should be merged")
+	}
+	if applied, _ := b.ApplyRemote(a.State()); applied {
+		t.Fatal("A state already seen should be skipped")
+	}
+
+	c := NewReplica("c", NewWithBits(2048, 3, doubleFNV))
+	c.Add([]byte("world"))
+	var e *IncompatibleError
+	if _, err := a.ApplyRemote(c.State()); !errors.As(err, &e) || !errors.Is(err, ErrIncompatibleSize) {
+		t.Fatal("A state of an incompatible filter should fail but got", err)
+	}
+	if _, ok := a.Clock()["c"]; ok {
+		t.Fatal("A failed merge should not change the clock")
+	}
+
+	a.Reset()
+	if a.Test([]byte("hello")) {
+		t.Fatal("Reset replica should be empty")
+	}
+	if applied, _ := a.ApplyRemote(b.State()); !applied || !a.Test([]byte("hello")) {
+		t.Fatal("Entries of a reset replica should come back from its peers")
+	}
+}
+
+func TestReplicaState_UnmarshalBinary(t *testing.T) {
+	r := NewReplica("a", NewWithBits(64, 2, doubleFNV))
+	r.Add([]byte("hello"))
+	b, _ := r.State().MarshalBinary()
+	var s ReplicaState
+	for i := range b {
+		if s.UnmarshalBinary(b[:i]) == nil {
+			t.Fatalf("A state truncated to %d bytes should not decode", i)
+		}
+	}
+	b[0] = 2
+	if err := s.UnmarshalBinary(b); err != ErrUnsupportedVersion {
+		t.Fatal("A state of another version should fail with ErrUnsupportedVersion, got", err)
+	}
+}
@@ -0,0 +1,169 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// ribbonWidth is the width of the coefficient band of a ribbon filter.
+const ribbonWidth = 64
+
+// Ribbon Filter
+//
+// A ribbon filter is an immutable filter built once from a known set of
+// keys. Each key is mapped to a random 64-bit coefficient row starting at a
+// random column and an R-bit fingerprint, and construction solves the linear
+// system over GF(2) whose solution Z satisfies, for every key, that the xor
+// of the rows of Z selected by its coefficients is its fingerprint. The band
+// structure makes solving fast, and the filter takes only a few percent over
+// R bits per key for a false positive rate of 2^-R.
+// See Dillinger and Walzer, "Ribbon filter: practically smaller than Bloom
+// and Xor" (2021).
+type RibbonFilter struct {
+	Z    []uint64 // solution, R columns of bits
+	M    uint64   // number of rows of the solution
+	R    uint     // fingerprint size in bits, 1 to 32
+	Seed uint64
+	H    func([]byte) (uint64, uint64)
+}
+
+// BuildRibbon builds a standard ribbon filter that contains keys with a false positive rate of 2^-r.
+func BuildRibbon(keys [][]byte, r uint, h func([]byte) (uint64, uint64)) (*RibbonFilter, error) {
+	if r < 1 || r > 32 {
+		return nil, errors.New("bloom: ribbon fingerprint size must be between 1 and 32 bits")
+	}
+	hashes := make([]uint64, len(keys))
+	for i, k := range keys {
+		hashes[i], _ = h(k)
+	}
+	hashes = unique(hashes)
+
+	f := &RibbonFilter{R: r, H: h}
+	m := float64(len(hashes)) * (1 + (4+float64(r)/4)/ribbonWidth)
+	for attempt := 0; attempt < xorMaxAttempts; attempt++ {
+		if attempt > 0 && attempt%10 == 0 {
+			m *= 1.05 // make more room for keys that keep failing
+		}
+		f.M = uint64(m) + ribbonWidth
+		f.Seed = splitmix64(uint64(attempt))
+		if f.build(hashes) {
+			return f, nil
+		}
+	}
+	return nil, errors.New("bloom: failed to build ribbon filter")
+}
+
+// columnWords is the number of words in a column of the solution, with room
+// for a full window at the last row.
+func (f *RibbonFilter) columnWords() uint64 { return (f.M + 2*ribbonWidth - 1) / 64 }
+
+// row returns the start row, the coefficients and the fingerprint of a hash.
+func (f *RibbonFilter) row(x uint64) (uint64, uint64, uint64) {
+	hash := splitmix64(x ^ f.Seed)
+	hi, _ := bits.Mul64(hash, f.M-ribbonWidth+1)
+	c := splitmix64(hash) | 1
+	return hi, c, (hash ^ hash>>32) & (1<<f.R - 1)
+}
+
+func (f *RibbonFilter) build(hashes []uint64) bool {
+	coeff := make([]uint64, f.M)
+	result := make([]uint64, f.M)
+	for _, x := range hashes {
+		s, c, r := f.row(x)
+		for {
+			if coeff[s] == 0 {
+				coeff[s], result[s] = c, r
+				break
+			}
+			c ^= coeff[s]
+			r ^= result[s]
+			if c == 0 {
+				if r != 0 {
+					return false // inconsistent, try another seed
+				}
+				break // redundant
+			}
+			tz := uint64(bits.TrailingZeros64(c))
+			s += tz
+			c >>= tz
+		}
+	}
+	words := f.columnWords()
+	f.Z = make([]uint64, uint64(f.R)*words)
+	for i := int64(f.M) - 1; i >= 0; i-- {
+		for b := uint(0); b < f.R; b++ {
+			col := f.Z[uint64(b)*words : uint64(b+1)*words]
+			bit := uint64(bits.OnesCount64(coeff[i]&window(col, uint64(i)))) ^ result[i]>>b
+			col[i/64] |= (bit & 1) << (i % 64)
+		}
+	}
+	return true
+}
+
+// window returns the 64 bits of col starting at bit s.
+func window(col []uint64, s uint64) uint64 {
+	w, off := s/64, s%64
+	if off == 0 {
+		return col[w]
+	}
+	return col[w]>>off | col[w+1]<<(64-off)
+}
+
+// Test tests if an entry is in the filter.
+func (f *RibbonFilter) Test(b []byte) bool {
+	x, _ := f.H(b)
+	s, c, r := f.row(x)
+	words := f.columnWords()
+	for i := uint(0); i < f.R; i++ {
+		col := f.Z[uint64(i)*words : uint64(i+1)*words]
+		if uint64(bits.OnesCount64(c&window(col, s)))&1 != r>>i&1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Size returns the size of the filter in bytes.
+func (f *RibbonFilter) Size() int { return 8 * len(f.Z) }
+
+// MarshalBinary encodes a version header, the parameters and the solution of the filter.
+// The hash function is not encoded.
+func (f *RibbonFilter) MarshalBinary() ([]byte, error) {
+	b := appendTyped(make([]byte, 0, typedHeaderSize+17+8*len(f.Z)), typeRibbon)
+	b = binary.LittleEndian.AppendUint64(b, f.Seed)
+	b = binary.LittleEndian.AppendUint64(b, f.M)
+	b = append(b, byte(f.R))
+	for _, z := range f.Z {
+		b = binary.LittleEndian.AppendUint64(b, z)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary, or by its versions before the
+// encoding had a version header.
+// H must be set to the hash function the filter was built with.
+func (f *RibbonFilter) UnmarshalBinary(b []byte) error {
+	b, err := parseTyped(b, typeRibbon)
+	if err != nil {
+		return err
+	}
+	if len(b) < 17 || b[16] < 1 || b[16] > 32 {
+		return errors.New("bloom: invalid ribbon filter encoding")
+	}
+	g := RibbonFilter{
+		Seed: binary.LittleEndian.Uint64(b),
+		M:    binary.LittleEndian.Uint64(b[8:]),
+		R:    uint(b[16]),
+		H:    f.H,
+	}
+	if g.M < ribbonWidth || uint64(len(b)-17) != 8*uint64(g.R)*g.columnWords() {
+		return errors.New("bloom: invalid ribbon filter encoding")
+	}
+	g.Z = make([]uint64, (len(b)-17)/8)
+	for i := range g.Z {
+		g.Z[i] = binary.LittleEndian.Uint64(b[17+8*i:])
+	}
+	*f = g
+	return nil
+}
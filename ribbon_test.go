@@ -0,0 +1,78 @@
+package bloom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestRibbonFilter_Test(t *testing.T) {
+	const n = 1e5
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+	}
+	rf, err := BuildRibbon(keys, 8, doubleSHA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if !rf.Test(k) {
+			t.Fatalf("%s should exist in filter but got false", k)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if rf.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	fpr := float64(fp) / n
+	t.Logf("Size = %d, bits/key = %.2f, FPR = %.4f%%", rf.Size(), float64(8*rf.Size())/n, fpr*100)
+	if fpr > 2.0/256 {
+		t.Fatalf("FPR %.4f%% is well above 2^-8", fpr*100)
+	}
+}
+
+func TestRibbonFilter_MarshalBinary(t *testing.T) {
+	keys := [][]byte{[]byte("hello"), []byte("world"), []byte("hello")}
+	rf, err := BuildRibbon(keys, 16, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := rf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &RibbonFilter{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if !decoded.Test(k) {
+			t.Fatalf("%s should exist in decoded filter but got false", k)
+		}
+	}
+	if err := decoded.UnmarshalBinary(data[:len(data)-8]); err == nil {
+		t.Fatal("Truncated data should fail to decode")
+	}
+}
+
+func TestRibbonFilter_MarshalBinaryGolden(t *testing.T) {
+	// words are encoded little-endian, so every platform must produce the same bytes
+	f, err := BuildRibbon([][]byte{[]byte("hello"), []byte("world")}, 7, doubleSHA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := f.MarshalBinary()
+	if string(data[:typedHeaderSize]) != "BLMV\x01\x03" {
+		t.Fatalf("Encoding starts with %q, want its version header", data[:typedHeaderSize])
+	}
+	// the encoding after the header is that written before the header was added
+	data = data[typedHeaderSize:]
+	const want = "e0e32047d0c772095762c9ffaa9dcdd44313539e6f786f02894b19e0327717c5"
+	if sum := sha256.Sum256(data); len(data) != 185 || hex.EncodeToString(sum[:]) != want {
+		t.Fatalf("Encoding of %d bytes has SHA-256 %x, want 185 bytes with %s", len(data), sum, want)
+	}
+}
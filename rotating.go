@@ -0,0 +1,189 @@
+package bloom
+
+import (
+	"sync"
+	"time"
+)
+
+// Rotating Bloom Filter
+//
+// A rotating filter adds entries to an active classic filter and keeps the
+// previously active one around for Test. Every interval I the previous
+// filter is cleared and becomes the active one, so Test covers entries added
+// within roughly the last one to two intervals. Rotation happens on demand
+// when the filter is used, on every tick once StartRotation is called, or
+// when Rotate is called directly. The filter is safe for concurrent use, but
+// Active and Previous must not be used directly while it is.
+type RotatingFilter struct {
+	Active   *ClassicFilter
+	Previous *ClassicFilter
+	I        time.Duration    // rotation interval, 0 disables rotation by the clock
+	Now      func() time.Time // clock, time.Now by default
+
+	// Ticker starts a ticker for StartRotation and returns its channel and a function that
+	// stops it, using time.NewTicker if nil.
+	Ticker func(d time.Duration) (<-chan time.Time, func())
+	// OnRotate, if set, is called after each rotation with its time, outside the lock.
+	OnRotate func(time.Time)
+
+	mu      sync.Mutex
+	rotated time.Time     // time of the last rotation
+	stop    chan struct{} // closed to stop the rotation goroutine
+	done    chan struct{} // closed when the rotation goroutine exits
+}
+
+// NewRotating creates a rotating Bloom Filter where each window is optimal for n entries and
+// false positive rate of p, rotating every interval.
+func NewRotating(n int, p float64, interval time.Duration, h func([]byte) (uint64, uint64)) *RotatingFilter {
+	f := &RotatingFilter{
+		Active:   newClassic(n, p, h),
+		Previous: newClassic(n, p, h),
+		I:        interval,
+		Now:      time.Now,
+	}
+	f.rotated = f.Now()
+	return f
+}
+
+// Rotate clears the previous filter and makes it the active one.
+func (f *RotatingFilter) Rotate() {
+	f.mu.Lock()
+	at := f.rotate()
+	f.mu.Unlock()
+	f.notify(at)
+}
+
+func (f *RotatingFilter) rotate() time.Time {
+	f.Previous.Reset()
+	f.Active, f.Previous = f.Previous, f.Active
+	f.rotated = f.Now()
+	return f.rotated
+}
+
+// notify calls OnRotate and emits EventRotate if the filter rotated at a non-zero time.
+func (f *RotatingFilter) notify(at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	emit(Event{Kind: EventRotate, Filter: f})
+	if f.OnRotate != nil {
+		f.OnRotate(at)
+	}
+}
+
+// tick rotates the filter if the interval has elapsed since the last rotation,
+// and returns the time it did, or the zero time. It does not rotate while the goroutine of
+// StartRotation does, which would rotate again a window only just rotated in.
+func (f *RotatingFilter) tick() time.Time {
+	if f.I <= 0 || f.stop != nil {
+		return time.Time{}
+	}
+	now := f.Now()
+	elapsed := now.Sub(f.rotated)
+	if elapsed < f.I {
+		return time.Time{}
+	}
+	f.rotate()
+	if elapsed >= 2*f.I {
+		// both windows are out of date, including the one just made previous
+		f.Previous.Reset()
+	}
+	// keep rotations aligned to the interval
+	f.rotated = now.Add(-(elapsed % f.I))
+	return now
+}
+
+func (f *RotatingFilter) Add(b []byte) {
+	f.mu.Lock()
+	at := f.tick()
+	f.Active.Add(b)
+	f.mu.Unlock()
+	f.notify(at)
+}
+
+func (f *RotatingFilter) Test(b []byte) bool {
+	f.mu.Lock()
+	at := f.tick()
+	ok := f.Active.Test(b) || f.Previous.Test(b)
+	f.mu.Unlock()
+	f.notify(at)
+	return ok
+}
+
+// TestAndAdd adds an entry to the active filter and reports whether it was already in
+// either window.
+func (f *RotatingFilter) TestAndAdd(b []byte) bool {
+	f.mu.Lock()
+	at := f.tick()
+	ok := f.Active.TestAndAdd(b) || f.Previous.Test(b)
+	f.mu.Unlock()
+	f.notify(at)
+	return ok
+}
+
+func (f *RotatingFilter) Size() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Active.Size() + f.Previous.Size()
+}
+
+func (f *RotatingFilter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Active.Reset()
+	f.Previous.Reset()
+	f.rotated = f.Now()
+}
+
+// StartRotation starts a goroutine that rotates the filter every interval, so windows are
+// swapped on time even when the filter is idle. It sets I to interval and stops any rotation
+// started before. Until Stop, the filter rotates only on the ticks, not when used.
+func (f *RotatingFilter) StartRotation(interval time.Duration) {
+	f.Stop()
+	ticker := f.Ticker
+	if ticker == nil {
+		ticker = func(d time.Duration) (<-chan time.Time, func()) {
+			t := time.NewTicker(d)
+			return t.C, t.Stop
+		}
+	}
+	f.mu.Lock()
+	f.I = interval
+	f.rotated = f.Now()
+	ticks, stopTicker := ticker(interval)
+	stop, done := make(chan struct{}), make(chan struct{})
+	f.stop, f.done = stop, done
+	f.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		defer stopTicker()
+		for {
+			select {
+			case <-ticks:
+				f.Rotate()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the rotation goroutine started by StartRotation and waits for it to exit.
+// It does nothing if none is running.
+func (f *RotatingFilter) Stop() {
+	f.mu.Lock()
+	stop, done := f.stop, f.done
+	f.stop, f.done = nil, nil
+	f.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Close stops the rotation goroutine, like Stop.
+func (f *RotatingFilter) Close() error {
+	f.Stop()
+	return nil
+}
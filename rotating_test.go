@@ -0,0 +1,93 @@
+package bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRotatingFilter_Rotate(t *testing.T) {
+	rf := NewRotating(1e4, 1e-4, 0, doubleFNV)
+	buf := []byte("testing")
+	rf.Add(buf)
+	rf.Rotate()
+	if !rf.Test(buf) {
+		t.Fatal("Entry should survive a single rotation")
+	}
+	rf.Rotate()
+	if rf.Test(buf) {
+		t.Fatal("Entry should be gone after two rotations")
+	}
+}
+
+func TestRotatingFilter_Clock(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	rf := NewRotating(1e4, 1e-4, time.Minute, doubleFNV)
+	rf.Now = clock.Now
+	rf.Reset()
+
+	a, b := []byte("a"), []byte("b")
+	rf.Add(a)
+	clock.Advance(time.Minute + time.Second)
+	rf.Add(b)
+	if !rf.Test(a) || !rf.Test(b) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	clock.Advance(time.Minute)
+	if rf.Test(a) {
+		t.Fatal("Entry two windows old should be gone")
+	}
+	if !rf.Test(b) {
+		t.Fatal("Entry of the previous window should exist in filter but got false")
+	}
+	clock.Advance(5 * time.Minute)
+	if rf.Test(b) {
+		t.Fatal("All entries should be gone after a long pause")
+	}
+	rf.Add(a)
+	clock.Advance(2 * time.Minute)
+	if rf.Test(a) {
+		t.Fatal("An entry of the active window should be gone after two intervals")
+	}
+}
+
+func TestRotatingFilter_StartRotation(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	ticks := make(chan time.Time)
+	stopped := false
+	rotations := make(chan time.Time, 4)
+	rf := NewRotating(1e4, 1e-4, 0, doubleFNV)
+	rf.Now = clock.Now
+	rf.Ticker = func(d time.Duration) (<-chan time.Time, func()) {
+		if d != time.Minute {
+			t.Errorf("Ticker interval = %v, want %v", d, time.Minute)
+		}
+		return ticks, func() { stopped = true }
+	}
+	rf.OnRotate = func(at time.Time) { rotations <- at }
+	rf.StartRotation(time.Minute)
+
+	a := []byte("a")
+	rf.Add(a)
+	// a late tick leaves the rotation to the ticker rather than rotating when used
+	clock.Advance(90 * time.Second)
+	if !rf.Test(a) || len(rotations) != 0 {
+		t.Fatal("The filter should not rotate by the clock while StartRotation does")
+	}
+	for i := 0; i < 2; i++ {
+		ticks <- clock.t
+		if at := <-rotations; !at.Equal(clock.t) {
+			t.Fatalf("Rotation time = %v, want %v", at, clock.t)
+		}
+	}
+	if rf.Test(a) {
+		t.Fatal("Entry should be gone after two ticks")
+	}
+
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !stopped {
+		t.Fatal("Close should stop the ticker")
+	}
+	rf.Stop() // stopping again is fine
+}
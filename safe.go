@@ -0,0 +1,70 @@
+package bloom
+
+import "sync"
+
+// SafeFilter is a Filter that is safe for concurrent use by multiple goroutines.
+// Tests of the wrapped filter run concurrently with each other, while adds
+// and resets are exclusive. Filters whose Test changes them must be wrapped
+// with WrapSafeExclusive.
+type SafeFilter struct {
+	mu        sync.RWMutex
+	f         Filter
+	exclusive bool
+}
+
+// NewSafe creates a concurrent classic Bloom Filter that is optimal for n entries and false positive rate of p.
+func NewSafe(n int, p float64, h func([]byte) (uint64, uint64)) *SafeFilter {
+	return WrapSafe(New(n, p, h))
+}
+
+// WrapSafe makes f safe for concurrent use. f must not be used directly afterwards.
+func WrapSafe(f Filter) *SafeFilter { return &SafeFilter{f: f} }
+
+// WrapSafeExclusive makes f safe for concurrent use, running Test exclusively too.
+func WrapSafeExclusive(f Filter) *SafeFilter { return &SafeFilter{f: f, exclusive: true} }
+
+func (s *SafeFilter) Add(b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.f.Add(b)
+}
+
+func (s *SafeFilter) Test(b []byte) bool {
+	if s.exclusive {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	return s.f.Test(b)
+}
+
+// TestAndAdd adds an entry and reports whether it was already in the filter, atomically with
+// respect to all other operations, so concurrent callers adding the same entry see it as new
+// exactly once.
+func (s *SafeFilter) TestAndAdd(b []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return TestAndAdd(s.f, b)
+}
+
+func (s *SafeFilter) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.f.Size()
+}
+
+func (s *SafeFilter) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.f.Reset()
+}
+
+// Do calls fn with the wrapped filter while holding the lock exclusively,
+// for operations Filter does not cover such as encoding or merging.
+func (s *SafeFilter) Do(fn func(Filter)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.f)
+}
@@ -0,0 +1,76 @@
+package bloom
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSafeFilter(t *testing.T) {
+	for _, sf := range []*SafeFilter{
+		NewSafe(1e4, 1e-3, doubleSHA),
+		WrapSafeExclusive(NewRotating(1e4, 1e-3, time.Hour, doubleSHA)),
+	} {
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					key := []byte(strconv.Itoa(g*1000 + i))
+					sf.Add(key)
+					if !sf.Test(key) {
+						t.Error("Should exist in filter but got false")
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		sf.Do(func(f Filter) {
+			if !f.Test([]byte("0")) {
+				t.Error("Do should see the wrapped filter")
+			}
+		})
+		sf.Reset()
+		if sf.Test([]byte("0")) {
+			t.Fatal("Should not exist after reset but got true")
+		}
+	}
+}
+
+func TestSafeFilter_TestAndAdd(t *testing.T) {
+	for _, f := range []interface{ TestAndAdd([]byte) bool }{
+		NewSafe(1e4, 1e-3, doubleSHA),
+		WrapSafe(NewCounting(1e4, 1e-3, doubleSHA)),
+		NewSharded(1e4, 1e-3, 4, doubleSHA),
+	} {
+		// every key is added by all goroutines but must be new to only one of them
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		seen := make(map[int]int)
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					if !f.TestAndAdd([]byte(strconv.Itoa(i))) {
+						mu.Lock()
+						seen[i]++
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		for i, n := range seen {
+			if n > 1 {
+				t.Fatalf("%T: key %d was new to %d goroutines", f, i, n)
+			}
+		}
+		if len(seen) < 990 {
+			t.Fatalf("%T: only %d of 1000 keys were new", f, len(seen))
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package bloom
+
+import "math"
+
+// SaturationPolicy is what a SaturatingFilter does when its active filter saturates.
+type SaturationPolicy int
+
+const (
+	// RotateOnSaturation starts a new filter of the same size and keeps the saturated one
+	// for Test until the new one saturates in turn, for windowed workloads such as
+	// deduplicating recent events: the filter forgets entries after one to two generations.
+	RotateOnSaturation SaturationPolicy = iota + 1
+
+	// GrowOnSaturation chains a new filter of twice the capacity and a tighter false
+	// positive rate, as a ScalableFilter does, so the filter keeps every entry and its
+	// compound false positive rate stays below the one it was created with.
+	GrowOnSaturation
+)
+
+// Saturating Bloom Filter
+//
+// A saturating filter adds entries to an active classic filter monitored for its fill
+// ratio, and when the fill ratio reaches Fill either rotates it out or chains a new larger
+// filter after it, as Policy says, so a long-running service does not end up with a filter
+// whose bits are nearly all set and that tests positive for nearly everything. Unlike a
+// ScalableFilter, which counts entries, it measures saturation from the bits themselves,
+// so it saturates at the false positive rate Fill gives whatever the entries are.
+type SaturatingFilter struct {
+	Policy SaturationPolicy
+	Fill   float64 // fill ratio at which the active filter saturates
+	H      func([]byte) (uint64, uint64)
+
+	// OnSaturate, if set, is called with the saturation of the active filter after the
+	// filter rotated or grew. It must not call the filter.
+	OnSaturate func(Saturation)
+
+	n         int     // capacity of the first filter
+	p         float64 // target false positive rate
+	filters   []*MonitoredFilter
+	saturated bool // the active filter crossed Fill
+	last      Saturation
+}
+
+// NewSaturating creates a saturating Bloom Filter whose first filter is optimal for n
+// entries and false positive rate of p, and that applies policy once its active filter
+// has fill of its bits set. A filter optimal for its capacity has half of its bits set
+// once it holds it, so a fill of 0.5 saturates it at about its capacity.
+func NewSaturating(n int, p float64, policy SaturationPolicy, fill float64, h func([]byte) (uint64, uint64)) *SaturatingFilter {
+	if policy != RotateOnSaturation && policy != GrowOnSaturation {
+		panic("bloom: unknown saturation policy")
+	}
+	f := &SaturatingFilter{Policy: policy, Fill: fill, H: h, n: n, p: p}
+	f.Reset()
+	return f
+}
+
+// next appends a new active filter: of the first size when rotating, and of twice the
+// capacity of the last one and a rate tightened by 0.9 when growing.
+func (f *SaturatingFilter) next() {
+	n, p := f.n, f.p
+	if f.Policy == GrowOnSaturation {
+		i := len(f.filters)
+		n <<= i
+		p = f.p * (1 - 0.9) * math.Pow(0.9, float64(i))
+	}
+	m := Monitor(newClassic(n, p, f.H), Thresholds{FillRatio: f.Fill, Notify: func(s Saturation) {
+		f.saturated, f.last = true, s
+	}})
+	f.filters = append(f.filters, m)
+}
+
+// saturate applies the policy if the active filter saturated at its last add.
+func (f *SaturatingFilter) saturate() {
+	if !f.saturated {
+		return
+	}
+	f.saturated = false
+	if f.Policy == RotateOnSaturation {
+		// drop the previous filter, keeping the saturated one
+		f.filters = append(f.filters[:0], f.filters[len(f.filters)-1])
+	}
+	f.next()
+	if f.Policy == RotateOnSaturation {
+		emit(Event{Kind: EventRotate, Filter: f})
+	}
+	if f.OnSaturate != nil {
+		f.OnSaturate(f.last)
+	}
+}
+
+func (f *SaturatingFilter) active() *MonitoredFilter { return f.filters[len(f.filters)-1] }
+
+func (f *SaturatingFilter) Add(b []byte) {
+	f.active().Add(b)
+	f.saturate()
+}
+
+// AddMany adds keys to the active filter, rotating or growing at most once.
+func (f *SaturatingFilter) AddMany(keys [][]byte) {
+	f.active().AddMany(keys)
+	f.saturate()
+}
+
+func (f *SaturatingFilter) Test(b []byte) bool {
+	for i := len(f.filters) - 1; i >= 0; i-- {
+		if f.filters[i].Test(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAndAdd adds an entry to the active filter and reports whether it was already in any
+// of the filters.
+func (f *SaturatingFilter) TestAndAdd(b []byte) bool {
+	found := f.active().TestAndAdd(b)
+	for i := len(f.filters) - 2; !found && i >= 0; i-- {
+		found = f.filters[i].Test(b)
+	}
+	f.saturate()
+	return found
+}
+
+func (f *SaturatingFilter) Size() int {
+	size := 0
+	for _, sub := range f.filters {
+		size += sub.Size()
+	}
+	return size
+}
+
+// Reset drops all but a fresh first filter.
+func (f *SaturatingFilter) Reset() {
+	clear(f.filters)
+	f.filters = f.filters[:0]
+	f.saturated = false
+	f.next()
+}
+
+// Filters returns the number of filters: 1 or 2 when rotating, and the number of filters
+// chained so far when growing.
+func (f *SaturatingFilter) Filters() int { return len(f.filters) }
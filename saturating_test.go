@@ -0,0 +1,87 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSaturatingFilter_Rotate(t *testing.T) {
+	events := recordEvents(t)
+	var saturations []Saturation
+	f := NewSaturating(1000, 0.01, RotateOnSaturation, 0.5, doubleFNV)
+	f.OnSaturate = func(s Saturation) { saturations = append(saturations, s) }
+	for i := range 5000 {
+		f.Add([]byte(fmt.Sprint(i)))
+	}
+	if n := len(saturations); n < 3 || n > 6 {
+		t.Fatalf("%d rotations of 5000 entries in filters of 1000", n)
+	}
+	if f.Filters() != 2 || f.Size() != 2*newClassic(1000, 0.01, doubleFNV).Size() {
+		t.Fatalf("A rotating filter should keep 2 filters of its size but has %d of %d bytes", f.Filters(), f.Size())
+	}
+	if !f.Test([]byte("4999")) || f.Test([]byte("0")) && f.Test([]byte("1")) && f.Test([]byte("2")) {
+		t.Error("The filter should keep the recent entries and forget the old ones")
+	}
+	for _, s := range saturations {
+		if s.FillRatio < 0.5 || s.Metric != "fill_ratio" {
+			t.Errorf("Saturation = %+v", s)
+		}
+	}
+	var rotations int
+	for _, e := range events() {
+		if e.Kind == EventRotate && e.Filter == f {
+			rotations++
+		}
+	}
+	if rotations != len(saturations) {
+		t.Errorf("%d rotation events of %d rotations", rotations, len(saturations))
+	}
+}
+
+func TestSaturatingFilter_Grow(t *testing.T) {
+	f := NewSaturating(100, 0.01, GrowOnSaturation, 0.5, doubleSHA)
+	keys := make([][]byte, 5000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+	}
+	for _, key := range keys {
+		if f.TestAndAdd(key) && f.Filters() == 1 {
+			t.Fatalf("%q should be new", key)
+		}
+	}
+	if f.Filters() < 5 {
+		t.Fatalf("5000 entries should grow a filter of 100 at least 5 times but got %d filters", f.Filters())
+	}
+	for _, key := range keys {
+		if !f.Test(key) {
+			t.Fatalf("%q should be in the filter", key)
+		}
+	}
+	var fp int
+	for i := range 10000 {
+		if f.Test([]byte(fmt.Sprint("absent", i))) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / 10000; rate > 0.02 {
+		t.Errorf("False positive rate is %v, want about 0.01", rate)
+	}
+
+	f.Reset()
+	if f.Filters() != 1 || f.Test(keys[0]) {
+		t.Error("Reset should leave a fresh first filter")
+	}
+	f.AddMany(keys)
+	if f.Filters() != 2 {
+		t.Errorf("AddMany should grow at most once but got %d filters", f.Filters())
+	}
+}
+
+func TestNewSaturating_Policy(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("An unknown policy should panic")
+		}
+	}()
+	NewSaturating(100, 0.01, 0, 0.5, doubleFNV)
+}
@@ -0,0 +1,161 @@
+package bloom
+
+import "math"
+
+// Thresholds configure when a MonitoredFilter reports that it is saturating.
+type Thresholds struct {
+	FillRatio float64 // report once this fraction of the bits is set, if not 0
+	FPR       float64 // report once the estimated false positive rate reaches it, if not 0
+
+	// Every is the number of inserts between measures of the fill ratio once the insert
+	// counter says a threshold may be near, a hundredth of the capacity if 0.
+	Every int
+
+	// Notify, if not nil, is called with each threshold crossed, after the add that
+	// crossed it, from that add. It must not call the filter.
+	Notify func(Saturation)
+
+	// C, if not nil, is sent each threshold crossed without blocking: it is dropped if
+	// the channel is full, so C should be buffered.
+	C chan<- Saturation
+}
+
+// Saturation reports a threshold of a MonitoredFilter crossed.
+type Saturation struct {
+	Metric    string  // "fill_ratio" or "fpr", the threshold crossed
+	Threshold float64 // value of that threshold
+	FillRatio float64 // fraction of the bits set
+	FPR       float64 // false positive rate estimated from the fill ratio
+	Inserts   uint64  // adds since the filter was monitored or last reset
+}
+
+// MonitoredFilter is a classic filter that reports when its fill ratio or estimated false
+// positive rate crosses a threshold, so a service can rebuild it larger before its
+// answers degrade. Measuring the fill ratio counts the bits, so it is measured only when
+// the number of inserts says a threshold may have been crossed: the thresholds cost a
+// counter per add until then. Each threshold reports once, until Reset.
+//
+// Like a ClassicFilter, a MonitoredFilter is not safe for concurrent use; wrap it in a
+// SafeFilter, as NewWithOptions does with WithConcurrencySafe.
+type MonitoredFilter struct {
+	f *ClassicFilter
+	t Thresholds
+
+	inserts uint64
+	next    uint64 // inserts at which to measure, or 0 once every threshold is crossed
+	fill    bool   // the fill ratio threshold is armed
+	fpr     bool   // the false positive rate threshold is armed
+}
+
+// Monitor returns f reporting as t says when it crosses the thresholds of t. f must not be
+// used directly afterwards. The fill ratio of f is measured once, so a filter that holds
+// entries already reports the thresholds it is past at its first add.
+func Monitor(f *ClassicFilter, t Thresholds) *MonitoredFilter {
+	if t.Every <= 0 {
+		t.Every = max(1, f.Capacity()/100)
+	}
+	m := &MonitoredFilter{f: f, t: t}
+	m.arm()
+	return m
+}
+
+// arm arms the thresholds and sets when to measure the fill ratio first.
+func (m *MonitoredFilter) arm() {
+	m.inserts = 0
+	m.fill, m.fpr = m.t.FillRatio > 0, m.t.FPR > 0
+	m.next = m.schedule(m.f.FillRatio())
+}
+
+// schedule returns the inserts at which to measure the fill ratio next, given its value
+// now: once the armed threshold of the lowest fill ratio is expected to be reached, if
+// each insert is a new entry, and no sooner than Every inserts from now. Inserts of
+// entries already in the filter only make it measure sooner than needed.
+func (m *MonitoredFilter) schedule(fill float64) uint64 {
+	target := 2.0
+	if m.fill {
+		target = m.t.FillRatio
+	}
+	if m.fpr {
+		target = min(target, math.Pow(m.t.FPR, 1/float64(m.f.K)))
+	}
+	if target > 1 {
+		return 0
+	}
+	if target >= 1 {
+		target = math.Nextafter(1, 0)
+	}
+	// a filter of m bits fills to 1 - exp(-kn/m) with n entries
+	bits, k := float64(m.f.BitCount()), float64(m.f.K)
+	need := bits / k * (math.Log1p(-fill) - math.Log1p(-target))
+	if m.inserts == 0 && need <= 0 {
+		return 1 // report the thresholds of a filter past them at its first add
+	}
+	return m.inserts + max(uint64(m.t.Every), uint64(max(need, 0)))
+}
+
+// count counts n inserts and measures the fill ratio if it is due.
+func (m *MonitoredFilter) count(n int) {
+	m.inserts += uint64(n)
+	if m.next == 0 || m.inserts < m.next {
+		return
+	}
+	fill := m.f.FillRatio()
+	fpr := math.Pow(fill, float64(m.f.K))
+	if m.fill && fill >= m.t.FillRatio {
+		m.fill = false
+		m.report(Saturation{"fill_ratio", m.t.FillRatio, fill, fpr, m.inserts})
+	}
+	if m.fpr && fpr >= m.t.FPR {
+		m.fpr = false
+		m.report(Saturation{"fpr", m.t.FPR, fill, fpr, m.inserts})
+	}
+	m.next = m.schedule(fill)
+}
+
+func (m *MonitoredFilter) report(s Saturation) {
+	emit(Event{Kind: EventSaturate, Filter: m})
+	if m.t.Notify != nil {
+		m.t.Notify(s)
+	}
+	if m.t.C != nil {
+		select {
+		case m.t.C <- s:
+		default:
+		}
+	}
+}
+
+// Unwrap returns the monitored filter.
+func (m *MonitoredFilter) Unwrap() Filter { return m.f }
+
+func (m *MonitoredFilter) Add(b []byte) {
+	m.f.Add(b)
+	m.count(1)
+}
+
+// AddMany adds keys like ClassicFilter.AddMany, measuring the fill ratio at most once.
+func (m *MonitoredFilter) AddMany(keys [][]byte) {
+	m.f.AddMany(keys)
+	m.count(len(keys))
+}
+
+func (m *MonitoredFilter) Test(b []byte) bool { return m.f.Test(b) }
+
+// TestMany tests keys like ClassicFilter.TestMany.
+func (m *MonitoredFilter) TestMany(keys [][]byte) []bool { return m.f.TestMany(keys) }
+
+func (m *MonitoredFilter) TestAndAdd(b []byte) bool {
+	found := m.f.TestAndAdd(b)
+	if !found {
+		m.count(1)
+	}
+	return found
+}
+
+func (m *MonitoredFilter) Size() int { return m.f.Size() }
+
+// Reset empties the filter and arms its thresholds again.
+func (m *MonitoredFilter) Reset() {
+	m.f.Reset()
+	m.arm()
+}
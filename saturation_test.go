@@ -0,0 +1,97 @@
+package bloom
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestMonitoredFilter(t *testing.T) {
+	events := recordEvents(t)
+	c := make(chan Saturation, 1)
+	var notified []Saturation
+	cf := NewWithBits(1<<14, 4, doubleFNV)
+	mf := Monitor(cf, Thresholds{FillRatio: 0.5, FPR: 0.1, Every: 10, C: c, Notify: func(s Saturation) {
+		notified = append(notified, s)
+	}})
+	// the 10 inserts between measures set at most 40 bits once a threshold is near
+	const late = 40.0 / (1 << 14)
+	for i := range 6000 {
+		mf.Add([]byte(fmt.Sprint(i)))
+	}
+	if len(notified) != 2 {
+		t.Fatalf("Notify = %+v, want both thresholds", notified)
+	}
+	fill, fpr := notified[0], notified[1]
+	if fill.Metric != "fill_ratio" || fill.FillRatio < 0.5 || fill.FillRatio > 0.5+late {
+		t.Errorf("Fill ratio threshold = %+v, want it reported once crossed", fill)
+	}
+	fprFill := math.Pow(0.1, 0.25)
+	if fpr.Metric != "fpr" || fpr.Threshold != 0.1 || fpr.FPR < 0.1 || fpr.FillRatio > fprFill+late {
+		t.Errorf("FPR threshold = %+v, want it reported once crossed", fpr)
+	}
+	if s := <-c; s != fill {
+		t.Errorf("C = %+v, want %+v", s, fill)
+	}
+	select {
+	case s := <-c:
+		t.Errorf("C should drop the threshold of a full channel but got %+v", s)
+	default:
+	}
+	if got := events(); len(got) != 2 || got[0].Kind != EventSaturate || got[0].Filter != mf {
+		t.Errorf("Events = %+v", got)
+	}
+
+	mf.Reset()
+	if mf.Size() != cf.Size() || cf.FillRatio() != 0 {
+		t.Fatal("Reset should empty the filter")
+	}
+	for i := range 6000 {
+		mf.TestAndAdd([]byte(fmt.Sprint(i)))
+	}
+	if len(notified) != 4 || notified[2].Metric != "fill_ratio" || notified[3].Metric != "fpr" {
+		t.Errorf("Reset should arm the thresholds again but got %+v", notified[2:])
+	}
+	if StatsOf(mf).BitsSet == 0 || !mf.Test([]byte("1")) {
+		t.Error("The monitored filter should have its entries")
+	}
+}
+
+// TestMonitoredFilter_Measures checks that the fill ratio is measured rarely until a
+// threshold is near.
+func TestMonitoredFilter_Measures(t *testing.T) {
+	mf := Monitor(NewWithBits(1<<16, 4, doubleFNV), Thresholds{FillRatio: 0.5, Every: 100})
+	var measures int
+	for i := range 20000 {
+		next := mf.next
+		mf.Add([]byte(fmt.Sprint(i)))
+		if mf.next != next {
+			measures++
+		}
+	}
+	if measures > 5 {
+		t.Errorf("The fill ratio was measured %d times", measures)
+	}
+	if mf.fill || mf.next != 0 {
+		t.Error("The threshold should be crossed and disarmed")
+	}
+}
+
+func TestMonitoredFilter_Full(t *testing.T) {
+	cf := NewWithBits(1024, 3, doubleFNV)
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+	}
+	cf.AddMany(keys)
+	var got []Saturation
+	mf := Monitor(cf, Thresholds{FillRatio: 0.9, Notify: func(s Saturation) { got = append(got, s) }})
+	mf.AddMany(keys[:1])
+	if len(got) != 1 || got[0].Inserts != 1 {
+		t.Errorf("A filter past its threshold should report it at its first add but got %+v", got)
+	}
+	mf.AddMany(keys)
+	if len(got) != 1 {
+		t.Errorf("A threshold should report once but got %+v", got)
+	}
+}
@@ -0,0 +1,83 @@
+package bloom
+
+import "math"
+
+// Scalable Bloom Filter
+//
+// A scalable filter starts with a single classic filter sized for n entries
+// and appends a new, larger and tighter filter every time the last one is
+// full, so the number of entries does not have to be known up front while
+// the compound false positive rate stays bounded by P.
+// See Almeida et al., "Scalable Bloom Filters" (2007).
+type ScalableFilter struct {
+	P float64 // target false positive rate of the whole filter
+	R float64 // tightening ratio of the false positive rate of each new filter
+	S int     // growth factor of the capacity of each new filter
+	H func([]byte) (uint64, uint64)
+
+	n       int // initial capacity
+	filters []*ClassicFilter
+	cap     int // capacity of the last filter
+	count   int // entries in the last filter
+}
+
+// NewScalable creates a scalable Bloom Filter that initially holds n entries and whose
+// false positive rate stays below p as it grows.
+func NewScalable(n int, p float64, h func([]byte) (uint64, uint64)) *ScalableFilter {
+	f := &ScalableFilter{P: p, R: 0.9, S: 2, H: h, n: n}
+	f.Reset()
+	return f
+}
+
+func (f *ScalableFilter) grow() {
+	i := len(f.filters)
+	if i == 0 {
+		f.cap = f.n
+	} else {
+		f.cap *= f.S
+	}
+	p := f.P * (1 - f.R) * math.Pow(f.R, float64(i))
+	f.filters = append(f.filters, newClassic(f.cap, p, f.H))
+	f.count = 0
+}
+
+// Add adds an entry to the last filter, appending a new one when it is full.
+// Entries that already test positive are not added again so they do not
+// use up capacity.
+func (f *ScalableFilter) Add(b []byte) {
+	if f.Test(b) {
+		return
+	}
+	if f.count >= f.cap {
+		emit(Event{Kind: EventSaturate, Filter: f})
+		f.grow()
+	}
+	f.filters[len(f.filters)-1].Add(b)
+	f.count++
+}
+
+func (f *ScalableFilter) Test(b []byte) bool {
+	for i := len(f.filters) - 1; i >= 0; i-- {
+		if f.filters[i].Test(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *ScalableFilter) Size() int {
+	size := 0
+	for _, sub := range f.filters {
+		size += sub.Size()
+	}
+	return size
+}
+
+// Reset drops all but a fresh initial filter.
+func (f *ScalableFilter) Reset() {
+	f.filters = f.filters[:0]
+	f.grow()
+}
+
+// Filters returns the number of sub filters.
+func (f *ScalableFilter) Filters() int { return len(f.filters) }
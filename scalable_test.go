@@ -0,0 +1,50 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScalableFilter_Grow(t *testing.T) {
+	const (
+		n         = 1e5
+		expectFPR = 1e-3
+	)
+	sf := NewScalable(1e3, expectFPR, doubleSHA)
+	for i := 0; i < n; i++ {
+		sf.Add([]byte(fmt.Sprint(i)))
+	}
+	if sf.Filters() < 2 {
+		t.Fatalf("Should have grown beyond a single filter but has %d", sf.Filters())
+	}
+	for i := 0; i < n; i++ {
+		if !sf.Test([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if sf.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	fpr := float64(fp) / n
+	t.Logf("Filters = %d, Size = %d, FP = %d, FPR = %.4f%%", sf.Filters(), sf.Size(), fp, fpr*100)
+	if fpr > 2*expectFPR {
+		t.Fatalf("FPR %.4f%% is well above the target %.4f%%", fpr*100, expectFPR*100)
+	}
+}
+
+func TestScalableFilter_Reset(t *testing.T) {
+	sf := NewScalable(10, 1e-2, doubleFNV)
+	for i := 0; i < 100; i++ {
+		sf.Add([]byte(fmt.Sprint(i)))
+	}
+	sf.Reset()
+	if sf.Filters() != 1 {
+		t.Fatalf("Reset should leave a single filter but got %d", sf.Filters())
+	}
+	if sf.Test([]byte("1")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
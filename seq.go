@@ -0,0 +1,22 @@
+package bloom
+
+import "iter"
+
+// AddSeq adds every entry of seq to f.
+func AddSeq(f Filter, seq iter.Seq[[]byte]) {
+	for b := range seq {
+		f.Add(b)
+	}
+}
+
+// TestSeq returns a sequence of the entries of seq and whether each is in f, tested as
+// the sequence is ranged over.
+func TestSeq(f Filter, seq iter.Seq[[]byte]) iter.Seq2[[]byte, bool] {
+	return func(yield func([]byte, bool) bool) {
+		for b := range seq {
+			if !yield(b, f.Test(b)) {
+				return
+			}
+		}
+	}
+}
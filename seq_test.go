@@ -0,0 +1,25 @@
+package bloom
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAddSeq(t *testing.T) {
+	bf := New(1e3, 1e-3, doubleFNV)
+	AddSeq(bf, slices.Values([][]byte{[]byte("hello"), []byte("world")}))
+	keys := [][]byte{[]byte("hello"), []byte("nope"), []byte("world")}
+	var found []bool
+	for b, ok := range TestSeq(bf, slices.Values(keys)) {
+		if string(b) != string(keys[len(found)]) {
+			t.Fatalf("TestSeq should yield %s but got %s", keys[len(found)], b)
+		}
+		found = append(found, ok)
+	}
+	if !slices.Equal(found, []bool{true, false, true}) {
+		t.Fatalf("TestSeq should report membership but got %v", found)
+	}
+	for range TestSeq(bf, slices.Values(keys)) {
+		break
+	}
+}
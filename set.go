@@ -0,0 +1,62 @@
+package bloom
+
+import "sync/atomic"
+
+// Set is a set of byte strings backed by a Filter, counting inserts and lookups. Members
+// are always found, and other strings are found at the false positive rate of the filter.
+// The counters are safe for concurrent use, and so is the set if the filter is.
+type Set struct {
+	F Filter
+
+	inserts   atomic.Uint64
+	lookups   atomic.Uint64
+	positives atomic.Uint64
+}
+
+// SetStats are the counters of a Set.
+type SetStats struct {
+	Inserts   uint64 // calls to Insert
+	Lookups   uint64 // calls to Contains
+	Positives uint64 // calls to Contains that returned true
+}
+
+// NewSet returns a set backed by f.
+func NewSet(f Filter) *Set { return &Set{F: f} }
+
+// Insert adds b to the set.
+func (s *Set) Insert(b []byte) {
+	s.F.Add(b)
+	s.inserts.Add(1)
+}
+
+// Contains reports whether b may be in the set.
+func (s *Set) Contains(b []byte) bool {
+	ok := s.F.Test(b)
+	s.lookups.Add(1)
+	if ok {
+		s.positives.Add(1)
+	}
+	return ok
+}
+
+// Len estimates the number of distinct members with ApproximateCount if the filter is an
+// ApproximateCounter, or else returns the number of inserts, duplicates included.
+func (s *Set) Len() uint64 {
+	if c, ok := s.F.(ApproximateCounter); ok {
+		return c.ApproximateCount()
+	}
+	return s.inserts.Load()
+}
+
+// Stats returns the counters of the set.
+func (s *Set) Stats() SetStats {
+	return SetStats{Inserts: s.inserts.Load(), Lookups: s.lookups.Load(), Positives: s.positives.Load()}
+}
+
+// Reset empties the set and its filter and zeroes the counters.
+func (s *Set) Reset() {
+	s.F.Reset()
+	s.inserts.Store(0)
+	s.lookups.Store(0)
+	s.positives.Store(0)
+}
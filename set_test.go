@@ -0,0 +1,34 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet(New(1e3, 1e-3, doubleSHA))
+	for i := 0; i < 100; i++ {
+		s.Insert([]byte(strconv.Itoa(i)))
+		s.Insert([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < 200; i++ {
+		s.Contains([]byte(strconv.Itoa(i)))
+	}
+	st := s.Stats()
+	if st.Inserts != 200 || st.Lookups != 200 || st.Positives < 100 || st.Positives > 105 {
+		t.Fatalf("Counters should be 200 inserts and lookups and about 100 positives but got %+v", st)
+	}
+	if n := s.Len(); n < 95 || n > 105 {
+		t.Fatalf("Set of 100 members should have about 100 but got %d", n)
+	}
+	counting := NewSet(NewCounting(1e3, 1e-3, doubleSHA))
+	counting.Insert([]byte("a"))
+	counting.Insert([]byte("a"))
+	if n := counting.Len(); n != 2 {
+		t.Fatalf("Set without an ApproximateCounter should count 2 inserts but got %d", n)
+	}
+	s.Reset()
+	if s.Stats() != (SetStats{}) || s.Contains([]byte("1")) {
+		t.Fatal("Reset set should be empty")
+	}
+}
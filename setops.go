@@ -0,0 +1,166 @@
+package bloom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrIncompatibleSize is returned when combining filters with different numbers of bits or hashes.
+// It wraps ErrIncompatible.
+var ErrIncompatibleSize = fmt.Errorf("%w: different sizes", ErrIncompatible)
+
+// ErrIncompatibleHash is returned when combining filters with different hash functions, probe
+// schemes or seeds. It wraps ErrIncompatible.
+var ErrIncompatibleHash = fmt.Errorf("%w: different hashes", ErrIncompatible)
+
+// Identity is what classic filters must share for their bits to mean the same entries:
+// the name of their hash function, the seed, the probe scheme and the geometry. Filters
+// whose hash functions are not named must also have the same function; see Union.
+type Identity struct {
+	Hash  string // name of the hash function, or "" if it is not known
+	Seed  uint64
+	Probe Probe
+	M     uint64 // number of bits
+	K     int    // number of hashes
+}
+
+// Identity returns the identity of the filter, which Union, Intersect, Merge and Equal
+// check before combining or comparing bits.
+func (f *ClassicFilter) Identity() Identity {
+	name, _ := f.hashName()
+	return Identity{Hash: name, Seed: f.Seed, Probe: f.Probe, M: f.BitCount(), K: f.K}
+}
+
+func (id Identity) String() string {
+	hash := id.Hash
+	if hash == "" {
+		hash = "unnamed"
+	}
+	return fmt.Sprintf("%s hash, seed %d, %v, %d bits, %d hashes", hash, id.Seed, id.Probe, id.M, id.K)
+}
+
+// IncompatibleError is returned when combining filters whose identities or hash functions
+// differ. It wraps ErrIncompatibleSize or ErrIncompatibleHash, and through them
+// ErrIncompatible, so callers can test for those with errors.Is.
+type IncompatibleError struct {
+	A, B Identity // identities of the filters
+	Err  error    // ErrIncompatibleSize or ErrIncompatibleHash
+}
+
+func (e *IncompatibleError) Error() string { return fmt.Sprintf("%v: %v and %v", e.Err, e.A, e.B) }
+
+func (e *IncompatibleError) Unwrap() error { return e.Err }
+
+// ErrInvalidFold is returned by Fold when the filter cannot be folded by the factor.
+var ErrInvalidFold = errors.New("bloom: filter size is not a multiple of the fold factor")
+
+// Fold returns a new filter with 1/factor of the bits of f, where each bit is set if any
+// of the factor bits of f that fold onto it is, so it holds the same entries. Offsets are
+// reduced modulo the number of bits, and that of the folded filter divides that of f, so
+// entries land on the folded bits whatever the probe scheme. The false positive rate grows
+// to that of a filter of as many entries with fewer bits, so an oversized filter can be
+// folded to fit the entries it ended up holding. The number of bits of f must be a
+// multiple of 8·factor, so the folded bits are whole bytes, or ErrInvalidFold is returned.
+func (f *ClassicFilter) Fold(factor int) (*ClassicFilter, error) {
+	m := f.BitCount()
+	if factor < 1 || m%(8*uint64(factor)) != 0 {
+		return nil, ErrInvalidFold
+	}
+	size := len(f.B) / factor
+	c := *f
+	c.B, c.m, c.n, c.p, c.readOnly = append([]byte(nil), f.B[:size]...), m/uint64(factor), 0, 0, false
+	for off := size; off < len(f.B); off += size {
+		orBits(c.B, f.B[off:off+size])
+	}
+	return &c, nil
+}
+
+// Union returns a new classic filter holding the entries of all of filters, such as the
+// filters of shards built in parallel. The filters must have the same size and K, or else
+// an IncompatibleError wrapping ErrIncompatibleSize is returned, and the same hash
+// function, probe scheme and seed, or else one wrapping ErrIncompatibleHash is returned.
+// Hash functions are the same if they are registered or given as a Hasher under the same
+// name, or else if they are the same function value: closures such as those of
+// XXHashSeeded are the same only if they come from one call, since their seeds cannot be
+// compared, so filters of separately made closures should name them with NewHasher. The
+// result has the false positive rate of a filter of all the entries added to one filter.
+func Union(filters ...*ClassicFilter) (*ClassicFilter, error) {
+	return combine(filters, orBits)
+}
+
+// Intersect returns a new classic filter of the bits set in all of filters, which must be
+// compatible as for Union. Every entry added to all of the filters is in the result, so it
+// has no false negatives for the intersection of their sets, and an entry in none of them
+// is a false positive of the result only if it is one of every filter.
+//
+// The result is not the filter of the common entries, though: an entry added to some of
+// the filters but not to another tests positive if it is a false positive of that other
+// filter, so the result has as many false positives among such entries as the filters
+// have. Bits of different entries that happen to be set in all filters also fill it more
+// than a filter of the common entries, so its false positive rate is higher.
+func Intersect(filters ...*ClassicFilter) (*ClassicFilter, error) {
+	return combine(filters, andBits)
+}
+
+// Merge adds all entries of other to f in place, after checking that they are compatible
+// as for Union. It returns ErrReadOnly if f is read-only.
+func (f *ClassicFilter) Merge(other *ClassicFilter) error {
+	if f.readOnly {
+		return ErrReadOnly
+	}
+	if err := compatible(f, other); err != nil {
+		emit(Event{Kind: EventMergeFail, Filter: f, Err: err})
+		return err
+	}
+	if strict && !checkMerge(f, other) {
+		return ErrIncompatibleSize
+	}
+	orBits(f.B, other.B)
+	return nil
+}
+
+// Equal reports whether f and other are compatible as for Union and have the same bits,
+// so they hold the same entries, as after transferring or decoding a filter.
+func (f *ClassicFilter) Equal(other *ClassicFilter) bool {
+	return compatible(f, other) == nil && bytes.Equal(f.B, other.B)
+}
+
+// combine returns a new filter of the bit arrays of compatible filters combined with op.
+func combine(filters []*ClassicFilter, op func(dst, src []byte)) (*ClassicFilter, error) {
+	if len(filters) == 0 {
+		return nil, ErrIncompatible
+	}
+	for _, f := range filters[1:] {
+		if err := compatible(filters[0], f); err != nil {
+			emit(Event{Kind: EventMergeFail, Filter: filters[0], Err: err})
+			return nil, err
+		}
+	}
+	c := filters[0].Clone()
+	for _, f := range filters[1:] {
+		if strict && !checkMerge(c, f) {
+			return nil, ErrIncompatibleSize
+		}
+		op(c.B, f.B)
+	}
+	return c, nil
+}
+
+// compatible returns an IncompatibleError unless the bits of a and b mean the same entries.
+func compatible(a, b *ClassicFilter) error {
+	ai, bi := a.Identity(), b.Identity()
+	switch {
+	case ai.M != bi.M || ai.K != bi.K:
+		return &IncompatibleError{ai, bi, ErrIncompatibleSize}
+	case ai.Probe != bi.Probe || ai.Seed != bi.Seed:
+		return &IncompatibleError{ai, bi, ErrIncompatibleHash}
+	case ai.Hash != "" && bi.Hash != "":
+		if ai.Hash != bi.Hash {
+			return &IncompatibleError{ai, bi, ErrIncompatibleHash}
+		}
+	case funcID(a.H) != funcID(b.H):
+		return &IncompatibleError{ai, bi, ErrIncompatibleHash}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package bloom
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestUnion(t *testing.T) {
+	shards := make([]*ClassicFilter, 4)
+	for s := range shards {
+		shards[s] = newClassic(1e3, 1e-3, doubleFNV)
+		for i := s; i < 1e3; i += len(shards) {
+			shards[s].Add([]byte(strconv.Itoa(i)))
+		}
+	}
+	before := append([]byte(nil), shards[0].B...)
+	u, err := Union(shards...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1e3; i++ {
+		if !u.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Entry %d of a shard should be in the union", i)
+		}
+	}
+	if string(before) != string(shards[0].B) {
+		t.Fatal("Union should not change its arguments")
+	}
+
+	for _, other := range []*ClassicFilter{
+		newClassic(2e3, 1e-3, doubleFNV),
+		newClassic(1e3, 1e-3, doubleSHA),
+		NewSeeded(1e3, 1e-3, 1, doubleFNV),
+	} {
+		if _, err := Union(shards[0], other); !errors.Is(err, ErrIncompatible) {
+			t.Fatalf("Union of incompatible filters should fail with ErrIncompatible but got %v", err)
+		}
+	}
+	if _, err := Union(); !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("Union of no filters should fail with ErrIncompatible but got %v", err)
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	a := newClassic(1e3, 1e-3, doubleFNV)
+	b := newClassic(1e3, 1e-3, doubleFNV)
+	for i := 0; i < 600; i++ {
+		a.Add([]byte(strconv.Itoa(i)))
+		b.Add([]byte(strconv.Itoa(i + 400)))
+	}
+	in, err := Intersect(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 400; i < 600; i++ {
+		if !in.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Common entry %d should be in the intersection", i)
+		}
+	}
+	fp := 0
+	for i := 0; i < 1000; i++ {
+		if (i < 400 || i >= 600) && in.Test([]byte(strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	t.Logf("False positives among 800 entries in one filter: %d", fp)
+	if fp > 40 {
+		t.Fatalf("Entries in only one filter should rarely be in the intersection but %d are", fp)
+	}
+	if _, err := Intersect(a, newClassic(1e3, 1e-3, doubleSHA)); !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("Intersection of incompatible filters should fail with ErrIncompatible but got %v", err)
+	}
+}
+
+func TestClassicFilter_Merge(t *testing.T) {
+	a := NewWithHasher(1e3, 1e-3, NewHasher("a", doubleFNV))
+	b := NewWithHasher(1e3, 1e-3, NewHasher("a", doubleFNV))
+	a.Add([]byte("hello"))
+	b.Add([]byte("world"))
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Test([]byte("hello")) || !a.Test([]byte("world")) {
+		t.Fatal("Merged filter should contain the entries of both")
+	}
+
+	for _, c := range []struct {
+		other *ClassicFilter
+		err   error
+	}{
+		{newClassic(2e3, 1e-3, doubleFNV), ErrIncompatibleSize},
+		{newClassic(1e3, 1e-4, doubleFNV), ErrIncompatibleSize},
+		{NewWithHasher(1e3, 1e-3, NewHasher("b", doubleFNV)), ErrIncompatibleHash},
+		{NewSeeded(1e3, 1e-3, 1, doubleFNV), ErrIncompatibleHash},
+		{&ClassicFilter{B: make([]byte, len(a.B)), K: a.K, H: doubleFNV, Probe: EnhancedDoubleHashing, m: a.m}, ErrIncompatibleHash},
+	} {
+		var ie *IncompatibleError
+		if err := a.Merge(c.other); !errors.Is(err, c.err) || !errors.Is(err, ErrIncompatible) ||
+			!errors.As(err, &ie) || ie.A != a.Identity() || ie.B != c.other.Identity() {
+			t.Fatalf("Merge should fail with %v but got %v", c.err, err)
+		}
+	}
+	if err := NewFromBytes(a.B, a.K, doubleFNV).Merge(b); err != ErrReadOnly {
+		t.Fatalf("Merge into a read-only filter should fail with ErrReadOnly but got %v", err)
+	}
+}
+
+func TestIncompatibleClosures(t *testing.T) {
+	h := XXHashSeeded(7, 11)
+	a, b := newClassic(1e3, 1e-3, h), newClassic(1e3, 1e-3, h)
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Filters of one closure should merge but got %v", err)
+	}
+	// closures of the same literal with other seeds have the same code
+	if err := a.Merge(newClassic(1e3, 1e-3, XXHashSeeded(7, 12))); !errors.Is(err, ErrIncompatibleHash) {
+		t.Fatalf("Filters of separately made closures should not merge but got %v", err)
+	}
+	if err := newClassic(1e3, 1e-3, doubleFNV).Merge(newClassic(1e3, 1e-3, doubleFNV)); err != nil {
+		t.Fatalf("Filters of one top-level function should merge but got %v", err)
+	}
+	if id := a.Identity(); id.Hash != "" || id.M != a.BitCount() || id.K != a.K {
+		t.Fatalf("Identity should describe the filter but got %v", id)
+	}
+}
+
+func TestClassicFilter_Equal(t *testing.T) {
+	bf := newClassic(1e3, 1e-3, doubleFNV)
+	bf.Add([]byte("hello"))
+	data, _ := bf.MarshalBinary()
+	decoded := &ClassicFilter{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !bf.Equal(decoded) || !decoded.Equal(bf) || !bf.Equal(bf.Clone()) {
+		t.Fatal("Decoded filter should equal the source")
+	}
+	decoded.Add([]byte("world"))
+	if bf.Equal(decoded) {
+		t.Fatal("Filters with different bits should not be equal")
+	}
+	other := bf.Clone()
+	other.Seed = 1
+	if bf.Equal(other) {
+		t.Fatal("Filters with different seeds should not be equal")
+	}
+}
+
+func TestClassicFilter_Fold(t *testing.T) {
+	for _, probe := range []Probe{DoubleHashing, EnhancedDoubleHashing, IndependentHashing} {
+		bf := NewWithBits(8*1024*8, 7, doubleFNV)
+		bf.Probe = probe
+		for i := 0; i < 1000; i++ {
+			bf.Add([]byte(strconv.Itoa(i)))
+		}
+		folded, err := bf.Fold(4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if folded.Size() != bf.Size()/4 {
+			t.Fatalf("Folded filter should have %d bytes but has %d", bf.Size()/4, folded.Size())
+		}
+		for i := 0; i < 1000; i++ {
+			if !folded.Test([]byte(strconv.Itoa(i))) {
+				t.Fatalf("Entry %d should be in the filter folded with %v probes", i, probe)
+			}
+		}
+		if folded.CurrentFalsePositiveRate() <= bf.CurrentFalsePositiveRate() {
+			t.Fatal("Folded filter should have a higher false positive rate")
+		}
+	}
+	bf := NewWithBits(24, 3, doubleFNV)
+	for _, factor := range []int{0, 2, 4} {
+		if _, err := bf.Fold(factor); err != ErrInvalidFold {
+			t.Fatalf("Fold by %d should fail with ErrInvalidFold but got %v", factor, err)
+		}
+	}
+}
+
+func BenchmarkUnion(b *testing.B) {
+	x, y := newClassic(1e6, 1e-4, doubleFNV), newClassic(1e6, 1e-4, doubleFNV)
+	b.SetBytes(int64(x.Size()))
+	for i := 0; i < b.N; i++ {
+		x.Merge(y)
+	}
+}
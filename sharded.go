@@ -0,0 +1,76 @@
+package bloom
+
+import "sync"
+
+// Sharded Bloom Filter
+//
+// A sharded filter splits entries across independent classic filters by the
+// top bits of their first hash, each with its own lock, so goroutines adding
+// entries on many cores rarely contend. Each entry is hashed once, and tests
+// only lock the shard of the entry.
+type ShardedFilter struct {
+	S []shard
+	H func([]byte) (uint64, uint64)
+}
+
+// shard is a classic filter and its lock, padded so adjacent locks are at least a
+// cache line apart.
+type shard struct {
+	sync.RWMutex
+	f *ClassicFilter
+	_ [64]byte
+}
+
+// NewSharded creates a filter of s shards that is optimal for n entries and false positive rate of p.
+func NewSharded(n int, p float64, s int, h func([]byte) (uint64, uint64)) *ShardedFilter {
+	if s < 1 {
+		panic("bloom: sharded filter needs at least one shard")
+	}
+	f := &ShardedFilter{S: make([]shard, s), H: h}
+	for i := range f.S {
+		f.S[i].f = newClassic((n+s-1)/s, p, h)
+	}
+	return f
+}
+
+// shard returns the shard of an entry with first hash x.
+func (f *ShardedFilter) shard(x uint64) *shard {
+	return &f.S[reduce(uint32(x>>32), uint32(len(f.S)))]
+}
+
+func (f *ShardedFilter) Add(b []byte) {
+	x, y := f.H(b)
+	f.AddHash(KeyHash{x, y})
+}
+
+func (f *ShardedFilter) Test(b []byte) bool {
+	x, y := f.H(b)
+	return f.TestHash(KeyHash{x, y})
+}
+
+// TestAndAdd adds an entry and reports whether it was already in the filter, atomically
+// with respect to concurrent callers.
+func (f *ShardedFilter) TestAndAdd(b []byte) bool {
+	x, y := f.H(b)
+	s := f.shard(x)
+	s.Lock()
+	defer s.Unlock()
+	return s.f.testAndAddHashes(x, y)
+}
+
+func (f *ShardedFilter) Size() int {
+	size := 0
+	for i := range f.S {
+		size += f.S[i].f.Size()
+	}
+	return size
+}
+
+func (f *ShardedFilter) Reset() {
+	for i := range f.S {
+		s := &f.S[i]
+		s.Lock()
+		s.f.Reset()
+		s.Unlock()
+	}
+}
@@ -0,0 +1,46 @@
+package bloom
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestShardedFilter(t *testing.T) {
+	if size := unsafe.Sizeof(shard{}); size < 64 {
+		t.Fatalf("Shard is %d bytes, want at least a cache line", size)
+	}
+	f := NewSharded(8e4, 1e-3, 16, doubleSHA)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				f.Add([]byte(strconv.Itoa(g*100000 + i)))
+			}
+		}()
+	}
+	wg.Wait()
+	for g := 0; g < 8; g++ {
+		for i := 0; i < 10000; i++ {
+			if !f.Test([]byte(strconv.Itoa(g*100000 + i))) {
+				t.Fatal("Should exist in filter but got false")
+			}
+		}
+	}
+	fp := 0
+	for i := 0; i < 10000; i++ {
+		if f.Test([]byte("absent" + strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if fp > 30 {
+		t.Fatalf("False positive rate too high: %d/10000", fp)
+	}
+	f.Reset()
+	if f.Test([]byte("0")) {
+		t.Fatal("Should not exist after reset but got true")
+	}
+}
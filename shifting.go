@@ -0,0 +1,74 @@
+package bloom
+
+// Shifting Bloom Filter
+//
+// A shifting filter stores a small attribute of each entry, e.g. which of
+// two sets it belongs to, by shifting all K probe positions of the entry by
+// the attribute value. Since the shifted bits are next to the unshifted ones,
+// TestWhich reads the same few words for all A possible values. An entry may
+// appear to have more than one value with about the false positive rate of
+// the filter, in which case the lowest one is reported.
+// See Yang et al., "A Shifting Bloom Filter Framework for Set Queries" (2016).
+type ShiftingFilter struct {
+	B []byte
+	A int // number of attribute values
+	K int
+	H func([]byte) (uint64, uint64)
+}
+
+// NewShifting creates a shifting Bloom Filter that is optimal for n entries and false positive
+// rate of p, storing attribute values from 0 to a-1.
+func NewShifting(n int, p float64, a int, h func([]byte) (uint64, uint64)) *ShiftingFilter {
+	m, k := optimal(n, p)
+	return &ShiftingFilter{B: make([]byte, elements(m/8, 1)), A: a, K: int(k), H: h}
+}
+
+func (f *ShiftingFilter) getOffset(x, y uint64, i, v int) uint64 {
+	y = nonzero(x, y)
+	return (x + uint64(i)*y + uint64(v)) % (8 * uint64(len(f.B)))
+}
+
+// AddWith adds an entry with attribute value v, 0 <= v < A.
+func (f *ShiftingFilter) AddWith(b []byte, v int) {
+	if v < 0 || v >= f.A {
+		panic("bloom: attribute value out of range")
+	}
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i, v)
+		f.B[offset/8] |= 1 << (offset % 8)
+	}
+}
+
+// TestWhich tests if an entry is in the filter and returns its attribute value, or -1 if it is not.
+func (f *ShiftingFilter) TestWhich(b []byte) (bool, int) {
+	x, y := f.H(b)
+next:
+	for v := 0; v < f.A; v++ {
+		for i := 0; i < f.K; i++ {
+			offset := f.getOffset(x, y, i, v)
+			if f.B[offset/8]&(1<<(offset%8)) == 0 {
+				continue next
+			}
+		}
+		return true, v
+	}
+	return false, -1
+}
+
+// Add adds an entry with attribute value 0.
+func (f *ShiftingFilter) Add(b []byte) { f.AddWith(b, 0) }
+
+// Test tests if an entry is in the filter with any attribute value.
+func (f *ShiftingFilter) Test(b []byte) bool {
+	ok, _ := f.TestWhich(b)
+	return ok
+}
+
+func (f *ShiftingFilter) Size() int { return len(f.B) }
+
+func (f *ShiftingFilter) Reset() {
+	for i := range f.B {
+		f.B[i] = 0
+	}
+}
@@ -0,0 +1,34 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShiftingFilter_TestWhich(t *testing.T) {
+	const n = 1e4
+	sf := NewShifting(n, 1e-4, 3, doubleSHA)
+	for i := 0; i < n; i++ {
+		sf.AddWith([]byte(fmt.Sprint(i)), i%3)
+	}
+	wrong := 0
+	for i := 0; i < n; i++ {
+		ok, v := sf.TestWhich([]byte(fmt.Sprint(i)))
+		if !ok {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+		if v != i%3 {
+			if v > i%3 {
+				t.Fatalf("%d: attribute %d is above the true value %d", i, v, i%3)
+			}
+			wrong++
+		}
+	}
+	t.Logf("Wrong attributes = %d", wrong)
+	if wrong > 10 {
+		t.Fatalf("Too many wrong attributes: %d", wrong)
+	}
+	if ok, v := sf.TestWhich([]byte("not-exists")); ok || v != -1 {
+		t.Fatalf("Should missing in filter but got %v, %d", ok, v)
+	}
+}
@@ -0,0 +1,60 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// sipHash128 returns the 128-bit SipHash-2-4 of b under key as two words.
+// See Aumasson and Bernstein, "SipHash: a fast short-input PRF" (2012).
+func sipHash128(key [16]byte, b []byte) (uint64, uint64) {
+	k0 := binary.LittleEndian.Uint64(key[:])
+	k1 := binary.LittleEndian.Uint64(key[8:])
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d ^ 0xee
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+	n := len(b)
+	for ; len(b) >= 8; b = b[8:] {
+		m := binary.LittleEndian.Uint64(b)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+	m := uint64(n) << 56
+	for i := len(b) - 1; i >= 0; i-- {
+		m |= uint64(b[i]) << (8 * i)
+	}
+	v3 ^= m
+	round()
+	round()
+	v0 ^= m
+
+	v2 ^= 0xee
+	for i := 0; i < 4; i++ {
+		round()
+	}
+	x := v0 ^ v1 ^ v2 ^ v3
+	v1 ^= 0xdd
+	for i := 0; i < 4; i++ {
+		round()
+	}
+	return x, v0 ^ v1 ^ v2 ^ v3
+}
@@ -0,0 +1,26 @@
+package bloom
+
+import "testing"
+
+func TestSipHash128(t *testing.T) {
+	// vectors of the reference implementation, key 00..0f and message 00..n-1
+	var key [16]byte
+	msg := make([]byte, 15)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	for _, c := range []struct {
+		n    int
+		x, y uint64
+	}{
+		{0, 0xe6a825ba047f81a3, 0x930255c71472f66d},
+		{15, 0x11a8b03399e99354, 0xd9c3cf970fec087e},
+	} {
+		if x, y := sipHash128(key, msg[:c.n]); x != c.x || y != c.y {
+			t.Fatalf("SipHash of %d bytes = %#x, %#x, want %#x, %#x", c.n, x, y, c.x, c.y)
+		}
+	}
+}
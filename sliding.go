@@ -0,0 +1,160 @@
+package bloom
+
+import (
+	"sync"
+	"time"
+)
+
+// Sliding Window Bloom Filter
+//
+// A sliding window filter splits a window W into N slices of W/N, each a classic filter.
+// Entries are added to the slice of the current time and Test consults all of them; when
+// a slice's time is up the oldest slice is cleared and becomes the current one, so entries
+// expire between W-W/N and W after they were added, a slice at a time rather than half the
+// window at a time like a RotatingFilter. Slices are recycled on demand when the filter is
+// used, or when Rotate is called directly. The filter is safe for concurrent use, but
+// Slices must not be used directly while it is.
+type SlidingWindowFilter struct {
+	Slices []*ClassicFilter // ring of slices, oldest after the current one
+	W      time.Duration    // total window
+	Now    func() time.Time // clock, time.Now by default
+
+	// OnRotate, if set, is called after each time slices are recycled with its time,
+	// outside the lock.
+	OnRotate func(time.Time)
+
+	mu      sync.Mutex
+	cur     int       // index of the current slice
+	started time.Time // start of the current slice, set from Now when first used
+}
+
+// NewSlidingWindow creates a sliding window Bloom Filter of slices slices covering window,
+// optimal for n entries within the window and a false positive rate of p. Test consults
+// every slice, so each is sized for n/slices entries at a false positive rate of
+// p/slices; entries arriving in bursts fill their slices beyond that and raise the rate.
+func NewSlidingWindow(n int, p float64, window time.Duration, slices int, h func([]byte) (uint64, uint64)) *SlidingWindowFilter {
+	if slices < 1 {
+		panic("bloom: a sliding window needs at least one slice")
+	}
+	f := &SlidingWindowFilter{Slices: make([]*ClassicFilter, slices), W: window, Now: time.Now}
+	for i := range f.Slices {
+		f.Slices[i] = newClassic(max(1, (n+slices-1)/slices), p/float64(slices), h)
+	}
+	return f
+}
+
+// width returns the time each slice covers.
+func (f *SlidingWindowFilter) width() time.Duration {
+	return max(f.W/time.Duration(len(f.Slices)), 1)
+}
+
+// recycle clears the n oldest slices, making the last of them the current one.
+func (f *SlidingWindowFilter) recycle(n int) {
+	for range min(n, len(f.Slices)) {
+		f.cur = (f.cur + 1) % len(f.Slices)
+		f.Slices[f.cur].Reset()
+	}
+}
+
+// Rotate clears the oldest slice and makes it the current one.
+func (f *SlidingWindowFilter) Rotate() {
+	f.mu.Lock()
+	f.recycle(1)
+	f.started = f.Now()
+	at := f.started
+	f.mu.Unlock()
+	f.notify(at)
+}
+
+// notify calls OnRotate and emits EventRotate if slices were recycled at a non-zero time.
+func (f *SlidingWindowFilter) notify(at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	emit(Event{Kind: EventRotate, Filter: f})
+	if f.OnRotate != nil {
+		f.OnRotate(at)
+	}
+}
+
+// tick recycles a slice for every slice width elapsed since the current slice started, and
+// returns the time it did, or the zero time. The current slice starts at the first use of
+// the filter, and again if the clock was set back before its start.
+func (f *SlidingWindowFilter) tick() time.Time {
+	if f.W <= 0 {
+		return time.Time{}
+	}
+	now := f.Now()
+	width := f.width()
+	elapsed := now.Sub(f.started)
+	if f.started.IsZero() || elapsed < 0 {
+		f.started = now
+		return time.Time{}
+	}
+	if elapsed < width {
+		return time.Time{}
+	}
+	steps := elapsed / width
+	f.recycle(int(min(steps, time.Duration(len(f.Slices)))))
+	// keep slices aligned to their width
+	f.started = f.started.Add(steps * width)
+	return now
+}
+
+func (f *SlidingWindowFilter) Add(b []byte) {
+	f.mu.Lock()
+	at := f.tick()
+	f.Slices[f.cur].Add(b)
+	f.mu.Unlock()
+	f.notify(at)
+}
+
+func (f *SlidingWindowFilter) test(b []byte) bool {
+	for i := range f.Slices {
+		// the current slice first, then from the newest to the oldest
+		if f.Slices[(f.cur-i+len(f.Slices))%len(f.Slices)].Test(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *SlidingWindowFilter) Test(b []byte) bool {
+	f.mu.Lock()
+	at := f.tick()
+	ok := f.test(b)
+	f.mu.Unlock()
+	f.notify(at)
+	return ok
+}
+
+// TestAndAdd adds an entry to the current slice and reports whether it was already in
+// any slice.
+func (f *SlidingWindowFilter) TestAndAdd(b []byte) bool {
+	f.mu.Lock()
+	at := f.tick()
+	ok := f.test(b)
+	f.Slices[f.cur].Add(b)
+	f.mu.Unlock()
+	f.notify(at)
+	return ok
+}
+
+func (f *SlidingWindowFilter) Size() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	size := 0
+	for _, s := range f.Slices {
+		size += s.Size()
+	}
+	return size
+}
+
+func (f *SlidingWindowFilter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.Slices {
+		s.Reset()
+	}
+	f.started = time.Time{}
+}
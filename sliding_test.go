@@ -0,0 +1,88 @@
+package bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowFilter(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	sw := NewSlidingWindow(24e3, 1e-3, 24*time.Hour, 24, doubleFNV)
+	sw.Now = clock.Now
+
+	var rotations int
+	sw.OnRotate = func(time.Time) { rotations++ }
+	a, b := []byte("a"), []byte("b")
+	sw.Add(a)
+	clock.Advance(12 * time.Hour)
+	sw.Add(b)
+	if !sw.Test(a) || !sw.Test(b) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	clock.Advance(11*time.Hour + 30*time.Minute)
+	if !sw.Test(a) {
+		t.Fatal("Entry within the window should exist in filter but got false")
+	}
+	clock.Advance(time.Hour)
+	if sw.Test(a) {
+		t.Fatal("Entry older than the window should have expired")
+	}
+	if !sw.Test(b) {
+		t.Fatal("Entry within the window should exist in filter but got false")
+	}
+	if rotations != 3 {
+		t.Errorf("OnRotate was called %d times, want once per recycling", rotations)
+	}
+	if sw.TestAndAdd(a) || !sw.TestAndAdd(a) {
+		t.Error("TestAndAdd should report an expired entry as new once")
+	}
+	clock.Advance(48 * time.Hour)
+	if sw.Test(a) || sw.Test(b) {
+		t.Fatal("All entries should be gone after a long pause")
+	}
+	if sw.Size() != 24*sw.Slices[0].Size() {
+		t.Errorf("Size = %d, want that of 24 slices", sw.Size())
+	}
+}
+
+func TestSlidingWindowFilter_ClockBack(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	sw := NewSlidingWindow(1e3, 1e-3, time.Hour, 4, doubleFNV)
+	sw.Now = clock.Now
+	sw.Add([]byte("a"))
+	clock.Advance(-time.Hour)
+	sw.Add([]byte("b"))
+	clock.Advance(50 * time.Minute)
+	if !sw.Test([]byte("a")) || !sw.Test([]byte("b")) {
+		t.Fatal("Entries should exist in filter after the clock was set back")
+	}
+	clock.Advance(time.Hour)
+	if sw.Test([]byte("a")) || sw.Test([]byte("b")) {
+		t.Fatal("Entries should expire a window after the clock was set back")
+	}
+}
+
+func TestSlidingWindowFilter_Rotate(t *testing.T) {
+	events := recordEvents(t)
+	sw := NewSlidingWindow(1e3, 1e-3, 0, 3, doubleFNV)
+	buf := []byte("hello")
+	sw.Add(buf)
+	sw.Rotate()
+	sw.Rotate()
+	if !sw.Test(buf) {
+		t.Fatal("Entry should survive two rotations of three slices")
+	}
+	sw.Rotate()
+	if sw.Test(buf) {
+		t.Fatal("Entry should be gone after three rotations")
+	}
+	if n := len(events()); n != 3 {
+		t.Errorf("%d events for 3 rotations", n)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("A window of no slices should panic")
+		}
+	}()
+	NewSlidingWindow(1e3, 1e-3, time.Hour, 0, doubleFNV)
+}
@@ -0,0 +1,83 @@
+package bloom
+
+// sparseBitBytes is about the number of bytes a set bit takes in the map of a sparse filter.
+const sparseBitBytes = 16
+
+// Sparse Bloom Filter
+//
+// A sparse filter is a classic filter that keeps the offsets of its set bits in a map
+// rather than a bit array while few of them are set, so that a filter sized for a billion
+// entries costs memory in proportion to the entries it holds. It converts itself to a
+// classic filter once the map would take more memory than the bit array.
+type SparseFilter struct {
+	K int
+	H func([]byte) (uint64, uint64)
+
+	bits  map[uint64]struct{} // offsets of the set bits while sparse
+	dense *ClassicFilter      // the filter once dense
+	m     uint64              // number of bits
+	n     int
+	p     float64
+}
+
+// NewSparse creates a sparse classic Bloom Filter that is optimal for n entries and false
+// positive rate of p. Its bits are those of the filter New(n, p, h) would create.
+func NewSparse(n int, p float64, h func([]byte) (uint64, uint64)) *SparseFilter {
+	m, k := optimal(n, p)
+	return &SparseFilter{K: max(int(k), 1), H: h, bits: make(map[uint64]struct{}), m: uint64(m), n: n, p: p}
+}
+
+// Dense reports whether the filter has converted itself to a bit array.
+func (f *SparseFilter) Dense() bool { return f.dense != nil }
+
+func (f *SparseFilter) Add(b []byte) {
+	if f.dense != nil {
+		f.dense.Add(b)
+		return
+	}
+	x, y := f.H(b)
+	probes := DoubleHashing.seq(x, nonzero(x, y))
+	for i := 0; i < f.K; i++ {
+		f.bits[reduceOffset(probes.next(), f.m)] = struct{}{}
+	}
+	if uint64(len(f.bits))*sparseBitBytes > f.m/8 {
+		f.dense = f.Classic()
+		f.bits = nil
+	}
+}
+
+func (f *SparseFilter) Test(b []byte) bool {
+	if f.dense != nil {
+		return f.dense.Test(b)
+	}
+	x, y := f.H(b)
+	probes := DoubleHashing.seq(x, nonzero(x, y))
+	for i := 0; i < f.K; i++ {
+		if _, ok := f.bits[reduceOffset(probes.next(), f.m)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Size returns the size of the bit array of the filter in bytes, which it takes in memory
+// only once dense.
+func (f *SparseFilter) Size() int { return int(byteLen(f.m)) }
+
+// Reset resets the filter to initial state, releasing its bit array if it is dense.
+func (f *SparseFilter) Reset() {
+	f.dense = nil
+	f.bits = make(map[uint64]struct{})
+}
+
+// Classic copies the filter into a ClassicFilter with the same bits.
+func (f *SparseFilter) Classic() *ClassicFilter {
+	if f.dense != nil {
+		return f.dense.Clone()
+	}
+	c := &ClassicFilter{B: make([]byte, byteLen(f.m)), K: f.K, H: f.H, m: f.m, n: f.n, p: f.p}
+	for offset := range f.bits {
+		c.B[offset/8] |= 1 << (offset % 8)
+	}
+	return c
+}
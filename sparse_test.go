@@ -0,0 +1,55 @@
+package bloom
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func TestSparseFilter(t *testing.T) {
+	f := NewSparse(1e9, 1e-3, doubleSHA)
+	if f.Size() < 1e9 {
+		t.Fatalf("Sparse filter should have the size of its bit array but has %d", f.Size())
+	}
+	for i := 0; i < 1000; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	if f.Dense() {
+		t.Fatal("Sparse filter of few entries should not be dense")
+	}
+	for i := 0; i < 1000; i++ {
+		if !f.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Entry %d should be in the filter", i)
+		}
+	}
+	if f.Test([]byte("absent")) {
+		t.Fatal("Absent entry should not be in the filter")
+	}
+	f.Reset()
+	if f.Test([]byte("0")) {
+		t.Fatal("Reset filter should be empty")
+	}
+}
+
+func TestSparseFilter_Dense(t *testing.T) {
+	f := NewSparse(1e3, 1e-2, doubleSHA)
+	c := New(1e3, 1e-2, doubleSHA).(*ClassicFilter)
+	for i := 0; i < 1000; i++ {
+		key := []byte(strconv.Itoa(i))
+		f.Add(key)
+		c.Add(key)
+		if i == 10 && !bytes.Equal(f.Classic().B, c.B) {
+			t.Fatal("Sparse filter should set the bits of a classic filter")
+		}
+	}
+	if !f.Dense() {
+		t.Fatal("Sparse filter at capacity should be dense")
+	}
+	if !bytes.Equal(f.Classic().B, c.B) {
+		t.Fatal("Dense filter should keep the bits of a classic filter")
+	}
+	f.Reset()
+	if f.Dense() || f.Test([]byte("0")) {
+		t.Fatal("Reset filter should be sparse and empty")
+	}
+}
@@ -0,0 +1,72 @@
+package bloom
+
+// Spectral Bloom Filter
+//
+// A spectral filter keeps a counter per position and estimates the number of
+// times an entry has been added as the minimum of its K counters. Counts are
+// added with the minimal increase heuristic: only counters below the new
+// minimum are raised, which keeps overestimates small. Estimates are never
+// below the true count.
+// See Cohen and Matias, "Spectral Bloom Filters" (2003).
+type SpectralFilter struct {
+	C []uint32 // counters
+	K int
+	H func([]byte) (uint64, uint64)
+}
+
+// NewSpectral creates a spectral Bloom Filter that is optimal for n distinct entries and
+// false positive rate of p.
+func NewSpectral(n int, p float64, h func([]byte) (uint64, uint64)) *SpectralFilter {
+	m, k := optimal(n, p)
+	return &SpectralFilter{C: make([]uint32, elements(m, 4)), K: int(k), H: h}
+}
+
+func (f *SpectralFilter) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return (x + uint64(i)*y) % uint64(len(f.C))
+}
+
+// AddCount adds c occurrences of an entry to the filter.
+func (f *SpectralFilter) AddCount(b []byte, c uint32) {
+	x, y := f.H(b)
+	min := f.min(x, y)
+	v := min + c
+	if v < min {
+		v = ^uint32(0) // saturate
+	}
+	for i := 0; i < f.K; i++ {
+		offset := f.getOffset(x, y, i)
+		if f.C[offset] < v {
+			f.C[offset] = v
+		}
+	}
+}
+
+func (f *SpectralFilter) min(x, y uint64) uint32 {
+	min := ^uint32(0)
+	for i := 0; i < f.K; i++ {
+		if c := f.C[f.getOffset(x, y, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// EstimateCount returns an estimate of the number of times an entry has been added.
+func (f *SpectralFilter) EstimateCount(b []byte) uint32 {
+	x, y := f.H(b)
+	return f.min(x, y)
+}
+
+// Add adds a single occurrence of an entry to the filter.
+func (f *SpectralFilter) Add(b []byte) { f.AddCount(b, 1) }
+
+func (f *SpectralFilter) Test(b []byte) bool { return f.EstimateCount(b) > 0 }
+
+func (f *SpectralFilter) Size() int { return 4 * len(f.C) }
+
+func (f *SpectralFilter) Reset() {
+	for i := range f.C {
+		f.C[i] = 0
+	}
+}
@@ -0,0 +1,41 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSpectralFilter_EstimateCount(t *testing.T) {
+	sf := NewSpectral(1e4, 1e-3, doubleSHA)
+	for i := 0; i < 1e4; i++ {
+		sf.AddCount([]byte(fmt.Sprint(i)), uint32(i%10+1))
+	}
+	over := 0
+	for i := 0; i < 1e4; i++ {
+		want := uint32(i%10 + 1)
+		got := sf.EstimateCount([]byte(fmt.Sprint(i)))
+		if got < want {
+			t.Fatalf("%d: estimate %d is below the true count %d", i, got, want)
+		}
+		if got > want {
+			over++
+		}
+	}
+	t.Logf("Overestimates = %d", over)
+	if over > 100 {
+		t.Fatalf("Too many overestimates: %d", over)
+	}
+	if sf.Test([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
+
+func TestSpectralFilter_Saturate(t *testing.T) {
+	sf := NewSpectral(10, 1e-2, doubleFNV)
+	buf := []byte("testing")
+	sf.AddCount(buf, ^uint32(0))
+	sf.Add(buf)
+	if got := sf.EstimateCount(buf); got != ^uint32(0) {
+		t.Fatalf("Counter should saturate but got %d", got)
+	}
+}
@@ -0,0 +1,95 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// split block filter sizes in bytes allowed by the Parquet specification
+const (
+	sbbfBlock    = 32
+	sbbfMinBytes = 32
+	sbbfMaxBytes = 128 << 20
+)
+
+// sbbfSalt are the salts of the eight words of a block.
+var sbbfSalt = [8]uint32{
+	0x47b6137b, 0x44974d91, 0x8824ad5b, 0xa2b7289d,
+	0x705495c7, 0x2df1424b, 0x9efc4947, 0x5c6bfb31,
+}
+
+// Split Block Bloom Filter
+//
+// A split block filter is the Bloom Filter of the Apache Parquet format. It
+// is made of 32-byte blocks of eight 32-bit words. The upper half of the
+// xxHash64 of an entry selects a block, and the lower half sets one bit in
+// each word of it, chosen by multiplying with the salt of the word.
+// B is the bitset exactly as stored in a Parquet file, so a filter can be
+// read from or written to Parquet column chunk metadata directly.
+// See https://github.com/apache/parquet-format/blob/master/BloomFilter.md
+type SplitBlockFilter struct {
+	B []byte // bitset of little-endian words
+}
+
+// NewSplitBlock creates a split block Bloom Filter for n distinct entries and false positive
+// rate of p, rounded up to a power of two number of bytes as Parquet writers do.
+func NewSplitBlock(n int, p float64) *SplitBlockFilter {
+	m := -8 * float64(n) / math.Log(1-math.Pow(checkRate(p), 1.0/8))
+	size := sbbfMinBytes
+	for size < sbbfMaxBytes && float64(size*8) < m {
+		size <<= 1
+	}
+	return &SplitBlockFilter{B: make([]byte, size)}
+}
+
+// NewSplitBlockFromBytes wraps the bitset of a Parquet bloom filter without copying it.
+func NewSplitBlockFromBytes(b []byte) (*SplitBlockFilter, error) {
+	if len(b) == 0 || len(b)%sbbfBlock != 0 {
+		return nil, errors.New("bloom: split block filter size must be a multiple of 32 bytes")
+	}
+	return &SplitBlockFilter{B: b}, nil
+}
+
+// block returns the bytes of the block of hash.
+func (f *SplitBlockFilter) block(hash uint64) []byte {
+	blocks := uint64(len(f.B) / sbbfBlock)
+	i := (hash >> 32) * blocks >> 32
+	return f.B[i*sbbfBlock : (i+1)*sbbfBlock]
+}
+
+// AddHash adds an entry by its xxHash64, as Parquet writers hash the plain encoding of values.
+func (f *SplitBlockFilter) AddHash(hash uint64) {
+	block := f.block(hash)
+	key := uint32(hash)
+	for i, salt := range sbbfSalt {
+		w := binary.LittleEndian.Uint32(block[4*i:])
+		binary.LittleEndian.PutUint32(block[4*i:], w|1<<(key*salt>>27))
+	}
+}
+
+// TestHash tests if an entry is in the filter by its xxHash64.
+func (f *SplitBlockFilter) TestHash(hash uint64) bool {
+	block := f.block(hash)
+	key := uint32(hash)
+	for i, salt := range sbbfSalt {
+		if binary.LittleEndian.Uint32(block[4*i:])&(1<<(key*salt>>27)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Add adds the plain encoding of a value to the filter.
+func (f *SplitBlockFilter) Add(b []byte) { f.AddHash(xxhash64(b, 0)) }
+
+// Test tests if the plain encoding of a value is in the filter.
+func (f *SplitBlockFilter) Test(b []byte) bool { return f.TestHash(xxhash64(b, 0)) }
+
+func (f *SplitBlockFilter) Size() int { return len(f.B) }
+
+func (f *SplitBlockFilter) Reset() {
+	for i := range f.B {
+		f.B[i] = 0
+	}
+}
@@ -0,0 +1,55 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestSplitBlockFilter_Test(t *testing.T) {
+	const (
+		n         = 1e5
+		expectFPR = 1e-3
+	)
+	f := NewSplitBlock(n, expectFPR)
+	if f.Size()&(f.Size()-1) != 0 {
+		t.Fatalf("Size %d should be a power of two", f.Size())
+	}
+	for i := 0; i < n; i++ {
+		f.Add([]byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < n; i++ {
+		if !f.Test([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if f.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	fpr := float64(fp) / n
+	t.Logf("Size = %d, FP = %d, FPR = %.4f%%", f.Size(), fp, fpr*100)
+	if fpr > expectFPR {
+		t.Fatalf("FPR %.4f%% is above the target %.4f%%", fpr*100, expectFPR*100)
+	}
+}
+
+func TestSplitBlockFilter_Layout(t *testing.T) {
+	// A single block filter: the hash picks block 0 and sets one bit per word.
+	f, err := NewSplitBlockFromBytes(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.AddHash(1)
+	for i, salt := range sbbfSalt {
+		want := uint32(1) << (salt >> 27)
+		if got := binary.LittleEndian.Uint32(f.B[4*i:]); got != want {
+			t.Fatalf("word %d = %#x, want %#x", i, got, want)
+		}
+	}
+	if _, err := NewSplitBlockFromBytes(make([]byte, 33)); err == nil {
+		t.Fatal("Bitset that is not a whole number of blocks should fail")
+	}
+}
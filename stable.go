@@ -0,0 +1,98 @@
+package bloom
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Stable Bloom Filter
+//
+// A stable filter keeps a small counter per cell. Every Add first decrements
+// P randomly chosen cells and then sets the k cells of the entry to Max, so
+// old entries gradually fade out and the false positive rate converges to a
+// fixed bound however many entries are added. The price is false negatives
+// for entries that have not been added recently.
+// See Deng and Rafiei, "Approximately Detecting Duplicates for Streaming
+// Data using Stable Bloom Filters" (2006).
+type StableFilter struct {
+	C   []byte // cells
+	Max byte   // value a cell is set to on Add
+	P   int    // number of cells decremented on each Add
+	K   int
+	H   func([]byte) (uint64, uint64)
+
+	rand *rand.Rand
+}
+
+// NewStable creates a stable Bloom Filter of m cells with k hashes, where each
+// cell counts down from max. The number of cells decremented on each Add is
+// chosen so that the false positive rate converges to p.
+func NewStable(m, k int, max byte, p float64, h func([]byte) (uint64, uint64)) *StableFilter {
+	return &StableFilter{
+		C:    make([]byte, m),
+		Max:  max,
+		P:    optimalStableP(m, k, max, p),
+		K:    k,
+		H:    h,
+		rand: rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+// optimalStableP returns the number of cells to decrement on each Add so that
+// the stable false positive rate of the filter is p.
+func optimalStableP(m, k int, max byte, p float64) int {
+	sub := math.Pow(1-math.Pow(p, 1/float64(k)), 1/float64(max))
+	d := (1/sub - 1) * (1/float64(k) - 1/float64(m))
+	if d <= 0 || 1/d < 1 {
+		return 1
+	}
+	return int(1 / d)
+}
+
+// Seed makes the choice of decremented cells deterministic.
+func (f *StableFilter) Seed(seed int64) { f.rand = rand.New(rand.NewSource(seed)) }
+
+func (f *StableFilter) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return (x + uint64(i)*y) % uint64(len(f.C))
+}
+
+func (f *StableFilter) decrement() {
+	i := f.rand.Intn(len(f.C))
+	for j := 0; j < f.P; j++ {
+		if f.C[i] > 0 {
+			f.C[i]--
+		}
+		if i++; i == len(f.C) {
+			i = 0
+		}
+	}
+}
+
+// Add decrements P cells starting at a random position and then sets the
+// cells of the entry to Max.
+func (f *StableFilter) Add(b []byte) {
+	f.decrement()
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		f.C[f.getOffset(x, y, i)] = f.Max
+	}
+}
+
+func (f *StableFilter) Test(b []byte) bool {
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		if f.C[f.getOffset(x, y, i)] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *StableFilter) Size() int { return len(f.C) }
+
+func (f *StableFilter) Reset() {
+	for i := range f.C {
+		f.C[i] = 0
+	}
+}
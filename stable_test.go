@@ -0,0 +1,51 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStableFilter_Test(t *testing.T) {
+	sf := NewStable(1e4, 3, 3, 1e-2, doubleFNV)
+	buf := []byte("testing")
+	sf.Add(buf)
+	if !sf.Test(buf) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if sf.Test([]byte("not-exists")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+}
+
+func TestStableFilter_Fade(t *testing.T) {
+	sf := NewStable(1e4, 3, 1, 1e-2, doubleSHA)
+	sf.Seed(1)
+	first := []byte("first")
+	sf.Add(first)
+	for i := 0; i < 1e5; i++ {
+		sf.Add([]byte(fmt.Sprint(i)))
+	}
+	if sf.Test(first) {
+		t.Fatal("Old entry should have faded out")
+	}
+}
+
+func TestStableFilter_StableFPR(t *testing.T) {
+	const expectFPR = 1e-2
+	sf := NewStable(1e5, 3, 3, expectFPR, doubleSHA)
+	sf.Seed(1)
+	for i := 0; i < 1e6; i++ {
+		sf.Add([]byte(fmt.Sprint(i)))
+	}
+	fp := 0
+	for i := 1e6; i < 1e6+1e5; i++ {
+		if sf.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	fpr := float64(fp) / 1e5
+	t.Logf("P = %d, FP = %d, FPR = %.4f%%", sf.P, fp, fpr*100)
+	if fpr > 2*expectFPR {
+		t.Fatalf("FPR %.4f%% is well above the stable bound %.4f%%", fpr*100, expectFPR*100)
+	}
+}
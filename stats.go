@@ -0,0 +1,225 @@
+package bloom
+
+import (
+	"iter"
+	"math"
+	"math/bits"
+	"slices"
+	"sync/atomic"
+)
+
+// ApproximateCount estimates the number of distinct entries added to the filter from the
+// number of set bits X as -(m/k) ln(1 - X/m). See Swamidass and Baldi, "Mathematical
+// Correction for Fingerprint Similarity Measures to Improve Chemical Retrieval" (2007).
+// It returns math.MaxUint64 for a filter with all bits set, whose count is unbounded.
+func (f *ClassicFilter) ApproximateCount() uint64 {
+	m := float64(f.BitCount())
+	set := float64(f.setBits())
+	if set == m {
+		return math.MaxUint64
+	}
+	return uint64(math.Round(-m / float64(f.K) * math.Log1p(-set/m)))
+}
+
+// FillRatio returns the fraction of set bits of the filter. A filter of as many entries as
+// it was made for has about half its bits set, and more push the false positive rate up fast.
+func (f *ClassicFilter) FillRatio() float64 {
+	if f.BitCount() == 0 {
+		return 0
+	}
+	return float64(f.setBits()) / float64(f.BitCount())
+}
+
+// CurrentFalsePositiveRate returns the probability that an entry not in the filter tests
+// positive, computed from the fill ratio as FillRatio^K. Unlike the rate the filter was
+// made for, it grows as entries are added, so a filter can be rebuilt larger once it is too high.
+func (f *ClassicFilter) CurrentFalsePositiveRate() float64 {
+	return math.Pow(f.FillRatio(), float64(f.K))
+}
+
+// setBits returns the number of set bits of the filter.
+func (f *ClassicFilter) setBits() int { return f.Bits().OnesCount() }
+
+// MeasureFPR returns the fraction of negatives, entries known not to be in f, that test
+// positive: the false positive rate f actually has, to check after a bulk load against the
+// rate it was made for. A rate well above it points to a weak hash function or a filter
+// sized for fewer entries than it holds. Negatives that were added to f count as false
+// positives. It returns NaN if there are no negatives.
+func MeasureFPR(f Filter, negatives [][]byte) float64 {
+	return MeasureFPRSeq(f, slices.Values(negatives))
+}
+
+// MeasureFPRSeq returns the false positive rate of f over a sequence of negatives like
+// MeasureFPR, so that they can be generated rather than held in memory.
+func MeasureFPRSeq(f Filter, negatives iter.Seq[[]byte]) float64 {
+	var tested, positive int
+	for b := range negatives {
+		tested++
+		if f.Test(b) {
+			positive++
+		}
+	}
+	if tested == 0 {
+		return math.NaN()
+	}
+	return float64(positive) / float64(tested)
+}
+
+// Stats is a report of the state of a filter, for dashboards and health checks, as
+// returned by StatsOf. Fields a filter cannot report are zero.
+type Stats struct {
+	Size           int     // bytes of the filter
+	Bits           uint64  // bits of its bit arrays, m
+	BitsSet        uint64  // bits that are set
+	K              int     // hashes of an entry
+	FillRatio      float64 // fraction of the bits that are set
+	EstimatedCount uint64  // distinct entries estimated from the bits that are set
+	EstimatedFPR   float64 // false positive rate estimated from the fill ratio
+	Capacity       int     // entries the filter was made for
+	TargetRate     float64 // false positive rate the filter was made for
+}
+
+// bitStats returns the stats of a filter of m bits, set of them set, with k hashes: the
+// estimates of ApproximateCount and CurrentFalsePositiveRate, and the capacity and rate
+// of an optimal filter of that many bits and hashes.
+func bitStats(size int, m, set uint64, k int) Stats {
+	st := Stats{Size: size, Bits: m, BitsSet: set, K: k}
+	if m == 0 || k == 0 {
+		return st
+	}
+	st.FillRatio = float64(set) / float64(m)
+	st.EstimatedFPR = math.Pow(st.FillRatio, float64(k))
+	st.EstimatedCount = math.MaxUint64
+	if set < m {
+		st.EstimatedCount = uint64(math.Round(-float64(m) / float64(k) * math.Log1p(-st.FillRatio)))
+	}
+	st.Capacity = int(float64(m) * math.Ln2 / float64(k))
+	st.TargetRate = math.Pow(0.5, float64(k))
+	return st
+}
+
+// unionStats returns the stats of a filter testing an entry against each of parts: the
+// sums of their sizes, bits and counts, and the rate at which any of them has a false
+// positive.
+func unionStats(parts ...Stats) Stats {
+	var st Stats
+	miss := 1.0
+	for _, p := range parts {
+		st.Size += p.Size
+		st.Bits += p.Bits
+		st.BitsSet += p.BitsSet
+		st.K = max(st.K, p.K)
+		st.EstimatedCount = satAdd(st.EstimatedCount, p.EstimatedCount)
+		st.Capacity += p.Capacity
+		miss *= 1 - p.EstimatedFPR
+	}
+	if st.Bits > 0 {
+		st.FillRatio = float64(st.BitsSet) / float64(st.Bits)
+	}
+	st.EstimatedFPR = 1 - miss
+	return st
+}
+
+// satAdd returns a+b, or math.MaxUint64 if it overflows.
+func satAdd(a, b uint64) uint64 {
+	if s := a + b; s >= a {
+		return s
+	}
+	return math.MaxUint64
+}
+
+// Stats returns the stats of the filter, with its own capacity and target rate.
+func (f *ClassicFilter) Stats() Stats {
+	st := bitStats(f.Size(), f.BitCount(), uint64(f.setBits()), f.K)
+	st.Capacity, st.TargetRate = f.Capacity(), f.TargetRate()
+	return st
+}
+
+// Stats returns the stats of the filter. Its estimates are those of a classic filter,
+// which are slightly low for the false positive rate of a blocked one.
+func (f *BlockedFilter) Stats() Stats {
+	var set int
+	for _, w := range f.B {
+		set += bits.OnesCount64(w)
+	}
+	return bitStats(f.Size(), 64*uint64(len(f.B)), uint64(set), f.K)
+}
+
+// Stats returns the stats of the filter, reading its words atomically, so entries added
+// concurrently may or may not be counted.
+func (f *AtomicFilter) Stats() Stats {
+	var set int
+	for i := range f.W {
+		set += bits.OnesCount64(atomic.LoadUint64(&f.W[i]))
+	}
+	return bitStats(f.Size(), 64*uint64(len(f.W)), uint64(set), f.K)
+}
+
+// Stats returns the stats of the filter.
+func (f *PartitionedFilter) Stats() Stats {
+	return bitStats(f.Size(), f.S*uint64(f.K), uint64(Bitset{b: f.B, m: 8 * uint64(len(f.B))}.OnesCount()), f.K)
+}
+
+// Stats returns the stats of the filter, summed over its filters, with the false positive
+// rate of any of them, and its target rate P.
+func (f *ScalableFilter) Stats() Stats {
+	parts := make([]Stats, len(f.filters))
+	for i, sub := range f.filters {
+		parts[i] = sub.Stats()
+	}
+	st := unionStats(parts...)
+	st.TargetRate = f.P
+	return st
+}
+
+// Stats returns the stats of the filter, summed over both windows, with the false positive
+// rate of either, and the capacity and target rate of a window.
+func (f *RotatingFilter) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	active := f.Active.Stats()
+	st := unionStats(active, f.Previous.Stats())
+	st.Capacity, st.TargetRate = active.Capacity, active.TargetRate
+	return st
+}
+
+// StatsOf returns the stats of any filter: those of its Stats method if it has one, those
+// of the filter it wraps like a SafeFilter, read under its lock, or one with an Unwrap
+// method, and otherwise those its methods report among HashCount, BitCount, FillRatio,
+// ApproximateCount, CurrentFalsePositiveRate, Capacity and TargetRate.
+func StatsOf(f Filter) Stats {
+	switch f := f.(type) {
+	case *SafeFilter:
+		var st Stats
+		f.Do(func(f Filter) { st = StatsOf(f) })
+		return st
+	case interface{ Stats() Stats }:
+		return f.Stats()
+	case interface{ Unwrap() Filter }:
+		return StatsOf(f.Unwrap())
+	}
+	st := Stats{Size: f.Size()}
+	if c, ok := f.(interface{ HashCount() int }); ok {
+		st.K = c.HashCount()
+	}
+	if c, ok := f.(interface{ BitCount() uint64 }); ok {
+		st.Bits = c.BitCount()
+	}
+	if c, ok := f.(interface{ FillRatio() float64 }); ok {
+		st.FillRatio = c.FillRatio()
+		st.BitsSet = uint64(math.Round(st.FillRatio * float64(st.Bits)))
+	}
+	if c, ok := f.(ApproximateCounter); ok {
+		st.EstimatedCount = c.ApproximateCount()
+	}
+	if c, ok := f.(interface{ CurrentFalsePositiveRate() float64 }); ok {
+		st.EstimatedFPR = c.CurrentFalsePositiveRate()
+	}
+	if c, ok := f.(interface{ Capacity() int }); ok {
+		st.Capacity = c.Capacity()
+	}
+	if c, ok := f.(interface{ TargetRate() float64 }); ok {
+		st.TargetRate = c.TargetRate()
+	}
+	return st
+}
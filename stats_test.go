@@ -0,0 +1,136 @@
+package bloom
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestClassicFilter_ApproximateCount(t *testing.T) {
+	bf := newClassic(1e4, 1e-3, doubleSHA)
+	if n := bf.ApproximateCount(); n != 0 {
+		t.Fatalf("Empty filter should count 0 entries but got %d", n)
+	}
+	for _, n := range []int{100, 1000, 10000} {
+		bf.Reset()
+		for i := 0; i < n; i++ {
+			bf.Add([]byte(strconv.Itoa(i)))
+		}
+		if c := bf.ApproximateCount(); math.Abs(float64(c)-float64(n)) > 0.05*float64(n) {
+			t.Fatalf("Filter of %d entries should count about as many but got %d", n, c)
+		}
+	}
+	for i := range bf.B {
+		bf.B[i] = 0xff
+	}
+	if n := bf.ApproximateCount(); n != math.MaxUint64 {
+		t.Fatalf("Full filter should count math.MaxUint64 entries but got %d", n)
+	}
+}
+
+func TestClassicFilter_FillRatio(t *testing.T) {
+	bf := newClassic(1e4, 1e-3, doubleSHA)
+	if r := bf.FillRatio(); r != 0 {
+		t.Fatalf("Empty filter should have fill ratio 0 but got %v", r)
+	}
+	for i := 0; i < 1e4; i++ {
+		bf.Add([]byte(strconv.Itoa(i)))
+	}
+	if r := bf.FillRatio(); r < 0.45 || r > 0.55 {
+		t.Fatalf("Filter at capacity should have about half its bits set but got %v", r)
+	}
+	odd := &ClassicFilter{B: []byte{1, 3, 7, 15, 31, 63, 127, 255, 1, 3, 0xff}, K: 1}
+	if r := odd.FillRatio(); r != 47.0/88 {
+		t.Fatalf("Fill ratio should be %v but got %v", 47.0/88, r)
+	}
+	if r := (&ClassicFilter{}).FillRatio(); r != 0 {
+		t.Fatalf("Filter without bits should have fill ratio 0 but got %v", r)
+	}
+}
+
+func TestClassicFilter_CurrentFalsePositiveRate(t *testing.T) {
+	bf := newClassic(1e4, 1e-3, doubleSHA)
+	if r := bf.CurrentFalsePositiveRate(); r != 0 {
+		t.Fatalf("Empty filter should have no false positives but got %v", r)
+	}
+	measured := fpRate(bf, 1e4)
+	if r := bf.CurrentFalsePositiveRate(); math.Abs(r-measured) > r/3 {
+		t.Fatalf("Filter at capacity should have a rate %v close to the measured %v", r, measured)
+	}
+	for i := 1e4; i < 3e4; i++ {
+		bf.Add([]byte(strconv.Itoa(int(i))))
+	}
+	if r := bf.CurrentFalsePositiveRate(); r < 1e-2 {
+		t.Fatalf("Overfull filter should have a rate far above 1e-3 but got %v", r)
+	}
+}
+
+func TestMeasureFPR(t *testing.T) {
+	bf := newClassic(1e4, 1e-2, doubleSHA)
+	for i := 0; i < 1e4; i++ {
+		bf.Add([]byte(strconv.Itoa(i)))
+	}
+	negatives := make([][]byte, 1e5)
+	for i := range negatives {
+		negatives[i] = []byte("absent" + strconv.Itoa(i))
+	}
+	// within three standard deviations of 1e5 samples
+	if r := MeasureFPR(bf, negatives); math.Abs(r-1e-2) > 3*math.Sqrt(1e-2*(1-1e-2)/1e5) {
+		t.Fatalf("Measured rate %v should be close to 1e-2", r)
+	}
+	positives := func(yield func([]byte) bool) {
+		for i := 0; i < 100 && yield([]byte(strconv.Itoa(i))); i++ {
+		}
+	}
+	if r := MeasureFPRSeq(bf, positives); r != 1 {
+		t.Fatalf("Added entries should all count as positives but got %v", r)
+	}
+	if r := MeasureFPR(bf, nil); !math.IsNaN(r) {
+		t.Fatalf("Rate without negatives should be NaN but got %v", r)
+	}
+}
+
+func TestStatsOf(t *testing.T) {
+	const n = 1000
+	filters := map[string]Filter{
+		"classic":     newClassic(n, 1e-3, doubleSHA),
+		"safe":        NewSafe(n, 1e-3, doubleSHA),
+		"blocked":     NewBlocked(n, 1e-3, doubleSHA),
+		"atomic":      NewAtomic(n, 1e-3, doubleSHA),
+		"partitioned": NewPartitioned(n, 1e-3, doubleSHA),
+		"scalable":    NewScalable(n/4, 1e-3, doubleSHA),
+		"rotating":    NewRotating(n, 1e-3, 0, doubleSHA),
+	}
+	for name, f := range filters {
+		if st := StatsOf(f); st.BitsSet != 0 || st.EstimatedCount != 0 || st.EstimatedFPR != 0 {
+			t.Errorf("%s: stats of an empty filter = %+v", name, st)
+		}
+		for i := range n {
+			f.Add([]byte(strconv.Itoa(i)))
+		}
+		st := StatsOf(f)
+		if st.Size != f.Size() || st.Bits == 0 || st.K == 0 || st.Capacity == 0 || st.TargetRate == 0 {
+			t.Errorf("%s: stats should report the design of the filter but got %+v", name, st)
+		}
+		if st.FillRatio != float64(st.BitsSet)/float64(st.Bits) {
+			t.Errorf("%s: FillRatio %v is not BitsSet/Bits", name, st.FillRatio)
+		}
+		if math.Abs(float64(st.EstimatedCount)-n) > 0.1*n {
+			t.Errorf("%s: EstimatedCount = %d, want about %d", name, st.EstimatedCount, n)
+		}
+		if st.EstimatedFPR <= 0 || st.EstimatedFPR > 1e-2 {
+			t.Errorf("%s: EstimatedFPR = %v, want about 1e-3", name, st.EstimatedFPR)
+		}
+	}
+
+	cf := filters["classic"].(*ClassicFilter)
+	if st := cf.Stats(); st.Capacity != n || st.TargetRate != 1e-3 || st.BitsSet != uint64(cf.setBits()) {
+		t.Errorf("Stats of a classic filter should report its own design but got %+v", st)
+	}
+	if st := StatsOf(filters["scalable"]); st.TargetRate != 1e-3 || st.Capacity <= n/4 {
+		t.Errorf("Stats of a scalable filter should sum its filters but got %+v", st)
+	}
+	if st := StatsOf(NewCuckoo(n, 12, 4, doubleSHA)); st.Size == 0 {
+		t.Errorf("Stats of any filter should report its size but got %+v", st)
+	}
+}
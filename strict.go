@@ -0,0 +1,82 @@
+package bloom
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Violation is a broken invariant found by a filter built with the bloomstrict build tag,
+// as in go test -tags bloomstrict, which makes filters check their invariants as they
+// operate, for fuzzing and debugging new variants. Without the tag the checks are
+// compiled out.
+type Violation struct {
+	Check  string // the invariant: "offset", "hash", "counter" or "merge"
+	Filter Filter // the filter that broke it
+	Detail string
+}
+
+func (v *Violation) Error() string { return "bloom: strict " + v.Check + ": " + v.Detail }
+
+var violationHandler atomic.Pointer[func(*Violation)]
+
+// SetViolationHandler makes h receive the violations of strict mode and returns the
+// previous handler, nil if there was none. Without a handler a violation panics with the
+// *Violation, so a fuzzer stops at the operation that broke the invariant. The handler is
+// called synchronously, from the operation, like that of SetEventHandler.
+func SetViolationHandler(h func(*Violation)) func(*Violation) {
+	var old *func(*Violation)
+	if h == nil {
+		old = violationHandler.Swap(nil)
+	} else {
+		old = violationHandler.Swap(&h)
+	}
+	if old == nil {
+		return nil
+	}
+	return *old
+}
+
+// Strict reports whether the package was built with the bloomstrict build tag.
+func Strict() bool { return strict }
+
+// violate reports a violation of check by f to the handler, or panics with it.
+func violate(f Filter, check, format string, args ...any) {
+	v := &Violation{Check: check, Filter: f, Detail: fmt.Sprintf(format, args...)}
+	if h := violationHandler.Load(); h != nil {
+		(*h)(v)
+		return
+	}
+	panic(v)
+}
+
+// checkHash reports a double hash of an entry whose second hash is 0, which puts all the
+// probes of the entry on one offset. A hash function of evenly distributed hashes returns
+// it once in 2⁶⁴ entries, so it shows a broken one, such as one hashing every entry to 0.
+func checkHash(f Filter, x, y uint64) {
+	if y == 0 {
+		violate(f, "hash", "degenerate double hash %#x, %#x", x, y)
+	}
+}
+
+// checkOffset reports an offset of a probe of f beyond its bits or its bit array, and
+// whether the offset is in the bit array, so that a probe beyond it is skipped once its
+// violation is handled.
+func (f *ClassicFilter) checkOffset(offset, m uint64) bool {
+	if offset >= m || offset/8 >= uint64(len(f.B)) {
+		violate(f, "offset", "offset %d of a filter of %d bits in %d bytes", offset, m, len(f.B))
+		return offset/8 < uint64(len(f.B))
+	}
+	return true
+}
+
+// checkMerge reports compatible filters whose bit arrays cannot be combined bit for bit,
+// because one of their bit arrays was replaced by one of another size, and whether they
+// can, so that the merge fails once the violation is handled.
+func checkMerge(f, other *ClassicFilter) bool {
+	want := byteLen(f.BitCount())
+	if uint64(len(f.B)) != want || uint64(len(other.B)) != want || f.K <= 0 {
+		violate(f, "merge", "bit arrays of %d and %d bytes for %d bits and %d hashes", len(f.B), len(other.B), f.BitCount(), f.K)
+		return false
+	}
+	return true
+}
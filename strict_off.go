@@ -0,0 +1,6 @@
+//go:build !bloomstrict
+
+package bloom
+
+// strict makes filters check their invariants, see Violation.
+const strict = false
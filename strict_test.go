@@ -0,0 +1,143 @@
+package bloom
+
+import (
+	"errors"
+	"testing"
+)
+
+// recordViolations makes the violations of the test recorded in the returned slice.
+func recordViolations(t *testing.T) *[]*Violation {
+	var violations []*Violation
+	old := SetViolationHandler(func(v *Violation) { violations = append(violations, v) })
+	t.Cleanup(func() { SetViolationHandler(old) })
+	return &violations
+}
+
+func TestViolate(t *testing.T) {
+	violations := recordViolations(t)
+	bf := NewWithBits(64, 3, doubleFNV)
+	checkHash(bf, 1, 0)
+	checkHash(bf, 0, 0)
+	checkHash(bf, 7, 7)
+	bf.checkOffset(64, 64)
+	bf.checkOffset(63, 64)
+	checkMerge(bf, NewWithBits(128, 3, doubleFNV))
+	var checks []string
+	for _, v := range *violations {
+		checks = append(checks, v.Check)
+		if v.Filter != bf {
+			t.Errorf("%v should name its filter", v)
+		}
+	}
+	if len(checks) != 4 || checks[0] != "hash" || checks[1] != "hash" || checks[2] != "offset" || checks[3] != "merge" {
+		t.Fatalf("Violations = %v", checks)
+	}
+	if got := (*violations)[2].Error(); got != "bloom: strict offset: offset 64 of a filter of 64 bits in 8 bytes" {
+		t.Errorf("Error = %q", got)
+	}
+
+	SetViolationHandler(nil)
+	defer func() {
+		var v *Violation
+		if err, ok := recover().(error); !ok || !errors.As(err, &v) || v.Check != "hash" {
+			t.Fatalf("A violation without a handler should panic with it but got %v", err)
+		}
+	}()
+	checkHash(bf, 1, 0)
+}
+
+// constHash is a broken double hash, returning the same hashes for every entry.
+func constHash([]byte) (uint64, uint64) { return 5, 0 }
+
+func TestStrict(t *testing.T) {
+	if !Strict() {
+		t.Skip("strict mode needs -tags bloomstrict")
+	}
+	violations := recordViolations(t)
+	bf := NewWithBits(1024, 3, constHash)
+	bf.Add([]byte("a"))
+	if len(*violations) != 1 || (*violations)[0].Check != "hash" {
+		t.Fatalf("A degenerate hash should be reported but got %v", *violations)
+	}
+
+	*violations = nil
+	bf = NewWithBits(1024, 3, doubleFNV)
+	bf.B = bf.B[:64]
+	bf.m = 1024
+	bf.Add([]byte("a"))
+	bf.Test([]byte("b"))
+	for _, v := range *violations {
+		if v.Check != "offset" {
+			t.Errorf("Violation = %v, want offsets beyond the bit array", v)
+		}
+	}
+	if len(*violations) == 0 {
+		t.Error("Offsets beyond a truncated bit array should be reported")
+	}
+	n := len(*violations)
+	bf.AddMany([][]byte{[]byte("a"), []byte("c")})
+	bf.TestMany([][]byte{[]byte("b"), []byte("d")})
+	if len(*violations) <= n {
+		t.Error("Offsets of AddMany and TestMany beyond a truncated bit array should be reported")
+	}
+
+	*violations = nil
+	df := NewDelta(100, 0.01, doubleFNV)
+	df.B = df.B[:len(df.B)/2]
+	for i := range 10 {
+		df.Add([]byte{byte(i)})
+	}
+	if len(*violations) == 0 || (*violations)[0].Check != "offset" {
+		t.Errorf("Offsets beyond the truncated bit array of a delta filter should be reported but got %v", *violations)
+	}
+	for _, offset := range df.set {
+		if offset/8 >= uint64(len(df.B)) {
+			t.Fatalf("Delta records offset %d beyond its bit array of %d bytes", offset, len(df.B))
+		}
+	}
+
+	*violations = nil
+	other := NewWithBits(1024, 3, doubleFNV)
+	bf = NewWithBits(1024, 3, doubleFNV)
+	other.B = other.B[:64]
+	if err := bf.Merge(other); err != ErrIncompatibleSize {
+		t.Fatalf("Merge of a bit array of another size = %v, want %v", err, ErrIncompatibleSize)
+	}
+	if len(*violations) != 1 || (*violations)[0].Check != "merge" {
+		t.Fatalf("A bit array of another size should be reported but got %v", *violations)
+	}
+
+	*violations = nil
+	cf := NewCountingWidth(100, 0.01, 1, doubleFNV)
+	for range 3 {
+		cf.Add([]byte("a"))
+	}
+	if len(*violations) != 0 {
+		t.Fatalf("Counters should saturate without violations but got %v", *violations)
+	}
+	cf.set(0, 2)
+	if len(*violations) != 1 || (*violations)[0].Check != "counter" {
+		t.Fatalf("A counter beyond its width should be reported but got %v", *violations)
+	}
+	*violations = nil
+	cf = NewCounting(100, 0.01, func([]byte) (uint64, uint64) { return 7, 1 << 63 })
+	cf.M = 2 // every probe lands on counter 1
+	cf.C[0] = 0x10
+	cf.Remove([]byte("b"))
+	if len(*violations) == 0 || (*violations)[len(*violations)-1].Check != "counter" {
+		t.Fatalf("A counter removed below 0 should be reported but got %v", *violations)
+	}
+}
+
+func TestStrict_Off(t *testing.T) {
+	if Strict() {
+		t.Skip("strict mode is on")
+	}
+	recordViolations(t)
+	// without the tag nothing is checked, so a broken hash goes by
+	bf := NewWithBits(1024, 3, constHash)
+	bf.Add([]byte("a"))
+	if !bf.Test([]byte("b")) {
+		t.Error("A constant hash should make every entry test positive")
+	}
+}
@@ -0,0 +1,31 @@
+package bloom
+
+import "unsafe"
+
+// stringBytes returns the bytes of s without copying them. They must not be modified.
+func stringBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+// AddString adds the string s to f without converting it to a new []byte. The hash
+// function of f must not modify or retain its input, which none in this package do.
+func AddString(f Filter, s string) { f.Add(stringBytes(s)) }
+
+// TestString tests if the string s is in f without converting it to a new []byte, like AddString.
+func TestString(f Filter, s string) bool { return f.Test(stringBytes(s)) }
+
+// AddString adds the string s to the filter without allocating, like the function AddString.
+func (f *ClassicFilter) AddString(s string) { f.Add(stringBytes(s)) }
+
+// TestString tests if the string s is in the filter without allocating, like the function TestString.
+func (f *ClassicFilter) TestString(s string) bool { return f.Test(stringBytes(s)) }
+
+// StringFilter is a typed filter of strings.
+type StringFilter = TypedFilter[string]
+
+// NewStringFilter creates a typed filter of strings backed by a classic filter that is
+// optimal for n entries and false positive rate of p, using DefaultHash. Strings are hashed
+// without copying them, so Add and Test do not allocate.
+func NewStringFilter(n int, p float64) *StringFilter {
+	return NewTyped(n, p, stringBytes)
+}
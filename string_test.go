@@ -0,0 +1,72 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestAddString(t *testing.T) {
+	bf := newClassic(1e3, 1e-3, DefaultHash)
+	bf.AddString("hello")
+	if !bf.Test([]byte("hello")) || !bf.TestString("hello") || bf.TestString("world") {
+		t.Fatal("Strings should be added like their bytes")
+	}
+	cf := NewCounting(1e3, 1e-3, DefaultHash)
+	AddString(cf, "hello")
+	if !TestString(cf, "hello") || TestString(cf, "world") {
+		t.Fatal("Strings should be added like their bytes")
+	}
+	if TestString(bf, "") {
+		t.Fatal("Empty string should not be in the filter")
+	}
+
+	s := strconv.Itoa(12345)
+	if n := testing.AllocsPerRun(100, func() {
+		bf.AddString(s)
+		bf.TestString(s)
+	}); n != 0 {
+		t.Fatalf("AddString and TestString should not allocate but made %v allocations", n)
+	}
+}
+
+func TestStringFilter(t *testing.T) {
+	f := NewStringFilter(1e3, 1e-3)
+	f.Add("hello")
+	if !f.Test("hello") || f.Test("world") || !f.F.Test([]byte("hello")) {
+		t.Fatal("Strings should be added like their bytes")
+	}
+	s := strconv.Itoa(12345)
+	if n := testing.AllocsPerRun(100, func() {
+		f.Add(s)
+		f.Test(s)
+	}); n != 0 {
+		t.Fatalf("StringFilter should not allocate but made %v allocations", n)
+	}
+}
+
+func BenchmarkAddString(b *testing.B) {
+	bf := newClassic(1e6, 1e-4, DefaultHash)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.AddString(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkStringFilter_Test(b *testing.B) {
+	f := NewStringFilter(1e6, 1e-4)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+		f.Add(keys[i])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Test(keys[i%len(keys)])
+	}
+}
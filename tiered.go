@@ -0,0 +1,157 @@
+package bloom
+
+import "sync"
+
+// WriteMode is how a TieredFilter adds entries to its back filter.
+type WriteMode int
+
+const (
+	// WriteThrough adds entries to the front and the back filter before Add returns.
+	WriteThrough WriteMode = iota
+
+	// WriteBehind adds entries to the front filter and queues them for a goroutine that
+	// adds them to the back, so Add does not wait for a back filter that is slow or
+	// remote. Entries queued are tested positive from the queue until they are added.
+	WriteBehind
+)
+
+// tieredQueue is the number of entries WriteBehind queues before Add waits for the back.
+const tieredQueue = 1024
+
+// Tiered Bloom Filter
+//
+// A tiered filter tests entries against a small, fast front filter first and only
+// confirms its positives against a large back filter, such as an MmapFilter or a remote
+// filter, which has the lower false positive rate. Most entries not in the filter are
+// answered from the front, so the back filter can be huge or off the machine without
+// slowing down the common negative. The front must hold every entry of the back: a tiered
+// filter adds its entries to both, and the entries of a back already filled can be given
+// to the front by adding them again or, for a ClassicFilter, with a Fold of it.
+//
+// A tiered filter is safe for concurrent use if its filters are. With WriteBehind its back
+// filter is added to from another goroutine, so it must be safe for concurrent use, and
+// the filter must be closed.
+type TieredFilter struct {
+	Front Filter
+	Back  Filter
+
+	mode    WriteMode
+	mu      sync.Mutex
+	idle    sync.Cond
+	pending map[string]int // entries queued for the back, with how many times each is
+	queued  int            // entries queued or being added
+	queue   chan []byte
+	done    chan struct{}
+}
+
+// NewTiered creates a tiered filter testing front before back and adding to back as mode
+// says. With WriteBehind it starts the goroutine adding to back, which Close stops.
+func NewTiered(front, back Filter, mode WriteMode) *TieredFilter {
+	f := &TieredFilter{Front: front, Back: back, mode: mode}
+	f.idle.L = &f.mu
+	if mode == WriteBehind {
+		f.pending = make(map[string]int)
+		f.queue = make(chan []byte, tieredQueue)
+		f.done = make(chan struct{})
+		go f.writeBehind()
+	}
+	return f
+}
+
+func (f *TieredFilter) writeBehind() {
+	defer close(f.done)
+	for b := range f.queue {
+		f.Back.Add(b)
+		f.mu.Lock()
+		if f.pending[string(b)]--; f.pending[string(b)] == 0 {
+			delete(f.pending, string(b))
+		}
+		if f.queued--; f.queued == 0 {
+			f.idle.Broadcast()
+		}
+		f.mu.Unlock()
+	}
+}
+
+// addBack adds an entry to the back filter, or queues it with WriteBehind.
+func (f *TieredFilter) addBack(b []byte) {
+	if f.mode != WriteBehind {
+		f.Back.Add(b)
+		return
+	}
+	f.mu.Lock()
+	f.pending[string(b)]++
+	f.queued++
+	f.mu.Unlock()
+	f.queue <- append([]byte(nil), b...)
+}
+
+// testBack tests an entry against the queue and the back filter.
+func (f *TieredFilter) testBack(b []byte) bool {
+	if f.mode == WriteBehind {
+		f.mu.Lock()
+		_, ok := f.pending[string(b)]
+		f.mu.Unlock()
+		if ok {
+			return true
+		}
+	}
+	return f.Back.Test(b)
+}
+
+func (f *TieredFilter) Add(b []byte) {
+	f.Front.Add(b)
+	f.addBack(b)
+}
+
+func (f *TieredFilter) Test(b []byte) bool {
+	return f.Front.Test(b) && f.testBack(b)
+}
+
+// TestAndAdd adds an entry and reports whether it was already in the filter, testing the
+// back filter only if the front says it was.
+func (f *TieredFilter) TestAndAdd(b []byte) bool {
+	if TestAndAdd(f.Front, b) && f.testBack(b) {
+		return true
+	}
+	f.addBack(b)
+	return false
+}
+
+func (f *TieredFilter) Size() int { return f.Front.Size() + f.Back.Size() }
+
+// Reset waits for the queued entries to be added and empties both filters.
+func (f *TieredFilter) Reset() {
+	f.Flush()
+	f.Front.Reset()
+	f.Back.Reset()
+}
+
+// Flush waits until the entries queued with WriteBehind are added to the back filter.
+func (f *TieredFilter) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.queued > 0 {
+		f.idle.Wait()
+	}
+}
+
+// Err returns the first error of the back filter since Err was last called, if it is a
+// remote filter recording its errors, such as a RedisFilter. Otherwise it returns nil.
+func (f *TieredFilter) Err() error {
+	if r, ok := f.Back.(interface{ Err() error }); ok {
+		return r.Err()
+	}
+	return nil
+}
+
+// Close adds the queued entries to the back filter and stops the goroutine of
+// WriteBehind. The filter must not be added to afterwards.
+func (f *TieredFilter) Close() error {
+	if f.mode == WriteBehind && f.queue != nil {
+		close(f.queue)
+		<-f.done
+		f.queue = nil
+	}
+	return nil
+}
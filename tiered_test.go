@@ -0,0 +1,96 @@
+package bloom
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// countingFilter counts the tests of a filter.
+type countingFilter struct {
+	Filter
+	tests atomic.Int64
+}
+
+func (c *countingFilter) Test(b []byte) bool {
+	c.tests.Add(1)
+	return c.Filter.Test(b)
+}
+
+// blockingFilter blocks its adds until release is closed.
+type blockingFilter struct {
+	Filter
+	release chan struct{}
+}
+
+func (f *blockingFilter) Add(b []byte) {
+	<-f.release
+	f.Filter.Add(b)
+}
+
+func TestTieredFilter(t *testing.T) {
+	back := &countingFilter{Filter: New(1e4, 1e-6, doubleSHA)}
+	tf := NewTiered(New(1e4, 1e-1, doubleSHA), back, WriteThrough)
+	for i := range 1000 {
+		tf.Add([]byte(fmt.Sprint(i)))
+	}
+	for i := range 1000 {
+		if !tf.Test([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%d should be in the filter", i)
+		}
+	}
+	back.tests.Store(0)
+	var fp int
+	for i := range 10000 {
+		if tf.Test([]byte(fmt.Sprint("absent", i))) {
+			fp++
+		}
+	}
+	if fp > 0 {
+		t.Errorf("%d false positives, want those of the back filter", fp)
+	}
+	// the front is about 1e-1 for 1e4 entries, far less with 1e3
+	if n := back.tests.Load(); n > 500 {
+		t.Errorf("The back filter was tested %d times for 10000 entries not in the filter", n)
+	}
+	if tf.TestAndAdd([]byte("new")) || !tf.TestAndAdd([]byte("new")) || !back.Test([]byte("new")) {
+		t.Error("TestAndAdd should add to both filters")
+	}
+	if tf.Size() != tf.Front.Size()+back.Size() {
+		t.Errorf("Size = %d", tf.Size())
+	}
+	tf.Reset()
+	if tf.Test([]byte("1")) || tf.Err() != nil {
+		t.Error("Reset should empty the filter")
+	}
+}
+
+func TestTieredFilter_WriteBehind(t *testing.T) {
+	release := make(chan struct{})
+	back := &blockingFilter{Filter: NewSafe(1e3, 1e-6, doubleSHA), release: release}
+	tf := NewTiered(New(1e3, 1e-2, doubleSHA), back, WriteBehind)
+	defer tf.Close()
+	a := []byte("a")
+	tf.Add(a)
+	if !tf.Test(a) || !tf.TestAndAdd(a) {
+		t.Fatal("A queued entry should test positive")
+	}
+	if back.Filter.Test(a) {
+		t.Fatal("The back filter should not be added to before it is released")
+	}
+	close(release)
+	tf.Flush()
+	if !back.Filter.Test(a) || !tf.Test(a) {
+		t.Fatal("Flush should wait for the entry to be added to the back filter")
+	}
+	if len(tf.pending) != 0 {
+		t.Errorf("Pending = %v after Flush", tf.pending)
+	}
+	tf.Add([]byte("b"))
+	if err := tf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !back.Filter.Test([]byte("b")) {
+		t.Error("Close should add the queued entries")
+	}
+}
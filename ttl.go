@@ -0,0 +1,98 @@
+package bloom
+
+import (
+	"math"
+	"time"
+)
+
+// TTL Bloom Filter
+//
+// A TTL filter gives each entry its own lifetime: each cell holds the latest deadline of
+// the entries on it, as a coarse timestamp in ticks of R, and Test only finds an entry
+// whose K cells all have deadlines still ahead. An entry added with AddWithTTL expires
+// between its TTL and its TTL plus R after it was added, on its own, so tokens and nonces
+// disappear at their own deadlines rather than all at once at a rotation. An entry sharing
+// its cells with entries that live longer can outlive its deadline: a false positive,
+// at the rate of the live entries. Cells are 32 bits wide, making the filter 32 times
+// larger than a classic filter of the same geometry; at a second a tick, deadlines reach
+// 136 years ahead of the first use of the filter.
+type TTLFilter struct {
+	C   []uint32      // tick of the latest deadline of each cell, 0 if never set
+	R   time.Duration // resolution of the deadlines
+	D   time.Duration // lifetime of entries added with Add
+	K   int
+	H   func([]byte) (uint64, uint64)
+	Now func() time.Time // clock, time.Now by default
+
+	epoch time.Time // time of tick 1, set from Now when the filter is first used
+}
+
+// NewTTL creates a TTL Bloom Filter that is optimal for n live entries and false positive
+// rate of p, with deadlines rounded up to resolution and entries added with Add expiring
+// d after they are added.
+func NewTTL(n int, p float64, d, resolution time.Duration, h func([]byte) (uint64, uint64)) *TTLFilter {
+	m, k := optimal(n, p)
+	return &TTLFilter{C: make([]uint32, elements(m, 4)), R: max(resolution, 1), D: d, K: int(k), H: h, Now: time.Now}
+}
+
+// ticks returns the tick of t, the ticks of R from the first use of the filter, starting
+// at 1 so that cells do not have a deadline of 0. Times before the first use, as after
+// the clock was set back, are at tick 1, and times past the last tick at the last tick.
+func (f *TTLFilter) ticks(t time.Time) uint32 {
+	if f.epoch.IsZero() {
+		f.epoch = f.Now()
+	}
+	return uint32(min(max(t.Sub(f.epoch)/f.R, 0), math.MaxUint32-1)) + 1
+}
+
+func (f *TTLFilter) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return (x + uint64(i)*y) % uint64(len(f.C))
+}
+
+// Add adds an entry expiring D after now.
+func (f *TTLFilter) Add(b []byte) { f.AddWithTTL(b, f.D) }
+
+// AddWithTTL adds an entry expiring ttl after now, or extends its lifetime to that if it
+// is in the filter with an earlier deadline. It never shortens the lifetime of an entry.
+func (f *TTLFilter) AddWithTTL(b []byte, ttl time.Duration) {
+	// the first tick starting at or after the deadline
+	deadline := f.ticks(f.Now().Add(ttl + f.R - 1))
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		o := f.getOffset(x, y, i)
+		f.C[o] = max(f.C[o], deadline)
+	}
+}
+
+// Test tests if an entry is in the filter with a deadline not yet passed.
+func (f *TTLFilter) Test(b []byte) bool {
+	now := f.ticks(f.Now())
+	x, y := f.H(b)
+	for i := 0; i < f.K; i++ {
+		if f.C[f.getOffset(x, y, i)] <= now {
+			return false
+		}
+	}
+	return true
+}
+
+// Live returns the fraction of the cells whose deadline has not passed, which sets the
+// false positive rate like the fill ratio of a classic filter.
+func (f *TTLFilter) Live() float64 {
+	now := f.ticks(f.Now())
+	var live int
+	for _, c := range f.C {
+		if c > now {
+			live++
+		}
+	}
+	return float64(live) / float64(len(f.C))
+}
+
+func (f *TTLFilter) Size() int { return 4 * len(f.C) }
+
+func (f *TTLFilter) Reset() {
+	clear(f.C)
+	f.epoch = time.Time{}
+}
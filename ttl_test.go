@@ -0,0 +1,76 @@
+package bloom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLFilter(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	tf := NewTTL(1e4, 1e-4, time.Minute, time.Second, doubleFNV)
+	tf.Now = clock.Now
+
+	token, nonce, session := []byte("token"), []byte("nonce"), []byte("session")
+	tf.AddWithTTL(token, 10*time.Second)
+	tf.AddWithTTL(nonce, 30*time.Second)
+	tf.Add(session)
+	if !tf.Test(token) || !tf.Test(nonce) || !tf.Test(session) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	clock.Advance(10*time.Second + time.Millisecond)
+	if tf.Test(token) {
+		t.Fatal("Entry past its TTL should have expired")
+	}
+	if !tf.Test(nonce) || !tf.Test(session) {
+		t.Fatal("Entries within their TTL should exist in filter but got false")
+	}
+	tf.AddWithTTL(nonce, time.Second)
+	clock.Advance(20 * time.Second)
+	if tf.Test(nonce) {
+		t.Fatal("Entry past its TTL should have expired")
+	}
+	tf.AddWithTTL(nonce, 5*time.Second)
+	clock.Advance(4 * time.Second)
+	if !tf.Test(nonce) {
+		t.Fatal("Adding again should renew the entry")
+	}
+	if live := tf.Live(); live == 0 || live > 0.01 {
+		t.Errorf("Live = %v, want the cells of 2 entries", live)
+	}
+	clock.Advance(time.Minute)
+	if tf.Test(nonce) || tf.Test(session) || tf.Live() != 0 {
+		t.Fatal("All entries should have expired")
+	}
+}
+
+func TestTTLFilter_Resolution(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	tf := NewTTL(1e3, 1e-3, time.Minute, 10*time.Second, doubleFNV)
+	tf.Now = clock.Now
+	clock.Advance(3 * time.Second)
+	tf.AddWithTTL([]byte("a"), 5*time.Second)
+	clock.Advance(6 * time.Second)
+	if !tf.Test([]byte("a")) {
+		t.Fatal("Deadlines should round up to the resolution")
+	}
+	clock.Advance(11 * time.Second)
+	if tf.Test([]byte("a")) {
+		t.Fatal("An entry should expire within a resolution of its deadline")
+	}
+}
+
+func TestTTLFilter_ClockBack(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	tf := NewTTL(1e3, 1e-3, time.Minute, time.Second, doubleFNV)
+	tf.Now = clock.Now
+	tf.Add([]byte("a"))
+	clock.Advance(-time.Hour)
+	if !tf.Test([]byte("a")) {
+		t.Fatal("Should exist in filter after the clock was set back")
+	}
+	tf.AddWithTTL([]byte("b"), time.Second)
+	clock.Advance(time.Hour)
+	if tf.Test([]byte("b")) {
+		t.Fatal("An entry added when the clock was behind should not live longer than its TTL")
+	}
+}
@@ -0,0 +1,33 @@
+package bloom
+
+// Typed Bloom Filter
+//
+// A typed filter wraps a Filter of byte slices as a filter of values of type T,
+// encoding each value with Encode, so the type of the entries is checked at
+// compile time. Values that are equal must have equal encodings.
+type TypedFilter[T any] struct {
+	F      Filter
+	Encode func(T) []byte
+}
+
+// NewTyped creates a typed filter backed by a classic filter that is optimal for n entries
+// and false positive rate of p, using DefaultHash.
+func NewTyped[T any](n int, p float64, encode func(T) []byte) *TypedFilter[T] {
+	return WrapTyped(NewDefault(n, p), encode)
+}
+
+// WrapTyped wraps f as a typed filter of values encoded with encode.
+func WrapTyped[T any](f Filter, encode func(T) []byte) *TypedFilter[T] {
+	return &TypedFilter[T]{F: f, Encode: encode}
+}
+
+func (f *TypedFilter[T]) Add(v T) { f.F.Add(f.Encode(v)) }
+
+func (f *TypedFilter[T]) Test(v T) bool { return f.F.Test(f.Encode(v)) }
+
+// TestAndAdd adds a value and reports whether it was already in the filter, like the function TestAndAdd.
+func (f *TypedFilter[T]) TestAndAdd(v T) bool { return TestAndAdd(f.F, f.Encode(v)) }
+
+func (f *TypedFilter[T]) Size() int { return f.F.Size() }
+
+func (f *TypedFilter[T]) Reset() { f.F.Reset() }
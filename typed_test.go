@@ -0,0 +1,31 @@
+package bloom
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestTypedFilter(t *testing.T) {
+	ips := NewTyped(1e3, 1e-3, func(a netip.Addr) []byte { return a.AsSlice() })
+	ips.Add(netip.MustParseAddr("192.0.2.1"))
+	if !ips.Test(netip.MustParseAddr("192.0.2.1")) {
+		t.Fatal("Should exist in filter but got false")
+	}
+	if ips.Test(netip.MustParseAddr("192.0.2.2")) {
+		t.Fatal("Should missing in filter but got true")
+	}
+	if ips.TestAndAdd(netip.MustParseAddr("192.0.2.3")) || !ips.TestAndAdd(netip.MustParseAddr("192.0.2.3")) {
+		t.Fatal("TestAndAdd should report whether the value was present")
+	}
+
+	type point struct{ X, Y byte }
+	points := WrapTyped(NewCounting(1e3, 1e-3, doubleFNV), func(p point) []byte { return []byte{p.X, p.Y} })
+	points.Add(point{1, 2})
+	if !points.Test(point{1, 2}) || points.Test(point{2, 1}) {
+		t.Fatal("Typed filter should test values by their encoding")
+	}
+	points.Reset()
+	if points.Test(point{1, 2}) {
+		t.Fatal("Should missing in reset filter but got true")
+	}
+}
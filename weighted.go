@@ -0,0 +1,77 @@
+package bloom
+
+import "math"
+
+// Weighted Bloom Filter
+//
+// A weighted filter chooses the number of probes of each key from its
+// weight, the rate at which it is queried relative to an average key. Keys
+// of weight w get about K+log2(w) probes, so popular keys that are queried
+// often but rarely added get a lower false positive rate, at the cost of a
+// slightly higher one for unpopular keys. This lowers the false positive
+// rate seen by the queries of a skewed workload. W must return the same
+// weight for a key every time.
+// See Bruck et al., "Weighted Bloom Filter" (2006).
+type WeightedFilter struct {
+	B []byte
+	K int // number of probes of a key of weight 1
+	H func([]byte) (uint64, uint64)
+	W func([]byte) float64 // weight of a key
+}
+
+// maxWeightedProbes caps the number of probes of a key.
+const maxWeightedProbes = 64
+
+// NewWeighted creates a weighted Bloom Filter that is optimal for n entries of average weight and
+// false positive rate of p.
+func NewWeighted(n int, p float64, h func([]byte) (uint64, uint64), w func([]byte) float64) *WeightedFilter {
+	m, k := optimal(n, p)
+	return &WeightedFilter{B: make([]byte, elements(m/8, 1)), K: int(k), H: h, W: w}
+}
+
+// probes returns the number of probes of a key.
+func (f *WeightedFilter) probes(b []byte) int {
+	w := f.W(b)
+	if w <= 0 {
+		return 1
+	}
+	k := f.K + int(math.Round(math.Log2(w)))
+	if k < 1 {
+		return 1
+	} else if k > maxWeightedProbes {
+		return maxWeightedProbes
+	}
+	return k
+}
+
+func (f *WeightedFilter) getOffset(x, y uint64, i int) uint64 {
+	y = nonzero(x, y)
+	return (x + uint64(i)*y) % (8 * uint64(len(f.B)))
+}
+
+func (f *WeightedFilter) Add(b []byte) {
+	x, y := f.H(b)
+	for i, k := 0, f.probes(b); i < k; i++ {
+		offset := f.getOffset(x, y, i)
+		f.B[offset/8] |= 1 << (offset % 8)
+	}
+}
+
+func (f *WeightedFilter) Test(b []byte) bool {
+	x, y := f.H(b)
+	for i, k := 0, f.probes(b); i < k; i++ {
+		offset := f.getOffset(x, y, i)
+		if f.B[offset/8]&(1<<(offset%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *WeightedFilter) Size() int { return len(f.B) }
+
+func (f *WeightedFilter) Reset() {
+	for i := range f.B {
+		f.B[i] = 0
+	}
+}
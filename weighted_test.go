@@ -0,0 +1,39 @@
+package bloom
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWeightedFilter_HotKeys(t *testing.T) {
+	const n = 1e4
+	weight := func(b []byte) float64 {
+		if strings.HasPrefix(string(b), "hot") {
+			return 64
+		}
+		return 1
+	}
+	wf := NewWeighted(n, 1e-2, doubleSHA, weight)
+	for i := 0; i < n; i++ {
+		wf.Add([]byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < n; i++ {
+		if !wf.Test([]byte(fmt.Sprint(i))) {
+			t.Fatalf("%d should exist in filter but got false", i)
+		}
+	}
+	hot, cold := 0, 0
+	for i := 0; i < 1e5; i++ {
+		if wf.Test([]byte(fmt.Sprint("hot", i))) {
+			hot++
+		}
+		if wf.Test([]byte(fmt.Sprint("cold", i))) {
+			cold++
+		}
+	}
+	t.Logf("FP hot = %d, cold = %d", hot, cold)
+	if hot*10 > cold {
+		t.Fatalf("Hot keys should have a much lower FPR: hot %d, cold %d", hot, cold)
+	}
+}
@@ -0,0 +1,75 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestXorFilter_Test(t *testing.T) {
+	const n = 1e5
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+	}
+	xf, err := BuildXorFilter(keys, doubleSHA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if !xf.Test(k) {
+			t.Fatalf("%s should exist in filter but got false", k)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if xf.Test([]byte(fmt.Sprint(i))) {
+			fp++
+		}
+	}
+	fpr := float64(fp) / n
+	t.Logf("Size = %d, bits/key = %.2f, FPR = %.4f%%", xf.Size(), float64(8*xf.Size())/n, fpr*100)
+	if fpr > 2.0/256 {
+		t.Fatalf("FPR %.4f%% is well above 1/256", fpr*100)
+	}
+}
+
+func TestXorFilter_Duplicates(t *testing.T) {
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("a"), []byte("c"), []byte("b")}
+	xf, err := BuildXorFilter(keys, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if !xf.Test(k) {
+			t.Fatalf("%s should exist in filter but got false", k)
+		}
+	}
+}
+
+func TestXorFilter_MarshalBinary(t *testing.T) {
+	keys := [][]byte{[]byte("hello"), []byte("world")}
+	xf, err := BuildXorFilter(keys, doubleFNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := xf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &XorFilter{H: doubleFNV}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if !decoded.Test(k) {
+			t.Fatalf("%s should exist in decoded filter but got false", k)
+		}
+	}
+	if err := decoded.UnmarshalBinary(data[:5]); err == nil {
+		t.Fatal("Truncated data should fail to decode")
+	}
+	empty, _ := (&XorFilter{Seed: 1}).MarshalBinary()
+	if err := decoded.UnmarshalBinary(empty); err == nil {
+		t.Fatal("A filter of no fingerprints should fail to decode")
+	}
+}
@@ -0,0 +1,21 @@
+package bloom
+
+import "testing"
+
+func TestXXHash64(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		seed uint64
+		want uint64
+	}{
+		{"", 0, 0xef46db3751d8e999},
+		{"a", 0, 0xd24ec4f1a98c6e5b},
+		{"abc", 0, 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0, 0xfbcea83c8a378bf1},
+		{"The quick brown fox jumps over the lazy dog", 0, 0x0b242d361fda71bc},
+	} {
+		if got := xxhash64([]byte(tc.in), tc.seed); got != tc.want {
+			t.Errorf("xxhash64(%q, %d) = %#x, want %#x", tc.in, tc.seed, got, tc.want)
+		}
+	}
+}